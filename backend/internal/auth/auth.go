@@ -44,15 +44,64 @@ func GenerateToken(jwtSecret string) (string, error) {
 	return token.SignedString([]byte(jwtSecret))
 }
 
-// ValidateToken validates a JWT token and returns the claims
+// ValidateToken validates a JWT token and returns the claims. SSO session
+// tokens are rejected so a cookie seen by a proxied app cannot be replayed
+// against the API.
 func ValidateToken(tokenString, jwtSecret string) (*JWTClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
+	claims, err := parseToken(tokenString, jwtSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, aud := range claims.Audience {
+		if aud == SSOAudience {
+			return nil, errors.New("session token is not valid for API access")
 		}
-		return []byte(jwtSecret), nil
-	})
+	}
+
+	return claims, nil
+}
+
+// SSOAudience marks session tokens issued by the forward-auth login page.
+const SSOAudience = "upm-sso"
+
+// SSOSessionDuration is how long a forward-auth session stays valid.
+const SSOSessionDuration = 24 * time.Hour
+
+// GenerateSSOToken creates a session token for the forward-auth cookie
+func GenerateSSOToken(jwtSecret string) (string, error) {
+	claims := JWTClaims{
+		IsAdmin: true,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(SSOSessionDuration)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+			Issuer:    "upm",
+			Subject:   "admin",
+			Audience:  jwt.ClaimStrings{SSOAudience},
+		},
+	}
 
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(jwtSecret))
+}
+
+// ValidateSSOToken validates a forward-auth session token
+func ValidateSSOToken(tokenString, jwtSecret string) (*JWTClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, keyFunc(jwtSecret), jwt.WithAudience(SSOAudience))
+	if err != nil {
+		return nil, err
+	}
+
+	if claims, ok := token.Claims.(*JWTClaims); ok && token.Valid {
+		return claims, nil
+	}
+
+	return nil, errors.New("invalid token")
+}
+
+func parseToken(tokenString, jwtSecret string) (*JWTClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, keyFunc(jwtSecret))
 	if err != nil {
 		return nil, err
 	}
@@ -64,6 +113,15 @@ func ValidateToken(tokenString, jwtSecret string) (*JWTClaims, error) {
 	return nil, errors.New("invalid token")
 }
 
+func keyFunc(jwtSecret string) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return []byte(jwtSecret), nil
+	}
+}
+
 // AuthenticateAdmin validates admin credentials (Pi-hole style)
 // In development mode, also accepts the dev test password
 func AuthenticateAdmin(password, hashedPassword string, devMode bool, devTestPassword string) bool {
@@ -3,6 +3,7 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
 	"time"
 )
 
@@ -25,6 +26,20 @@ type Config struct {
 	LetsEncryptCertPath string // Path to store Let's Encrypt certificates
 	// Certificate auto-renewal
 	CertRenewalCheckInterval time.Duration // How often to check for expiring certificates
+	// Internal certificate authority
+	InternalCAPath string // Issued internal certificates, keys and CRLs, shared with nginx
+	// Proxy health checks
+	HealthCheckTick time.Duration // How often due health checks are started; 0 disables them
+	// Config drift detection
+	DriftCheckInterval time.Duration // How often generated nginx files are compared with the database; 0 disables it
+	// Forward-auth (UPM login in front of proxied apps)
+	InternalBackendURL string // Backend URL as reached from the nginx container
+	SSOCookieDomain    string // Session cookie domain (e.g. ".example.com"); empty scopes it to each host
+	// GeoIP country rules
+	GeoIPDatabasePath string // Local GeoLite2/DB-IP country mmdb file; empty disables country rules
+	// HTTP/3
+	HTTP3Enabled    bool   // Whether proxies that opt in get QUIC listeners
+	HTTP3AltSvcPort string // UDP port clients reach nginx's QUIC listeners on, advertised in Alt-Svc
 }
 
 func Load() *Config {
@@ -52,6 +67,14 @@ func Load() *Config {
 		LetsEncryptWebroot:         getEnv("LETSENCRYPT_WEBROOT", "/var/www/html"),
 		LetsEncryptCertPath:        getEnv("LETSENCRYPT_CERT_PATH", "/etc/letsencrypt"),
 		CertRenewalCheckInterval:   getEnvDuration("CERT_RENEWAL_CHECK_INTERVAL", 12*time.Hour),
+		InternalCAPath:             getEnv("INTERNAL_CA_PATH", "/etc/ssl/certs/upm-ca"),
+		HealthCheckTick:            getEnvDuration("HEALTH_CHECK_TICK", 10*time.Second),
+		DriftCheckInterval:         getEnvDuration("DRIFT_CHECK_INTERVAL", 15*time.Minute),
+		InternalBackendURL:         getEnv("UPM_INTERNAL_BACKEND_URL", "http://backend:"+getEnv("BACKEND_PORT", "6080")),
+		SSOCookieDomain:            getEnv("SSO_COOKIE_DOMAIN", ""),
+		GeoIPDatabasePath:          getEnv("GEOIP_DB_PATH", ""),
+		HTTP3Enabled:               getEnvBool("HTTP3_ENABLED", false),
+		HTTP3AltSvcPort:            getEnv("HTTP3_ALT_SVC_PORT", getEnv("PROD_NGINX_HTTPS_PORT", "443")),
 	}
 }
 
@@ -72,6 +95,16 @@ func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	return defaultValue
 }
 
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			return parsed
+		}
+		log.Printf("Warning: invalid boolean for %s, using default %v", key, defaultValue)
+	}
+	return defaultValue
+}
+
 // getEnvWithDevDefault returns the environment variable value, or a dev default if in dev mode
 func getEnvWithDevDefault(key, devDefault string, devMode bool) string {
 	if value := os.Getenv(key); value != "" {
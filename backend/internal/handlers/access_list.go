@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"upm-backend/internal/models"
+	"upm-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAccessLists godoc
+// @Summary      Get all IP access lists
+// @Description  Get a list of all IP access lists and their entries
+// @Tags         access-lists
+// @Accept       json
+// @Produce      json
+// @Success      200  {array}   models.AccessList
+// @Failure      500  {object}  map[string]string
+// @Router       /access-lists [get]
+func GetAccessLists(c *gin.Context) {
+	if dbService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database service not initialized"})
+		return
+	}
+
+	lists, err := dbService.GetAccessLists()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch access lists: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  lists,
+		"count": len(lists),
+	})
+}
+
+// GetAccessList godoc
+// @Summary      Get IP access list by ID
+// @Description  Get a specific IP access list by ID
+// @Tags         access-lists
+// @Accept       json
+// @Produce      json
+// @Param        id   path      int  true  "Access list ID"
+// @Success      200  {object}  models.AccessList
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /access-lists/{id} [get]
+func GetAccessList(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid access list ID"})
+		return
+	}
+
+	if dbService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database service not initialized"})
+		return
+	}
+
+	list, err := dbService.GetAccessList(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Access list not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+// CreateAccessList godoc
+// @Summary      Create an IP access list
+// @Description  Create a named list of allowed or denied addresses that proxies and location rules can use
+// @Tags         access-lists
+// @Accept       json
+// @Produce      json
+// @Param        list  body      models.AccessListCreateRequest  true  "Access list"
+// @Success      201   {object}  models.AccessList
+// @Failure      400   {object}  map[string]string
+// @Failure      409   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /access-lists [post]
+func CreateAccessList(c *gin.Context) {
+	var req models.AccessListCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	list := &models.AccessList{Name: req.Name, Entries: accessListEntries(req.Entries)}
+	if err := models.ValidateAccessListEntries(list.Entries); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if dbService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database service not initialized"})
+		return
+	}
+
+	if err := checkAccessListNameAvailable(list.Name, 0); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := dbService.CreateAccessList(list); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create access list: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": list})
+}
+
+// UpdateAccessList godoc
+// @Summary      Update an IP access list
+// @Description  Rename an access list or replace its entries, then regenerate the configs of proxies using it
+// @Tags         access-lists
+// @Accept       json
+// @Produce      json
+// @Param        id    path      int                             true  "Access list ID"
+// @Param        list  body      models.AccessListUpdateRequest  true  "Access list data"
+// @Success      200   {object}  models.AccessList
+// @Failure      400   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      409   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /access-lists/{id} [put]
+func UpdateAccessList(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid access list ID"})
+		return
+	}
+
+	var req models.AccessListUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if dbService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database service not initialized"})
+		return
+	}
+
+	list, err := dbService.GetAccessList(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Access list not found"})
+		return
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+			return
+		}
+		if err := checkAccessListNameAvailable(name, id); err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		list.Name = name
+	}
+	if req.Entries != nil {
+		list.Entries = accessListEntries(*req.Entries)
+		if err := models.ValidateAccessListEntries(list.Entries); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if !applyAccessList(c, id, func(db *services.DatabaseService) error {
+		if err := db.UpdateAccessList(list); err != nil {
+			return failApply(http.StatusInternalServerError, "Failed to update access list: "+err.Error())
+		}
+		return nil
+	}) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+// DeleteAccessList godoc
+// @Summary      Delete an IP access list
+// @Description  Delete an access list that is not attached to any proxy or location
+// @Tags         access-lists
+// @Accept       json
+// @Produce      json
+// @Param        id   path      int  true  "Access list ID"
+// @Success      204  {object}  map[string]string
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /access-lists/{id} [delete]
+func DeleteAccessList(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid access list ID"})
+		return
+	}
+
+	if dbService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database service not initialized"})
+		return
+	}
+
+	proxies, err := dbService.GetProxiesByAccessList(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check access list usage: " + err.Error()})
+		return
+	}
+	if len(proxies) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Access list is still used by %d proxies", len(proxies))})
+		return
+	}
+
+	if err := dbService.DeleteAccessList(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Failed to delete access list: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, gin.H{"message": "Access list deleted successfully"})
+}
+
+// accessListEntries converts request entries, trimming their addresses.
+func accessListEntries(reqs []models.AccessListEntryRequest) []models.AccessListEntry {
+	entries := make([]models.AccessListEntry, 0, len(reqs))
+	for _, r := range reqs {
+		r.Address = strings.TrimSpace(r.Address)
+		entries = append(entries, r.ToAccessListEntry())
+	}
+	return entries
+}
+
+// applyAccessList applies a change to an access list and regenerates only
+// the proxies that use it, writing the error response when that fails.
+func applyAccessList(c *gin.Context, listID int, update func(db *services.DatabaseService) error) bool {
+	return applyChange(c, update, func(nginx *services.NginxService) error {
+		proxies, err := nginx.DatabaseService.GetProxiesByAccessList(listID)
+		if err != nil {
+			return fmt.Errorf("failed to find proxies using it: %w", err)
+		}
+		for i := range proxies {
+			if err := nginx.GenerateProxyConfig(&proxies[i]); err != nil {
+				return fmt.Errorf("failed to generate nginx config for %s: %w", proxies[i].Domain, err)
+			}
+		}
+		return nil
+	})
+}
+
+// checkAccessListNameAvailable returns an error when another list already
+// uses the name.
+func checkAccessListNameAvailable(name string, exceptID int) error {
+	inUse, err := dbService.AccessListNameInUse(name, exceptID)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return fmt.Errorf("an access list named %s already exists", name)
+	}
+	return nil
+}
+
+// checkAccessListsExist returns an error naming the first access list
+// referenced by the proxy or its locations that does not exist.
+func checkAccessListsExist(proxy *models.Proxy) error {
+	listIDs := []int{proxy.AccessListID}
+	for _, loc := range proxy.Locations {
+		listIDs = append(listIDs, loc.AccessListID)
+	}
+	for _, id := range listIDs {
+		if id == 0 {
+			continue
+		}
+		if _, err := dbService.GetAccessList(id); err != nil {
+			return fmt.Errorf("access list %d not found", id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,402 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"upm-backend/internal/auth"
+	"upm-backend/internal/models"
+	"upm-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetBasicAuthSets godoc
+// @Summary      Get all basic auth credential sets
+// @Description  Get a list of all basic auth credential sets and their usernames
+// @Tags         basic-auth
+// @Accept       json
+// @Produce      json
+// @Success      200  {array}   models.BasicAuthSet
+// @Failure      500  {object}  map[string]string
+// @Router       /basic-auth [get]
+func GetBasicAuthSets(c *gin.Context) {
+	if dbService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database service not initialized"})
+		return
+	}
+
+	sets, err := dbService.GetBasicAuthSets()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch basic auth sets: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  sets,
+		"count": len(sets),
+	})
+}
+
+// GetBasicAuthSet godoc
+// @Summary      Get basic auth credential set by ID
+// @Description  Get a specific basic auth credential set by ID
+// @Tags         basic-auth
+// @Accept       json
+// @Produce      json
+// @Param        id   path      int  true  "Credential set ID"
+// @Success      200  {object}  models.BasicAuthSet
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /basic-auth/{id} [get]
+func GetBasicAuthSet(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid credential set ID"})
+		return
+	}
+
+	if dbService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database service not initialized"})
+		return
+	}
+
+	set, err := dbService.GetBasicAuthSet(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Credential set not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": set})
+}
+
+// CreateBasicAuthSet godoc
+// @Summary      Create a basic auth credential set
+// @Description  Create a named set of basic auth credentials; passwords are stored as bcrypt hashes
+// @Tags         basic-auth
+// @Accept       json
+// @Produce      json
+// @Param        set  body      models.BasicAuthSetCreateRequest  true  "Credential set"
+// @Success      201  {object}  models.BasicAuthSet
+// @Failure      400  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /basic-auth [post]
+func CreateBasicAuthSet(c *gin.Context) {
+	var req models.BasicAuthSetCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	if err := models.ValidateBasicAuthCredentials(req.Users); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if dbService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database service not initialized"})
+		return
+	}
+
+	if err := checkBasicAuthSetNameAvailable(req.Name, 0); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	set := &models.BasicAuthSet{Name: req.Name}
+	for _, u := range req.Users {
+		hash, err := auth.HashPassword(u.Password)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+			return
+		}
+		set.Users = append(set.Users, models.BasicAuthUser{Username: u.Username, PasswordHash: hash})
+	}
+
+	if err := dbService.CreateBasicAuthSet(set); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create credential set: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": set})
+}
+
+// UpdateBasicAuthSet godoc
+// @Summary      Update a basic auth credential set
+// @Description  Rename a basic auth credential set
+// @Tags         basic-auth
+// @Accept       json
+// @Produce      json
+// @Param        id   path      int                               true  "Credential set ID"
+// @Param        set  body      models.BasicAuthSetUpdateRequest  true  "Credential set data"
+// @Success      200  {object}  models.BasicAuthSet
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /basic-auth/{id} [put]
+func UpdateBasicAuthSet(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid credential set ID"})
+		return
+	}
+
+	var req models.BasicAuthSetUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if dbService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database service not initialized"})
+		return
+	}
+
+	set, err := dbService.GetBasicAuthSet(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Credential set not found"})
+		return
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+			return
+		}
+		if err := checkBasicAuthSetNameAvailable(name, id); err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		set.Name = name
+	}
+
+	if err := dbService.UpdateBasicAuthSet(set); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update credential set: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": set})
+}
+
+// DeleteBasicAuthSet godoc
+// @Summary      Delete a basic auth credential set
+// @Description  Delete a basic auth credential set that is not attached to any proxy or location
+// @Tags         basic-auth
+// @Accept       json
+// @Produce      json
+// @Param        id   path      int  true  "Credential set ID"
+// @Success      204  {object}  map[string]string
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /basic-auth/{id} [delete]
+func DeleteBasicAuthSet(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid credential set ID"})
+		return
+	}
+
+	if dbService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database service not initialized"})
+		return
+	}
+
+	proxies, err := dbService.GetProxiesByBasicAuthSet(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check credential set usage: " + err.Error()})
+		return
+	}
+	if len(proxies) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Credential set is still used by %d proxies", len(proxies))})
+		return
+	}
+
+	if err := dbService.DeleteBasicAuthSet(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Failed to delete credential set: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, gin.H{"message": "Credential set deleted successfully"})
+}
+
+// SetBasicAuthUser godoc
+// @Summary      Add or rotate a basic auth credential
+// @Description  Add a user to a credential set or rotate an existing user's password, then regenerate the configs of proxies using the set
+// @Tags         basic-auth
+// @Accept       json
+// @Produce      json
+// @Param        id        path      int                              true  "Credential set ID"
+// @Param        username  path      string                           true  "Username"
+// @Param        password  body      models.BasicAuthPasswordRequest  true  "New password"
+// @Success      200       {object}  models.BasicAuthSet
+// @Failure      400       {object}  map[string]string
+// @Failure      404       {object}  map[string]string
+// @Failure      500       {object}  map[string]string
+// @Router       /basic-auth/{id}/users/{username} [put]
+func SetBasicAuthUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid credential set ID"})
+		return
+	}
+
+	username := c.Param("username")
+	if err := models.ValidateBasicAuthUsername(username); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req models.BasicAuthPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := models.ValidateBasicAuthPassword(req.Password); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if dbService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database service not initialized"})
+		return
+	}
+
+	if _, err := dbService.GetBasicAuthSet(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Credential set not found"})
+		return
+	}
+
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+	if !applyBasicAuthSet(c, id, func(db *services.DatabaseService) error {
+		if err := db.SetBasicAuthUser(id, username, hash); err != nil {
+			return failApply(http.StatusInternalServerError, "Failed to save credential: "+err.Error())
+		}
+		return nil
+	}) {
+		return
+	}
+
+	set, err := dbService.GetBasicAuthSet(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch credential set: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": set})
+}
+
+// DeleteBasicAuthUser godoc
+// @Summary      Remove a basic auth credential
+// @Description  Remove a user from a credential set, then regenerate the configs of proxies using the set
+// @Tags         basic-auth
+// @Accept       json
+// @Produce      json
+// @Param        id        path      int     true  "Credential set ID"
+// @Param        username  path      string  true  "Username"
+// @Success      200       {object}  models.BasicAuthSet
+// @Failure      400       {object}  map[string]string
+// @Failure      404       {object}  map[string]string
+// @Failure      500       {object}  map[string]string
+// @Router       /basic-auth/{id}/users/{username} [delete]
+func DeleteBasicAuthUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid credential set ID"})
+		return
+	}
+
+	if dbService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database service not initialized"})
+		return
+	}
+
+	set, err := dbService.GetBasicAuthSet(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Credential set not found"})
+		return
+	}
+	if len(set.Users) == 1 && set.Users[0].Username == c.Param("username") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot remove the last credential of a set; delete the set instead"})
+		return
+	}
+
+	if !applyBasicAuthSet(c, id, func(db *services.DatabaseService) error {
+		if err := db.DeleteBasicAuthUser(id, c.Param("username")); err != nil {
+			return failApply(http.StatusNotFound, "Failed to remove credential: "+err.Error())
+		}
+		return nil
+	}) {
+		return
+	}
+
+	set, err = dbService.GetBasicAuthSet(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch credential set: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": set})
+}
+
+// applyBasicAuthSet applies a change to a credential set and regenerates
+// only the proxies that use it, writing the error response when that fails.
+func applyBasicAuthSet(c *gin.Context, setID int, update func(db *services.DatabaseService) error) bool {
+	return applyChange(c, update, func(nginx *services.NginxService) error {
+		proxies, err := nginx.DatabaseService.GetProxiesByBasicAuthSet(setID)
+		if err != nil {
+			return fmt.Errorf("failed to find proxies using it: %w", err)
+		}
+		for i := range proxies {
+			if err := nginx.GenerateProxyConfig(&proxies[i]); err != nil {
+				return fmt.Errorf("failed to generate nginx config for %s: %w", proxies[i].Domain, err)
+			}
+		}
+		return nil
+	})
+}
+
+// checkBasicAuthSetNameAvailable returns an error when another set already
+// uses the name.
+func checkBasicAuthSetNameAvailable(name string, exceptID int) error {
+	inUse, err := dbService.BasicAuthSetNameInUse(name, exceptID)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return fmt.Errorf("a credential set named %s already exists", name)
+	}
+	return nil
+}
+
+// checkBasicAuthSetsExist returns an error naming the first credential set
+// referenced by the proxy or its locations that does not exist.
+func checkBasicAuthSetsExist(proxy *models.Proxy) error {
+	setIDs := []int{proxy.BasicAuthSetID}
+	for _, loc := range proxy.Locations {
+		setIDs = append(setIDs, loc.BasicAuthSetID)
+	}
+	for _, id := range setIDs {
+		if id == 0 {
+			continue
+		}
+		if _, err := dbService.GetBasicAuthSet(id); err != nil {
+			return fmt.Errorf("basic auth set %d not found", id)
+		}
+	}
+	return nil
+}
@@ -231,6 +231,20 @@ func DeleteCertificate(c *gin.Context) {
 
 	log.Printf("Deleting certificate ID %d for domain: %s", id, certificate.Domain)
 
+	// Internal certificates stay on their CA's CRL after deletion
+	revoked := false
+	if certificate.IsInternal() && !certificate.IsRevoked() {
+		if internalCAService == nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal CA service not initialized"})
+			return
+		}
+		if err := internalCAService.RevokeCertificate(certificate, models.CertificateRevocationReasons["cessation_of_operation"]); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke certificate: " + err.Error()})
+			return
+		}
+		revoked = true
+	}
+
 	if err := services.RemoveCertificateFiles(certificate); err != nil {
 		log.Printf("Warning: failed to remove certificate files for %s: %v", certificate.Domain, err)
 	}
@@ -241,7 +255,13 @@ func DeleteCertificate(c *gin.Context) {
 		return
 	}
 
-	disableSSLForDomain(certificate.Domain)
+	if certificate.Usage == models.CertificateUsageClient {
+		if revoked {
+			reloadNginxForCRL()
+		}
+	} else {
+		disableSSLForDomain(certificate.Domain)
+	}
 
 	log.Printf("Successfully deleted certificate ID %d", id)
 	c.JSON(http.StatusNoContent, gin.H{"message": "Certificate deleted successfully"})
@@ -351,6 +371,10 @@ func RenewAllCertificates(c *gin.Context) {
 }
 
 func renewCertificateRecord(certificate *models.Certificate) (*models.Certificate, error) {
+	if certificate.IsInternal() {
+		return renewInternalCertificate(certificate)
+	}
+
 	certService := services.NewCertificateService("/etc/nginx/ssl")
 
 	log.Printf("Attempting to renew certificate for domain: %s (ID: %d)", certificate.Domain, certificate.ID)
@@ -372,7 +396,7 @@ func renewCertificateRecord(certificate *models.Certificate) (*models.Certificat
 
 	nginxService := GetNginxService()
 	if nginxService != nil {
-		if err := nginxService.ReloadNginx(); err != nil {
+		if err := nginxService.Apply(nil, nil); err != nil {
 			log.Printf("Warning: Failed to reload nginx after certificate renewal: %v", err)
 		} else {
 			log.Printf("Nginx reloaded successfully after certificate renewal for %s", certificate.Domain)
@@ -418,6 +442,7 @@ func GenerateLetsEncryptCertificate(c *gin.Context) {
 	}
 
 	// Save to database
+	certificate.Source = models.CertificateSourceLetsEncrypt
 	if err := dbService.CreateCertificate(certificate); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save certificate: " + err.Error()})
 		return
@@ -492,48 +517,82 @@ func regenerateNginxConfigForDomain(domain string) {
 		return
 	}
 
-	// Regenerate config for each proxy
-	for _, proxy := range proxies {
+	err = nginxService.Apply(func(db *services.DatabaseService) error {
 		// Check if a certificate exists and auto-enable SSL if needed
-		if !proxy.SSLEnabled {
-			existingCert, err := dbService.GetCertificateByDomain(proxy.Domain)
-			if err == nil && existingCert != nil {
-				proxy.SSLEnabled = true
-				proxy.SSLPath = existingCert.CertPath
-				if err := dbService.UpdateProxy(&proxy); err != nil {
-					log.Printf("Warning: Failed to update proxy SSL status for %s: %v", proxy.Domain, err)
-				} else {
-					log.Printf("Auto-enabled SSL for %s based on existing certificate", proxy.Domain)
-					// Reload proxy from database to ensure we have the latest state
-					updatedProxy, err := dbService.GetProxy(proxy.ID)
-					if err == nil {
-						proxy = *updatedProxy
-					}
-				}
+		for i := range proxies {
+			proxy := &proxies[i]
+			if proxy.SSLEnabled {
+				continue
+			}
+			existingCert, err := db.GetCertificateForProxy(proxy)
+			if err != nil || existingCert == nil {
+				continue
+			}
+			proxy.SSLEnabled = true
+			proxy.SSLPath = existingCert.CertPath
+			if err := db.UpdateProxy(proxy); err != nil {
+				return fmt.Errorf("failed to update proxy SSL status for %s: %w", proxy.Domain, err)
+			}
+			log.Printf("Auto-enabled SSL for %s based on existing certificate", proxy.Domain)
+			// Reload proxy from database to ensure we have the latest state
+			if updatedProxy, err := db.GetProxy(proxy.ID); err == nil {
+				*proxy = *updatedProxy
 			}
 		}
-
-		// Regenerate nginx configuration
-		if err := nginxService.GenerateProxyConfig(&proxy); err != nil {
-			log.Printf("Failed to regenerate nginx config for proxy %d (%s): %v", proxy.ID, proxy.Domain, err)
-			continue
+		return nil
+	}, func(nginx *services.NginxService) error {
+		// Regenerate config for each proxy
+		for i := range proxies {
+			if err := nginx.GenerateProxyConfig(&proxies[i]); err != nil {
+				return fmt.Errorf("failed to regenerate nginx config for proxy %d (%s): %w", proxies[i].ID, proxies[i].Domain, err)
+			}
+			log.Printf("Regenerated nginx config for proxy %d (%s)", proxies[i].ID, proxies[i].Domain)
 		}
-		log.Printf("Regenerated nginx config for proxy %d (%s)", proxy.ID, proxy.Domain)
-	}
-
-	// Test nginx configuration
-	if err := nginxService.TestNginxConfig(); err != nil {
-		log.Printf("Invalid nginx configuration after certificate creation: %v", err)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to apply nginx config after certificate creation: %v", err)
 		return
 	}
 
-	// Reload nginx once for all updated proxies
-	if err := nginxService.ReloadNginx(); err != nil {
-		log.Printf("Failed to reload nginx after certificate creation: %v", err)
-		return
+	log.Printf("Successfully regenerated nginx config and reloaded nginx for domain: %s", domain)
+}
+
+// normalizeClientAuth applies the defaults of a proxy's client certificate
+// settings.
+func normalizeClientAuth(ca *models.ClientAuth) *models.ClientAuth {
+	normalized := *ca
+	normalized.CABundle = strings.TrimSpace(ca.CABundle)
+	normalized.DNHeader = strings.TrimSpace(ca.DNHeader)
+	if normalized.VerifyDepth == 0 {
+		normalized.VerifyDepth = models.DefaultClientAuthVerifyDepth
 	}
+	return &normalized
+}
 
-	log.Printf("Successfully regenerated nginx config and reloaded nginx for domain: %s", domain)
+// checkClientAuth validates a proxy's client certificate settings and the
+// certificates of its CA bundle, or that its internal CA exists.
+func checkClientAuth(ca *models.ClientAuth) error {
+	if err := models.ValidateClientAuth(ca); err != nil {
+		return err
+	}
+	if !ca.Enabled() {
+		return nil
+	}
+	if ca.CAID != 0 {
+		if internalCAService == nil {
+			return fmt.Errorf("client_auth ca_id: internal CA is not available")
+		}
+		if _, err := dbService.GetCertificateAuthority(ca.CAID); err != nil {
+			return fmt.Errorf("client_auth ca_id: certificate authority %d not found", ca.CAID)
+		}
+		return nil
+	}
+	certService := services.NewCertificateService("/etc/nginx/ssl")
+	if _, err := certService.ParseCABundle(ca.CABundle); err != nil {
+		return fmt.Errorf("client_auth ca_bundle: %w", err)
+	}
+	return nil
 }
 
 // formatLetsEncryptError extracts and formats Let's Encrypt errors into user-friendly messages
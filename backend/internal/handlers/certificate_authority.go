@@ -0,0 +1,545 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"upm-backend/internal/models"
+	"upm-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+var internalCAService *services.InternalCAService
+
+// SetInternalCAService sets the internal certificate authority service
+// instance.
+func SetInternalCAService(service *services.InternalCAService) {
+	internalCAService = service
+}
+
+// GetCertificateAuthorities godoc
+// @Summary      Get all internal certificate authorities
+// @Description  Get the root and intermediate CAs UPM issues internal certificates from
+// @Tags         certificate-authorities
+// @Accept       json
+// @Produce      json
+// @Success      200  {array}   models.CertificateAuthority
+// @Failure      500  {object}  map[string]string
+// @Router       /certificate-authorities [get]
+func GetCertificateAuthorities(c *gin.Context) {
+	if dbService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database service not initialized"})
+		return
+	}
+
+	authorities, err := dbService.GetCertificateAuthorities()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch certificate authorities: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  authorities,
+		"count": len(authorities),
+	})
+}
+
+// GetCertificateAuthority godoc
+// @Summary      Get internal certificate authority by ID
+// @Description  Get a specific internal CA and the certificate chain clients should trust
+// @Tags         certificate-authorities
+// @Accept       json
+// @Produce      json
+// @Param        id   path      int  true  "Certificate authority ID"
+// @Success      200  {object}  models.CertificateAuthority
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /certificate-authorities/{id} [get]
+func GetCertificateAuthority(c *gin.Context) {
+	ca, ok := loadCertificateAuthority(c)
+	if !ok {
+		return
+	}
+
+	chain, err := internalCAService.ChainPEM(ca)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build certificate chain: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": ca, "chain_pem": chain})
+}
+
+// CreateCertificateAuthority godoc
+// @Summary      Create an internal certificate authority
+// @Description  Generate a root CA, or an intermediate CA signed by parent_id
+// @Tags         certificate-authorities
+// @Accept       json
+// @Produce      json
+// @Param        authority  body      models.CertificateAuthorityCreateRequest  true  "Certificate authority"
+// @Success      201        {object}  models.CertificateAuthority
+// @Failure      400        {object}  map[string]string
+// @Failure      409        {object}  map[string]string
+// @Failure      500        {object}  map[string]string
+// @Router       /certificate-authorities [post]
+func CreateCertificateAuthority(c *gin.Context) {
+	var req models.CertificateAuthorityCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if dbService == nil || internalCAService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal CA service not initialized"})
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	req.CommonName = strings.TrimSpace(req.CommonName)
+	if req.CommonName == "" {
+		req.CommonName = req.Name
+	}
+	if req.ValidityDays == 0 {
+		req.ValidityDays = models.DefaultRootCAValidityDays
+		if req.ParentID != 0 {
+			req.ValidityDays = models.DefaultIntermediateCAValidityDays
+		}
+	}
+	if err := models.ValidateCertificateAuthorityRequest(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkCertificateAuthorityName(c, req.Name) || !checkParentCertificateAuthority(c, req.ParentID) {
+		return
+	}
+
+	ca, err := internalCAService.CreateAuthority(&req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create certificate authority: " + err.Error()})
+		return
+	}
+
+	log.Printf("Created internal certificate authority %s (ID: %d)", ca.Name, ca.ID)
+	c.JSON(http.StatusCreated, gin.H{"data": ca})
+}
+
+// ImportCertificateAuthority godoc
+// @Summary      Import an internal certificate authority
+// @Description  Import an existing root CA, or an intermediate CA signed by parent_id, with its private key
+// @Tags         certificate-authorities
+// @Accept       json
+// @Produce      json
+// @Param        authority  body      models.CertificateAuthorityImportRequest  true  "Certificate authority"
+// @Success      201        {object}  models.CertificateAuthority
+// @Failure      400        {object}  map[string]string
+// @Failure      409        {object}  map[string]string
+// @Failure      500        {object}  map[string]string
+// @Router       /certificate-authorities/import [post]
+func ImportCertificateAuthority(c *gin.Context) {
+	var req models.CertificateAuthorityImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if dbService == nil || internalCAService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal CA service not initialized"})
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	if !checkCertificateAuthorityName(c, req.Name) || !checkParentCertificateAuthority(c, req.ParentID) {
+		return
+	}
+
+	ca, err := internalCAService.ImportAuthority(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to import certificate authority: " + err.Error()})
+		return
+	}
+
+	log.Printf("Imported certificate authority %s (ID: %d)", ca.Name, ca.ID)
+	c.JSON(http.StatusCreated, gin.H{"data": ca})
+}
+
+// DeleteCertificateAuthority godoc
+// @Summary      Delete an internal certificate authority
+// @Description  Delete a CA that has no intermediates or certificates and is not used by any proxy
+// @Tags         certificate-authorities
+// @Accept       json
+// @Produce      json
+// @Param        id   path      int  true  "Certificate authority ID"
+// @Success      204  {object}  map[string]string
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /certificate-authorities/{id} [delete]
+func DeleteCertificateAuthority(c *gin.Context) {
+	ca, ok := loadCertificateAuthority(c)
+	if !ok {
+		return
+	}
+
+	authorities, err := dbService.GetCertificateAuthorities()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check certificate authority usage: " + err.Error()})
+		return
+	}
+	for _, other := range authorities {
+		if other.ParentID == ca.ID {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Certificate authority still signs intermediate %s", other.Name)})
+			return
+		}
+	}
+
+	certificates, err := dbService.GetCertificatesByCA(ca.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check certificate authority usage: " + err.Error()})
+		return
+	}
+	if len(certificates) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Certificate authority still has %d certificates; delete them first", len(certificates))})
+		return
+	}
+
+	proxies, err := dbService.GetProxiesByClientCA(ca.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check certificate authority usage: " + err.Error()})
+		return
+	}
+	if len(proxies) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Certificate authority is still used for client certificates by %d proxies", len(proxies))})
+		return
+	}
+
+	if err := dbService.DeleteCertificateAuthority(ca.ID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Failed to delete certificate authority: " + err.Error()})
+		return
+	}
+	if err := internalCAService.RemoveAuthorityFiles(ca.ID); err != nil {
+		log.Printf("Warning: failed to remove CRL files of certificate authority %d: %v", ca.ID, err)
+	}
+
+	c.JSON(http.StatusNoContent, gin.H{"message": "Certificate authority deleted successfully"})
+}
+
+// GetCertificateAuthorityCRL godoc
+// @Summary      Download the CRL of an internal certificate authority
+// @Description  Get the PEM encoded list of certificates the CA revoked
+// @Tags         certificate-authorities
+// @Produce      application/x-pem-file
+// @Param        id   path      int  true  "Certificate authority ID"
+// @Success      200  {string}  string
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /certificate-authorities/{id}/crl [get]
+func GetCertificateAuthorityCRL(c *gin.Context) {
+	ca, ok := loadCertificateAuthority(c)
+	if !ok {
+		return
+	}
+
+	crl, err := internalCAService.ReadCRL(ca)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate CRL: " + err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=ca-%d.crl", ca.ID))
+	c.Data(http.StatusOK, "application/x-pem-file", crl)
+}
+
+// IssueInternalCertificate godoc
+// @Summary      Issue a certificate from an internal certificate authority
+// @Description  Issue a server certificate for an internal domain, which proxies for the domain then use, or a client certificate for mutual TLS. The private key of a client certificate is only returned in this response.
+// @Tags         certificate-authorities
+// @Accept       json
+// @Produce      json
+// @Param        id       path      int                                true  "Certificate authority ID"
+// @Param        request  body      models.InternalCertificateRequest  true  "Certificate request"
+// @Success      201      {object}  models.IssuedCertificate
+// @Failure      400      {object}  map[string]string
+// @Failure      404      {object}  map[string]string
+// @Failure      409      {object}  map[string]string
+// @Failure      500      {object}  map[string]string
+// @Router       /certificate-authorities/{id}/certificates [post]
+func IssueInternalCertificate(c *gin.Context) {
+	ca, ok := loadCertificateAuthority(c)
+	if !ok {
+		return
+	}
+
+	var req models.InternalCertificateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	normalizeInternalCertificateRequest(&req)
+	if err := models.ValidateInternalCertificateRequest(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	inUse, err := dbService.CertificateDomainInUse(req.CommonName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if inUse {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("A certificate for %s already exists; delete it first", req.CommonName)})
+		return
+	}
+
+	issued, err := internalCAService.IssueCertificate(ca.ID, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue certificate: " + err.Error()})
+		return
+	}
+	log.Printf("Issued internal %s certificate for %s from CA %s", req.Usage, req.CommonName, ca.Name)
+
+	if req.Usage == models.CertificateUsageServer {
+		// Enable SSL on matching proxies, regenerate their configs and reload nginx
+		enableSSLForDomain(req.CommonName, issued.Certificate.CertPath)
+		regenerateNginxConfigForDomain(req.CommonName)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": issued, "message": "Certificate issued successfully"})
+}
+
+// RevokeCertificate godoc
+// @Summary      Revoke an internal certificate
+// @Description  Put a certificate issued by an internal CA on the CA's CRL. Proxies verifying client certificates against the CA refuse it after nginx reloads; proxies stop serving a revoked server certificate.
+// @Tags         certificates
+// @Accept       json
+// @Produce      json
+// @Param        id       path      int                              true   "Certificate ID"
+// @Param        request  body      models.CertificateRevokeRequest  false  "Revocation reason"
+// @Success      200      {object}  models.Certificate
+// @Failure      400      {object}  map[string]string
+// @Failure      404      {object}  map[string]string
+// @Failure      409      {object}  map[string]string
+// @Failure      500      {object}  map[string]string
+// @Router       /certificates/{id}/revoke [post]
+func RevokeCertificate(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid certificate ID"})
+		return
+	}
+
+	var req models.CertificateRevokeRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.Reason == "" {
+		req.Reason = "unspecified"
+	}
+	reason, known := models.CertificateRevocationReasons[req.Reason]
+	if !known {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown revocation reason %q", req.Reason)})
+		return
+	}
+
+	if dbService == nil || internalCAService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal CA service not initialized"})
+		return
+	}
+
+	certificate, err := dbService.GetCertificate(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Certificate not found"})
+		return
+	}
+	if !certificate.IsInternal() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only certificates issued by an internal CA can be revoked"})
+		return
+	}
+	if certificate.IsRevoked() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Certificate is already revoked"})
+		return
+	}
+
+	if err := internalCAService.RevokeCertificate(certificate, reason); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke certificate: " + err.Error()})
+		return
+	}
+	log.Printf("Revoked internal certificate %s (ID: %d, serial %s)", certificate.Domain, certificate.ID, certificate.SerialNumber)
+
+	if certificate.Usage == models.CertificateUsageClient {
+		reloadNginxForCRL()
+	} else {
+		disableSSLForDomain(certificate.Domain)
+		regenerateNginxConfigForDomain(certificate.Domain)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": certificate, "message": "Certificate revoked successfully"})
+}
+
+// GetCertificatePEM godoc
+// @Summary      Download an internal certificate
+// @Description  Get an internal certificate and its CA chain, e.g. to hand a renewed client certificate to its user
+// @Tags         certificates
+// @Accept       json
+// @Produce      json
+// @Param        id   path      int  true  "Certificate ID"
+// @Success      200  {object}  models.IssuedCertificate
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /certificates/{id}/pem [get]
+func GetCertificatePEM(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid certificate ID"})
+		return
+	}
+
+	if dbService == nil || internalCAService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal CA service not initialized"})
+		return
+	}
+
+	certificate, err := dbService.GetCertificate(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Certificate not found"})
+		return
+	}
+	if !certificate.IsInternal() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only certificates issued by an internal CA can be downloaded"})
+		return
+	}
+
+	certPEM, chainPEM, err := internalCAService.CertificatePEM(certificate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read certificate: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": models.IssuedCertificate{
+		Certificate:    certificate,
+		CertificatePEM: certPEM,
+		ChainPEM:       chainPEM,
+	}})
+}
+
+// loadCertificateAuthority loads the CA named by the id parameter, writing
+// the error response when that fails.
+func loadCertificateAuthority(c *gin.Context) (*models.CertificateAuthority, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid certificate authority ID"})
+		return nil, false
+	}
+
+	if dbService == nil || internalCAService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal CA service not initialized"})
+		return nil, false
+	}
+
+	ca, err := dbService.GetCertificateAuthority(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Certificate authority not found"})
+		return nil, false
+	}
+	return ca, true
+}
+
+// checkCertificateAuthorityName writes a conflict response when another CA
+// already uses the name.
+func checkCertificateAuthorityName(c *gin.Context, name string) bool {
+	inUse, err := dbService.CertificateAuthorityNameInUse(name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if inUse {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("A certificate authority named %s already exists", name)})
+		return false
+	}
+	return true
+}
+
+// checkParentCertificateAuthority writes a bad request response when the
+// parent of an intermediate does not exist.
+func checkParentCertificateAuthority(c *gin.Context, parentID int) bool {
+	if parentID == 0 {
+		return true
+	}
+	if _, err := dbService.GetCertificateAuthority(parentID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("parent_id: certificate authority %d not found", parentID)})
+		return false
+	}
+	return true
+}
+
+// normalizeInternalCertificateRequest trims the names of a certificate
+// request and applies its defaults. Host names are lower-cased.
+func normalizeInternalCertificateRequest(req *models.InternalCertificateRequest) {
+	req.Usage = strings.ToLower(strings.TrimSpace(req.Usage))
+	if req.Usage == "" {
+		req.Usage = models.CertificateUsageServer
+	}
+	req.CommonName = strings.TrimSpace(req.CommonName)
+	if req.Usage == models.CertificateUsageServer {
+		req.CommonName = strings.ToLower(req.CommonName)
+	}
+	for i := range req.DNSNames {
+		req.DNSNames[i] = strings.ToLower(strings.TrimSpace(req.DNSNames[i]))
+	}
+	for i := range req.IPAddresses {
+		req.IPAddresses[i] = strings.TrimSpace(req.IPAddresses[i])
+	}
+	if req.ValidityDays == 0 {
+		req.ValidityDays = models.DefaultCertificateValidityDays
+	}
+}
+
+// renewInternalCertificate re-issues an internal certificate and reloads
+// nginx when it serves the certificate.
+func renewInternalCertificate(certificate *models.Certificate) (*models.Certificate, error) {
+	if internalCAService == nil {
+		return nil, fmt.Errorf("internal CA service not initialized")
+	}
+
+	log.Printf("Attempting to renew internal certificate for %s (ID: %d)", certificate.Domain, certificate.ID)
+	renewedCert, err := internalCAService.RenewCertificate(certificate)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Certificate renewal successful for %s", certificate.Domain)
+
+	if renewedCert.Usage == models.CertificateUsageServer {
+		if nginxService := GetNginxService(); nginxService != nil {
+			if err := nginxService.Apply(nil, nil); err != nil {
+				log.Printf("Warning: Failed to reload nginx after certificate renewal: %v", err)
+			}
+		}
+	}
+	return renewedCert, nil
+}
+
+// reloadNginxForCRL reloads nginx so proxies verifying client certificates
+// pick up a changed CRL.
+func reloadNginxForCRL() {
+	nginxService := GetNginxService()
+	if nginxService == nil {
+		return
+	}
+	if err := nginxService.Apply(nil, nil); err != nil {
+		log.Printf("Warning: Failed to reload nginx after CRL update: %v", err)
+	}
+}
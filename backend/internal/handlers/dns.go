@@ -31,6 +31,7 @@ func SetDNSService(service *services.DNSService) {
 
 // SetSchedulerService sets the scheduler service instance
 func SetSchedulerService(service *services.SchedulerService) {
+	schedulerService = service
 	if dnsHandler != nil {
 		dnsHandler.schedulerService = service
 	}
@@ -213,20 +214,10 @@ func CreateDNSRecord(c *gin.Context) {
 		return
 	}
 
-	if req.BackendURL != "" {
-		if err := models.ValidateBackendURL(req.BackendURL); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid backend_url: " + err.Error()})
-			return
-		}
-	}
-
 	record := &models.DNSRecord{
 		ConfigID:              req.ConfigID,
 		Host:                  req.Host,
-		AllowedIPRanges:       req.AllowedIPRanges,
 		DynamicDNSRefreshRate: req.DynamicDNSRefreshRate,
-		IncludeBackend:        req.IncludeBackend,
-		BackendURL:            req.BackendURL,
 		IsActive:              true,
 	}
 
@@ -260,13 +251,6 @@ func UpdateDNSRecord(c *gin.Context) {
 		return
 	}
 
-	if req.BackendURL != nil && *req.BackendURL != "" {
-		if err := models.ValidateBackendURL(*req.BackendURL); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid backend_url: " + err.Error()})
-			return
-		}
-	}
-
 	record, err := dnsHandler.dnsService.DbService.GetDNSRecord(id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
@@ -277,18 +261,9 @@ func UpdateDNSRecord(c *gin.Context) {
 	if req.Host != nil {
 		record.Host = *req.Host
 	}
-	if req.AllowedIPRanges != nil {
-		record.AllowedIPRanges = *req.AllowedIPRanges
-	}
 	if req.DynamicDNSRefreshRate != nil {
 		record.DynamicDNSRefreshRate = req.DynamicDNSRefreshRate
 	}
-	if req.IncludeBackend != nil {
-		record.IncludeBackend = *req.IncludeBackend
-	}
-	if req.BackendURL != nil {
-		record.BackendURL = *req.BackendURL
-	}
 	if req.IsActive != nil {
 		record.IsActive = *req.IsActive
 	}
@@ -306,57 +281,9 @@ func UpdateDNSRecord(c *gin.Context) {
 		}
 	}
 
-	// Allowed IP ranges live on the DNS record, but nginx only reads them when
-	// the proxy config is generated. Re-render so a save in the DNS UI actually
-	// applies allow/deny on the vhost.
-	if err := regenerateNginxForDNSRecord(record); err != nil {
-		fmt.Printf("Warning: Failed to regenerate nginx for DNS record %d: %v\n", record.ID, err)
-	}
-
 	c.JSON(http.StatusOK, gin.H{"record": record})
 }
 
-func regenerateNginxForDNSRecord(record *models.DNSRecord) error {
-	nginx := GetNginxService()
-	db := GetDatabaseService()
-	if nginx == nil || db == nil {
-		return nil
-	}
-
-	config, err := db.GetDNSConfig(record.ConfigID)
-	if err != nil {
-		return err
-	}
-
-	fqdn := config.Domain
-	if record.Host != "" && record.Host != "@" {
-		fqdn = record.Host + "." + config.Domain
-	}
-
-	proxies, err := db.GetProxiesByDomain(fqdn)
-	if err != nil {
-		return err
-	}
-
-	regenerated := false
-	for i := range proxies {
-		if proxies[i].Domain != fqdn {
-			continue
-		}
-		if err := nginx.GenerateProxyConfig(&proxies[i]); err != nil {
-			return err
-		}
-		regenerated = true
-	}
-	if !regenerated {
-		return nil
-	}
-	if err := nginx.TestNginxConfig(); err != nil {
-		return err
-	}
-	return nginx.ReloadNginx()
-}
-
 // DeleteDNSRecord deletes a DNS record
 func DeleteDNSRecord(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
@@ -365,12 +292,6 @@ func DeleteDNSRecord(c *gin.Context) {
 		return
 	}
 
-	record, err := dnsHandler.dnsService.DbService.GetDNSRecord(id)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-		return
-	}
-
 	if err := dnsHandler.dnsService.DbService.DeleteDNSRecord(id); err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
@@ -380,10 +301,6 @@ func DeleteDNSRecord(c *gin.Context) {
 		dnsHandler.schedulerService.StopScheduledJob(id)
 	}
 
-	if err := regenerateNginxForDNSRecord(record); err != nil {
-		fmt.Printf("Warning: Failed to regenerate nginx for deleted DNS record %d: %v\n", id, err)
-	}
-
 	c.JSON(http.StatusOK, gin.H{
 		"message": "DNS record deleted successfully",
 		"warning": "Namecheap dynamic DNS cannot delete the host. Remove the A record at the registrar if it should stop resolving.",
@@ -0,0 +1,378 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"upm-backend/internal/models"
+	"upm-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetErrorPages godoc
+// @Summary      Get global error pages
+// @Description  Get the custom error pages served by every proxy that has no page of its own for the code
+// @Tags         error-pages
+// @Produce      json
+// @Success      200  {array}   models.ErrorPage
+// @Failure      500  {object}  map[string]string
+// @Router       /error-pages [get]
+func GetErrorPages(c *gin.Context) {
+	if dbService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database service not initialized"})
+		return
+	}
+
+	pages, err := dbService.GetErrorPages(0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch error pages: " + err.Error()})
+		return
+	}
+	if pages == nil {
+		pages = []models.ErrorPage{}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": pages, "count": len(pages)})
+}
+
+// PreviewErrorPage godoc
+// @Summary      Preview a global error page
+// @Description  Render the global custom error page for a status code as HTML
+// @Tags         error-pages
+// @Produce      html
+// @Param        code  path      int  true  "Status code"
+// @Success      200   {string}  string
+// @Failure      400   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Router       /error-pages/{code}/preview [get]
+func PreviewErrorPage(c *gin.Context) {
+	code, ok := parseErrorPageCode(c)
+	if !ok {
+		return
+	}
+	if dbService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database service not initialized"})
+		return
+	}
+
+	page, err := dbService.GetErrorPage(0, code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch error page: " + err.Error()})
+		return
+	}
+	writeErrorPagePreview(c, page)
+}
+
+// SetErrorPage godoc
+// @Summary      Upload a global error page
+// @Description  Create or replace the global custom error page for a 4xx/5xx status code, from a JSON body with content or a multipart upload with a file field, and apply it to every proxy
+// @Tags         error-pages
+// @Accept       json,mpfd
+// @Produce      json
+// @Param        code  path      int                      true   "Status code"
+// @Param        page  body      models.ErrorPageRequest  false  "Error page HTML"
+// @Param        file  formData  file                     false  "Error page HTML file"
+// @Success      200   {object}  models.ErrorPage
+// @Failure      400   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /error-pages/{code} [put]
+func SetErrorPage(c *gin.Context) {
+	code, ok := parseErrorPageCode(c)
+	if !ok {
+		return
+	}
+	content, ok := readErrorPageContent(c, code)
+	if !ok {
+		return
+	}
+	if dbService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database service not initialized"})
+		return
+	}
+
+	page := &models.ErrorPage{StatusCode: code, Content: content}
+	if !applyGlobalErrorPages(c, saveErrorPage(page)) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": page})
+}
+
+// ResetErrorPage godoc
+// @Summary      Reset a global error page
+// @Description  Delete the global custom error page for a status code so proxies fall back to nginx's own page
+// @Tags         error-pages
+// @Produce      json
+// @Param        code  path      int  true  "Status code"
+// @Success      200   {object}  map[string]string
+// @Failure      400   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /error-pages/{code} [delete]
+func ResetErrorPage(c *gin.Context) {
+	code, ok := parseErrorPageCode(c)
+	if !ok {
+		return
+	}
+	if dbService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database service not initialized"})
+		return
+	}
+
+	if !applyGlobalErrorPages(c, deleteErrorPage(0, code)) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Error page reset successfully"})
+}
+
+// GetProxyErrorPages godoc
+// @Summary      Get proxy error pages
+// @Description  Get the custom error pages in effect for a proxy: its own pages and the global pages it does not override (proxy_id 0)
+// @Tags         proxies
+// @Produce      json
+// @Param        id   path      int  true  "Proxy ID"
+// @Success      200  {array}   models.ErrorPage
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /proxies/{id}/error-pages [get]
+func GetProxyErrorPages(c *gin.Context) {
+	proxy, ok := loadProxyParam(c)
+	if !ok {
+		return
+	}
+
+	global, err := dbService.GetErrorPages(0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch error pages: " + err.Error()})
+		return
+	}
+	pages := models.MergeErrorPages(global, proxy.ErrorPages)
+	c.JSON(http.StatusOK, gin.H{"data": pages, "count": len(pages)})
+}
+
+// PreviewProxyErrorPage godoc
+// @Summary      Preview a proxy error page
+// @Description  Render the error page a proxy serves for a status code as HTML: its own page, or else the global one
+// @Tags         proxies
+// @Produce      html
+// @Param        id    path      int  true  "Proxy ID"
+// @Param        code  path      int  true  "Status code"
+// @Success      200   {string}  string
+// @Failure      400   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Router       /proxies/{id}/error-pages/{code}/preview [get]
+func PreviewProxyErrorPage(c *gin.Context) {
+	code, ok := parseErrorPageCode(c)
+	if !ok {
+		return
+	}
+	proxy, ok := loadProxyParam(c)
+	if !ok {
+		return
+	}
+
+	page, err := dbService.GetErrorPage(proxy.ID, code)
+	if err == nil && page == nil {
+		page, err = dbService.GetErrorPage(0, code)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch error page: " + err.Error()})
+		return
+	}
+	writeErrorPagePreview(c, page)
+}
+
+// SetProxyErrorPage godoc
+// @Summary      Upload a proxy error page
+// @Description  Create or replace a proxy's own error page for a 4xx/5xx status code, overriding the global page, from a JSON body with content or a multipart upload with a file field
+// @Tags         proxies
+// @Accept       json,mpfd
+// @Produce      json
+// @Param        id    path      int                      true   "Proxy ID"
+// @Param        code  path      int                      true   "Status code"
+// @Param        page  body      models.ErrorPageRequest  false  "Error page HTML"
+// @Param        file  formData  file                     false  "Error page HTML file"
+// @Success      200   {object}  models.ErrorPage
+// @Failure      400   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /proxies/{id}/error-pages/{code} [put]
+func SetProxyErrorPage(c *gin.Context) {
+	code, ok := parseErrorPageCode(c)
+	if !ok {
+		return
+	}
+	content, ok := readErrorPageContent(c, code)
+	if !ok {
+		return
+	}
+	proxy, ok := loadProxyParam(c)
+	if !ok {
+		return
+	}
+	if proxy.IsPassthrough() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Error pages are not supported for passthrough proxies"})
+		return
+	}
+
+	page := &models.ErrorPage{ProxyID: proxy.ID, StatusCode: code, Content: content}
+	if !applyProxyErrorPages(c, proxy.ID, saveErrorPage(page)) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": page})
+}
+
+// ResetProxyErrorPage godoc
+// @Summary      Reset a proxy error page
+// @Description  Delete a proxy's own error page for a status code so it falls back to the global page, or nginx's own
+// @Tags         proxies
+// @Produce      json
+// @Param        id    path      int  true  "Proxy ID"
+// @Param        code  path      int  true  "Status code"
+// @Success      200   {object}  map[string]string
+// @Failure      400   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /proxies/{id}/error-pages/{code} [delete]
+func ResetProxyErrorPage(c *gin.Context) {
+	code, ok := parseErrorPageCode(c)
+	if !ok {
+		return
+	}
+	proxy, ok := loadProxyParam(c)
+	if !ok {
+		return
+	}
+
+	if !applyProxyErrorPages(c, proxy.ID, deleteErrorPage(proxy.ID, code)) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Error page reset successfully"})
+}
+
+// parseErrorPageCode parses the :code parameter, writing the error response
+// when it is not a 4xx/5xx status.
+func parseErrorPageCode(c *gin.Context) (int, bool) {
+	code, err := strconv.Atoi(c.Param("code"))
+	if err != nil || code < 400 || code > 599 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Status code must be between 400 and 599"})
+		return 0, false
+	}
+	return code, true
+}
+
+// readErrorPageContent reads the HTML of an uploaded error page from a
+// multipart file field or a JSON body, writing the error response when it
+// is missing or invalid.
+func readErrorPageContent(c *gin.Context, code int) (string, bool) {
+	var content string
+	if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		header, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "File field is required: " + err.Error()})
+			return "", false
+		}
+		file, err := header.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file: " + err.Error()})
+			return "", false
+		}
+		defer file.Close()
+		// Read one byte past the limit so oversized pages fail validation
+		data, err := io.ReadAll(io.LimitReader(file, models.MaxErrorPageSize+1))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file: " + err.Error()})
+			return "", false
+		}
+		content = string(data)
+	} else {
+		var req models.ErrorPageRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return "", false
+		}
+		content = req.Content
+	}
+
+	if err := models.ValidateErrorPage(code, content); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", false
+	}
+	return content, true
+}
+
+// writeErrorPagePreview answers with the page's HTML, sandboxed so scripts
+// in it cannot run against the API's origin.
+func writeErrorPagePreview(c *gin.Context, page *models.ErrorPage) {
+	if page == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Error page not found"})
+		return
+	}
+	c.Header("Content-Security-Policy", "sandbox")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(page.Content))
+}
+
+// saveErrorPage is the database update of an apply that creates or
+// replaces an error page.
+func saveErrorPage(page *models.ErrorPage) func(db *services.DatabaseService) error {
+	return func(db *services.DatabaseService) error {
+		if err := db.SetErrorPage(page); err != nil {
+			return failApply(http.StatusInternalServerError, "Failed to save error page: "+err.Error())
+		}
+		return nil
+	}
+}
+
+// deleteErrorPage is the database update of an apply that deletes an error
+// page.
+func deleteErrorPage(proxyID, code int) func(db *services.DatabaseService) error {
+	return func(db *services.DatabaseService) error {
+		deleted, err := db.DeleteErrorPage(proxyID, code)
+		if err != nil {
+			return failApply(http.StatusInternalServerError, "Failed to delete error page: "+err.Error())
+		}
+		if !deleted {
+			return failApply(http.StatusNotFound, "Error page not found")
+		}
+		return nil
+	}
+}
+
+// applyProxyErrorPages applies a change to one proxy's error pages and
+// regenerates its nginx config, writing the error response when that fails.
+func applyProxyErrorPages(c *gin.Context, proxyID int, update func(db *services.DatabaseService) error) bool {
+	return applyChange(c, update, func(nginx *services.NginxService) error {
+		proxy, err := nginx.DatabaseService.GetProxy(proxyID)
+		if err != nil {
+			return fmt.Errorf("failed to fetch proxy: %w", err)
+		}
+		return nginx.UpdateProxyConfig(proxy)
+	})
+}
+
+// applyGlobalErrorPages applies a change to the global error pages and
+// regenerates every proxy, writing the error response when that fails.
+func applyGlobalErrorPages(c *gin.Context, update func(db *services.DatabaseService) error) bool {
+	return applyChange(c, update, func(nginx *services.NginxService) error {
+		proxies, err := nginx.DatabaseService.GetProxies()
+		if err != nil {
+			return fmt.Errorf("failed to load proxies: %w", err)
+		}
+		for i := range proxies {
+			if proxies[i].IsPassthrough() {
+				continue
+			}
+			if err := nginx.GenerateProxyConfig(&proxies[i]); err != nil {
+				return fmt.Errorf("failed to generate nginx config for %s: %w", proxies[i].Domain, err)
+			}
+		}
+		return nil
+	})
+}
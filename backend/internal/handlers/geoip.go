@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"upm-backend/internal/models"
+	"upm-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+var geoIPService *services.GeoIPService
+
+// SetGeoIPService sets the GeoIP service instance.
+func SetGeoIPService(service *services.GeoIPService) {
+	geoIPService = service
+}
+
+// LookupGeoIP godoc
+// @Summary      Look up the country of an IP address
+// @Description  Look up which country an IP address maps to in the local GeoIP database, to debug proxy country rules. Without ip the caller's address is looked up.
+// @Tags         geoip
+// @Accept       json
+// @Produce      json
+// @Param        ip   query     string  false  "IPv4 or IPv6 address"
+// @Success      200  {object}  models.GeoIPLookup
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Failure      503  {object}  map[string]string
+// @Router       /geoip/lookup [get]
+func LookupGeoIP(c *gin.Context) {
+	address := c.Query("ip")
+	if address == "" {
+		address = c.ClientIP()
+	}
+	ip := net.ParseIP(strings.TrimSpace(address))
+	if ip == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid IP address"})
+		return
+	}
+
+	if !geoIPService.Enabled() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "No GeoIP database configured; set GEOIP_DB_PATH"})
+		return
+	}
+
+	result, err := geoIPService.Lookup(ip)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up address: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// normalizeGeoIPRule upper-cases and trims the countries of a rule.
+func normalizeGeoIPRule(rule *models.GeoIPRule) *models.GeoIPRule {
+	normalized := &models.GeoIPRule{Action: rule.Action}
+	for _, code := range rule.Countries {
+		normalized.Countries = append(normalized.Countries, strings.ToUpper(strings.TrimSpace(code)))
+	}
+	return normalized
+}
+
+// checkGeoIPRule validates a proxy's country rule and makes sure there is
+// a database to resolve its countries.
+func checkGeoIPRule(rule *models.GeoIPRule) error {
+	if err := models.ValidateGeoIPRule(rule); err != nil {
+		return err
+	}
+	if rule.Enabled() && !geoIPService.Enabled() {
+		return fmt.Errorf("geoip rules need a GeoIP database; set GEOIP_DB_PATH")
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"upm-backend/internal/models"
+	"upm-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// schedulerService runs scheduled maintenance windows; set by
+// SetSchedulerService.
+var schedulerService *services.SchedulerService
+
+// GetProxyMaintenance godoc
+// @Summary      Get proxy maintenance settings
+// @Description  Get the maintenance mode, page, allowlist and scheduled window of a proxy
+// @Tags         proxies
+// @Produce      json
+// @Param        id   path      int  true  "Proxy ID"
+// @Success      200  {object}  models.Maintenance
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /proxies/{id}/maintenance [get]
+func GetProxyMaintenance(c *gin.Context) {
+	proxy, ok := loadProxyParam(c)
+	if !ok {
+		return
+	}
+
+	maintenance := proxy.Maintenance
+	if maintenance == nil {
+		maintenance = &models.Maintenance{RetryAfterSeconds: models.DefaultMaintenanceRetryAfter}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": maintenance})
+}
+
+// UpdateProxyMaintenance godoc
+// @Summary      Update proxy maintenance settings
+// @Description  Turn maintenance mode on or off, set the maintenance page and the IPs that still reach the upstream, or schedule a maintenance window with starts_at/ends_at. With starts_at set, enabled follows the window
+// @Tags         proxies
+// @Accept       json
+// @Produce      json
+// @Param        id           path      int                 true  "Proxy ID"
+// @Param        maintenance  body      models.Maintenance  true  "Maintenance settings"
+// @Success      200  {object}  models.Maintenance
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /proxies/{id}/maintenance [put]
+func UpdateProxyMaintenance(c *gin.Context) {
+	var maintenance models.Maintenance
+	if err := c.ShouldBindJSON(&maintenance); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	maintenance.ApplyDefaults(now)
+	if err := models.ValidateMaintenance(&maintenance, now); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	proxy, ok := loadProxyParam(c)
+	if !ok {
+		return
+	}
+	if proxy.IsPassthrough() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Maintenance mode is not supported for passthrough proxies"})
+		return
+	}
+
+	proxy.Maintenance = &maintenance
+	if !applyProxyMaintenance(c, proxy) {
+		return
+	}
+	if schedulerService != nil {
+		schedulerService.ScheduleMaintenance(proxy.ID, proxy.Maintenance)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": proxy.Maintenance})
+}
+
+// EndProxyMaintenance godoc
+// @Summary      End proxy maintenance
+// @Description  Turn maintenance mode off and cancel any scheduled window. The page and allowlist are kept for next time
+// @Tags         proxies
+// @Produce      json
+// @Param        id   path      int  true  "Proxy ID"
+// @Success      200  {object}  models.Maintenance
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /proxies/{id}/maintenance [delete]
+func EndProxyMaintenance(c *gin.Context) {
+	proxy, ok := loadProxyParam(c)
+	if !ok {
+		return
+	}
+	if schedulerService != nil {
+		schedulerService.CancelMaintenance(proxy.ID)
+	}
+	if proxy.Maintenance == nil {
+		c.JSON(http.StatusOK, gin.H{"data": models.Maintenance{RetryAfterSeconds: models.DefaultMaintenanceRetryAfter}})
+		return
+	}
+
+	proxy.Maintenance.Enabled = false
+	proxy.Maintenance.StartsAt = nil
+	proxy.Maintenance.EndsAt = nil
+	if !applyProxyMaintenance(c, proxy) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": proxy.Maintenance})
+}
+
+// loadProxyParam loads the proxy named by the :id parameter, writing
+// the error response when that fails.
+func loadProxyParam(c *gin.Context) (*models.Proxy, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid proxy ID"})
+		return nil, false
+	}
+
+	if dbService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database service not initialized"})
+		return nil, false
+	}
+
+	proxy, err := dbService.GetProxy(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Proxy not found"})
+		return nil, false
+	}
+	return proxy, true
+}
+
+// applyProxyMaintenance saves the proxy's maintenance settings and
+// regenerates its nginx config, writing the error response when that fails.
+func applyProxyMaintenance(c *gin.Context, proxy *models.Proxy) bool {
+	return applyChange(c, func(db *services.DatabaseService) error {
+		if err := db.SetProxyMaintenance(proxy.ID, proxy.Maintenance); err != nil {
+			return failApply(http.StatusInternalServerError, "Failed to save maintenance settings: "+err.Error())
+		}
+		return nil
+	}, func(nginx *services.NginxService) error {
+		return nginx.UpdateProxyConfig(proxy)
+	})
+}
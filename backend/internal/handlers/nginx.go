@@ -1,8 +1,10 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"upm-backend/internal/models"
 	"upm-backend/internal/services"
@@ -22,6 +24,51 @@ func GetNginxService() *services.NginxService {
 	return nginxService
 }
 
+// applyError is a failure of the database update of an apply, carrying
+// the response to send for it.
+type applyError struct {
+	status  int
+	message string
+}
+
+func (e *applyError) Error() string {
+	return e.message
+}
+
+// failApply fails the database update of an apply with the given response.
+func failApply(status int, message string) error {
+	return &applyError{status: status, message: message}
+}
+
+// applyChange runs a change through the nginx apply pipeline, so the
+// database update and the nginx configuration render commits together or
+// not at all, and writes the error response when it fails. Without an
+// nginx service the update runs in a plain transaction. Either function
+// may be nil. It reports whether the change was applied.
+func applyChange(c *gin.Context, update func(db *services.DatabaseService) error, render func(nginx *services.NginxService) error) bool {
+	var err error
+	if nginxService := getNginxService(); nginxService != nil {
+		err = nginxService.Apply(update, render)
+	} else if update != nil {
+		err = dbService.InTransaction(update)
+	}
+	if err == nil {
+		return true
+	}
+
+	var failure *applyError
+	var testErr *services.ConfigTestError
+	switch {
+	case errors.As(err, &failure):
+		c.JSON(failure.status, gin.H{"error": failure.message})
+	case errors.As(err, &testErr):
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid nginx configuration: " + strings.TrimSpace(testErr.Output)})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply changes: " + err.Error()})
+	}
+	return false
+}
+
 // ReloadNginx godoc
 // @Summary      Reload nginx configuration
 // @Description  Manually reload nginx configuration
@@ -37,15 +84,8 @@ func ReloadNginx(c *gin.Context) {
 		return
 	}
 
-	// Test nginx configuration first
-	if err := nginxService.TestNginxConfig(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid nginx configuration: " + err.Error()})
-		return
-	}
-
-	// Reload nginx
-	if err := nginxService.ReloadNginx(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reload nginx: " + err.Error()})
+	// Test nginx configuration first, then reload
+	if !applyChange(c, nil, nil) {
 		return
 	}
 
@@ -76,6 +116,57 @@ func TestNginxConfig(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Nginx configuration is valid"})
 }
 
+// GetNginxDrift godoc
+// @Summary      Detect nginx config drift
+// @Description  Render every proxy and stream from the database in memory and report generated files on disk that are missing, orphaned or modified, with unified diffs from disk to the rendered config
+// @Tags         nginx
+// @Produce      json
+// @Success      200  {object}  models.DriftReport
+// @Failure      500  {object}  map[string]string
+// @Router       /nginx/drift [get]
+func GetNginxDrift(c *gin.Context) {
+	if nginxService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Nginx service not initialized"})
+		return
+	}
+
+	report, err := nginxService.DetectDrift()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to detect config drift: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": report})
+}
+
+// ReconcileNginx godoc
+// @Summary      Reconcile nginx config with the database
+// @Description  Regenerate every generated file from the database, remove orphaned ones, validate and reload nginx. Returns the drift that was corrected
+// @Tags         nginx
+// @Produce      json
+// @Success      200  {object}  models.DriftReport
+// @Failure      500  {object}  map[string]string
+// @Router       /nginx/reconcile [post]
+func ReconcileNginx(c *gin.Context) {
+	if nginxService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Nginx service not initialized"})
+		return
+	}
+
+	report, err := nginxService.DetectDrift()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to detect config drift: " + err.Error()})
+		return
+	}
+	if !applyChange(c, nil, func(nginx *services.NginxService) error {
+		return nginx.ReconcileConfig()
+	}) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": report, "message": "Nginx configuration reconciled with the database"})
+}
+
 // UpdateAdminIPRestrictions godoc
 // @Summary      Update nginx admin IP restrictions
 // @Description  Update the IP ranges allowed to access the admin interface
@@ -102,20 +193,9 @@ func UpdateAdminIPRestrictions(c *gin.Context) {
 	}
 
 	// Update admin configuration
-	if err := nginxService.UpdateAdminConfig(req.AllowedRanges); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update admin config: " + err.Error()})
-		return
-	}
-
-	// Test nginx configuration
-	if err := nginxService.TestNginxConfig(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid nginx configuration: " + err.Error()})
-		return
-	}
-
-	// Reload nginx
-	if err := nginxService.ReloadNginx(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reload nginx: " + err.Error()})
+	if !applyChange(c, nil, func(nginx *services.NginxService) error {
+		return nginx.UpdateAdminConfig(req.AllowedRanges)
+	}) {
 		return
 	}
 
@@ -189,7 +269,7 @@ func RegenerateProxyConfig(c *gin.Context) {
 
 	var targetProxy *models.Proxy
 	for _, proxy := range proxies {
-		if proxy.Domain == domain {
+		if proxy.MatchesServerName(domain) {
 			targetProxy = &proxy
 			break
 		}
@@ -201,23 +281,14 @@ func RegenerateProxyConfig(c *gin.Context) {
 	}
 
 	// Check if a certificate exists for this domain and auto-enable SSL if needed
+	enableSSL := false
 	if !targetProxy.SSLEnabled {
-		existingCert, err := dbService.GetCertificateByDomain(targetProxy.Domain)
+		existingCert, err := dbService.GetCertificateForProxy(targetProxy)
 		if err == nil && existingCert != nil {
 			// Certificate exists, enable SSL on the proxy
 			targetProxy.SSLEnabled = true
 			targetProxy.SSLPath = existingCert.CertPath
-			// Update the proxy in the database
-			if err := dbService.UpdateProxy(targetProxy); err != nil {
-				fmt.Printf("Warning: Failed to update proxy SSL status for %s: %v\n", targetProxy.Domain, err)
-			} else {
-				fmt.Printf("Auto-enabled SSL for %s based on existing certificate\n", targetProxy.Domain)
-				// Reload the proxy from database to ensure we have the latest state
-				updatedProxy, err := dbService.GetProxy(targetProxy.ID)
-				if err == nil {
-					targetProxy = updatedProxy
-				}
-			}
+			enableSSL = true
 		} else {
 			if err != nil {
 				fmt.Printf("Certificate lookup for %s: %v\n", targetProxy.Domain, err)
@@ -228,8 +299,24 @@ func RegenerateProxyConfig(c *gin.Context) {
 	}
 
 	// Regenerate nginx configuration
-	if err := nginxService.GenerateProxyConfig(targetProxy); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to regenerate nginx config: " + err.Error()})
+	if !applyChange(c, func(db *services.DatabaseService) error {
+		if !enableSSL {
+			return nil
+		}
+		// Update the proxy in the database
+		if err := db.UpdateProxy(targetProxy); err != nil {
+			return failApply(http.StatusInternalServerError, "Failed to update proxy SSL status: "+err.Error())
+		}
+		fmt.Printf("Auto-enabled SSL for %s based on existing certificate\n", targetProxy.Domain)
+		// Reload the proxy from database to ensure we have the latest state
+		updatedProxy, err := db.GetProxy(targetProxy.ID)
+		if err == nil {
+			targetProxy = updatedProxy
+		}
+		return nil
+	}, func(nginx *services.NginxService) error {
+		return nginx.GenerateProxyConfig(targetProxy)
+	}) {
 		return
 	}
 
@@ -239,24 +326,12 @@ func RegenerateProxyConfig(c *gin.Context) {
 		targetProxy = finalProxy
 	}
 
-	// Test nginx configuration
-	if err := nginxService.TestNginxConfig(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid nginx configuration: " + err.Error()})
-		return
-	}
-
-	// Reload nginx
-	if err := nginxService.ReloadNginx(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reload nginx: " + err.Error()})
-		return
-	}
-
 	message := fmt.Sprintf("Nginx configuration regenerated successfully for domain: %s", domain)
 	if targetProxy.SSLEnabled {
 		message += " (SSL enabled)"
 	} else {
 		// Check if certificate exists to provide helpful message
-		existingCert, err := dbService.GetCertificateByDomain(targetProxy.Domain)
+		existingCert, err := dbService.GetCertificateForProxy(targetProxy)
 		if err == nil && existingCert != nil {
 			message += " (SSL disabled - certificate files may be missing or invalid, check backend logs)"
 		} else {
@@ -93,14 +93,16 @@ func GetProxy(c *gin.Context) {
 
 // CreateProxy godoc
 // @Summary      Create a new proxy
-// @Description  Create a new proxy configuration
+// @Description  Create a new proxy configuration. With dry_run=true nothing is saved; the response is the nginx config the proxy would get
 // @Tags         proxies
 // @Accept       json
 // @Produce      json
-// @Param        proxy  body      models.ProxyCreateRequest  true  "Proxy data"
-// @Success      201    {object}  models.Proxy
-// @Failure      400    {object}  map[string]string
-// @Failure      500    {object}  map[string]string
+// @Param        proxy    body      models.ProxyCreateRequest  true   "Proxy data"
+// @Param        dry_run  query     bool                       false  "Only preview the rendered nginx config"
+// @Success      201      {object}  models.Proxy
+// @Success      200      {object}  models.ProxyConfigPreview
+// @Failure      400      {object}  map[string]string
+// @Failure      500      {object}  map[string]string
 // @Router       /proxies [post]
 func CreateProxy(c *gin.Context) {
 	var req models.ProxyCreateRequest
@@ -113,8 +115,49 @@ func CreateProxy(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if err := models.ValidateBackendURL(req.TargetURL); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid target_url: " + err.Error()})
+	if err := models.ValidateProxyType(req.Type); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	upstreamServers := upstreamServersFromRequest(req.UpstreamServers)
+	if err := models.ValidateUpstreamServers(upstreamServers, req.LoadBalanceMethod); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.TargetURL == "" {
+		// With a pool, target_url defaults to its first primary member
+		req.TargetURL = models.PrimaryUpstreamURL(upstreamServers)
+	}
+	// Static hosts serve files and have no target
+	if req.Type != models.ProxyTypeStatic {
+		if err := models.ValidateBackendURL(req.TargetURL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid target_url: " + err.Error()})
+			return
+		}
+	}
+
+	if err := models.ValidateAliases(req.Domain, req.Aliases); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := models.ValidateSSLMode(req.SSLMode); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	wsEnabled := false
+	if req.WSEnabled != nil {
+		wsEnabled = *req.WSEnabled
+	}
+	locations := locationsFromRequest(req.Locations)
+	if err := models.ValidateProxyLocations(locations, wsEnabled); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	headerRules := headerRulesFromRequest(req.HeaderRules)
+	if err := models.ValidateHeaderRules(headerRules); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -138,11 +181,12 @@ func CreateProxy(c *gin.Context) {
 		return
 	}
 
-	// Create proxy object
-	wsEnabled := false
-	if req.WSEnabled != nil {
-		wsEnabled = *req.WSEnabled
+	if err := checkServerNamesAvailable(append([]string{req.Domain}, req.Aliases...), 0); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
 	}
+
+	// Create proxy object
 	proxy := &models.Proxy{
 		Name:             req.Name,
 		Domain:           req.Domain,
@@ -152,12 +196,105 @@ func CreateProxy(c *gin.Context) {
 		RateLimitEnabled: rateLimitEnabled,
 		RateLimitRPS:     rateLimitRPS,
 		Status:           "active",
+		Type:             req.Type,
+		Redirect:         req.Redirect,
+		Static:           req.Static,
+
+		LoadBalanceMethod: req.LoadBalanceMethod,
+		CanonicalRedirect: req.CanonicalRedirect,
+		SSLMode:           req.SSLMode,
+		BasicAuthSetID:    req.BasicAuthSetID,
+		AccessListID:      req.AccessListID,
+		RequireUPMLogin:   req.RequireUPMLogin,
+		UpstreamServers:   upstreamServers,
+		Locations:         locations,
+		HeaderRules:       headerRules,
+		CacheEnabled:      req.CacheEnabled,
+		Cache:             req.Cache,
+		RateLimit:         req.RateLimit,
+		HTTP3Enabled:      req.HTTP3Enabled,
+	}
+	if req.ForwardAuth.Enabled() {
+		proxy.ForwardAuth = req.ForwardAuth
+	}
+	if req.GeoIP.Enabled() {
+		proxy.GeoIP = normalizeGeoIPRule(req.GeoIP)
+	}
+	if req.ClientAuth.Enabled() {
+		proxy.ClientAuth = normalizeClientAuth(req.ClientAuth)
+	}
+	if !req.TLS.IsZero() {
+		proxy.TLS = req.TLS
+	}
+	proxy.ApplyHostTypeDefaults()
+	if err := models.ValidateCachePolicy(proxy.EffectiveCachePolicy()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if proxy.RateLimit != nil {
+		if err := models.ValidateRateLimitPolicy(proxy.EffectiveRateLimitPolicy()); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if err := models.ValidateTLSPolicy(proxy.TLS); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := models.ValidateHTTP3(proxy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := checkHTTP3PortAvailable(proxy); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if req.HealthCheck != nil {
+		healthCheck := req.HealthCheck.ToHealthCheck()
+		if err := models.ValidateHealthCheck(healthCheck); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		proxy.HealthCheck = &healthCheck
+	}
+	if err := models.ValidatePassthroughProxy(proxy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := models.ValidateHostType(proxy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := models.ValidateForwardAuth(proxy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := checkGeoIPRule(proxy.GeoIP); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := checkClientAuth(proxy.ClientAuth); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := checkBasicAuthSetsExist(proxy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := checkAccessListsExist(proxy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	proxy.Aliases = req.Aliases
+	if dryRunRequested(c) {
+		previewProxyConfig(c, proxy)
+		return
 	}
 
 	// If SSL is enabled, check if certificate already exists
 	if req.SSLEnabled {
-		// First check if a certificate already exists for this domain
-		existingCert, err := dbService.GetCertificateByDomain(req.Domain)
+		// First check if a certificate already covers the domain and its aliases
+		existingCert, err := dbService.GetCertificateForProxy(proxy)
 		if err == nil && existingCert != nil {
 			// Certificate already exists, use it
 			proxy.SSLEnabled = true
@@ -168,7 +305,7 @@ func CreateProxy(c *gin.Context) {
 			certService := services.NewCertificateService("/etc/ssl/certs")
 
 			// Generate Let's Encrypt certificate
-			certificate, err := certService.GenerateLetsEncryptCertificate(req.Domain)
+			certificate, err := certService.GenerateLetsEncryptCertificate(req.Domain, req.Aliases...)
 			if err != nil {
 				// If certificate generation fails, disable SSL and continue
 				proxy.SSLEnabled = false
@@ -188,35 +325,65 @@ func CreateProxy(c *gin.Context) {
 		}
 	}
 
-	// Save to database
-	if err := dbService.CreateProxy(proxy); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create proxy: " + err.Error()})
-		return
-	}
-
-	// Generate nginx configuration
-	nginxService := getNginxService()
-	if nginxService != nil {
-		if err := nginxService.GenerateProxyConfig(proxy); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate nginx config: " + err.Error()})
-			return
+	// Save to database and generate nginx configuration
+	if !applyChange(c, func(db *services.DatabaseService) error {
+		if err := db.CreateProxy(proxy); err != nil {
+			return failApply(http.StatusInternalServerError, "Failed to create proxy: "+err.Error())
 		}
-
-		// Test nginx configuration
-		if err := nginxService.TestNginxConfig(); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid nginx configuration: " + err.Error()})
-			return
+		if len(upstreamServers) > 0 {
+			if err := db.ReplaceUpstreamServers(proxy.ID, upstreamServers); err != nil {
+				return failApply(http.StatusInternalServerError, "Failed to save upstream servers: "+err.Error())
+			}
 		}
-
-		// Reload nginx
-		if err := nginxService.ReloadNginx(); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reload nginx: " + err.Error()})
-			return
+		if len(locations) > 0 {
+			if err := db.ReplaceProxyLocations(proxy.ID, locations); err != nil {
+				return failApply(http.StatusInternalServerError, "Failed to save locations: "+err.Error())
+			}
+		}
+		if len(headerRules) > 0 {
+			if err := db.ReplaceProxyHeaderRules(proxy.ID, headerRules); err != nil {
+				return failApply(http.StatusInternalServerError, "Failed to save header rules: "+err.Error())
+			}
+		}
+		if proxy.Cache != nil {
+			if err := db.SetProxyCachePolicy(proxy.ID, proxy.Cache); err != nil {
+				return failApply(http.StatusInternalServerError, "Failed to save cache policy: "+err.Error())
+			}
+		}
+		if proxy.RateLimit != nil {
+			if err := db.SetProxyRateLimitPolicy(proxy.ID, proxy.RateLimit); err != nil {
+				return failApply(http.StatusInternalServerError, "Failed to save rate limit policy: "+err.Error())
+			}
+		}
+		if proxy.TLS != nil {
+			if err := db.SetProxyTLSPolicy(proxy.ID, proxy.TLS); err != nil {
+				return failApply(http.StatusInternalServerError, "Failed to save TLS policy: "+err.Error())
+			}
 		}
+		if proxy.HealthCheck != nil {
+			if err := db.SetProxyHealthCheck(proxy.ID, proxy.HealthCheck); err != nil {
+				return failApply(http.StatusInternalServerError, "Failed to save health check: "+err.Error())
+			}
+		}
+		if len(req.Aliases) > 0 {
+			if err := db.ReplaceProxyAliases(proxy.ID, req.Aliases); err != nil {
+				return failApply(http.StatusInternalServerError, "Failed to save aliases: "+err.Error())
+			}
+			proxy.Aliases = req.Aliases
+		}
+		return nil
+	}, func(nginx *services.NginxService) error {
+		return nginx.GenerateProxyConfig(proxy)
+	}) {
+		return
 	}
+	refreshQuarantine(proxy)
 
 	// Prepare response with SSL status
 	response := gin.H{"data": proxy}
+	if proxy.Quarantined {
+		response["warning"] = "Nginx rejected the proxy's configuration, so it was quarantined: " + proxy.LastError
+	}
 
 	// Add SSL certificate generation status
 	if req.SSLEnabled {
@@ -234,16 +401,17 @@ func CreateProxy(c *gin.Context) {
 
 // UpdateProxy godoc
 // @Summary      Update a proxy
-// @Description  Update an existing proxy configuration
+// @Description  Update an existing proxy configuration. With dry_run=true nothing is saved; the response is the nginx config the proxy would get and its diff against the current one
 // @Tags         proxies
 // @Accept       json
 // @Produce      json
-// @Param        id     path      int                        true  "Proxy ID"
-// @Param        proxy  body      models.ProxyUpdateRequest  true  "Proxy data"
-// @Success      200    {object}  models.Proxy
-// @Failure      400    {object}  map[string]string
-// @Failure      404    {object}  map[string]string
-// @Failure      500    {object}  map[string]string
+// @Param        id       path      int                        true   "Proxy ID"
+// @Param        proxy    body      models.ProxyUpdateRequest  true   "Proxy data"
+// @Param        dry_run  query     bool                       false  "Only preview the rendered nginx config and its diff against the current one"
+// @Success      200      {object}  models.Proxy
+// @Failure      400      {object}  map[string]string
+// @Failure      404      {object}  map[string]string
+// @Failure      500      {object}  map[string]string
 // @Router       /proxies/{id} [put]
 func UpdateProxy(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
@@ -264,9 +432,9 @@ func UpdateProxy(c *gin.Context) {
 			return
 		}
 	}
-	if req.TargetURL != nil {
-		if err := models.ValidateBackendURL(*req.TargetURL); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid target_url: " + err.Error()})
+	if req.Type != nil {
+		if err := models.ValidateProxyType(*req.Type); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 	}
@@ -276,6 +444,12 @@ func UpdateProxy(c *gin.Context) {
 			return
 		}
 	}
+	if req.SSLMode != nil {
+		if err := models.ValidateSSLMode(*req.SSLMode); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
 
 	if dbService == nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database service not initialized"})
@@ -308,11 +482,206 @@ func UpdateProxy(c *gin.Context) {
 	if req.RateLimitRPS != nil {
 		proxy.RateLimitRPS = *req.RateLimitRPS
 	}
+	if req.LoadBalanceMethod != nil {
+		proxy.LoadBalanceMethod = *req.LoadBalanceMethod
+	}
+	if req.UpstreamServers != nil {
+		proxy.UpstreamServers = upstreamServersFromRequest(*req.UpstreamServers)
+		if req.TargetURL == nil && len(proxy.UpstreamServers) > 0 {
+			proxy.TargetURL = models.PrimaryUpstreamURL(proxy.UpstreamServers)
+		}
+	}
+	if req.Type != nil {
+		proxy.Type = *req.Type
+	}
+	if req.Redirect != nil {
+		proxy.Redirect = req.Redirect
+	}
+	if req.Static != nil {
+		proxy.Static = req.Static
+	}
+	proxy.ApplyHostTypeDefaults()
+	if req.Type != nil && proxy.Type != models.ProxyTypeProxy && proxy.Status == "error" {
+		// Only proxy hosts are health checked, so nothing would clear it
+		proxy.Status = "active"
+	}
+	// Check the merged target, since a type change may add or drop it
+	if (req.TargetURL != nil || req.Type != nil) && proxy.Type != models.ProxyTypeStatic {
+		if err := models.ValidateBackendURL(proxy.TargetURL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid target_url: " + err.Error()})
+			return
+		}
+	}
+	// Validate the merged pool so a method change is checked against the
+	// existing members and vice versa.
+	if err := models.ValidateUpstreamServers(proxy.UpstreamServers, proxy.LoadBalanceMethod); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Locations != nil {
+		proxy.Locations = locationsFromRequest(*req.Locations)
+	}
+	if err := models.ValidateProxyLocations(proxy.Locations, proxy.WSEnabled); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.HeaderRules != nil {
+		proxy.HeaderRules = headerRulesFromRequest(*req.HeaderRules)
+		if err := models.ValidateHeaderRules(proxy.HeaderRules); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.CacheEnabled != nil {
+		proxy.CacheEnabled = *req.CacheEnabled
+	}
+	if req.Cache != nil {
+		proxy.Cache = req.Cache
+		if err := models.ValidateCachePolicy(proxy.EffectiveCachePolicy()); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.RateLimit != nil {
+		proxy.RateLimit = req.RateLimit
+	}
+	if req.TLS != nil {
+		proxy.TLS = nil
+		if !req.TLS.IsZero() {
+			proxy.TLS = req.TLS
+		}
+		if err := models.ValidateTLSPolicy(proxy.TLS); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.HTTP3Enabled != nil {
+		proxy.HTTP3Enabled = *req.HTTP3Enabled
+	}
+	if req.TLS != nil || req.HTTP3Enabled != nil {
+		if err := models.ValidateHTTP3(proxy); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.HTTP3Enabled != nil {
+		if err := checkHTTP3PortAvailable(proxy); err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	// Check the merged policy, since rate_limit_rps may supply its rate
+	if req.RateLimit != nil || req.RateLimitRPS != nil {
+		if err := models.ValidateRateLimitPolicy(proxy.EffectiveRateLimitPolicy()); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.HealthCheck != nil {
+		healthCheck := req.HealthCheck.ToHealthCheck()
+		if err := models.ValidateHealthCheck(healthCheck); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		proxy.HealthCheck = &healthCheck
+		// Nothing would clear a failed status once probing stops
+		if !healthCheck.Enabled && proxy.Status == "error" {
+			proxy.Status = "active"
+		}
+	}
+	if req.Aliases != nil {
+		proxy.Aliases = *req.Aliases
+	}
+	if req.CanonicalRedirect != nil {
+		proxy.CanonicalRedirect = *req.CanonicalRedirect
+	}
+	if req.Domain != nil || req.Aliases != nil {
+		if err := models.ValidateAliases(proxy.Domain, proxy.Aliases); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := checkServerNamesAvailable(proxy.ServerNames(), proxy.ID); err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.SSLMode != nil {
+		proxy.SSLMode = *req.SSLMode
+	}
+	if req.BasicAuthSetID != nil {
+		proxy.BasicAuthSetID = *req.BasicAuthSetID
+	}
+	if req.AccessListID != nil {
+		proxy.AccessListID = *req.AccessListID
+	}
+	if req.RequireUPMLogin != nil {
+		proxy.RequireUPMLogin = *req.RequireUPMLogin
+	}
+	if req.ForwardAuth != nil {
+		proxy.ForwardAuth = nil
+		if req.ForwardAuth.Enabled() {
+			proxy.ForwardAuth = req.ForwardAuth
+		}
+	}
+	if req.GeoIP != nil {
+		proxy.GeoIP = nil
+		if req.GeoIP.Enabled() {
+			proxy.GeoIP = normalizeGeoIPRule(req.GeoIP)
+		}
+		if err := checkGeoIPRule(proxy.GeoIP); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.ClientAuth != nil {
+		proxy.ClientAuth = nil
+		if req.ClientAuth.Enabled() {
+			proxy.ClientAuth = normalizeClientAuth(req.ClientAuth)
+		}
+		if err := checkClientAuth(proxy.ClientAuth); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.BasicAuthSetID != nil || req.Locations != nil {
+		if err := checkBasicAuthSetsExist(proxy); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.AccessListID != nil || req.Locations != nil {
+		if err := checkAccessListsExist(proxy); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	// Check the mode against the requested SSL state before any
+	// certificate is issued
+	requested := *proxy
+	if req.SSLEnabled != nil {
+		requested.SSLEnabled = *req.SSLEnabled
+	}
+	if err := models.ValidatePassthroughProxy(&requested); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := models.ValidateHostType(&requested); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := models.ValidateForwardAuth(&requested); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if dryRunRequested(c) {
+		previewProxyConfig(c, &requested)
+		return
+	}
 	if req.SSLEnabled != nil {
 		// If SSL is being enabled, check if certificate already exists
 		if *req.SSLEnabled && !proxy.SSLEnabled {
-			// First check if a certificate already exists for this domain
-			existingCert, err := dbService.GetCertificateByDomain(proxy.Domain)
+			// First check if a certificate already covers the domain and its aliases
+			existingCert, err := dbService.GetCertificateForProxy(proxy)
 			if err == nil && existingCert != nil {
 				// Certificate already exists, enable SSL
 				proxy.SSLEnabled = true
@@ -323,7 +692,7 @@ func UpdateProxy(c *gin.Context) {
 				certService := services.NewCertificateService("/etc/ssl/certs")
 
 				// Generate Let's Encrypt certificate
-				certificate, err := certService.GenerateLetsEncryptCertificate(proxy.Domain)
+				certificate, err := certService.GenerateLetsEncryptCertificate(proxy.Domain, proxy.Aliases...)
 				if err != nil {
 					// If certificate generation fails, keep SSL disabled
 					fmt.Printf("Warning: Failed to generate Let's Encrypt certificate for %s: %v. Keeping SSL disabled.\n", proxy.Domain, err)
@@ -344,35 +713,64 @@ func UpdateProxy(c *gin.Context) {
 		}
 	}
 
-	// Save to database
-	if err := dbService.UpdateProxy(proxy); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update proxy: " + err.Error()})
-		return
-	}
-
-	// Update nginx configuration
-	nginxService := getNginxService()
-	if nginxService != nil {
-		if err := nginxService.UpdateProxyConfig(proxy); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update nginx config: " + err.Error()})
-			return
+	// Save to database and update nginx configuration
+	if !applyChange(c, func(db *services.DatabaseService) error {
+		if err := db.UpdateProxy(proxy); err != nil {
+			return failApply(http.StatusInternalServerError, "Failed to update proxy: "+err.Error())
 		}
-
-		// Test nginx configuration
-		if err := nginxService.TestNginxConfig(); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid nginx configuration: " + err.Error()})
-			return
+		if req.UpstreamServers != nil {
+			if err := db.ReplaceUpstreamServers(proxy.ID, proxy.UpstreamServers); err != nil {
+				return failApply(http.StatusInternalServerError, "Failed to save upstream servers: "+err.Error())
+			}
 		}
-
-		// Reload nginx
-		if err := nginxService.ReloadNginx(); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reload nginx: " + err.Error()})
-			return
+		if req.Locations != nil {
+			if err := db.ReplaceProxyLocations(proxy.ID, proxy.Locations); err != nil {
+				return failApply(http.StatusInternalServerError, "Failed to save locations: "+err.Error())
+			}
+		}
+		if req.HeaderRules != nil {
+			if err := db.ReplaceProxyHeaderRules(proxy.ID, proxy.HeaderRules); err != nil {
+				return failApply(http.StatusInternalServerError, "Failed to save header rules: "+err.Error())
+			}
+		}
+		if req.Cache != nil {
+			if err := db.SetProxyCachePolicy(proxy.ID, proxy.Cache); err != nil {
+				return failApply(http.StatusInternalServerError, "Failed to save cache policy: "+err.Error())
+			}
+		}
+		if req.RateLimit != nil {
+			if err := db.SetProxyRateLimitPolicy(proxy.ID, proxy.RateLimit); err != nil {
+				return failApply(http.StatusInternalServerError, "Failed to save rate limit policy: "+err.Error())
+			}
+		}
+		if req.TLS != nil {
+			if err := db.SetProxyTLSPolicy(proxy.ID, proxy.TLS); err != nil {
+				return failApply(http.StatusInternalServerError, "Failed to save TLS policy: "+err.Error())
+			}
+		}
+		if req.HealthCheck != nil {
+			if err := db.SetProxyHealthCheck(proxy.ID, proxy.HealthCheck); err != nil {
+				return failApply(http.StatusInternalServerError, "Failed to save health check: "+err.Error())
+			}
 		}
+		if req.Aliases != nil {
+			if err := db.ReplaceProxyAliases(proxy.ID, proxy.Aliases); err != nil {
+				return failApply(http.StatusInternalServerError, "Failed to save aliases: "+err.Error())
+			}
+		}
+		return nil
+	}, func(nginx *services.NginxService) error {
+		return nginx.UpdateProxyConfig(proxy)
+	}) {
+		return
 	}
+	refreshQuarantine(proxy)
 
 	// Prepare response with SSL status
 	response := gin.H{"data": proxy}
+	if proxy.Quarantined {
+		response["warning"] = "Nginx rejected the proxy's configuration, so it was quarantined: " + proxy.LastError
+	}
 
 	// Add SSL certificate generation status if SSL was enabled
 	if req.SSLEnabled != nil && *req.SSLEnabled {
@@ -420,30 +818,72 @@ func DeleteProxy(c *gin.Context) {
 
 	removeUnusedCertificateForDomain(proxy.Domain, id)
 
-	if err := dbService.DeleteProxy(id); err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Failed to delete proxy: " + err.Error()})
+	if !applyChange(c, func(db *services.DatabaseService) error {
+		if err := db.DeleteProxy(id); err != nil {
+			return failApply(http.StatusNotFound, "Failed to delete proxy: "+err.Error())
+		}
+		return nil
+	}, func(nginx *services.NginxService) error {
+		return nginx.RemoveProxyConfig(id)
+	}) {
 		return
 	}
+	if schedulerService != nil {
+		schedulerService.CancelMaintenance(id)
+	}
 
-	nginxService := getNginxService()
-	if nginxService != nil {
-		if err := nginxService.RemoveProxyConfig(id); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove nginx config: " + err.Error()})
-			return
-		}
+	c.JSON(http.StatusNoContent, gin.H{"message": "Proxy deleted successfully"})
+}
 
-		if err := nginxService.TestNginxConfig(); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid nginx configuration: " + err.Error()})
-			return
-		}
+// upstreamServersFromRequest converts request pool members into models.
+func upstreamServersFromRequest(reqs []models.UpstreamServerRequest) []models.UpstreamServer {
+	if len(reqs) == 0 {
+		return nil
+	}
+	servers := make([]models.UpstreamServer, 0, len(reqs))
+	for _, r := range reqs {
+		servers = append(servers, r.ToUpstreamServer())
+	}
+	return servers
+}
 
-		if err := nginxService.ReloadNginx(); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reload nginx: " + err.Error()})
-			return
+// checkServerNamesAvailable returns an error naming the first server name
+// already used by a proxy other than proxyID.
+func checkServerNamesAvailable(names []string, proxyID int) error {
+	for _, name := range names {
+		inUse, err := dbService.ServerNameInUse(name, proxyID)
+		if err != nil {
+			return err
+		}
+		if inUse {
+			return fmt.Errorf("server name %s is already used by another proxy", name)
 		}
 	}
+	return nil
+}
 
-	c.JSON(http.StatusNoContent, gin.H{"message": "Proxy deleted successfully"})
+// locationsFromRequest converts request location rules into models.
+func locationsFromRequest(reqs []models.ProxyLocationRequest) []models.ProxyLocation {
+	if len(reqs) == 0 {
+		return nil
+	}
+	locations := make([]models.ProxyLocation, 0, len(reqs))
+	for _, r := range reqs {
+		locations = append(locations, r.ToProxyLocation())
+	}
+	return locations
+}
+
+// headerRulesFromRequest converts request header rules into models.
+func headerRulesFromRequest(reqs []models.ProxyHeaderRuleRequest) []models.ProxyHeaderRule {
+	if len(reqs) == 0 {
+		return nil
+	}
+	rules := make([]models.ProxyHeaderRule, 0, len(reqs))
+	for _, r := range reqs {
+		rules = append(rules, r.ToProxyHeaderRule())
+	}
+	return rules
 }
 
 // removeUnusedCertificateForDomain deletes the cert DB row and PEM files when no
@@ -506,8 +946,8 @@ func GetProxyCertificate(c *gin.Context) {
 		return
 	}
 
-	// Get certificate by domain (return if present regardless of flag)
-	certificate, err := dbService.GetCertificateByDomain(proxy.Domain)
+	// Get certificate by domain or alias (return if present regardless of flag)
+	certificate, err := dbService.GetCertificateForProxy(proxy)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Certificate not found for this domain"})
 		return
@@ -515,3 +955,177 @@ func GetProxyCertificate(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"data": certificate})
 }
+
+// ProbeProxyForwardAuth godoc
+// @Summary      Probe a proxy's forward auth service
+// @Description  Send an unauthenticated request for the proxy's domain to its forward auth service, the way nginx's auth_request does, and report the answer. A working service normally answers 401
+// @Tags         proxies
+// @Produce      json
+// @Param        id   path      int  true  "Proxy ID"
+// @Success      200  {object}  services.ForwardAuthProbe
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      502  {object}  map[string]string
+// @Router       /proxies/{id}/forward-auth/probe [post]
+func ProbeProxyForwardAuth(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid proxy ID"})
+		return
+	}
+
+	if dbService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database service not initialized"})
+		return
+	}
+
+	proxy, err := dbService.GetProxy(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Proxy not found"})
+		return
+	}
+	if !proxy.ForwardAuth.Enabled() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Forward auth is not configured for this proxy"})
+		return
+	}
+
+	scheme := "http"
+	if proxy.SSLEnabled {
+		scheme = "https"
+	}
+	probe, err := services.ProbeForwardAuth(proxy.ForwardAuth, scheme+"://"+proxy.Domain+"/")
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": probe})
+}
+
+// PurgeProxyCache godoc
+// @Summary      Purge a proxy's cache
+// @Description  Delete every cached response of the proxy. nginx fetches purged responses from the backend again on the next request
+// @Tags         proxies
+// @Produce      json
+// @Param        id   path      int  true  "Proxy ID"
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /proxies/{id}/cache/purge [post]
+func PurgeProxyCache(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid proxy ID"})
+		return
+	}
+
+	if dbService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database service not initialized"})
+		return
+	}
+
+	proxy, err := dbService.GetProxy(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Proxy not found"})
+		return
+	}
+
+	nginxService := getNginxService()
+	if nginxService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Nginx service not initialized"})
+		return
+	}
+	removed, err := nginxService.PurgeProxyCache(proxy.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to purge cache: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"proxy_id": proxy.ID, "removed": removed}})
+}
+
+// maxHealthHistory bounds the history returned by GetProxyHealth.
+const maxHealthHistory = 100
+
+// GetProxyHealth godoc
+// @Summary      Get proxy health
+// @Description  Get the health check settings, status and recent probe results of a proxy, newest first
+// @Tags         proxies
+// @Produce      json
+// @Param        id     path      int  true   "Proxy ID"
+// @Param        limit  query     int  false  "Number of results to return (default 50, max 100)"
+// @Success      200  {object}  models.ProxyHealth
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /proxies/{id}/health [get]
+func GetProxyHealth(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid proxy ID"})
+		return
+	}
+
+	limit := 50
+	if raw := c.Query("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxHealthHistory {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxHealthHistory)})
+			return
+		}
+	}
+
+	if dbService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database service not initialized"})
+		return
+	}
+
+	proxy, err := dbService.GetProxy(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Proxy not found"})
+		return
+	}
+
+	history, err := dbService.GetHealthCheckResults(proxy.ID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get health history: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": services.SummarizeProxyHealth(proxy, history)})
+}
+
+// refreshQuarantine copies the quarantine state an apply may have set onto
+// proxy, so responses show why its config was taken out of nginx.
+func refreshQuarantine(proxy *models.Proxy) {
+	saved, err := dbService.GetProxy(proxy.ID)
+	if err != nil {
+		return
+	}
+	proxy.Status = saved.Status
+	proxy.Quarantined = saved.Quarantined
+	proxy.LastError = saved.LastError
+}
+
+// dryRunRequested reports whether a create or update asks for a preview of
+// the nginx config instead of saving.
+func dryRunRequested(c *gin.Context) bool {
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+	return dryRun
+}
+
+// previewProxyConfig answers a dry run with the nginx config proxy would
+// get and its diff against the config on disk.
+func previewProxyConfig(c *gin.Context, proxy *models.Proxy) {
+	nginxService := getNginxService()
+	if nginxService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Nginx service not initialized"})
+		return
+	}
+
+	preview, err := nginxService.PreviewProxyConfig(proxy)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render nginx config: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": preview})
+}
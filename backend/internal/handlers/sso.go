@@ -0,0 +1,356 @@
+package handlers
+
+import (
+	"html/template"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"upm-backend/internal/auth"
+	"upm-backend/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ssoCookieName is the forward-auth session cookie set by the login page.
+const ssoCookieName = "upm_session"
+
+// ssoLoginTemplate is the login page shown to visitors of proxies that
+// require UPM login. It is served on the proxied host under /_upm/, so the
+// form posts to the relative "login" path.
+var ssoLoginTemplate = template.Must(template.New("sso-login").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Sign in - UPM</title>
+<style>
+body { font-family: system-ui, sans-serif; background: #f3f4f6; display: flex; align-items: center; justify-content: center; min-height: 100vh; margin: 0; }
+form { background: #fff; padding: 2rem; border-radius: 8px; box-shadow: 0 1px 3px rgba(0,0,0,.15); width: 100%; max-width: 320px; }
+h1 { font-size: 1.25rem; margin: 0 0 1rem; }
+input[type=password] { width: 100%; padding: .5rem; margin-bottom: 1rem; box-sizing: border-box; }
+button { width: 100%; padding: .5rem; background: #2563eb; color: #fff; border: 0; border-radius: 4px; cursor: pointer; }
+.error { color: #b91c1c; margin-bottom: 1rem; }
+.notice { color: #374151; margin-bottom: 1rem; }
+</style>
+</head>
+<body>
+<form method="post" action="login">
+<h1>Sign in to continue</h1>
+{{if .Error}}<div class="error">{{.Error}}</div>{{end}}
+{{if .Notice}}<div class="notice">{{.Notice}}</div>{{end}}
+<input type="hidden" name="rd" value="{{.Redirect}}">
+<input type="password" name="password" placeholder="Admin password" autocomplete="current-password" autofocus required>
+<button type="submit">Sign in</button>
+</form>
+</body>
+</html>
+`))
+
+type ssoLoginPage struct {
+	Redirect string
+	Error    string
+	Notice   string
+}
+
+// The login page is reachable on every proxied host, so a client that fails
+// the admin password ssoMaxFailures times within ssoFailureWindow is locked
+// out for ssoLockoutDuration.
+const (
+	ssoMaxFailures     = 5
+	ssoFailureWindow   = 15 * time.Minute
+	ssoLockoutDuration = 15 * time.Minute
+)
+
+// ssoAttempts are the recent failed logins of one client.
+type ssoAttempts struct {
+	failures    int
+	windowStart time.Time
+	lockedUntil time.Time
+}
+
+// loginThrottle tracks failed logins per client address.
+type loginThrottle struct {
+	mu      sync.Mutex
+	clients map[string]*ssoAttempts
+}
+
+var ssoThrottle = &loginThrottle{clients: make(map[string]*ssoAttempts)}
+
+// lockedFor returns how long client is still locked out.
+func (l *loginThrottle) lockedFor(client string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	attempts := l.clients[client]
+	if attempts == nil || !now.Before(attempts.lockedUntil) {
+		return 0
+	}
+	return attempts.lockedUntil.Sub(now)
+}
+
+// fail records a failed login of client and returns how long it is locked
+// out as a result.
+func (l *loginThrottle) fail(client string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prune(now)
+
+	attempts := l.clients[client]
+	if attempts == nil || now.Sub(attempts.windowStart) > ssoFailureWindow {
+		attempts = &ssoAttempts{windowStart: now}
+		l.clients[client] = attempts
+	}
+	attempts.failures++
+	if attempts.failures < ssoMaxFailures {
+		return 0
+	}
+	// Counting starts over once the lockout ends
+	attempts.failures = 0
+	attempts.lockedUntil = now.Add(ssoLockoutDuration)
+	attempts.windowStart = attempts.lockedUntil
+	return ssoLockoutDuration
+}
+
+// succeed forgets the failed logins of client.
+func (l *loginThrottle) succeed(client string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.clients, client)
+}
+
+// prune drops clients whose failures and lockout have expired, so the map
+// only grows with recent failures. Callers hold mu.
+func (l *loginThrottle) prune(now time.Time) {
+	for client, attempts := range l.clients {
+		if now.Sub(attempts.windowStart) > ssoFailureWindow && !now.Before(attempts.lockedUntil) {
+			delete(l.clients, client)
+		}
+	}
+}
+
+// ssoClientAddress identifies the client of a login for throttling. nginx
+// sets X-Real-IP to the peer address on /_upm/, so the header is used when
+// the request comes from a private or loopback address such as the nginx
+// container. X-Forwarded-For is not used: clients can prepend to it.
+func ssoClientAddress(c *gin.Context) string {
+	remote := c.RemoteIP()
+	if ip := net.ParseIP(remote); ip != nil && (ip.IsLoopback() || ip.IsPrivate()) {
+		if realIP := strings.TrimSpace(c.GetHeader("X-Real-IP")); realIP != "" {
+			return realIP
+		}
+	}
+	return remote
+}
+
+// VerifySession godoc
+// @Summary      Verify forward-auth session
+// @Description  Used by nginx auth_request for proxies that require UPM login. Returns 204 with X-UPM-User when the session cookie is valid, 401 otherwise
+// @Tags         auth
+// @Success      204
+// @Failure      401  {object}  map[string]string
+// @Router       /auth/verify [get]
+func VerifySession(c *gin.Context) {
+	token, err := c.Cookie(ssoCookieName)
+	if err != nil || token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login required"})
+		return
+	}
+
+	cfg := config.Load()
+	claims, err := auth.ValidateSSOToken(token, cfg.JWTSecret)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired session"})
+		return
+	}
+
+	c.Header("X-UPM-User", claims.Subject)
+	c.Status(http.StatusNoContent)
+}
+
+// SSOLoginPage godoc
+// @Summary      Forward-auth login page
+// @Description  HTML login page shown by proxies that require UPM login. The rd query parameter is the URL to return to
+// @Tags         auth
+// @Produce      html
+// @Param        rd   query     string  false  "URL to return to after login"
+// @Success      200  {string}  string
+// @Router       /auth/sso/login [get]
+func SSOLoginPage(c *gin.Context) {
+	renderSSOLogin(c, http.StatusOK, ssoLoginPage{Redirect: ssoRedirectParam(c)})
+}
+
+// SSOLogin godoc
+// @Summary      Forward-auth login
+// @Description  Check the admin password, set the session cookie and redirect back to the proxied app
+// @Tags         auth
+// @Accept       x-www-form-urlencoded
+// @Produce      html
+// @Param        password  formData  string  true   "Admin password"
+// @Param        rd        formData  string  false  "URL to return to after login"
+// @Success      302
+// @Failure      401  {string}  string
+// @Failure      429  {string}  string
+// @Router       /auth/sso/login [post]
+func SSOLogin(c *gin.Context) {
+	cfg := config.Load()
+	rd := c.PostForm("rd")
+
+	client := ssoClientAddress(c)
+	now := time.Now()
+	if wait := ssoThrottle.lockedFor(client, now); wait > 0 {
+		renderSSOLockout(c, rd, wait)
+		return
+	}
+	if !checkAdminPassword(c.PostForm("password"), cfg) {
+		if wait := ssoThrottle.fail(client, now); wait > 0 {
+			renderSSOLockout(c, rd, wait)
+			return
+		}
+		renderSSOLogin(c, http.StatusUnauthorized, ssoLoginPage{Redirect: rd, Error: "Invalid credentials"})
+		return
+	}
+	ssoThrottle.succeed(client)
+
+	token, err := auth.GenerateSSOToken(cfg.JWTSecret)
+	if err != nil {
+		renderSSOLogin(c, http.StatusInternalServerError, ssoLoginPage{Redirect: rd, Error: "Failed to create session"})
+		return
+	}
+
+	setSSOCookie(c, token, int(auth.SSOSessionDuration.Seconds()), cfg.SSOCookieDomain)
+	c.Redirect(http.StatusFound, ssoRedirectTarget(rd, c.Request.Host, cfg.SSOCookieDomain))
+}
+
+// SSOLogout godoc
+// @Summary      Forward-auth logout
+// @Description  Clear the forward-auth session cookie and show the login page
+// @Tags         auth
+// @Produce      html
+// @Success      200  {string}  string
+// @Router       /auth/sso/logout [get]
+func SSOLogout(c *gin.Context) {
+	cfg := config.Load()
+	setSSOCookie(c, "", -1, cfg.SSOCookieDomain)
+	renderSSOLogin(c, http.StatusOK, ssoLoginPage{Redirect: ssoRedirectParam(c), Notice: "You have been signed out."})
+}
+
+// ForwardAuthSignIn godoc
+// @Summary      Forward-auth sign-in redirect
+// @Description  Used by nginx when an external forward-auth service answers 401. Redirects to the proxy's sign-in URL with the X-Original-URL header encoded as rd
+// @Tags         auth
+// @Param        id   path      int  true  "Proxy ID"
+// @Success      302
+// @Failure      404  {object}  map[string]string
+// @Router       /auth/forward-auth/{id}/sign-in [get]
+func ForwardAuthSignIn(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid proxy ID"})
+		return
+	}
+
+	if dbService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database service not initialized"})
+		return
+	}
+
+	proxy, err := dbService.GetProxy(id)
+	if err != nil || !proxy.ForwardAuth.Enabled() || proxy.ForwardAuth.SignInURL == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Forward auth sign-in is not configured for this proxy"})
+		return
+	}
+
+	c.Redirect(http.StatusFound, proxy.ForwardAuth.SignInRedirect(c.GetHeader("X-Original-URL")))
+}
+
+func renderSSOLogin(c *gin.Context, status int, page ssoLoginPage) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Header("Cache-Control", "no-store")
+	c.Status(status)
+	if err := ssoLoginTemplate.Execute(c.Writer, page); err != nil {
+		c.Error(err)
+	}
+}
+
+// renderSSOLockout tells a locked out client when it may try again.
+func renderSSOLockout(c *gin.Context, rd string, wait time.Duration) {
+	retry := "Try again in " + strconv.Itoa(int((wait+time.Minute-1)/time.Minute)) + " minutes."
+	if wait <= time.Minute {
+		retry = "Try again in a minute."
+	}
+	c.Header("Retry-After", strconv.Itoa(int((wait+time.Second-1)/time.Second)))
+	renderSSOLogin(c, http.StatusTooManyRequests, ssoLoginPage{Redirect: rd, Error: "Too many failed sign-in attempts. " + retry})
+}
+
+// setSSOCookie writes the session cookie. An empty domain leaves the cookie
+// scoped to the requesting host; Secure follows the scheme nginx reports.
+func setSSOCookie(c *gin.Context, value string, maxAge int, domain string) {
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(ssoCookieName, value, maxAge, "/", domain, secure, true)
+}
+
+// checkAdminPassword authenticates against the admin user, with the same
+// development bypass as the API login.
+func checkAdminPassword(password string, cfg *config.Config) bool {
+	if password == "" || dbService == nil {
+		return false
+	}
+
+	adminUser, err := dbService.GetAdminUser()
+	if err != nil {
+		return cfg.DevMode && password == cfg.DevTestPassword
+	}
+	if !adminUser.IsActive {
+		return false
+	}
+	return auth.CheckPasswordHash(password, adminUser.Password)
+}
+
+// ssoRedirectParam reads rd from the query string. nginx appends the
+// original URL unencoded, so everything after "rd=" belongs to it.
+func ssoRedirectParam(c *gin.Context) string {
+	rd, ok := strings.CutPrefix(c.Request.URL.RawQuery, "rd=")
+	if !ok {
+		return c.Query("rd")
+	}
+	if !strings.Contains(rd, "://") {
+		if unescaped, err := url.QueryUnescape(rd); err == nil {
+			rd = unescaped
+		}
+	}
+	return rd
+}
+
+// ssoRedirectTarget returns rd when it is safe to send the browser there
+// after login: a local path, or an http(s) URL on the requesting host or a
+// host covered by the cookie domain. Anything else falls back to "/" so the
+// login page cannot be used as an open redirect.
+func ssoRedirectTarget(rd, requestHost, cookieDomain string) string {
+	if strings.HasPrefix(rd, "/") && !strings.HasPrefix(rd, "//") && !strings.HasPrefix(rd, "/\\") {
+		return rd
+	}
+
+	u, err := url.Parse(rd)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.User != nil {
+		return "/"
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if h, _, err := net.SplitHostPort(requestHost); err == nil {
+		requestHost = h
+	}
+	if host == strings.ToLower(requestHost) {
+		return rd
+	}
+
+	domain := strings.ToLower(strings.TrimPrefix(cookieDomain, "."))
+	if domain != "" && (host == domain || strings.HasSuffix(host, "."+domain)) {
+		return rd
+	}
+	return "/"
+}
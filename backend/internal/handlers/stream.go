@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"upm-backend/internal/models"
+	"upm-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetStreams godoc
+// @Summary      Get all stream proxies
+// @Description  Get a list of all TCP/UDP stream proxy configurations
+// @Tags         streams
+// @Accept       json
+// @Produce      json
+// @Success      200  {array}   models.StreamProxy
+// @Failure      500  {object}  map[string]string
+// @Router       /streams [get]
+func GetStreams(c *gin.Context) {
+	if dbService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database service not initialized"})
+		return
+	}
+
+	streams, err := dbService.GetStreamProxies()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stream proxies: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  streams,
+		"count": len(streams),
+	})
+}
+
+// GetStream godoc
+// @Summary      Get stream proxy by ID
+// @Description  Get a specific TCP/UDP stream proxy configuration by ID
+// @Tags         streams
+// @Accept       json
+// @Produce      json
+// @Param        id   path      int  true  "Stream proxy ID"
+// @Success      200  {object}  models.StreamProxy
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /streams/{id} [get]
+func GetStream(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stream proxy ID"})
+		return
+	}
+
+	if dbService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database service not initialized"})
+		return
+	}
+
+	stream, err := dbService.GetStreamProxy(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Stream proxy not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": stream})
+}
+
+// CreateStream godoc
+// @Summary      Create a new stream proxy
+// @Description  Create a new TCP/UDP stream proxy configuration
+// @Tags         streams
+// @Accept       json
+// @Produce      json
+// @Param        stream  body      models.StreamProxyCreateRequest  true  "Stream proxy data"
+// @Success      201     {object}  models.StreamProxy
+// @Failure      400     {object}  map[string]string
+// @Failure      409     {object}  map[string]string
+// @Failure      500     {object}  map[string]string
+// @Router       /streams [post]
+func CreateStream(c *gin.Context) {
+	var req models.StreamProxyCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	stream := &models.StreamProxy{
+		Name:            req.Name,
+		ListenPort:      req.ListenPort,
+		Protocol:        req.Protocol,
+		Targets:         req.Targets,
+		AllowedIPRanges: req.AllowedIPRanges,
+		ProxyTimeout:    models.DefaultStreamProxyTimeout,
+		Status:          "active",
+	}
+	if stream.Protocol == "" {
+		stream.Protocol = models.StreamProtocolTCP
+	}
+	if req.ProxyTimeout != nil {
+		stream.ProxyTimeout = *req.ProxyTimeout
+	}
+
+	if err := models.ValidateStreamProxy(stream, http3Enabled()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if dbService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database service not initialized"})
+		return
+	}
+
+	if err := checkStreamPortAvailable(stream); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !applyChange(c, func(db *services.DatabaseService) error {
+		if err := db.CreateStreamProxy(stream); err != nil {
+			return failApply(http.StatusInternalServerError, "Failed to create stream proxy: "+err.Error())
+		}
+		return nil
+	}, func(nginx *services.NginxService) error {
+		return nginx.GenerateStreamConfig(stream)
+	}) {
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": stream})
+}
+
+// UpdateStream godoc
+// @Summary      Update a stream proxy
+// @Description  Update an existing TCP/UDP stream proxy configuration
+// @Tags         streams
+// @Accept       json
+// @Produce      json
+// @Param        id      path      int                              true  "Stream proxy ID"
+// @Param        stream  body      models.StreamProxyUpdateRequest  true  "Stream proxy data"
+// @Success      200     {object}  models.StreamProxy
+// @Failure      400     {object}  map[string]string
+// @Failure      404     {object}  map[string]string
+// @Failure      409     {object}  map[string]string
+// @Failure      500     {object}  map[string]string
+// @Router       /streams/{id} [put]
+func UpdateStream(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stream proxy ID"})
+		return
+	}
+
+	var req models.StreamProxyUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if dbService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database service not initialized"})
+		return
+	}
+
+	stream, err := dbService.GetStreamProxy(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Stream proxy not found"})
+		return
+	}
+
+	// Update fields if provided
+	if req.Name != nil {
+		stream.Name = *req.Name
+	}
+	if req.ListenPort != nil {
+		stream.ListenPort = *req.ListenPort
+	}
+	if req.Protocol != nil {
+		stream.Protocol = *req.Protocol
+	}
+	if req.Targets != nil {
+		stream.Targets = *req.Targets
+	}
+	if req.AllowedIPRanges != nil {
+		stream.AllowedIPRanges = *req.AllowedIPRanges
+	}
+	if req.ProxyTimeout != nil {
+		stream.ProxyTimeout = *req.ProxyTimeout
+	}
+
+	if err := models.ValidateStreamProxy(stream, http3Enabled()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := checkStreamPortAvailable(stream); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !applyChange(c, func(db *services.DatabaseService) error {
+		if err := db.UpdateStreamProxy(stream); err != nil {
+			return failApply(http.StatusInternalServerError, "Failed to update stream proxy: "+err.Error())
+		}
+		return nil
+	}, func(nginx *services.NginxService) error {
+		return nginx.GenerateStreamConfig(stream)
+	}) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": stream})
+}
+
+// DeleteStream godoc
+// @Summary      Delete a stream proxy
+// @Description  Delete a TCP/UDP stream proxy configuration
+// @Tags         streams
+// @Accept       json
+// @Produce      json
+// @Param        id   path      int  true  "Stream proxy ID"
+// @Success      204  {object}  map[string]string
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /streams/{id} [delete]
+func DeleteStream(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stream proxy ID"})
+		return
+	}
+
+	if dbService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database service not initialized"})
+		return
+	}
+
+	if !applyChange(c, func(db *services.DatabaseService) error {
+		if err := db.DeleteStreamProxy(id); err != nil {
+			return failApply(http.StatusNotFound, "Failed to delete stream proxy: "+err.Error())
+		}
+		return nil
+	}, func(nginx *services.NginxService) error {
+		return nginx.RemoveStreamConfig(id)
+	}) {
+		return
+	}
+
+	c.JSON(http.StatusNoContent, gin.H{"message": "Stream proxy deleted successfully"})
+}
+
+// http3Enabled reports whether HTTP/3 is switched on globally.
+func http3Enabled() bool {
+	return nginxService != nil && nginxService.HTTP3Enabled
+}
+
+// checkHTTP3PortAvailable returns an error when a proxy opts into HTTP/3
+// while a UDP stream proxy listens on the port of the QUIC listeners.
+func checkHTTP3PortAvailable(proxy *models.Proxy) error {
+	if !proxy.HTTP3Enabled {
+		return nil
+	}
+	inUse, err := dbService.StreamPortInUse(models.HTTP3Port, models.StreamProtocolUDP, 0)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return fmt.Errorf("port %d/%s is used by a stream proxy, HTTP/3 needs it", models.HTTP3Port, models.StreamProtocolUDP)
+	}
+	return nil
+}
+
+// checkStreamPortAvailable returns an error when another stream proxy
+// already listens on the same port and protocol.
+func checkStreamPortAvailable(stream *models.StreamProxy) error {
+	inUse, err := dbService.StreamPortInUse(stream.ListenPort, stream.Protocol, stream.ID)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return fmt.Errorf("port %d/%s is already used by another stream proxy", stream.ListenPort, stream.Protocol)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+)
+
+// Actions of an access list entry.
+const (
+	AccessActionAllow = "allow"
+	AccessActionDeny  = "deny"
+)
+
+// AccessList is a named list of client addresses that are allowed or denied.
+// A list can be attached to whole proxies or to individual location rules;
+// nginx checks its entries in order and the first match wins. Lists with
+// any allow entry deny every other address, deny-only lists let every
+// other address through.
+type AccessList struct {
+	ID        int               `json:"id" db:"id"`
+	Name      string            `json:"name" db:"name"`
+	Entries   []AccessListEntry `json:"entries"`
+	CreatedAt time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at" db:"updated_at"`
+}
+
+// AccessListEntry allows or denies one IPv4 or IPv6 address or CIDR range.
+type AccessListEntry struct {
+	ID      int    `json:"id" db:"id"`
+	ListID  int    `json:"list_id" db:"list_id"`
+	Action  string `json:"action" db:"action"` // allow, deny
+	Address string `json:"address" db:"address"`
+}
+
+// AllowsOnly reports whether the list has an allow entry, in which case
+// addresses matching no entry are denied.
+func (l *AccessList) AllowsOnly() bool {
+	for _, e := range l.Entries {
+		if e.Action == AccessActionAllow {
+			return true
+		}
+	}
+	return false
+}
+
+type AccessListEntryRequest struct {
+	Action  string `json:"action,omitempty"` // defaults to allow
+	Address string `json:"address"`
+}
+
+// ToAccessListEntry converts a request entry into the stored representation.
+func (r AccessListEntryRequest) ToAccessListEntry() AccessListEntry {
+	action := r.Action
+	if action == "" {
+		action = AccessActionAllow
+	}
+	return AccessListEntry{
+		Action:  action,
+		Address: r.Address,
+	}
+}
+
+type AccessListCreateRequest struct {
+	Name    string                   `json:"name" binding:"required"`
+	Entries []AccessListEntryRequest `json:"entries" binding:"required"`
+}
+
+type AccessListUpdateRequest struct {
+	Name *string `json:"name,omitempty"`
+	// Entries replaces every entry when present.
+	Entries *[]AccessListEntryRequest `json:"entries,omitempty"`
+}
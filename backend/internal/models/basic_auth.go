@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+)
+
+// BasicAuthSet is a named list of HTTP basic authentication credentials.
+// A set can be attached to whole proxies or to individual location rules;
+// nginx checks it against a generated htpasswd file.
+type BasicAuthSet struct {
+	ID        int             `json:"id" db:"id"`
+	Name      string          `json:"name" db:"name"`
+	Users     []BasicAuthUser `json:"users"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// BasicAuthUser is one credential in a set. Only the bcrypt hash is stored.
+type BasicAuthUser struct {
+	ID           int       `json:"id" db:"id"`
+	SetID        int       `json:"set_id" db:"set_id"`
+	Username     string    `json:"username" db:"username"`
+	PasswordHash string    `json:"-" db:"password_hash"` // Hidden from JSON
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type BasicAuthCredentialRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type BasicAuthSetCreateRequest struct {
+	Name  string                       `json:"name" binding:"required"`
+	Users []BasicAuthCredentialRequest `json:"users" binding:"required"`
+}
+
+type BasicAuthSetUpdateRequest struct {
+	Name *string `json:"name,omitempty"`
+}
+
+// BasicAuthPasswordRequest sets or rotates the password of one user.
+type BasicAuthPasswordRequest struct {
+	Password string `json:"password" binding:"required"`
+}
@@ -0,0 +1,93 @@
+package models
+
+// Defaults applied to a proxy's cache policy for any setting left at zero.
+const (
+	DefaultCacheZoneSizeMB      = 10
+	DefaultCacheMaxSizeMB       = 1024
+	DefaultCacheInactiveMinutes = 60
+)
+
+// DefaultCacheKey is nginx's usual cache key with $host in place of
+// $proxy_host, so aliases of one proxy share cached responses only when
+// they are canonical-redirected.
+var DefaultCacheKey = []string{"scheme", "host", "request_uri"}
+
+// DefaultCacheValid caches successful responses and redirects for ten
+// minutes and not-found answers for one.
+var DefaultCacheValid = []CacheValidity{
+	{Status: "200", TTL: 600},
+	{Status: "301", TTL: 600},
+	{Status: "302", TTL: 600},
+	{Status: "404", TTL: 60},
+}
+
+// CachePolicy configures the nginx proxy_cache zone of a proxy. The zone is
+// declared whenever the proxy or one of its location rules has caching
+// enabled; unset fields fall back to the defaults above.
+type CachePolicy struct {
+	ZoneSizeMB      int             `json:"zone_size_mb,omitempty"`     // keys_zone size
+	MaxSizeMB       int             `json:"max_size_mb,omitempty"`      // disk limit of the cache directory
+	InactiveMinutes int             `json:"inactive_minutes,omitempty"` // unused entries are evicted after this
+	Valid           []CacheValidity `json:"valid,omitempty"`            // TTLs per response status
+	// Key lists the parts of the cache key: scheme, method, host, uri,
+	// args, request_uri, or cookie:NAME, header:NAME and arg:NAME.
+	Key []string `json:"key,omitempty"`
+	// Requests carrying any of these cookies, headers or query arguments
+	// skip the cache and are not stored.
+	BypassCookies []string `json:"bypass_cookies,omitempty"`
+	BypassHeaders []string `json:"bypass_headers,omitempty"`
+	BypassArgs    []string `json:"bypass_args,omitempty"`
+	// StatusHeader adds a Cache-Status response header (HIT, MISS, ...).
+	StatusHeader bool `json:"status_header"`
+}
+
+// CacheValidity caches responses with Status ("200", "404", ... or "any")
+// for TTL seconds.
+type CacheValidity struct {
+	Status string `json:"status"`
+	TTL    int    `json:"ttl"` // seconds
+}
+
+// ApplyDefaults fills unset fields with the package defaults.
+func (c *CachePolicy) ApplyDefaults() {
+	if c.ZoneSizeMB == 0 {
+		c.ZoneSizeMB = DefaultCacheZoneSizeMB
+	}
+	if c.MaxSizeMB == 0 {
+		c.MaxSizeMB = DefaultCacheMaxSizeMB
+	}
+	if c.InactiveMinutes == 0 {
+		c.InactiveMinutes = DefaultCacheInactiveMinutes
+	}
+	if len(c.Valid) == 0 {
+		c.Valid = append([]CacheValidity(nil), DefaultCacheValid...)
+	}
+	if len(c.Key) == 0 {
+		c.Key = append([]string(nil), DefaultCacheKey...)
+	}
+}
+
+// UsesCache reports whether the proxy or any of its location rules caches
+// responses, which is when its cache zone must exist.
+func (p *Proxy) UsesCache() bool {
+	if p.CacheEnabled {
+		return true
+	}
+	for _, loc := range p.Locations {
+		if loc.CacheEnabled {
+			return true
+		}
+	}
+	return false
+}
+
+// EffectiveCachePolicy returns the proxy's cache policy with defaults
+// applied.
+func (p *Proxy) EffectiveCachePolicy() CachePolicy {
+	var policy CachePolicy
+	if p.Cache != nil {
+		policy = *p.Cache
+	}
+	policy.ApplyDefaults()
+	return policy
+}
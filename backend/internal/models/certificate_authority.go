@@ -0,0 +1,111 @@
+package models
+
+import (
+	"time"
+)
+
+// Certificate sources: how a certificate was obtained and therefore how it
+// is renewed. Manual certificates are renewed outside UPM.
+const (
+	CertificateSourceLetsEncrypt = "letsencrypt"
+	CertificateSourceManual      = "manual"
+	CertificateSourceInternal    = "internal"
+)
+
+// Certificate usages. Server certificates are served by proxies for their
+// domain; client certificates authenticate users to mutual TLS proxies.
+const (
+	CertificateUsageServer = "server"
+	CertificateUsageClient = "client"
+)
+
+// Default validity of internal CAs and certificates, in days.
+const (
+	DefaultRootCAValidityDays         = 10 * 365
+	DefaultIntermediateCAValidityDays = 5 * 365
+	DefaultCertificateValidityDays    = 90
+)
+
+// CertificateAuthority is a CA run by UPM to issue certificates for
+// internal hostnames that cannot pass ACME challenges and for mutual TLS
+// clients. A root has no parent; an intermediate is signed by its parent.
+type CertificateAuthority struct {
+	ID          int       `json:"id" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	ParentID    int       `json:"parent_id,omitempty" db:"parent_id"`
+	CommonName  string    `json:"common_name" db:"common_name"`
+	Certificate string    `json:"certificate" db:"cert_pem"` // PEM; clients and browsers trust the root's
+	PrivateKey  string    `json:"-" db:"key_pem"`            // PEM; encrypted at rest
+	Imported    bool      `json:"imported" db:"imported"`
+	ExpiresAt   time.Time `json:"expires_at" db:"expires_at"`
+	CRLNumber   int64     `json:"crl_number" db:"crl_number"` // number of the last CRL generated
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// IsRoot reports whether the CA is self-signed.
+func (a *CertificateAuthority) IsRoot() bool {
+	return a.ParentID == 0
+}
+
+// CertificateRevocation puts a certificate on its CA's CRL until the
+// certificate expires. Reason is an RFC 5280 CRL reason code.
+type CertificateRevocation struct {
+	ID           int       `json:"id" db:"id"`
+	CAID         int       `json:"ca_id" db:"ca_id"`
+	SerialNumber string    `json:"serial_number" db:"serial_number"` // hex
+	Reason       int       `json:"reason" db:"reason"`
+	RevokedAt    time.Time `json:"revoked_at" db:"revoked_at"`
+	ExpiresAt    time.Time `json:"expires_at" db:"expires_at"`
+}
+
+// CertificateAuthorityCreateRequest generates a new CA key and certificate.
+type CertificateAuthorityCreateRequest struct {
+	Name         string `json:"name" binding:"required"`
+	CommonName   string `json:"common_name,omitempty"`   // defaults to the name
+	ParentID     int    `json:"parent_id,omitempty"`     // CA signing an intermediate; 0 creates a root
+	ValidityDays int    `json:"validity_days,omitempty"` // defaults to 10 years for roots and 5 for intermediates
+}
+
+// CertificateAuthorityImportRequest imports an existing CA certificate and
+// its private key.
+type CertificateAuthorityImportRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Certificate string `json:"certificate" binding:"required"` // PEM
+	PrivateKey  string `json:"private_key" binding:"required"` // PEM, unencrypted
+	ParentID    int    `json:"parent_id,omitempty"`            // CA that signed an intermediate
+}
+
+// InternalCertificateRequest asks an internal CA for a certificate.
+type InternalCertificateRequest struct {
+	Usage        string   `json:"usage,omitempty"`         // server (default), client
+	CommonName   string   `json:"common_name"`             // domain of a server certificate, user of a client certificate
+	DNSNames     []string `json:"dns_names,omitempty"`     // further names of a server certificate
+	IPAddresses  []string `json:"ip_addresses,omitempty"`  // addresses of a server certificate
+	ValidityDays int      `json:"validity_days,omitempty"` // defaults to 90 days
+}
+
+// IssuedCertificate is the result of issuing an internal certificate. UPM
+// does not keep the private key of a client certificate; it is only
+// returned here.
+type IssuedCertificate struct {
+	Certificate    *Certificate `json:"certificate"`
+	CertificatePEM string       `json:"certificate_pem"`
+	ChainPEM       string       `json:"chain_pem"` // issuing CA and its parents, up to the root
+	PrivateKeyPEM  string       `json:"private_key_pem,omitempty"`
+}
+
+// CertificateRevocationReasons maps the revocation reasons accepted by the
+// API to their RFC 5280 CRL reason codes.
+var CertificateRevocationReasons = map[string]int{
+	"unspecified":            0,
+	"key_compromise":         1,
+	"affiliation_changed":    3,
+	"superseded":             4,
+	"cessation_of_operation": 5,
+}
+
+// CertificateRevokeRequest revokes an internal certificate.
+type CertificateRevokeRequest struct {
+	Reason string `json:"reason,omitempty"` // one of CertificateRevocationReasons, defaults to unspecified
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Kinds of config drift.
+const (
+	DriftMissing  = "missing"  // UPM renders the file but it is not on disk
+	DriftOrphaned = "orphaned" // a generated file on disk that nothing in the database renders
+	DriftModified = "modified" // the file on disk differs from what UPM renders
+)
+
+// ConfigDrift is one generated nginx file whose state on disk differs from
+// what the database renders.
+type ConfigDrift struct {
+	Path string `json:"path"`
+	Kind string `json:"kind"`
+	// Diff is a unified diff from the file on disk to the rendered one
+	Diff string `json:"diff,omitempty"`
+}
+
+// DriftReport compares the generated nginx files on disk with the database.
+type DriftReport struct {
+	CheckedAt time.Time     `json:"checked_at"`
+	InSync    bool          `json:"in_sync"`
+	Files     []ConfigDrift `json:"files"`
+}
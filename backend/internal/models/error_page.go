@@ -0,0 +1,47 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// MaxErrorPageSize bounds the HTML of a custom error page.
+const MaxErrorPageSize = 256 * 1024
+
+// ErrorPage is a custom HTML page nginx serves in place of its stock page
+// for one 4xx or 5xx status code. Global pages (ProxyID 0) apply to every
+// proxy; a proxy's own page for the same code takes precedence.
+type ErrorPage struct {
+	ID         int       `json:"id" db:"id"`
+	ProxyID    int       `json:"proxy_id" db:"proxy_id"` // 0 for a global page
+	StatusCode int       `json:"status_code" db:"status_code"`
+	Content    string    `json:"content" db:"content"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type ErrorPageRequest struct {
+	Content string `json:"content"`
+}
+
+// MergeErrorPages returns the error pages in effect for a proxy: its own
+// pages plus the global pages for codes it does not override, ordered by
+// status code.
+func MergeErrorPages(global, own []ErrorPage) []ErrorPage {
+	byCode := make(map[int]ErrorPage)
+	for _, page := range global {
+		byCode[page.StatusCode] = page
+	}
+	for _, page := range own {
+		byCode[page.StatusCode] = page
+	}
+
+	merged := make([]ErrorPage, 0, len(byCode))
+	for _, page := range byCode {
+		merged = append(merged, page)
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].StatusCode < merged[j].StatusCode
+	})
+	return merged
+}
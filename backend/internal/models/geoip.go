@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+)
+
+// GeoIPRule allows or denies clients by the country their address maps to
+// in the local GeoIP database. An allow rule denies every other country,
+// including addresses the database does not know; a deny rule lets them in.
+type GeoIPRule struct {
+	Action    string   `json:"action"`    // allow, deny
+	Countries []string `json:"countries"` // ISO 3166-1 alpha-2 codes, e.g. DE
+}
+
+// Enabled reports whether the rule restricts anything.
+func (g *GeoIPRule) Enabled() bool {
+	return g != nil && g.Action != ""
+}
+
+// GeoIPLookup is the country an address maps to in the GeoIP database.
+type GeoIPLookup struct {
+	IP          string    `json:"ip"`
+	Country     string    `json:"country,omitempty"` // empty when the database has no country for the address
+	CountryName string    `json:"country_name,omitempty"`
+	Network     string    `json:"network,omitempty"` // network of the database record that matched
+	Database    string    `json:"database"`          // database type, e.g. GeoLite2-Country
+	BuildDate   time.Time `json:"build_date"`
+}
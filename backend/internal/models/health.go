@@ -0,0 +1,105 @@
+package models
+
+import "time"
+
+// Defaults applied to a proxy's health check for any setting left at zero.
+const (
+	DefaultHealthCheckPath               = "/"
+	DefaultHealthCheckIntervalSeconds    = 30
+	DefaultHealthCheckTimeoutSeconds     = 5
+	DefaultHealthCheckUnhealthyThreshold = 3
+)
+
+// HealthCheck configures the active probe of a proxy's target. Proxies
+// without a stored health check are probed with the defaults above.
+type HealthCheck struct {
+	Enabled bool   `json:"enabled"`
+	Path    string `json:"path"`
+	// ExpectedStatus is the status code the target must answer with; zero
+	// accepts any status below 500, i.e. the backend is up and not failing.
+	ExpectedStatus  int `json:"expected_status,omitempty"`
+	IntervalSeconds int `json:"interval_seconds"`
+	TimeoutSeconds  int `json:"timeout_seconds"`
+	// UnhealthyThreshold is the number of consecutive failed probes after
+	// which the proxy's status becomes "error".
+	UnhealthyThreshold int `json:"unhealthy_threshold"`
+}
+
+// HealthCheckRequest is the API form of a health check. Enabled defaults to
+// true and zero values fall back to the defaults.
+type HealthCheckRequest struct {
+	Enabled            *bool  `json:"enabled,omitempty"`
+	Path               string `json:"path,omitempty"`
+	ExpectedStatus     int    `json:"expected_status,omitempty"`
+	IntervalSeconds    int    `json:"interval_seconds,omitempty"`
+	TimeoutSeconds     int    `json:"timeout_seconds,omitempty"`
+	UnhealthyThreshold int    `json:"unhealthy_threshold,omitempty"`
+}
+
+// ToHealthCheck converts a request into a health check with defaults applied.
+func (r HealthCheckRequest) ToHealthCheck() HealthCheck {
+	check := HealthCheck{
+		Enabled:            true,
+		Path:               r.Path,
+		ExpectedStatus:     r.ExpectedStatus,
+		IntervalSeconds:    r.IntervalSeconds,
+		TimeoutSeconds:     r.TimeoutSeconds,
+		UnhealthyThreshold: r.UnhealthyThreshold,
+	}
+	if r.Enabled != nil {
+		check.Enabled = *r.Enabled
+	}
+	check.ApplyDefaults()
+	return check
+}
+
+// ApplyDefaults fills unset fields with the package defaults.
+func (h *HealthCheck) ApplyDefaults() {
+	if h.Path == "" {
+		h.Path = DefaultHealthCheckPath
+	}
+	if h.IntervalSeconds == 0 {
+		h.IntervalSeconds = DefaultHealthCheckIntervalSeconds
+	}
+	if h.TimeoutSeconds == 0 {
+		h.TimeoutSeconds = DefaultHealthCheckTimeoutSeconds
+	}
+	if h.UnhealthyThreshold == 0 {
+		h.UnhealthyThreshold = DefaultHealthCheckUnhealthyThreshold
+	}
+}
+
+// EffectiveHealthCheck returns the proxy's health check with defaults
+// applied.
+func (p *Proxy) EffectiveHealthCheck() HealthCheck {
+	check := HealthCheck{Enabled: true}
+	if p.HealthCheck != nil {
+		check = *p.HealthCheck
+	}
+	check.ApplyDefaults()
+	return check
+}
+
+// HealthCheckResult is the outcome of one probe of a proxy's target.
+type HealthCheckResult struct {
+	ID         int       `json:"id" db:"id"`
+	ProxyID    int       `json:"proxy_id" db:"proxy_id"`
+	Healthy    bool      `json:"healthy" db:"healthy"`
+	StatusCode int       `json:"status_code,omitempty" db:"status_code"` // zero for TCP probes and connection errors
+	LatencyMS  int64     `json:"latency_ms" db:"latency_ms"`
+	Error      string    `json:"error,omitempty" db:"error"`
+	CheckedAt  time.Time `json:"checked_at" db:"checked_at"`
+}
+
+// ProxyHealth is the health summary of a proxy with its recent probes,
+// newest first.
+type ProxyHealth struct {
+	ProxyID             int                 `json:"proxy_id"`
+	Status              string              `json:"status"`
+	Check               HealthCheck         `json:"check"`
+	ConsecutiveFailures int                 `json:"consecutive_failures"`
+	UptimePercent       float64             `json:"uptime_percent"` // share of healthy probes in History
+	AvgLatencyMS        int64               `json:"avg_latency_ms"` // over the healthy probes in History
+	LastCheckedAt       *time.Time          `json:"last_checked_at,omitempty"`
+	History             []HealthCheckResult `json:"history"`
+}
@@ -0,0 +1,72 @@
+package models
+
+import "strings"
+
+// Defaults applied to redirect and static hosts for any setting left unset.
+const (
+	DefaultRedirectStatusCode = 301
+	DefaultStaticIndex        = "index.html"
+)
+
+// RedirectHost configures a redirect host, which answers every request with
+// a redirect to the proxy's TargetURL.
+type RedirectHost struct {
+	StatusCode int `json:"status_code"` // 301, 302, 307 or 308
+	// PreservePath appends the request path to the destination, and
+	// PreserveQuery the query string.
+	PreservePath  bool `json:"preserve_path"`
+	PreserveQuery bool `json:"preserve_query"`
+}
+
+// ApplyDefaults fills unset fields with the package defaults.
+func (r *RedirectHost) ApplyDefaults() {
+	if r.StatusCode == 0 {
+		r.StatusCode = DefaultRedirectStatusCode
+	}
+}
+
+// StaticHost configures a static host, which serves files from a directory
+// under the static sites root shared with nginx.
+type StaticHost struct {
+	Root  string `json:"root"`  // directory relative to the static sites root
+	Index string `json:"index"` // index file of directories
+	// SPAFallback serves the index file for paths that match no file, so
+	// client-side routes of single-page apps load.
+	SPAFallback bool `json:"spa_fallback"`
+}
+
+// ApplyDefaults fills unset fields with the package defaults. The root
+// defaults to a directory named after the domain.
+func (s *StaticHost) ApplyDefaults(domain string) {
+	if s.Root == "" {
+		s.Root = strings.ToLower(domain)
+	}
+	if s.Index == "" {
+		s.Index = DefaultStaticIndex
+	}
+}
+
+// ApplyHostTypeDefaults creates the settings of the proxy's host type when
+// they are missing, fills their defaults and drops those of other types.
+func (p *Proxy) ApplyHostTypeDefaults() {
+	p.Type = p.HostType()
+	switch p.Type {
+	case ProxyTypeRedirect:
+		if p.Redirect == nil {
+			p.Redirect = &RedirectHost{}
+		}
+		p.Redirect.ApplyDefaults()
+		p.Static = nil
+	case ProxyTypeStatic:
+		if p.Static == nil {
+			p.Static = &StaticHost{}
+		}
+		p.Static.ApplyDefaults(p.Domain)
+		p.Redirect = nil
+		// Static hosts have no target
+		p.TargetURL = ""
+	default:
+		p.Redirect = nil
+		p.Static = nil
+	}
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// DefaultMaintenanceRetryAfter is the Retry-After value, in seconds, sent
+// with the maintenance page when none is configured.
+const DefaultMaintenanceRetryAfter = 300
+
+// Maintenance puts a proxy into maintenance mode: nginx answers every
+// request with a 503 maintenance page, except for clients in AllowedIPs,
+// which still reach the upstream.
+type Maintenance struct {
+	Enabled           bool     `json:"enabled"`             // the maintenance page is being served
+	Page              string   `json:"page,omitempty"`      // HTML; empty serves the built-in page
+	RetryAfterSeconds int      `json:"retry_after_seconds"` // Retry-After header of the 503
+	AllowedIPs        []string `json:"allowed_ips,omitempty"`
+	// StartsAt and EndsAt schedule a maintenance window. With StartsAt set,
+	// Enabled follows the window; EndsAt alone ends manual maintenance.
+	StartsAt *time.Time `json:"starts_at,omitempty"`
+	EndsAt   *time.Time `json:"ends_at,omitempty"`
+}
+
+// ApplyDefaults fills unset fields with the package defaults and derives
+// Enabled from a scheduled window at now.
+func (m *Maintenance) ApplyDefaults(now time.Time) {
+	if m.RetryAfterSeconds == 0 {
+		m.RetryAfterSeconds = DefaultMaintenanceRetryAfter
+	}
+	if m.StartsAt != nil {
+		m.Enabled = !m.StartsAt.After(now) && (m.EndsAt == nil || m.EndsAt.After(now))
+	}
+}
+
+// InMaintenance reports whether nginx serves the maintenance page for the
+// proxy.
+func (p *Proxy) InMaintenance() bool {
+	return p.Maintenance != nil && p.Maintenance.Enabled && !p.IsPassthrough()
+}
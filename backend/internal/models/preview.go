@@ -0,0 +1,13 @@
+package models
+
+// ProxyConfigPreview is the nginx config a proxy change would produce,
+// rendered without saving the change.
+type ProxyConfigPreview struct {
+	// Path is the config in sites-enabled the change would replace; empty
+	// for a new proxy
+	Path       string `json:"path,omitempty"`
+	Config     string `json:"config"`
+	Changed    bool   `json:"changed"`
+	Diff       string `json:"diff,omitempty"` // unified diff from the file on disk to Config
+	SSLEnabled bool   `json:"ssl_enabled"`    // whether the config serves HTTPS with the certificates on disk
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"net/url"
+	"strings"
 	"time"
 )
 
@@ -8,6 +10,66 @@ import (
 // when rate limiting is enabled and no explicit rate is provided.
 const DefaultRateLimitRPS = 15
 
+// Load-balancing methods for a proxy's upstream pool. An empty method leaves
+// nginx on its default weighted round-robin.
+const (
+	LoadBalanceRoundRobin = "round_robin"
+	LoadBalanceLeastConn  = "least_conn"
+	LoadBalanceIPHash     = "ip_hash"
+)
+
+// SSL modes for a proxy. Terminate is the default: nginx holds the
+// certificate and proxies HTTP. Passthrough routes the raw TLS connection to
+// the backend by SNI hostname without decrypting it.
+const (
+	SSLModeTerminate   = "terminate"
+	SSLModePassthrough = "passthrough"
+)
+
+// Client certificate modes of mutual TLS, as in nginx ssl_verify_client.
+// On refuses clients without a certificate signed by the proxy's CA bundle;
+// optional also lets clients without a certificate in and leaves the
+// decision to the upstream.
+const (
+	ClientAuthModeOn       = "on"
+	ClientAuthModeOptional = "optional"
+)
+
+// DefaultClientAuthVerifyDepth is nginx's ssl_verify_depth default: client
+// certificates are signed directly by a CA in the bundle.
+const DefaultClientAuthVerifyDepth = 1
+
+// Host types. A proxy host forwards requests to TargetURL, a redirect host
+// answers every request with a redirect to TargetURL and a static host serves
+// files from a directory. An empty type is a proxy host.
+const (
+	ProxyTypeProxy    = "proxy"
+	ProxyTypeRedirect = "redirect"
+	ProxyTypeStatic   = "static"
+)
+
+// Header rule directions: request rules change what nginx sends to the
+// upstream, response rules change what it sends to the client.
+const (
+	HeaderDirectionRequest  = "request"
+	HeaderDirectionResponse = "response"
+)
+
+// Header rule actions. Set adds a header or overrides the existing value;
+// remove drops it.
+const (
+	HeaderActionSet    = "set"
+	HeaderActionRemove = "remove"
+)
+
+// Match types for a proxy location rule, mapping to nginx's prefix, "=" and
+// "~" location modifiers.
+const (
+	LocationMatchPrefix = "prefix"
+	LocationMatchExact  = "exact"
+	LocationMatchRegex  = "regex"
+)
+
 type Proxy struct {
 	ID               int       `json:"id" db:"id"`
 	Name             string    `json:"name" db:"name"`
@@ -21,16 +83,344 @@ type Proxy struct {
 	Status           string    `json:"status" db:"status"` // active, inactive, error
 	CreatedAt        time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt        time.Time `json:"updated_at" db:"updated_at"`
+
+	// Quarantined is set when nginx rejected the proxy's config: the file
+	// was taken out of sites-enabled so the other proxies keep working,
+	// Status is error and LastError holds nginx's message. It is cleared
+	// once a regenerated config passes validation. Health checks leave the
+	// status of a quarantined proxy alone.
+	Quarantined bool   `json:"quarantined" db:"quarantined"`
+	LastError   string `json:"last_error,omitempty" db:"last_error"`
+
+	// Type selects what the host does with requests; Redirect and Static
+	// hold the settings of redirect and static hosts.
+	Type     string        `json:"type" db:"type"` // proxy, redirect, static
+	Redirect *RedirectHost `json:"redirect,omitempty"`
+	Static   *StaticHost   `json:"static,omitempty"`
+
+	// LoadBalanceMethod and UpstreamServers describe an optional upstream
+	// pool. When UpstreamServers is non-empty it replaces TargetURL in
+	// proxy_pass; TargetURL is kept pointing at the first primary member.
+	LoadBalanceMethod string           `json:"load_balance_method,omitempty" db:"load_balance_method"`
+	UpstreamServers   []UpstreamServer `json:"upstream_servers,omitempty"`
+
+	// Aliases are extra server names (exact or "*.example.com" wildcards)
+	// served alongside Domain. With CanonicalRedirect set, requests for an
+	// alias are redirected to Domain.
+	Aliases           []string `json:"aliases,omitempty"`
+	CanonicalRedirect bool     `json:"canonical_redirect" db:"canonical_redirect"`
+
+	// Locations route matching request paths to other targets; everything
+	// else falls through to location / and TargetURL (or the pool).
+	Locations []ProxyLocation `json:"locations,omitempty"`
+
+	// SSLMode selects TLS termination (default) or SNI passthrough.
+	SSLMode string `json:"ssl_mode" db:"ssl_mode"` // terminate, passthrough
+
+	// BasicAuthSetID puts the whole host behind a basic auth credential
+	// set; 0 means no authentication.
+	BasicAuthSetID int `json:"basic_auth_set_id,omitempty" db:"basic_auth_set_id"`
+
+	// AccessListID restricts the whole host to the client addresses of an
+	// access list; 0 lets every address in.
+	AccessListID int `json:"access_list_id,omitempty" db:"access_list_id"`
+
+	// RequireUPMLogin gates the host behind the UPM login page using nginx
+	// auth_request against /api/v1/auth/verify.
+	RequireUPMLogin bool `json:"require_upm_login" db:"require_upm_login"`
+
+	// ForwardAuth delegates authentication to an external service; nil
+	// leaves the host open.
+	ForwardAuth *ForwardAuth `json:"forward_auth,omitempty"`
+
+	// GeoIP allows or denies client countries; nil lets every country in.
+	GeoIP *GeoIPRule `json:"geoip,omitempty"`
+
+	// ClientAuth verifies TLS client certificates on the HTTPS server
+	// (mutual TLS); nil accepts clients without one.
+	ClientAuth *ClientAuth `json:"client_auth,omitempty"`
+
+	// TLS selects the TLS profile, HSTS, OCSP stapling and session
+	// settings of the HTTPS server; nil uses the defaults.
+	TLS *TLSPolicy `json:"tls,omitempty"`
+
+	// HTTP3Enabled adds a QUIC listener and an Alt-Svc header to the HTTPS
+	// server. It only takes effect while HTTP/3 is enabled globally.
+	HTTP3Enabled bool `json:"http3_enabled" db:"http3_enabled"`
+
+	// HeaderRules add, override or remove request and response headers,
+	// including the built-in security headers such as X-Frame-Options.
+	HeaderRules []ProxyHeaderRule `json:"header_rules,omitempty"`
+
+	// CacheEnabled caches responses of the whole host (location /) using
+	// Cache, or the default policy when Cache is nil.
+	CacheEnabled bool         `json:"cache_enabled" db:"cache_enabled"`
+	Cache        *CachePolicy `json:"cache,omitempty"`
+
+	// RateLimit tunes the rate limiting enabled by RateLimitEnabled and by
+	// location rules; nil limits to RateLimitRPS per client address.
+	RateLimit *RateLimitPolicy `json:"rate_limit,omitempty"`
+
+	// HealthCheck configures the active probe that moves Status between
+	// "active" and "error"; nil probes with the defaults.
+	HealthCheck *HealthCheck `json:"health_check,omitempty"`
+
+	// Maintenance is managed through /proxies/:id/maintenance.
+	Maintenance *Maintenance `json:"maintenance,omitempty"`
+
+	// ErrorPages are the proxy's own custom error pages, managed through
+	// /proxies/:id/error-pages; global pages fill in the other codes.
+	ErrorPages []ErrorPage `json:"error_pages,omitempty"`
+}
+
+// HostType returns the proxy's type, treating an empty type as a proxy host.
+func (p *Proxy) HostType() string {
+	if p.Type == "" {
+		return ProxyTypeProxy
+	}
+	return p.Type
+}
+
+// IsPassthrough reports whether the proxy forwards TLS untouched by SNI.
+func (p *Proxy) IsPassthrough() bool {
+	return p.SSLMode == SSLModePassthrough
+}
+
+// ServerNames returns the primary domain followed by the proxy's aliases.
+func (p *Proxy) ServerNames() []string {
+	return append([]string{p.Domain}, p.Aliases...)
+}
+
+// MatchesServerName reports whether host is served by this proxy, either
+// as its domain, an exact alias or under a wildcard alias.
+func (p *Proxy) MatchesServerName(host string) bool {
+	for _, name := range p.ServerNames() {
+		if MatchServerName(name, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchServerName reports whether host matches an nginx server name. A
+// leading "*." matches any number of labels, as nginx does; comparison is
+// case-insensitive.
+func MatchServerName(pattern, host string) bool {
+	pattern = strings.ToLower(pattern)
+	host = strings.ToLower(host)
+	if pattern == host {
+		return true
+	}
+	if suffix, ok := strings.CutPrefix(pattern, "*"); ok && strings.HasPrefix(suffix, ".") {
+		return strings.HasSuffix(host, suffix) && len(host) > len(suffix)
+	}
+	return false
+}
+
+// ForwardAuth points a proxy at an external forward-auth service such as
+// Authelia, Authentik or oauth2-proxy. nginx checks every request against URL
+// with auth_request: a 2xx answer lets it through, 401 sends the visitor to
+// SignInURL through the UPM backend and 403 denies it.
+type ForwardAuth struct {
+	URL             string   `json:"url"`                        // verification endpoint, e.g. http://authelia:9091/api/verify
+	ResponseHeaders []string `json:"response_headers,omitempty"` // copied from the auth response to the upstream, e.g. Remote-User
+	SignInURL       string   `json:"signin_url,omitempty"`       // login page; the original URL is appended as rd
+}
+
+// Enabled reports whether forward auth is configured.
+func (f *ForwardAuth) Enabled() bool {
+	return f != nil && f.URL != ""
+}
+
+// SignInRedirect returns SignInURL with returnURL encoded as its rd
+// parameter, so the ampersands and percent escapes of the original request
+// survive the round trip through the auth service.
+func (f *ForwardAuth) SignInRedirect(returnURL string) string {
+	sep := "?"
+	if strings.Contains(f.SignInURL, "?") {
+		sep = "&"
+	}
+	return f.SignInURL + sep + "rd=" + url.QueryEscape(returnURL)
+}
+
+// ClientAuth verifies TLS client certificates against a bundle of CA
+// certificates. Only the HTTPS server can ask for certificates, so with
+// mode on the HTTP server refuses every request but ACME challenges until
+// SSL is enabled.
+//
+// Instead of a bundle, CAID can name an internal certificate authority:
+// its chain is trusted and certificates it revoked are refused.
+type ClientAuth struct {
+	Mode        string `json:"mode"`                   // on, optional
+	CABundle    string `json:"ca_bundle,omitempty"`    // PEM certificates of the CAs that sign client certificates
+	CAID        int    `json:"ca_id,omitempty"`        // internal CA signing client certificates, instead of CABundle
+	VerifyDepth int    `json:"verify_depth,omitempty"` // longest chain to a CA in the bundle, defaults to 1
+	DNHeader    string `json:"dn_header,omitempty"`    // request header carrying the client's subject DN, e.g. X-Client-DN
+}
+
+// Enabled reports whether client certificates are verified.
+func (c *ClientAuth) Enabled() bool {
+	return c != nil && c.Mode != ""
+}
+
+// UpstreamServer is one member of a proxy's load-balanced upstream pool.
+// Zero values for Weight, MaxFails and FailTimeout leave the nginx defaults.
+type UpstreamServer struct {
+	ID          int       `json:"id" db:"id"`
+	ProxyID     int       `json:"proxy_id" db:"proxy_id"`
+	URL         string    `json:"url" db:"url"`
+	Weight      int       `json:"weight" db:"weight"`
+	MaxFails    int       `json:"max_fails" db:"max_fails"`
+	FailTimeout int       `json:"fail_timeout" db:"fail_timeout"` // seconds
+	Backup      bool      `json:"backup" db:"backup"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+type UpstreamServerRequest struct {
+	URL         string `json:"url"`
+	Weight      int    `json:"weight,omitempty"`
+	MaxFails    int    `json:"max_fails,omitempty"`
+	FailTimeout int    `json:"fail_timeout,omitempty"` // seconds
+	Backup      bool   `json:"backup,omitempty"`
+}
+
+// ToUpstreamServer converts a request member into the stored representation.
+func (r UpstreamServerRequest) ToUpstreamServer() UpstreamServer {
+	return UpstreamServer{
+		URL:         r.URL,
+		Weight:      r.Weight,
+		MaxFails:    r.MaxFails,
+		FailTimeout: r.FailTimeout,
+		Backup:      r.Backup,
+	}
+}
+
+// ProxyLocation is a path-based routing rule inside a proxy host.
+type ProxyLocation struct {
+	ID               int       `json:"id" db:"id"`
+	ProxyID          int       `json:"proxy_id" db:"proxy_id"`
+	Path             string    `json:"path" db:"path"`
+	MatchType        string    `json:"match_type" db:"match_type"` // prefix, exact, regex
+	TargetURL        string    `json:"target_url" db:"target_url"`
+	StripPrefix      bool      `json:"strip_prefix" db:"strip_prefix"` // prefix rules only
+	Rewrite          string    `json:"rewrite,omitempty" db:"rewrite"` // replacement for the matched path
+	WSEnabled        bool      `json:"ws_enabled" db:"ws_enabled"`
+	RateLimitEnabled bool      `json:"rate_limit_enabled" db:"rate_limit_enabled"`
+	RateLimitRate    int       `json:"rate_limit_rate,omitempty" db:"rate_limit_rate"`     // own limit, per the proxy policy's unit; 0 shares the proxy's
+	RateLimitBurst   int       `json:"rate_limit_burst,omitempty" db:"rate_limit_burst"`   // own burst, defaults to twice the rate
+	BasicAuthSetID   int       `json:"basic_auth_set_id,omitempty" db:"basic_auth_set_id"` // overrides the proxy's set
+	AccessListID     int       `json:"access_list_id,omitempty" db:"access_list_id"`       // overrides the proxy's list
+	CacheEnabled     bool      `json:"cache_enabled" db:"cache_enabled"`                   // uses the proxy's cache policy
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+}
+
+type ProxyLocationRequest struct {
+	Path             string `json:"path"`
+	MatchType        string `json:"match_type,omitempty"` // defaults to prefix
+	TargetURL        string `json:"target_url"`
+	StripPrefix      bool   `json:"strip_prefix,omitempty"`
+	Rewrite          string `json:"rewrite,omitempty"`
+	WSEnabled        bool   `json:"ws_enabled,omitempty"`
+	RateLimitEnabled bool   `json:"rate_limit_enabled,omitempty"`
+	RateLimitRate    int    `json:"rate_limit_rate,omitempty"`
+	RateLimitBurst   int    `json:"rate_limit_burst,omitempty"`
+	BasicAuthSetID   int    `json:"basic_auth_set_id,omitempty"`
+	AccessListID     int    `json:"access_list_id,omitempty"`
+	CacheEnabled     bool   `json:"cache_enabled,omitempty"`
+}
+
+// ToProxyLocation converts a request rule into the stored representation.
+func (r ProxyLocationRequest) ToProxyLocation() ProxyLocation {
+	matchType := r.MatchType
+	if matchType == "" {
+		matchType = LocationMatchPrefix
+	}
+	return ProxyLocation{
+		Path:             r.Path,
+		MatchType:        matchType,
+		TargetURL:        r.TargetURL,
+		StripPrefix:      r.StripPrefix,
+		Rewrite:          r.Rewrite,
+		WSEnabled:        r.WSEnabled,
+		RateLimitEnabled: r.RateLimitEnabled,
+		RateLimitRate:    r.RateLimitRate,
+		RateLimitBurst:   r.RateLimitBurst,
+		BasicAuthSetID:   r.BasicAuthSetID,
+		AccessListID:     r.AccessListID,
+		CacheEnabled:     r.CacheEnabled,
+	}
+}
+
+// ProxyHeaderRule sets or removes one header on requests to the upstream
+// or on responses to the client.
+type ProxyHeaderRule struct {
+	ID        int       `json:"id" db:"id"`
+	ProxyID   int       `json:"proxy_id" db:"proxy_id"`
+	Direction string    `json:"direction" db:"direction"` // request, response
+	Action    string    `json:"action" db:"action"`       // set, remove
+	Name      string    `json:"name" db:"name"`
+	Value     string    `json:"value,omitempty" db:"value"` // set only; nginx variables such as $remote_addr are expanded
+	Always    bool      `json:"always" db:"always"`         // response set only: also send on error responses
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+type ProxyHeaderRuleRequest struct {
+	Direction string `json:"direction"`
+	Action    string `json:"action,omitempty"` // defaults to set
+	Name      string `json:"name"`
+	Value     string `json:"value,omitempty"`
+	Always    bool   `json:"always,omitempty"`
+}
+
+// ToProxyHeaderRule converts a request rule into the stored representation.
+func (r ProxyHeaderRuleRequest) ToProxyHeaderRule() ProxyHeaderRule {
+	action := r.Action
+	if action == "" {
+		action = HeaderActionSet
+	}
+	return ProxyHeaderRule{
+		Direction: r.Direction,
+		Action:    action,
+		Name:      r.Name,
+		Value:     r.Value,
+		Always:    r.Always,
+	}
 }
 
 type ProxyCreateRequest struct {
 	Name             string `json:"name" binding:"required"`
 	Domain           string `json:"domain" binding:"required"`
-	TargetURL        string `json:"target_url" binding:"required"`
+	TargetURL        string `json:"target_url"` // optional when upstream_servers is set
 	SSLEnabled       bool   `json:"ssl_enabled"`
 	WSEnabled        *bool  `json:"ws_enabled,omitempty"`
 	RateLimitEnabled *bool  `json:"rate_limit_enabled,omitempty"`
 	RateLimitRPS     *int   `json:"rate_limit_rps,omitempty"`
+
+	Type     string        `json:"type,omitempty"` // defaults to proxy
+	Redirect *RedirectHost `json:"redirect,omitempty"`
+	Static   *StaticHost   `json:"static,omitempty"`
+
+	LoadBalanceMethod string                  `json:"load_balance_method,omitempty"`
+	UpstreamServers   []UpstreamServerRequest `json:"upstream_servers,omitempty"`
+	Locations         []ProxyLocationRequest  `json:"locations,omitempty"`
+	Aliases           []string                `json:"aliases,omitempty"`
+	CanonicalRedirect bool                    `json:"canonical_redirect,omitempty"`
+	SSLMode           string                  `json:"ssl_mode,omitempty"` // defaults to terminate
+	BasicAuthSetID    int                     `json:"basic_auth_set_id,omitempty"`
+	AccessListID      int                     `json:"access_list_id,omitempty"`
+	RequireUPMLogin   bool                    `json:"require_upm_login,omitempty"`
+	ForwardAuth       *ForwardAuth            `json:"forward_auth,omitempty"`
+	GeoIP             *GeoIPRule              `json:"geoip,omitempty"`
+	ClientAuth        *ClientAuth             `json:"client_auth,omitempty"`
+	TLS               *TLSPolicy              `json:"tls,omitempty"`
+	HTTP3Enabled      bool                    `json:"http3_enabled,omitempty"`
+
+	HeaderRules  []ProxyHeaderRuleRequest `json:"header_rules,omitempty"`
+	CacheEnabled bool                     `json:"cache_enabled,omitempty"`
+	Cache        *CachePolicy             `json:"cache,omitempty"`
+	RateLimit    *RateLimitPolicy         `json:"rate_limit,omitempty"`
+
+	HealthCheck *HealthCheckRequest `json:"health_check,omitempty"`
 }
 
 type ProxyUpdateRequest struct {
@@ -41,17 +431,81 @@ type ProxyUpdateRequest struct {
 	WSEnabled        *bool   `json:"ws_enabled,omitempty"`
 	RateLimitEnabled *bool   `json:"rate_limit_enabled,omitempty"`
 	RateLimitRPS     *int    `json:"rate_limit_rps,omitempty"`
+
+	Type *string `json:"type,omitempty"`
+	// Redirect and Static replace the host type settings when present.
+	Redirect *RedirectHost `json:"redirect,omitempty"`
+	Static   *StaticHost   `json:"static,omitempty"`
+
+	LoadBalanceMethod *string `json:"load_balance_method,omitempty"`
+	// UpstreamServers replaces the whole pool when present; an empty list
+	// removes the pool and falls back to TargetURL.
+	UpstreamServers *[]UpstreamServerRequest `json:"upstream_servers,omitempty"`
+	// Locations replaces every location rule when present.
+	Locations *[]ProxyLocationRequest `json:"locations,omitempty"`
+	// Aliases replaces every alias when present.
+	Aliases           *[]string `json:"aliases,omitempty"`
+	CanonicalRedirect *bool     `json:"canonical_redirect,omitempty"`
+	SSLMode           *string   `json:"ssl_mode,omitempty"`
+	// BasicAuthSetID attaches a credential set; 0 removes it.
+	BasicAuthSetID *int `json:"basic_auth_set_id,omitempty"`
+	// AccessListID attaches an access list; 0 removes it.
+	AccessListID    *int  `json:"access_list_id,omitempty"`
+	RequireUPMLogin *bool `json:"require_upm_login,omitempty"`
+	// ForwardAuth replaces the forward auth settings when present; an empty
+	// url removes them.
+	ForwardAuth *ForwardAuth `json:"forward_auth,omitempty"`
+	// GeoIP replaces the country rule when present; an empty action
+	// removes it.
+	GeoIP *GeoIPRule `json:"geoip,omitempty"`
+	// ClientAuth replaces the client certificate settings when present; an
+	// empty mode removes them.
+	ClientAuth *ClientAuth `json:"client_auth,omitempty"`
+	// TLS replaces the TLS policy when present; an empty object restores
+	// the defaults.
+	TLS          *TLSPolicy `json:"tls,omitempty"`
+	HTTP3Enabled *bool      `json:"http3_enabled,omitempty"`
+	// HeaderRules replaces every header rule when present.
+	HeaderRules  *[]ProxyHeaderRuleRequest `json:"header_rules,omitempty"`
+	CacheEnabled *bool                     `json:"cache_enabled,omitempty"`
+	// Cache replaces the cache policy when present; an empty object
+	// restores the defaults.
+	Cache *CachePolicy `json:"cache,omitempty"`
+	// RateLimit replaces the rate limit policy when present; an empty
+	// object restores the defaults.
+	RateLimit *RateLimitPolicy `json:"rate_limit,omitempty"`
+	// HealthCheck replaces the health check when present.
+	HealthCheck *HealthCheckRequest `json:"health_check,omitempty"`
 }
 
+// Certificate is a certificate UPM serves or has issued. Client
+// certificates of an internal CA are tracked by their common name in
+// Domain and have no key on disk.
 type Certificate struct {
-	ID        int       `json:"id" db:"id"`
-	Domain    string    `json:"domain" db:"domain"`
-	CertPath  string    `json:"cert_path" db:"cert_path"`
-	KeyPath   string    `json:"key_path" db:"key_path"`
-	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
-	IsValid   bool      `json:"is_valid" db:"is_valid"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ID           int        `json:"id" db:"id"`
+	Domain       string     `json:"domain" db:"domain"`
+	CertPath     string     `json:"cert_path" db:"cert_path"`
+	KeyPath      string     `json:"key_path" db:"key_path"`
+	ExpiresAt    time.Time  `json:"expires_at" db:"expires_at"`
+	IsValid      bool       `json:"is_valid" db:"is_valid"`
+	Source       string     `json:"source" db:"source"`                         // letsencrypt, manual, internal
+	Usage        string     `json:"usage" db:"usage"`                           // server, client
+	Issuer       string     `json:"issuer,omitempty" db:"issuer"`               // common name of the issuing CA
+	CAID         int        `json:"ca_id,omitempty" db:"ca_id"`                 // issuing internal CA
+	SerialNumber string     `json:"serial_number,omitempty" db:"serial_number"` // hex, internal certificates only
+	RevokedAt    *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// IsInternal reports whether the certificate was issued by an internal CA.
+func (c *Certificate) IsInternal() bool {
+	return c.Source == CertificateSourceInternal
+}
+
+// IsRevoked reports whether an internal certificate has been revoked.
+func (c *Certificate) IsRevoked() bool {
+	return c.RevokedAt != nil
 }
 
 type CertificateCreateRequest struct {
@@ -0,0 +1,123 @@
+package models
+
+// Defaults applied to a proxy's rate limit policy for any setting left at
+// zero.
+const (
+	DefaultRateLimitStatus      = 429
+	DefaultRateLimitZoneSizeMB  = 10
+	DefaultRateLimitContentType = "text/plain"
+)
+
+// Units of a rate limit.
+const (
+	RateLimitPerSecond = "second"
+	RateLimitPerMinute = "minute"
+)
+
+// Plain rate limit keys. Requests can also be counted per header:NAME or
+// cookie:NAME.
+const (
+	RateLimitKeyIP     = "ip"      // the client address
+	RateLimitKeyAPIKey = "api_key" // the X-API-Key request header
+)
+
+// RateLimitPolicy tunes the nginx limit_req and limit_conn of a proxy. It
+// applies to the proxy when rate_limit_enabled is set and to its location
+// rules that enable rate limiting; unset fields fall back to the defaults
+// above.
+type RateLimitPolicy struct {
+	// Rate overrides rate_limit_rps; Per counts it per second (default)
+	// or per minute.
+	Rate int    `json:"rate,omitempty"`
+	Per  string `json:"per,omitempty"`
+	// Burst is how many requests above the rate are queued before
+	// rejecting; it defaults to twice the rate.
+	Burst int `json:"burst,omitempty"`
+	// Delay is how many queued requests are served without delay; the rest
+	// are slowed down to the rate. nil serves the whole burst right away
+	// (nodelay).
+	Delay *int `json:"delay,omitempty"`
+	// Key is what requests are counted by: ip (default), api_key,
+	// header:NAME or cookie:NAME. Requests without the header or cookie
+	// are counted by client address instead.
+	Key string `json:"key,omitempty"`
+	// ExemptCIDRs are client addresses and ranges that are never limited.
+	ExemptCIDRs []string `json:"exempt_cidrs,omitempty"`
+	// ConnLimit caps the concurrent connections per key; 0 leaves them
+	// unlimited.
+	ConnLimit int `json:"conn_limit,omitempty"`
+	// Status is the status of rejected requests. ResponseBody replaces
+	// nginx's stock page for them, and RetryAfter adds a Retry-After header
+	// in seconds.
+	Status              int    `json:"status,omitempty"`
+	ResponseBody        string `json:"response_body,omitempty"`
+	ResponseContentType string `json:"response_content_type,omitempty"`
+	RetryAfter          int    `json:"retry_after,omitempty"`
+	ZoneSizeMB          int    `json:"zone_size_mb,omitempty"` // size of each limit zone
+}
+
+// ApplyDefaults fills unset fields with the package defaults. Rate has no
+// default of its own; see Proxy.EffectiveRateLimitPolicy.
+func (r *RateLimitPolicy) ApplyDefaults() {
+	if r.Per == "" {
+		r.Per = RateLimitPerSecond
+	}
+	if r.Burst == 0 {
+		r.Burst = r.Rate * 2
+	}
+	if r.Key == "" {
+		r.Key = RateLimitKeyIP
+	}
+	if r.Status == 0 {
+		r.Status = DefaultRateLimitStatus
+	}
+	if r.ResponseBody != "" && r.ResponseContentType == "" {
+		r.ResponseContentType = DefaultRateLimitContentType
+	}
+	if r.ZoneSizeMB == 0 {
+		r.ZoneSizeMB = DefaultRateLimitZoneSizeMB
+	}
+}
+
+// CustomResponse reports whether rejected requests get a response of the
+// policy instead of nginx's stock page.
+func (r *RateLimitPolicy) CustomResponse() bool {
+	return r.ResponseBody != "" || r.RetryAfter > 0
+}
+
+// UsesRateLimit reports whether the proxy or any of its location rules
+// limits requests, which is when its limit zones must exist. Redirect hosts
+// return before limit_req would run, and passthrough proxies never reach
+// the http module.
+func (p *Proxy) UsesRateLimit() bool {
+	if p.IsPassthrough() || p.HostType() == ProxyTypeRedirect {
+		return false
+	}
+	if p.RateLimitEnabled {
+		return true
+	}
+	for _, loc := range p.Locations {
+		if loc.RateLimitEnabled {
+			return true
+		}
+	}
+	return false
+}
+
+// EffectiveRateLimitPolicy returns the proxy's rate limit policy with
+// defaults applied. Without a rate of its own the policy counts
+// rate_limit_rps requests per second, or per minute when Per says so.
+func (p *Proxy) EffectiveRateLimitPolicy() RateLimitPolicy {
+	var policy RateLimitPolicy
+	if p.RateLimit != nil {
+		policy = *p.RateLimit
+	}
+	if policy.Rate == 0 {
+		policy.Rate = p.RateLimitRPS
+		if policy.Rate < 1 {
+			policy.Rate = DefaultRateLimitRPS
+		}
+	}
+	policy.ApplyDefaults()
+	return policy
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+)
+
+// Protocols a stream proxy can listen on.
+const (
+	StreamProtocolTCP = "tcp"
+	StreamProtocolUDP = "udp"
+)
+
+// DefaultStreamProxyTimeout is the idle timeout, in seconds, applied to a
+// stream proxy when none is provided. It matches nginx's own default.
+const DefaultStreamProxyTimeout = 600
+
+// StreamProxy is a TCP/UDP proxy rendered into nginx's stream {} context,
+// used for non-HTTP services such as databases, MQTT, SSH or game servers.
+type StreamProxy struct {
+	ID              int       `json:"id" db:"id"`
+	Name            string    `json:"name" db:"name"`
+	ListenPort      int       `json:"listen_port" db:"listen_port"`
+	Protocol        string    `json:"protocol" db:"protocol"`                   // tcp, udp
+	Targets         []string  `json:"targets" db:"targets"`                     // host:port upstreams, stored comma-separated
+	AllowedIPRanges string    `json:"allowed_ip_ranges" db:"allowed_ip_ranges"` // Comma-separated list of IP ranges
+	ProxyTimeout    int       `json:"proxy_timeout" db:"proxy_timeout"`         // seconds
+	Status          string    `json:"status" db:"status"`                       // active, inactive, error
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type StreamProxyCreateRequest struct {
+	Name            string   `json:"name" binding:"required"`
+	ListenPort      int      `json:"listen_port" binding:"required"`
+	Protocol        string   `json:"protocol,omitempty"` // defaults to tcp
+	Targets         []string `json:"targets" binding:"required"`
+	AllowedIPRanges string   `json:"allowed_ip_ranges,omitempty"`
+	ProxyTimeout    *int     `json:"proxy_timeout,omitempty"`
+}
+
+type StreamProxyUpdateRequest struct {
+	Name            *string   `json:"name,omitempty"`
+	ListenPort      *int      `json:"listen_port,omitempty"`
+	Protocol        *string   `json:"protocol,omitempty"`
+	Targets         *[]string `json:"targets,omitempty"`
+	AllowedIPRanges *string   `json:"allowed_ip_ranges,omitempty"`
+	ProxyTimeout    *int      `json:"proxy_timeout,omitempty"`
+}
@@ -0,0 +1,169 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+)
+
+// TLS profiles of a proxy's HTTPS server. The named profiles follow the
+// Mozilla server side TLS guidelines; custom takes the protocols and
+// ciphers from the policy itself.
+const (
+	TLSProfileModern       = "modern"
+	TLSProfileIntermediate = "intermediate"
+	TLSProfileOld          = "old"
+	TLSProfileCustom       = "custom"
+)
+
+// TLS protocol versions, as named by nginx ssl_protocols.
+const (
+	TLSProtocol10 = "TLSv1"
+	TLSProtocol11 = "TLSv1.1"
+	TLSProtocol12 = "TLSv1.2"
+	TLSProtocol13 = "TLSv1.3"
+)
+
+// TLSProtocols lists the protocols a custom profile may enable, oldest
+// first.
+var TLSProtocols = []string{TLSProtocol10, TLSProtocol11, TLSProtocol12, TLSProtocol13}
+
+// Defaults applied to a proxy's TLS policy for any setting left at zero.
+const (
+	DefaultTLSProfile          = TLSProfileIntermediate
+	DefaultTLSSessionTimeout   = 10 // minutes
+	DefaultHSTSMaxAge          = 365 * 24 * 60 * 60
+	DefaultOCSPResolverTimeout = 5 // seconds
+)
+
+// DefaultOCSPResolver is Docker's embedded DNS server, which nginx can
+// reach on the compose network.
+const DefaultOCSPResolver = "127.0.0.11"
+
+// TLSProfile is the protocols and cipher suites of a named profile.
+type TLSProfile struct {
+	Protocols           []string
+	Ciphers             string // OpenSSL cipher list for TLS 1.2 and older, empty for OpenSSL's default
+	PreferServerCiphers bool
+}
+
+// TLSProfiles are the Mozilla server side TLS profiles. Modern only
+// speaks TLS 1.3, whose cipher suites are not configured by ssl_ciphers.
+// Old needs security level 0 for OpenSSL 3 to still offer TLS 1.0 and 1.1.
+var TLSProfiles = map[string]TLSProfile{
+	TLSProfileModern: {
+		Protocols: []string{TLSProtocol13},
+	},
+	TLSProfileIntermediate: {
+		Protocols: []string{TLSProtocol12, TLSProtocol13},
+		Ciphers: "ECDHE-ECDSA-AES128-GCM-SHA256:ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES256-GCM-SHA384:ECDHE-RSA-AES256-GCM-SHA384:" +
+			"ECDHE-ECDSA-CHACHA20-POLY1305:ECDHE-RSA-CHACHA20-POLY1305:DHE-RSA-AES128-GCM-SHA256:DHE-RSA-AES256-GCM-SHA384:DHE-RSA-CHACHA20-POLY1305",
+	},
+	TLSProfileOld: {
+		Protocols: []string{TLSProtocol10, TLSProtocol11, TLSProtocol12, TLSProtocol13},
+		Ciphers: "ECDHE-ECDSA-AES128-GCM-SHA256:ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES256-GCM-SHA384:ECDHE-RSA-AES256-GCM-SHA384:" +
+			"ECDHE-ECDSA-CHACHA20-POLY1305:ECDHE-RSA-CHACHA20-POLY1305:DHE-RSA-AES128-GCM-SHA256:DHE-RSA-AES256-GCM-SHA384:DHE-RSA-CHACHA20-POLY1305:" +
+			"ECDHE-ECDSA-AES128-SHA256:ECDHE-RSA-AES128-SHA256:ECDHE-ECDSA-AES128-SHA:ECDHE-RSA-AES128-SHA:ECDHE-ECDSA-AES256-SHA384:ECDHE-RSA-AES256-SHA384:" +
+			"ECDHE-ECDSA-AES256-SHA:ECDHE-RSA-AES256-SHA:DHE-RSA-AES128-SHA256:DHE-RSA-AES256-SHA256:AES128-GCM-SHA256:AES256-GCM-SHA384:" +
+			"AES128-SHA256:AES256-SHA256:AES128-SHA:AES256-SHA:DES-CBC3-SHA:@SECLEVEL=0",
+		PreferServerCiphers: true,
+	},
+}
+
+// TLSPolicy configures the TLS of a proxy's HTTPS server: the protocols
+// and ciphers, the Strict-Transport-Security header, OCSP stapling and
+// session resumption. Unset fields fall back to the defaults above.
+type TLSPolicy struct {
+	// Profile is modern, intermediate (default), old or custom. Protocols,
+	// Ciphers and PreferServerCiphers only apply to custom.
+	Profile             string   `json:"profile,omitempty"`
+	Protocols           []string `json:"protocols,omitempty"`
+	Ciphers             string   `json:"ciphers,omitempty"` // OpenSSL cipher list
+	PreferServerCiphers bool     `json:"prefer_server_ciphers,omitempty"`
+	// HSTS replaces the Strict-Transport-Security header; nil sends it
+	// for a year with includeSubDomains.
+	HSTS *HSTSPolicy `json:"hsts,omitempty"`
+	// OCSPStapling staples the CA's OCSP response to the handshake. nginx
+	// looks the responder up through Resolvers, Docker's DNS by default.
+	OCSPStapling    bool     `json:"ocsp_stapling,omitempty"`
+	Resolvers       []string `json:"resolvers,omitempty"`        // IP addresses, optionally with a port
+	ResolverTimeout int      `json:"resolver_timeout,omitempty"` // seconds
+	// SessionTickets enables TLS session tickets. They are off by default,
+	// as nginx never rotates the ticket key; the shared session cache
+	// resumes sessions instead, for SessionTimeout minutes.
+	SessionTickets bool `json:"session_tickets,omitempty"`
+	SessionTimeout int  `json:"session_timeout,omitempty"`
+}
+
+// HSTSPolicy is the Strict-Transport-Security header of a proxy.
+type HSTSPolicy struct {
+	Enabled           bool `json:"enabled"`
+	MaxAge            int  `json:"max_age,omitempty"` // seconds, defaults to a year
+	IncludeSubDomains bool `json:"include_subdomains"`
+	Preload           bool `json:"preload"` // requires include_subdomains and a max_age of a year or more
+}
+
+// IsZero reports whether the policy sets nothing, so the defaults apply.
+func (t *TLSPolicy) IsZero() bool {
+	return t == nil || (t.Profile == "" && len(t.Protocols) == 0 && t.Ciphers == "" && !t.PreferServerCiphers &&
+		t.HSTS == nil && !t.OCSPStapling && len(t.Resolvers) == 0 && t.ResolverTimeout == 0 &&
+		!t.SessionTickets && t.SessionTimeout == 0)
+}
+
+// ApplyDefaults fills unset fields with the package defaults. The
+// protocols and ciphers of a named profile replace the policy's own.
+func (t *TLSPolicy) ApplyDefaults() {
+	if t.Profile == "" {
+		t.Profile = DefaultTLSProfile
+	}
+	if profile, ok := TLSProfiles[t.Profile]; ok {
+		t.Protocols = append([]string(nil), profile.Protocols...)
+		t.Ciphers = profile.Ciphers
+		t.PreferServerCiphers = profile.PreferServerCiphers
+	}
+	if t.HSTS == nil {
+		t.HSTS = &HSTSPolicy{Enabled: true, IncludeSubDomains: true}
+	} else {
+		hsts := *t.HSTS
+		t.HSTS = &hsts
+	}
+	if t.HSTS.Enabled && t.HSTS.MaxAge == 0 {
+		t.HSTS.MaxAge = DefaultHSTSMaxAge
+	}
+	if t.OCSPStapling {
+		if len(t.Resolvers) == 0 {
+			t.Resolvers = []string{DefaultOCSPResolver}
+		}
+		if t.ResolverTimeout == 0 {
+			t.ResolverTimeout = DefaultOCSPResolverTimeout
+		}
+	}
+	if t.SessionTimeout == 0 {
+		t.SessionTimeout = DefaultTLSSessionTimeout
+	}
+}
+
+// HeaderValue returns the Strict-Transport-Security header value, or ""
+// when the header is not sent.
+func (h *HSTSPolicy) HeaderValue() string {
+	if h == nil || !h.Enabled {
+		return ""
+	}
+	parts := []string{"max-age=" + strconv.Itoa(h.MaxAge)}
+	if h.IncludeSubDomains {
+		parts = append(parts, "includeSubDomains")
+	}
+	if h.Preload {
+		parts = append(parts, "preload")
+	}
+	return strings.Join(parts, "; ")
+}
+
+// EffectiveTLSPolicy returns the proxy's TLS policy with defaults applied.
+func (p *Proxy) EffectiveTLSPolicy() TLSPolicy {
+	var policy TLSPolicy
+	if p.TLS != nil {
+		policy = *p.TLS
+	}
+	policy.ApplyDefaults()
+	return policy
+}
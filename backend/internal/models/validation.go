@@ -2,9 +2,13 @@ package models
 
 import (
 	"fmt"
+	"net"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 )
 
 // domainRegex matches a valid DNS hostname: labels of alphanumerics/hyphens
@@ -29,6 +33,45 @@ func ValidateDomain(domain string) error {
 	return nil
 }
 
+// ValidateServerName accepts either a hostname (see ValidateDomain) or a
+// leading-wildcard pattern such as *.apps.example.com. Wildcards must sit
+// under at least a two-label domain.
+func ValidateServerName(name string) error {
+	if rest, ok := strings.CutPrefix(name, "*."); ok {
+		if err := ValidateDomain(rest); err != nil {
+			return fmt.Errorf("invalid wildcard %s: %w", name, err)
+		}
+		if !strings.Contains(rest, ".") {
+			return fmt.Errorf("invalid wildcard %s: must be under a domain with at least two labels", name)
+		}
+		return nil
+	}
+	return ValidateDomain(name)
+}
+
+// maxProxyAliases bounds the number of extra server names on one proxy.
+const maxProxyAliases = 32
+
+// ValidateAliases checks a proxy's aliases against each other and its
+// primary domain. Names are compared case-insensitively, as nginx does.
+func ValidateAliases(domain string, aliases []string) error {
+	if len(aliases) > maxProxyAliases {
+		return fmt.Errorf("aliases cannot contain more than %d names", maxProxyAliases)
+	}
+	seen := map[string]bool{strings.ToLower(domain): true}
+	for i, alias := range aliases {
+		if err := ValidateServerName(alias); err != nil {
+			return fmt.Errorf("aliases[%d]: %w", i, err)
+		}
+		key := strings.ToLower(alias)
+		if seen[key] {
+			return fmt.Errorf("aliases[%d]: duplicate server name %s", i, alias)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
 // ValidateBackendURL ensures a URL is well-formed (http/https, valid host,
 // no embedded whitespace/control characters) before it is rendered directly
 // into an nginx proxy_pass directive.
@@ -70,3 +113,1243 @@ func ValidateRateLimitRPS(rps int) error {
 	}
 	return nil
 }
+
+// ValidateLoadBalanceMethod ensures the method is one nginx's upstream module
+// understands. An empty method means the nginx default (round-robin).
+func ValidateLoadBalanceMethod(method string) error {
+	switch method {
+	case "", LoadBalanceRoundRobin, LoadBalanceLeastConn, LoadBalanceIPHash:
+		return nil
+	}
+	return fmt.Errorf("load_balance_method must be one of %s, %s, %s", LoadBalanceRoundRobin, LoadBalanceLeastConn, LoadBalanceIPHash)
+}
+
+// maxUpstreamServers bounds the size of a single proxy's upstream pool.
+const maxUpstreamServers = 64
+
+// ValidateUpstreamServers checks every pool member with ValidateBackendURL and
+// then applies the extra constraints of an nginx upstream block: members are
+// host:port only (no path or query), share one scheme since proxy_pass names
+// the pool with a single scheme, and ip_hash pools cannot carry backups.
+func ValidateUpstreamServers(servers []UpstreamServer, method string) error {
+	if err := ValidateLoadBalanceMethod(method); err != nil {
+		return err
+	}
+	if len(servers) == 0 {
+		return nil
+	}
+	if len(servers) > maxUpstreamServers {
+		return fmt.Errorf("upstream_servers cannot contain more than %d members", maxUpstreamServers)
+	}
+
+	scheme := ""
+	primaries := 0
+	seen := make(map[string]bool)
+	for i, s := range servers {
+		if err := ValidateBackendURL(s.URL); err != nil {
+			return fmt.Errorf("upstream_servers[%d]: %w", i, err)
+		}
+		parsed, _ := url.Parse(s.URL)
+		if parsed.Path != "" && parsed.Path != "/" {
+			return fmt.Errorf("upstream_servers[%d]: URL must not include a path", i)
+		}
+		if parsed.RawQuery != "" || parsed.Fragment != "" || parsed.User != nil {
+			return fmt.Errorf("upstream_servers[%d]: URL must only contain scheme, host and port", i)
+		}
+		if scheme == "" {
+			scheme = parsed.Scheme
+		} else if parsed.Scheme != scheme {
+			return fmt.Errorf("upstream_servers[%d]: all members must use the same scheme", i)
+		}
+		if seen[parsed.Host] {
+			return fmt.Errorf("upstream_servers[%d]: duplicate member %s", i, parsed.Host)
+		}
+		seen[parsed.Host] = true
+
+		if s.Weight < 0 || s.Weight > 1000 {
+			return fmt.Errorf("upstream_servers[%d]: weight must be between 0 and 1000", i)
+		}
+		if s.MaxFails < 0 || s.MaxFails > 100 {
+			return fmt.Errorf("upstream_servers[%d]: max_fails must be between 0 and 100", i)
+		}
+		if s.FailTimeout < 0 || s.FailTimeout > 3600 {
+			return fmt.Errorf("upstream_servers[%d]: fail_timeout must be between 0 and 3600 seconds", i)
+		}
+		if s.Backup {
+			if method == LoadBalanceIPHash {
+				return fmt.Errorf("upstream_servers[%d]: backup members are not supported with %s", i, LoadBalanceIPHash)
+			}
+		} else {
+			primaries++
+		}
+	}
+	if primaries == 0 {
+		return fmt.Errorf("upstream_servers must contain at least one non-backup member")
+	}
+
+	return nil
+}
+
+// PrimaryUpstreamURL returns the URL of the first non-backup pool member, or
+// an empty string when the pool has none.
+func PrimaryUpstreamURL(servers []UpstreamServer) string {
+	for _, s := range servers {
+		if !s.Backup {
+			return s.URL
+		}
+	}
+	return ""
+}
+
+// ValidateSSLMode checks a proxy's ssl_mode; empty means terminate.
+func ValidateSSLMode(mode string) error {
+	switch mode {
+	case "", SSLModeTerminate, SSLModePassthrough:
+		return nil
+	}
+	return fmt.Errorf("ssl_mode must be one of %s, %s", SSLModeTerminate, SSLModePassthrough)
+}
+
+// ValidatePassthroughProxy applies the constraints of SNI passthrough to an
+// otherwise valid proxy. nginx never sees the HTTP request, so HTTP-level
+// features cannot apply and the backends only contribute host:port.
+func ValidatePassthroughProxy(proxy *Proxy) error {
+	if !proxy.IsPassthrough() {
+		return nil
+	}
+	if proxy.SSLEnabled {
+		return fmt.Errorf("ssl_enabled must be off for passthrough proxies; the backend terminates TLS")
+	}
+	if len(proxy.Locations) > 0 {
+		return fmt.Errorf("locations are not supported for passthrough proxies")
+	}
+	if proxy.CanonicalRedirect {
+		return fmt.Errorf("canonical_redirect is not supported for passthrough proxies")
+	}
+	if proxy.BasicAuthSetID != 0 {
+		return fmt.Errorf("basic auth is not supported for passthrough proxies")
+	}
+	if proxy.RequireUPMLogin {
+		return fmt.Errorf("require_upm_login is not supported for passthrough proxies")
+	}
+	if proxy.ForwardAuth.Enabled() {
+		return fmt.Errorf("forward_auth is not supported for passthrough proxies")
+	}
+	if proxy.GeoIP.Enabled() {
+		return fmt.Errorf("geoip rules are not supported for passthrough proxies")
+	}
+	if proxy.ClientAuth.Enabled() {
+		return fmt.Errorf("client_auth is not supported for passthrough proxies; the backend terminates TLS")
+	}
+	if proxy.TLS != nil {
+		return fmt.Errorf("tls settings are not supported for passthrough proxies; the backend terminates TLS")
+	}
+	if proxy.HTTP3Enabled {
+		return fmt.Errorf("http3_enabled is not supported for passthrough proxies; the SNI router only forwards TCP")
+	}
+	if len(proxy.HeaderRules) > 0 {
+		return fmt.Errorf("header_rules are not supported for passthrough proxies")
+	}
+	if proxy.CacheEnabled {
+		return fmt.Errorf("caching is not supported for passthrough proxies")
+	}
+
+	targets := []string{proxy.TargetURL}
+	for _, s := range proxy.UpstreamServers {
+		targets = append(targets, s.URL)
+	}
+	for _, target := range targets {
+		parsed, err := url.Parse(target)
+		if err != nil {
+			return fmt.Errorf("invalid backend URL %s: %w", target, err)
+		}
+		if (parsed.Path != "" && parsed.Path != "/") || parsed.RawQuery != "" {
+			return fmt.Errorf("passthrough backend %s must not include a path or query", target)
+		}
+	}
+	return nil
+}
+
+// ValidateProxyType checks a proxy's type; empty means proxy.
+func ValidateProxyType(proxyType string) error {
+	switch proxyType {
+	case "", ProxyTypeProxy, ProxyTypeRedirect, ProxyTypeStatic:
+		return nil
+	}
+	return fmt.Errorf("type must be one of %s, %s, %s", ProxyTypeProxy, ProxyTypeRedirect, ProxyTypeStatic)
+}
+
+// staticPathRegex matches a static host's root: slash-separated names that
+// start with an alphanumeric, which rules out ".." and absolute paths.
+var staticPathRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*(/[A-Za-z0-9][A-Za-z0-9._-]*)*$`)
+
+// staticIndexRegex matches a static host's index file name.
+var staticIndexRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$`)
+
+// ValidateHostType applies the constraints of redirect and static hosts to
+// an otherwise valid proxy with host type defaults applied. Neither type
+// forwards location / to a target, so upstream features cannot apply to it;
+// a static host may still route location rules to backends.
+func ValidateHostType(proxy *Proxy) error {
+	hostType := proxy.HostType()
+	if hostType == ProxyTypeProxy {
+		return nil
+	}
+	if proxy.IsPassthrough() {
+		return fmt.Errorf("ssl_mode %s is not supported for %s hosts", SSLModePassthrough, hostType)
+	}
+	if len(proxy.UpstreamServers) > 0 {
+		return fmt.Errorf("upstream_servers are not supported for %s hosts", hostType)
+	}
+	if proxy.WSEnabled {
+		return fmt.Errorf("ws_enabled is not supported for %s hosts", hostType)
+	}
+	if proxy.CacheEnabled {
+		return fmt.Errorf("caching is not supported for %s hosts", hostType)
+	}
+
+	switch hostType {
+	case ProxyTypeRedirect:
+		r := proxy.Redirect
+		if r == nil {
+			return fmt.Errorf("redirect settings are required for redirect hosts")
+		}
+		switch r.StatusCode {
+		case 301, 302, 307, 308:
+		default:
+			return fmt.Errorf("redirect status_code must be one of 301, 302, 307, 308")
+		}
+		if err := ValidateBackendURL(proxy.TargetURL); err != nil {
+			return fmt.Errorf("invalid redirect destination: %w", err)
+		}
+		// The destination is rendered into a return directive, where nginx
+		// would expand variables
+		if strings.ContainsAny(proxy.TargetURL, "$#") {
+			return fmt.Errorf("invalid redirect destination: contains disallowed characters")
+		}
+		if (r.PreservePath || r.PreserveQuery) && strings.Contains(proxy.TargetURL, "?") {
+			return fmt.Errorf("redirect destination must not include a query when the request path or query is preserved")
+		}
+		if len(proxy.Locations) > 0 {
+			return fmt.Errorf("locations are not supported for redirect hosts")
+		}
+	case ProxyTypeStatic:
+		s := proxy.Static
+		if s == nil {
+			return fmt.Errorf("static settings are required for static hosts")
+		}
+		if len(s.Root) > 255 || !staticPathRegex.MatchString(s.Root) {
+			return fmt.Errorf("static root must be a relative path of letters, digits, '.', '_' and '-'")
+		}
+		if !staticIndexRegex.MatchString(s.Index) {
+			return fmt.Errorf("static index must be a file name of letters, digits, '.', '_' and '-'")
+		}
+	}
+	return nil
+}
+
+// maxForwardAuthHeaders bounds the response headers copied from a
+// forward-auth service.
+const maxForwardAuthHeaders = 16
+
+// headerNameRegex matches an HTTP header name safe to render unquoted into
+// proxy_set_header and to map onto an nginx $upstream_http_ variable.
+var headerNameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]{0,63}$`)
+
+// ValidateForwardAuth checks a proxy's external forward-auth settings. Both
+// URLs are rendered into nginx directives, so they get the same checks as
+// proxy targets. Forward auth and UPM login both own auth_request, so only
+// one of them may be used.
+func ValidateForwardAuth(proxy *Proxy) error {
+	fa := proxy.ForwardAuth
+	if !fa.Enabled() {
+		return nil
+	}
+	if proxy.RequireUPMLogin {
+		return fmt.Errorf("forward_auth cannot be combined with require_upm_login")
+	}
+	if err := ValidateBackendURL(fa.URL); err != nil {
+		return fmt.Errorf("forward_auth url: %w", err)
+	}
+	if fa.SignInURL != "" {
+		if err := ValidateBackendURL(fa.SignInURL); err != nil {
+			return fmt.Errorf("forward_auth signin_url: %w", err)
+		}
+	}
+
+	if len(fa.ResponseHeaders) > maxForwardAuthHeaders {
+		return fmt.Errorf("forward_auth response_headers cannot contain more than %d headers", maxForwardAuthHeaders)
+	}
+	seen := make(map[string]bool)
+	for i, name := range fa.ResponseHeaders {
+		if !headerNameRegex.MatchString(name) {
+			return fmt.Errorf("forward_auth response_headers[%d]: invalid header name %q", i, name)
+		}
+		// Each header replaces the template's line of the same name, so the
+		// auth service must not take over Host, keep-alive or the client IP
+		key := strings.ToLower(name)
+		if templateManagedRequestHeaders[key] || forwardedRequestHeaders[key] {
+			return fmt.Errorf("forward_auth response_headers[%d]: %s cannot be copied from the auth service", i, name)
+		}
+		if seen[key] {
+			return fmt.Errorf("forward_auth response_headers[%d]: duplicate header %s", i, name)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// maxClientAuthVerifyDepth bounds ssl_verify_depth of mutual TLS.
+const maxClientAuthVerifyDepth = 10
+
+// maxClientCABundleSize bounds the PEM CA bundle of mutual TLS.
+const maxClientCABundleSize = 256 << 10
+
+// ValidateClientAuth checks a proxy's client certificate settings with
+// defaults applied. The CA bundle is only checked for presence and size
+// here; CertificateService.ParseCABundle checks its certificates, and the
+// handler checks that an internal CA exists.
+func ValidateClientAuth(ca *ClientAuth) error {
+	if !ca.Enabled() {
+		return nil
+	}
+	if ca.Mode != ClientAuthModeOn && ca.Mode != ClientAuthModeOptional {
+		return fmt.Errorf("client_auth mode must be %s or %s", ClientAuthModeOn, ClientAuthModeOptional)
+	}
+	if ca.CAID < 0 {
+		return fmt.Errorf("client_auth ca_id is invalid")
+	}
+	if ca.CAID != 0 && strings.TrimSpace(ca.CABundle) != "" {
+		return fmt.Errorf("client_auth takes either a ca_bundle or a ca_id, not both")
+	}
+	if ca.CAID == 0 && strings.TrimSpace(ca.CABundle) == "" {
+		return fmt.Errorf("client_auth ca_bundle or ca_id is required")
+	}
+	if len(ca.CABundle) > maxClientCABundleSize {
+		return fmt.Errorf("client_auth ca_bundle cannot be larger than %d KiB", maxClientCABundleSize>>10)
+	}
+	if ca.VerifyDepth < 1 || ca.VerifyDepth > maxClientAuthVerifyDepth {
+		return fmt.Errorf("client_auth verify_depth must be between 1 and %d", maxClientAuthVerifyDepth)
+	}
+	if ca.DNHeader != "" {
+		if !headerNameRegex.MatchString(ca.DNHeader) {
+			return fmt.Errorf("client_auth dn_header: invalid header name %q", ca.DNHeader)
+		}
+		if templateManagedRequestHeaders[strings.ToLower(ca.DNHeader)] || strings.EqualFold(ca.DNHeader, "Host") {
+			return fmt.Errorf("client_auth dn_header cannot be %s", ca.DNHeader)
+		}
+	}
+	return nil
+}
+
+// Bounds for a proxy's TLS policy.
+const (
+	maxTLSCiphersLength  = 2048
+	maxHSTSMaxAge        = 2 * 365 * 24 * 60 * 60
+	maxOCSPResolvers     = 8
+	maxOCSPResolverWait  = 60      // seconds
+	maxTLSSessionTimeout = 24 * 60 // minutes
+)
+
+// cipherListRegex matches an OpenSSL cipher list, rendered unquoted into
+// ssl_ciphers.
+var cipherListRegex = regexp.MustCompile(`^[A-Za-z0-9@=+!:._-]+$`)
+
+// ValidateTLSPolicy checks a proxy's TLS policy as requested, before
+// defaults are applied, so that custom settings on a named profile are
+// reported instead of silently replaced. A nil policy is valid.
+func ValidateTLSPolicy(policy *TLSPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	switch policy.Profile {
+	case "", TLSProfileModern, TLSProfileIntermediate, TLSProfileOld:
+		if len(policy.Protocols) > 0 || policy.Ciphers != "" || policy.PreferServerCiphers {
+			return fmt.Errorf("tls protocols, ciphers and prefer_server_ciphers only apply to the %s profile", TLSProfileCustom)
+		}
+	case TLSProfileCustom:
+		if len(policy.Protocols) == 0 {
+			return fmt.Errorf("tls protocols are required for the %s profile", TLSProfileCustom)
+		}
+		seen := make(map[string]bool)
+		for _, protocol := range policy.Protocols {
+			known := false
+			for _, p := range TLSProtocols {
+				known = known || p == protocol
+			}
+			if !known {
+				return fmt.Errorf("unknown tls protocol %q: must be one of %s", protocol, strings.Join(TLSProtocols, ", "))
+			}
+			if seen[protocol] {
+				return fmt.Errorf("duplicate tls protocol %q", protocol)
+			}
+			seen[protocol] = true
+		}
+		if policy.Ciphers != "" && (len(policy.Ciphers) > maxTLSCiphersLength || !cipherListRegex.MatchString(policy.Ciphers)) {
+			return fmt.Errorf("invalid tls ciphers: must be an OpenSSL cipher list of at most %d characters", maxTLSCiphersLength)
+		}
+	default:
+		return fmt.Errorf("tls profile must be %s, %s, %s or %s", TLSProfileModern, TLSProfileIntermediate, TLSProfileOld, TLSProfileCustom)
+	}
+
+	if hsts := policy.HSTS; hsts != nil {
+		if hsts.MaxAge < 0 || hsts.MaxAge > maxHSTSMaxAge {
+			return fmt.Errorf("hsts max_age must be between 0 and %d seconds", maxHSTSMaxAge)
+		}
+		// The requirements of the browsers' HSTS preload lists
+		if hsts.Enabled && hsts.Preload {
+			if !hsts.IncludeSubDomains {
+				return fmt.Errorf("hsts preload requires include_subdomains")
+			}
+			if hsts.MaxAge != 0 && hsts.MaxAge < DefaultHSTSMaxAge {
+				return fmt.Errorf("hsts preload requires a max_age of at least %d seconds", DefaultHSTSMaxAge)
+			}
+		}
+	}
+
+	if !policy.OCSPStapling && (len(policy.Resolvers) > 0 || policy.ResolverTimeout != 0) {
+		return fmt.Errorf("tls resolvers only apply with ocsp_stapling")
+	}
+	if len(policy.Resolvers) > maxOCSPResolvers {
+		return fmt.Errorf("tls resolvers cannot contain more than %d entries", maxOCSPResolvers)
+	}
+	for _, resolver := range policy.Resolvers {
+		if err := validateResolverAddress(resolver); err != nil {
+			return err
+		}
+	}
+	if policy.ResolverTimeout < 0 || policy.ResolverTimeout > maxOCSPResolverWait {
+		return fmt.Errorf("tls resolver_timeout must be between 0 and %d seconds", maxOCSPResolverWait)
+	}
+	if policy.SessionTimeout < 0 || policy.SessionTimeout > maxTLSSessionTimeout {
+		return fmt.Errorf("tls session_timeout must be between 0 and %d minutes", maxTLSSessionTimeout)
+	}
+	return nil
+}
+
+// ValidateHTTP3 checks that a proxy opted into HTTP/3 still offers TLS
+// 1.3, the only version QUIC runs on.
+func ValidateHTTP3(proxy *Proxy) error {
+	if !proxy.HTTP3Enabled {
+		return nil
+	}
+	for _, protocol := range proxy.EffectiveTLSPolicy().Protocols {
+		if protocol == TLSProtocol13 {
+			return nil
+		}
+	}
+	return fmt.Errorf("http3_enabled requires %s in the tls protocols", TLSProtocol13)
+}
+
+// validateResolverAddress accepts an IP address, optionally with a port;
+// IPv6 addresses with a port are bracketed.
+func validateResolverAddress(addr string) error {
+	if net.ParseIP(addr) != nil {
+		return nil
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err == nil && net.ParseIP(host) != nil {
+		if n, err := strconv.Atoi(port); err == nil && n >= 1 && n <= 65535 {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid tls resolver %q: must be an IP address, optionally with a port", addr)
+}
+
+// maxHeaderRules bounds the number of header rules on a single proxy.
+const maxHeaderRules = 64
+
+// maxHeaderValueLength bounds a header rule value.
+const maxHeaderValueLength = 4096
+
+// templateManagedRequestHeaders are set by the proxy template itself for
+// keep-alive and WebSocket handling and cannot be changed by a rule.
+var templateManagedRequestHeaders = map[string]bool{
+	"connection":        true,
+	"upgrade":           true,
+	"content-length":    true,
+	"transfer-encoding": true,
+}
+
+// forwardedRequestHeaders carry the client's host, address and scheme to
+// the upstream. Rules may override them, but headers copied from a
+// forward-auth response may not.
+var forwardedRequestHeaders = map[string]bool{
+	"host":              true,
+	"x-real-ip":         true,
+	"x-forwarded-for":   true,
+	"x-forwarded-proto": true,
+}
+
+// ValidateHeaderRules checks a proxy's header rules. Names are rendered
+// unquoted, so they are restricted to header token characters; values are
+// quoted and escaped when rendered but must not contain control characters,
+// which would end the directive's line.
+func ValidateHeaderRules(rules []ProxyHeaderRule) error {
+	if len(rules) > maxHeaderRules {
+		return fmt.Errorf("header_rules cannot contain more than %d rules", maxHeaderRules)
+	}
+
+	seen := make(map[string]bool)
+	for i, rule := range rules {
+		if rule.Direction != HeaderDirectionRequest && rule.Direction != HeaderDirectionResponse {
+			return fmt.Errorf("header_rules[%d]: direction must be %s or %s", i, HeaderDirectionRequest, HeaderDirectionResponse)
+		}
+		if rule.Action != HeaderActionSet && rule.Action != HeaderActionRemove {
+			return fmt.Errorf("header_rules[%d]: action must be %s or %s", i, HeaderActionSet, HeaderActionRemove)
+		}
+		if !headerNameRegex.MatchString(rule.Name) {
+			return fmt.Errorf("header_rules[%d]: invalid header name %q", i, rule.Name)
+		}
+		key := strings.ToLower(rule.Name)
+		if rule.Direction == HeaderDirectionRequest {
+			if templateManagedRequestHeaders[key] {
+				return fmt.Errorf("header_rules[%d]: %s is managed by the proxy and cannot be changed", i, rule.Name)
+			}
+			if key == "host" && rule.Action == HeaderActionRemove {
+				return fmt.Errorf("header_rules[%d]: the Host header cannot be removed", i)
+			}
+		}
+
+		switch rule.Action {
+		case HeaderActionSet:
+			if rule.Value == "" {
+				return fmt.Errorf("header_rules[%d]: value is required; use the remove action to drop a header", i)
+			}
+			if len(rule.Value) > maxHeaderValueLength {
+				return fmt.Errorf("header_rules[%d]: value cannot be longer than %d characters", i, maxHeaderValueLength)
+			}
+			for _, r := range rule.Value {
+				if r <= 0x1f || r == 0x7f {
+					return fmt.Errorf("header_rules[%d]: value must not contain control characters", i)
+				}
+			}
+		case HeaderActionRemove:
+			if rule.Value != "" {
+				return fmt.Errorf("header_rules[%d]: value is not allowed with the remove action", i)
+			}
+		}
+		if rule.Always && (rule.Direction != HeaderDirectionResponse || rule.Action != HeaderActionSet) {
+			return fmt.Errorf("header_rules[%d]: always only applies to response headers that are set", i)
+		}
+
+		dedupeKey := rule.Direction + ":" + key
+		if seen[dedupeKey] {
+			return fmt.Errorf("header_rules[%d]: duplicate %s header %s", i, rule.Direction, rule.Name)
+		}
+		seen[dedupeKey] = true
+	}
+	return nil
+}
+
+// Bounds for a proxy cache policy.
+const (
+	maxCacheZoneSizeMB      = 1024
+	maxCacheMaxSizeMB       = 1024 * 1024
+	maxCacheInactiveMinutes = 30 * 24 * 60
+	maxCacheTTL             = 365 * 24 * 60 * 60
+	maxCacheListLength      = 16
+)
+
+// cacheKeyParts are the plain cache key parts and their nginx variables.
+var cacheKeyParts = map[string]bool{
+	"scheme":      true,
+	"method":      true,
+	"host":        true,
+	"uri":         true,
+	"args":        true,
+	"request_uri": true,
+}
+
+// variableNameRegex matches cookie and query argument names that nginx can
+// expose as $cookie_NAME and $arg_NAME variables.
+var variableNameRegex = regexp.MustCompile(`^[A-Za-z0-9_]{1,64}$`)
+
+// ValidateCachePolicy checks a cache policy after defaults were applied.
+func ValidateCachePolicy(policy CachePolicy) error {
+	if policy.ZoneSizeMB < 1 || policy.ZoneSizeMB > maxCacheZoneSizeMB {
+		return fmt.Errorf("cache zone_size_mb must be between 1 and %d", maxCacheZoneSizeMB)
+	}
+	if policy.MaxSizeMB < 1 || policy.MaxSizeMB > maxCacheMaxSizeMB {
+		return fmt.Errorf("cache max_size_mb must be between 1 and %d", maxCacheMaxSizeMB)
+	}
+	if policy.InactiveMinutes < 1 || policy.InactiveMinutes > maxCacheInactiveMinutes {
+		return fmt.Errorf("cache inactive_minutes must be between 1 and %d", maxCacheInactiveMinutes)
+	}
+
+	if len(policy.Valid) > maxCacheListLength {
+		return fmt.Errorf("cache valid cannot contain more than %d entries", maxCacheListLength)
+	}
+	seen := make(map[string]bool)
+	for i, v := range policy.Valid {
+		if v.Status != "any" {
+			code, err := strconv.Atoi(v.Status)
+			if err != nil || code < 100 || code > 599 {
+				return fmt.Errorf("cache valid[%d]: status must be an HTTP status code or \"any\"", i)
+			}
+		}
+		if seen[v.Status] {
+			return fmt.Errorf("cache valid[%d]: duplicate status %s", i, v.Status)
+		}
+		seen[v.Status] = true
+		if v.TTL < 1 || v.TTL > maxCacheTTL {
+			return fmt.Errorf("cache valid[%d]: ttl must be between 1 and %d seconds", i, maxCacheTTL)
+		}
+	}
+
+	if len(policy.Key) == 0 || len(policy.Key) > maxCacheListLength {
+		return fmt.Errorf("cache key must contain between 1 and %d parts", maxCacheListLength)
+	}
+	for i, part := range policy.Key {
+		if err := validateCacheKeyPart(part); err != nil {
+			return fmt.Errorf("cache key[%d]: %w", i, err)
+		}
+	}
+
+	bypass := []struct {
+		field string
+		names []string
+		regex *regexp.Regexp
+	}{
+		{"bypass_cookies", policy.BypassCookies, variableNameRegex},
+		{"bypass_headers", policy.BypassHeaders, headerNameRegex},
+		{"bypass_args", policy.BypassArgs, variableNameRegex},
+	}
+	for _, b := range bypass {
+		if len(b.names) > maxCacheListLength {
+			return fmt.Errorf("cache %s cannot contain more than %d names", b.field, maxCacheListLength)
+		}
+		for i, name := range b.names {
+			if !b.regex.MatchString(name) {
+				return fmt.Errorf("cache %s[%d]: invalid name %q", b.field, i, name)
+			}
+		}
+	}
+	return nil
+}
+
+// validateCacheKeyPart accepts a plain part such as "host" or a named
+// cookie:NAME, header:NAME or arg:NAME part.
+func validateCacheKeyPart(part string) error {
+	if cacheKeyParts[part] {
+		return nil
+	}
+	kind, name, ok := strings.Cut(part, ":")
+	if !ok {
+		return fmt.Errorf("unknown part %q", part)
+	}
+	switch kind {
+	case "cookie", "arg":
+		if !variableNameRegex.MatchString(name) {
+			return fmt.Errorf("invalid %s name %q", kind, name)
+		}
+	case "header":
+		if !headerNameRegex.MatchString(name) {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+	default:
+		return fmt.Errorf("unknown part %q", part)
+	}
+	return nil
+}
+
+// Bounds for a proxy's rate limit policy.
+const (
+	maxRateLimitRate        = 10000
+	maxRateLimitBurst       = 100000
+	maxRateLimitConnLimit   = 100000
+	maxRateLimitExemptCIDRs = 64
+	maxRateLimitZoneSizeMB  = 1024
+	maxRateLimitRetryAfter  = 24 * 60 * 60
+	maxRateLimitBodySize    = 64 * 1024
+)
+
+// contentTypeRegex matches a bare MIME type, rendered unquoted into
+// default_type.
+var contentTypeRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9.+-]*/[A-Za-z0-9][A-Za-z0-9.+-]*$`)
+
+// ValidateRateLimitPolicy checks a rate limit policy after defaults were
+// applied.
+func ValidateRateLimitPolicy(policy RateLimitPolicy) error {
+	if policy.Rate < 1 || policy.Rate > maxRateLimitRate {
+		return fmt.Errorf("rate limit rate must be between 1 and %d", maxRateLimitRate)
+	}
+	if policy.Per != RateLimitPerSecond && policy.Per != RateLimitPerMinute {
+		return fmt.Errorf("rate limit per must be %s or %s", RateLimitPerSecond, RateLimitPerMinute)
+	}
+	if policy.Burst < 1 || policy.Burst > maxRateLimitBurst {
+		return fmt.Errorf("rate limit burst must be between 1 and %d", maxRateLimitBurst)
+	}
+	if policy.Delay != nil && (*policy.Delay < 0 || *policy.Delay > policy.Burst) {
+		return fmt.Errorf("rate limit delay must be between 0 and the burst")
+	}
+	if err := validateRateLimitKey(policy.Key); err != nil {
+		return fmt.Errorf("rate limit key: %w", err)
+	}
+	if len(policy.ExemptCIDRs) > maxRateLimitExemptCIDRs {
+		return fmt.Errorf("rate limit exempt_cidrs cannot contain more than %d entries", maxRateLimitExemptCIDRs)
+	}
+	for _, cidr := range policy.ExemptCIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil && net.ParseIP(cidr) == nil {
+			return fmt.Errorf("invalid rate limit exempt address %q: must be an IP address or CIDR", cidr)
+		}
+	}
+	if policy.ConnLimit < 0 || policy.ConnLimit > maxRateLimitConnLimit {
+		return fmt.Errorf("rate limit conn_limit must be between 0 and %d", maxRateLimitConnLimit)
+	}
+	// The range limit_req_status and limit_conn_status accept
+	if policy.Status < 400 || policy.Status > 599 {
+		return fmt.Errorf("rate limit status must be between 400 and 599")
+	}
+	if len(policy.ResponseBody) > maxRateLimitBodySize {
+		return fmt.Errorf("rate limit response_body cannot be larger than %d KB", maxRateLimitBodySize/1024)
+	}
+	if policy.ResponseContentType != "" && !contentTypeRegex.MatchString(policy.ResponseContentType) {
+		return fmt.Errorf("invalid rate limit response_content_type %q", policy.ResponseContentType)
+	}
+	if policy.RetryAfter < 0 || policy.RetryAfter > maxRateLimitRetryAfter {
+		return fmt.Errorf("rate limit retry_after must be between 0 and %d seconds", maxRateLimitRetryAfter)
+	}
+	if policy.ZoneSizeMB < 1 || policy.ZoneSizeMB > maxRateLimitZoneSizeMB {
+		return fmt.Errorf("rate limit zone_size_mb must be between 1 and %d", maxRateLimitZoneSizeMB)
+	}
+	return nil
+}
+
+// validateRateLimitKey accepts ip, api_key or a named header:NAME or
+// cookie:NAME key.
+func validateRateLimitKey(key string) error {
+	if key == RateLimitKeyIP || key == RateLimitKeyAPIKey {
+		return nil
+	}
+	kind, name, ok := strings.Cut(key, ":")
+	if !ok {
+		return fmt.Errorf("unknown key %q", key)
+	}
+	switch kind {
+	case "cookie":
+		if !variableNameRegex.MatchString(name) {
+			return fmt.Errorf("invalid cookie name %q", name)
+		}
+	case "header":
+		if !headerNameRegex.MatchString(name) {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+	default:
+		return fmt.Errorf("unknown key %q", key)
+	}
+	return nil
+}
+
+// Bounds for a proxy health check.
+const (
+	minHealthCheckInterval   = 10
+	maxHealthCheckInterval   = 3600
+	maxHealthCheckTimeout    = 60
+	maxHealthCheckThreshold  = 20
+	maxHealthCheckPathLength = 1024
+)
+
+// ValidateHealthCheck checks a health check after defaults were applied.
+func ValidateHealthCheck(check HealthCheck) error {
+	if !strings.HasPrefix(check.Path, "/") || len(check.Path) > maxHealthCheckPathLength {
+		return fmt.Errorf("health check path must start with / and be at most %d characters", maxHealthCheckPathLength)
+	}
+	for _, r := range check.Path {
+		if r <= ' ' || r == 0x7f {
+			return fmt.Errorf("health check path cannot contain spaces or control characters")
+		}
+	}
+	if check.ExpectedStatus != 0 && (check.ExpectedStatus < 100 || check.ExpectedStatus > 599) {
+		return fmt.Errorf("health check expected_status must be an HTTP status code")
+	}
+	if check.IntervalSeconds < minHealthCheckInterval || check.IntervalSeconds > maxHealthCheckInterval {
+		return fmt.Errorf("health check interval_seconds must be between %d and %d", minHealthCheckInterval, maxHealthCheckInterval)
+	}
+	if check.TimeoutSeconds < 1 || check.TimeoutSeconds > maxHealthCheckTimeout || check.TimeoutSeconds >= check.IntervalSeconds {
+		return fmt.Errorf("health check timeout_seconds must be between 1 and %d and shorter than the interval", maxHealthCheckTimeout)
+	}
+	if check.UnhealthyThreshold < 1 || check.UnhealthyThreshold > maxHealthCheckThreshold {
+		return fmt.Errorf("health check unhealthy_threshold must be between 1 and %d", maxHealthCheckThreshold)
+	}
+	return nil
+}
+
+// Bounds for a proxy's maintenance settings.
+const (
+	maxMaintenancePageSize   = 256 * 1024
+	maxMaintenanceRetryAfter = 7 * 24 * 60 * 60
+	maxMaintenanceAllowedIPs = 64
+)
+
+// ValidateMaintenance checks maintenance settings after defaults were
+// applied. A scheduled window must not have ended already.
+func ValidateMaintenance(m *Maintenance, now time.Time) error {
+	if len(m.Page) > maxMaintenancePageSize {
+		return fmt.Errorf("maintenance page cannot be larger than %d KB", maxMaintenancePageSize/1024)
+	}
+	if m.RetryAfterSeconds < 1 || m.RetryAfterSeconds > maxMaintenanceRetryAfter {
+		return fmt.Errorf("retry_after_seconds must be between 1 and %d", maxMaintenanceRetryAfter)
+	}
+	if len(m.AllowedIPs) > maxMaintenanceAllowedIPs {
+		return fmt.Errorf("allowed_ips cannot contain more than %d entries", maxMaintenanceAllowedIPs)
+	}
+	for _, ip := range m.AllowedIPs {
+		if _, _, err := net.ParseCIDR(ip); err != nil && net.ParseIP(ip) == nil {
+			return fmt.Errorf("invalid allowed IP %q: must be an IP address or CIDR", ip)
+		}
+	}
+	if m.EndsAt != nil {
+		if !m.EndsAt.After(now) {
+			return fmt.Errorf("ends_at must be in the future")
+		}
+		if m.StartsAt != nil && !m.EndsAt.After(*m.StartsAt) {
+			return fmt.Errorf("ends_at must be after starts_at")
+		}
+	}
+	return nil
+}
+
+// ValidateErrorPage checks a custom error page. nginx's error_page accepts
+// codes from 300 up; redirects are not errors, so only 4xx and 5xx are
+// allowed.
+func ValidateErrorPage(statusCode int, content string) error {
+	if statusCode < 400 || statusCode > 599 {
+		return fmt.Errorf("status code must be between 400 and 599")
+	}
+	if strings.TrimSpace(content) == "" {
+		return fmt.Errorf("error page content is required")
+	}
+	if len(content) > MaxErrorPageSize {
+		return fmt.Errorf("error page cannot be larger than %d KB", MaxErrorPageSize/1024)
+	}
+	return nil
+}
+
+// maxProxyLocations bounds the number of location rules on a single proxy.
+const maxProxyLocations = 64
+
+// locationPathRegex matches prefix/exact location paths. Like domainRegex it
+// is deliberately narrow because the path is rendered unquoted into a
+// location directive.
+var locationPathRegex = regexp.MustCompile(`^/[A-Za-z0-9._~%@:+,=/-]*$`)
+
+// locationRewriteRegex matches a rewrite replacement for prefix/exact rules;
+// regex rules may additionally reference captures as $1..$9.
+var (
+	locationRewriteRegex      = regexp.MustCompile(`^/[A-Za-z0-9._~%@:+,=&?/-]*$`)
+	locationRegexRewriteRegex = regexp.MustCompile(`^/([A-Za-z0-9._~%@:+,=&?/-]|\$[0-9])*$`)
+)
+
+// reservedLocationPaths are prefix locations the proxy template renders
+// itself; a rule with the same path would be a duplicate location. /_upm/
+// serves the UPM login page on hosts that require it.
+var reservedLocationPaths = []string{"/.well-known/acme-challenge/", "/_upm/"}
+
+// wsLocationPaths are the Socket.IO/WebSocket locations rendered when a
+// proxy has ws_enabled set.
+var wsLocationPaths = []string{"/ws/socket.io/", "/socket.io/", "/ws/"}
+
+// ValidateProxyLocations checks a proxy's location rules before they are
+// rendered into nginx config. wsEnabled is the proxy-level WebSocket flag,
+// which reserves the built-in Socket.IO paths.
+func ValidateProxyLocations(locations []ProxyLocation, wsEnabled bool) error {
+	if len(locations) > maxProxyLocations {
+		return fmt.Errorf("locations cannot contain more than %d rules", maxProxyLocations)
+	}
+
+	reserved := append([]string{}, reservedLocationPaths...)
+	if wsEnabled {
+		reserved = append(reserved, wsLocationPaths...)
+	}
+
+	seen := make(map[string]bool)
+	for i, loc := range locations {
+		if err := validateProxyLocation(loc); err != nil {
+			return fmt.Errorf("locations[%d]: %w", i, err)
+		}
+
+		if loc.MatchType == LocationMatchPrefix {
+			for _, r := range reserved {
+				if loc.Path == r {
+					return fmt.Errorf("locations[%d]: path %s is reserved", i, loc.Path)
+				}
+			}
+		}
+		if strings.HasPrefix(loc.Path, "/.well-known/acme-challenge/") && loc.MatchType != LocationMatchRegex {
+			return fmt.Errorf("locations[%d]: paths under /.well-known/acme-challenge/ are reserved", i)
+		}
+
+		key := loc.MatchType + " " + loc.Path
+		if seen[key] {
+			return fmt.Errorf("locations[%d]: duplicate %s location %s", i, loc.MatchType, loc.Path)
+		}
+		seen[key] = true
+	}
+
+	return nil
+}
+
+func validateProxyLocation(loc ProxyLocation) error {
+	switch loc.MatchType {
+	case LocationMatchPrefix, LocationMatchExact:
+		if !locationPathRegex.MatchString(loc.Path) {
+			return fmt.Errorf("invalid path: must start with / and contain only URL path characters")
+		}
+		if loc.MatchType == LocationMatchPrefix && loc.Path == "/" {
+			return fmt.Errorf("prefix / is the proxy's default target; set target_url instead")
+		}
+	case LocationMatchRegex:
+		if loc.Path == "" || len(loc.Path) > 256 {
+			return fmt.Errorf("invalid path: regex must be between 1 and 256 characters")
+		}
+		if strings.ContainsAny(loc.Path, " \t\r\n\"';{}") {
+			return fmt.Errorf("invalid path: regex contains disallowed characters")
+		}
+		if _, err := regexp.Compile(loc.Path); err != nil {
+			return fmt.Errorf("invalid path: %w", err)
+		}
+	default:
+		return fmt.Errorf("match_type must be one of %s, %s, %s", LocationMatchPrefix, LocationMatchExact, LocationMatchRegex)
+	}
+
+	if err := ValidateBackendURL(loc.TargetURL); err != nil {
+		return fmt.Errorf("invalid target_url: %w", err)
+	}
+	target, _ := url.Parse(loc.TargetURL)
+	if target.RawQuery != "" || target.Fragment != "" || target.User != nil {
+		return fmt.Errorf("invalid target_url: only scheme, host, port and path are allowed")
+	}
+	hasTargetPath := target.Path != "" && target.Path != "/"
+	if loc.MatchType == LocationMatchRegex && hasTargetPath {
+		return fmt.Errorf("invalid target_url: regex rules cannot proxy to a path; use rewrite instead")
+	}
+
+	if loc.StripPrefix {
+		if loc.MatchType != LocationMatchPrefix {
+			return fmt.Errorf("strip_prefix is only supported on prefix rules")
+		}
+		if loc.Rewrite != "" {
+			return fmt.Errorf("strip_prefix and rewrite cannot be combined")
+		}
+	}
+	if loc.RateLimitRate < 0 || loc.RateLimitRate > maxRateLimitRate {
+		return fmt.Errorf("rate_limit_rate must be between 0 and %d", maxRateLimitRate)
+	}
+	if loc.RateLimitBurst < 0 || loc.RateLimitBurst > maxRateLimitBurst {
+		return fmt.Errorf("rate_limit_burst must be between 0 and %d", maxRateLimitBurst)
+	}
+	if (loc.RateLimitRate > 0 || loc.RateLimitBurst > 0) && !loc.RateLimitEnabled {
+		return fmt.Errorf("rate_limit_rate and rate_limit_burst require rate_limit_enabled")
+	}
+	if loc.RateLimitBurst > 0 && loc.RateLimitRate == 0 {
+		return fmt.Errorf("rate_limit_burst requires rate_limit_rate")
+	}
+	if loc.Rewrite != "" {
+		rewriteRegex := locationRewriteRegex
+		if loc.MatchType == LocationMatchRegex {
+			rewriteRegex = locationRegexRewriteRegex
+		}
+		if !rewriteRegex.MatchString(loc.Rewrite) {
+			return fmt.Errorf("invalid rewrite: must start with / and contain only URL path characters")
+		}
+		if hasTargetPath {
+			return fmt.Errorf("invalid target_url: cannot include a path when rewrite is set")
+		}
+	}
+
+	return nil
+}
+
+// ValidateIPRanges checks a comma-separated list of IP addresses and CIDR
+// ranges as stored in allowed_ip_ranges. Empty entries are ignored.
+func ValidateIPRanges(ranges string) error {
+	for _, r := range strings.Split(ranges, ",") {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(r); err == nil {
+			continue
+		}
+		if net.ParseIP(r) == nil {
+			return fmt.Errorf("invalid IP range %q: must be an IP address or CIDR", r)
+		}
+	}
+	return nil
+}
+
+// reservedStreamPorts are TCP ports nginx already listens on for the HTTP
+// proxies; a stream server cannot bind them as well.
+var reservedStreamPorts = map[int]bool{80: true, 443: true}
+
+// HTTP3Port is the UDP port of the QUIC listeners of HTTP/3 proxies.
+const HTTP3Port = 443
+
+// maxStreamTargets bounds the upstream list of a single stream proxy.
+const maxStreamTargets = 64
+
+// ValidateStreamProxy checks a stream proxy before it is rendered into the
+// nginx stream {} context. Conflicts with other stream proxies are checked
+// separately against the database. http3 reports whether HTTP/3 is enabled
+// globally, which reserves HTTP3Port/udp for the QUIC listeners.
+func ValidateStreamProxy(stream *StreamProxy, http3 bool) error {
+	if strings.TrimSpace(stream.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if stream.ListenPort < 1 || stream.ListenPort > 65535 {
+		return fmt.Errorf("listen_port must be between 1 and 65535")
+	}
+	switch stream.Protocol {
+	case StreamProtocolTCP:
+		if reservedStreamPorts[stream.ListenPort] {
+			return fmt.Errorf("listen_port %d/tcp is reserved for HTTP proxies", stream.ListenPort)
+		}
+	case StreamProtocolUDP:
+		if http3 && stream.ListenPort == HTTP3Port {
+			return fmt.Errorf("listen_port %d/udp is reserved for HTTP/3", stream.ListenPort)
+		}
+	default:
+		return fmt.Errorf("protocol must be %s or %s", StreamProtocolTCP, StreamProtocolUDP)
+	}
+
+	if len(stream.Targets) == 0 {
+		return fmt.Errorf("targets must contain at least one host:port")
+	}
+	if len(stream.Targets) > maxStreamTargets {
+		return fmt.Errorf("targets cannot contain more than %d entries", maxStreamTargets)
+	}
+	seen := make(map[string]bool)
+	for i, target := range stream.Targets {
+		if err := ValidateStreamTarget(target); err != nil {
+			return fmt.Errorf("targets[%d]: %w", i, err)
+		}
+		if seen[target] {
+			return fmt.Errorf("targets[%d]: duplicate target %s", i, target)
+		}
+		seen[target] = true
+	}
+
+	if err := ValidateIPRanges(stream.AllowedIPRanges); err != nil {
+		return err
+	}
+	if stream.ProxyTimeout < 1 || stream.ProxyTimeout > 86400 {
+		return fmt.Errorf("proxy_timeout must be between 1 and 86400 seconds")
+	}
+	return nil
+}
+
+// ValidateStreamTarget ensures a target is a host:port pair with a valid
+// hostname or IP address, safe to render into an upstream server line.
+func ValidateStreamTarget(target string) error {
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		return fmt.Errorf("invalid target %q: must be host:port", target)
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil || p < 1 || p > 65535 {
+		return fmt.Errorf("invalid target %q: port must be between 1 and 65535", target)
+	}
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	if err := ValidateDomain(host); err != nil {
+		return fmt.Errorf("invalid target %q: %w", target, err)
+	}
+	return nil
+}
+
+// basicAuthUsernameRegex keeps usernames to characters that are safe in an
+// htpasswd line; a ':' or newline would corrupt the file.
+var basicAuthUsernameRegex = regexp.MustCompile(`^[A-Za-z0-9._@+-]{1,64}$`)
+
+// maxBasicAuthUsers bounds the number of credentials in a single set.
+const maxBasicAuthUsers = 256
+
+// ValidateBasicAuthUsername checks a basic auth username.
+func ValidateBasicAuthUsername(username string) error {
+	if !basicAuthUsernameRegex.MatchString(username) {
+		return fmt.Errorf("invalid username %q: use 1-64 letters, digits or . _ @ + -", username)
+	}
+	return nil
+}
+
+// ValidateBasicAuthPassword checks a basic auth password. bcrypt ignores
+// everything past 72 bytes, so longer passwords are rejected rather than
+// silently truncated.
+func ValidateBasicAuthPassword(password string) error {
+	if len(password) < 8 {
+		return fmt.Errorf("password must be at least 8 characters")
+	}
+	if len(password) > 72 {
+		return fmt.Errorf("password cannot be longer than 72 bytes")
+	}
+	return nil
+}
+
+// ValidateBasicAuthCredentials checks the credentials of a new set.
+func ValidateBasicAuthCredentials(users []BasicAuthCredentialRequest) error {
+	if len(users) == 0 {
+		return fmt.Errorf("users must contain at least one credential")
+	}
+	if len(users) > maxBasicAuthUsers {
+		return fmt.Errorf("users cannot contain more than %d credentials", maxBasicAuthUsers)
+	}
+	seen := make(map[string]bool)
+	for i, u := range users {
+		if err := ValidateBasicAuthUsername(u.Username); err != nil {
+			return fmt.Errorf("users[%d]: %w", i, err)
+		}
+		if err := ValidateBasicAuthPassword(u.Password); err != nil {
+			return fmt.Errorf("users[%d]: %w", i, err)
+		}
+		if seen[u.Username] {
+			return fmt.Errorf("users[%d]: duplicate username %s", i, u.Username)
+		}
+		seen[u.Username] = true
+	}
+	return nil
+}
+
+// maxAccessListEntries bounds the number of entries in a single access list.
+const maxAccessListEntries = 256
+
+// ValidateAccessListEntries checks the entries of an access list. Each
+// address must be an IPv4 or IPv6 address or CIDR range, listed once.
+func ValidateAccessListEntries(entries []AccessListEntry) error {
+	if len(entries) == 0 {
+		return fmt.Errorf("entries must contain at least one address")
+	}
+	if len(entries) > maxAccessListEntries {
+		return fmt.Errorf("entries cannot contain more than %d addresses", maxAccessListEntries)
+	}
+	seen := make(map[string]bool)
+	for i, e := range entries {
+		if e.Action != AccessActionAllow && e.Action != AccessActionDeny {
+			return fmt.Errorf("entries[%d]: action must be %s or %s", i, AccessActionAllow, AccessActionDeny)
+		}
+		if _, _, err := net.ParseCIDR(e.Address); err != nil && net.ParseIP(e.Address) == nil {
+			return fmt.Errorf("entries[%d]: invalid address %q: must be an IP address or CIDR", i, e.Address)
+		}
+		if seen[e.Address] {
+			return fmt.Errorf("entries[%d]: duplicate address %s", i, e.Address)
+		}
+		seen[e.Address] = true
+	}
+	return nil
+}
+
+// maxGeoIPCountries bounds the number of countries in a GeoIP rule.
+const maxGeoIPCountries = 250
+
+var countryCodeRegex = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// ValidateGeoIPRule checks a proxy's country rule. Countries are upper-case
+// ISO 3166-1 alpha-2 codes, each listed once.
+func ValidateGeoIPRule(rule *GeoIPRule) error {
+	if !rule.Enabled() {
+		return nil
+	}
+	if rule.Action != AccessActionAllow && rule.Action != AccessActionDeny {
+		return fmt.Errorf("geoip action must be %s or %s", AccessActionAllow, AccessActionDeny)
+	}
+	if len(rule.Countries) == 0 {
+		return fmt.Errorf("geoip countries must contain at least one country")
+	}
+	if len(rule.Countries) > maxGeoIPCountries {
+		return fmt.Errorf("geoip countries cannot contain more than %d countries", maxGeoIPCountries)
+	}
+	seen := make(map[string]bool)
+	for i, country := range rule.Countries {
+		if !countryCodeRegex.MatchString(country) {
+			return fmt.Errorf("geoip countries[%d]: invalid country code %q: must be two upper-case letters", i, country)
+		}
+		if seen[country] {
+			return fmt.Errorf("geoip countries[%d]: duplicate country %s", i, country)
+		}
+		seen[country] = true
+	}
+	return nil
+}
+
+// maxCommonNameLength is the X.509 upper bound of a subject common name.
+const maxCommonNameLength = 64
+
+// Validity bounds of internal certificates, in days. Server certificates
+// stay within the 825 days Apple platforms accept from private CAs.
+const (
+	maxCAValidityDays            = 30 * 365
+	maxServerCertificateValidity = 825
+	maxClientCertificateValidity = 10 * 365
+)
+
+// validateCommonName checks a subject common name entered by the user.
+func validateCommonName(field, name string) error {
+	if name == "" {
+		return fmt.Errorf("%s is required", field)
+	}
+	if len(name) > maxCommonNameLength {
+		return fmt.Errorf("%s cannot be longer than %d characters", field, maxCommonNameLength)
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("%s cannot contain control characters", field)
+		}
+	}
+	return nil
+}
+
+// ValidateCertificateAuthorityRequest checks a request to generate a CA
+// with defaults applied.
+func ValidateCertificateAuthorityRequest(req *CertificateAuthorityCreateRequest) error {
+	if err := validateCommonName("name", req.Name); err != nil {
+		return err
+	}
+	if err := validateCommonName("common_name", req.CommonName); err != nil {
+		return err
+	}
+	if req.ParentID < 0 {
+		return fmt.Errorf("parent_id is invalid")
+	}
+	if req.ValidityDays < 1 || req.ValidityDays > maxCAValidityDays {
+		return fmt.Errorf("validity_days must be between 1 and %d", maxCAValidityDays)
+	}
+	return nil
+}
+
+// ValidateInternalCertificateRequest checks a request for an internal
+// certificate with defaults applied. Server certificates name hosts, so
+// their common name and DNS names must be host names or wildcards.
+func ValidateInternalCertificateRequest(req *InternalCertificateRequest) error {
+	maxValidity := maxServerCertificateValidity
+	switch req.Usage {
+	case CertificateUsageServer:
+		if err := ValidateServerName(req.CommonName); err != nil {
+			return fmt.Errorf("common_name: %w", err)
+		}
+		for i, name := range req.DNSNames {
+			if err := ValidateServerName(name); err != nil {
+				return fmt.Errorf("dns_names[%d]: %w", i, err)
+			}
+		}
+		for i, addr := range req.IPAddresses {
+			if net.ParseIP(addr) == nil {
+				return fmt.Errorf("ip_addresses[%d]: invalid IP address %q", i, addr)
+			}
+		}
+	case CertificateUsageClient:
+		if err := validateCommonName("common_name", req.CommonName); err != nil {
+			return err
+		}
+		if len(req.DNSNames) > 0 || len(req.IPAddresses) > 0 {
+			return fmt.Errorf("client certificates cannot have dns_names or ip_addresses")
+		}
+		maxValidity = maxClientCertificateValidity
+	default:
+		return fmt.Errorf("usage must be %s or %s", CertificateUsageServer, CertificateUsageClient)
+	}
+	if req.ValidityDays < 1 || req.ValidityDays > maxValidity {
+		return fmt.Errorf("validity_days of %s certificates must be between 1 and %d", req.Usage, maxValidity)
+	}
+	return nil
+}
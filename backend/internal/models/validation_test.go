@@ -1,6 +1,10 @@
 package models
 
-import "testing"
+import (
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestValidateDomain(t *testing.T) {
 	valid := []string{
@@ -87,3 +91,849 @@ func TestValidateBackendURL(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateUpstreamServers(t *testing.T) {
+	valid := [][]UpstreamServer{
+		nil,
+		{{URL: "http://app1:8080"}},
+		{{URL: "http://app1:8080", Weight: 3}, {URL: "http://app2:8080", MaxFails: 2, FailTimeout: 10}, {URL: "http://app3:8080", Backup: true}},
+		{{URL: "https://10.0.0.1"}, {URL: "https://10.0.0.2/"}},
+	}
+	for _, servers := range valid {
+		if err := ValidateUpstreamServers(servers, LoadBalanceLeastConn); err != nil {
+			t.Errorf("ValidateUpstreamServers(%v) = %v, want nil", servers, err)
+		}
+	}
+
+	invalid := [][]UpstreamServer{
+		{{URL: "backend:8080"}},
+		{{URL: "http://app1:8080/api"}},
+		{{URL: "http://app1:8080?x=1"}},
+		{{URL: "http://app1:8080"}, {URL: "https://app2:8443"}},
+		{{URL: "http://app1:8080"}, {URL: "http://app1:8080"}},
+		{{URL: "http://app1:8080", Backup: true}},
+		{{URL: "http://app1:8080", Weight: -1}},
+		{{URL: "http://app1:8080;\n}"}},
+	}
+	for _, servers := range invalid {
+		if err := ValidateUpstreamServers(servers, ""); err == nil {
+			t.Errorf("ValidateUpstreamServers(%v) = nil, want error", servers)
+		}
+	}
+
+	ipHashWithBackup := []UpstreamServer{{URL: "http://app1:8080"}, {URL: "http://app2:8080", Backup: true}}
+	if err := ValidateUpstreamServers(ipHashWithBackup, LoadBalanceIPHash); err == nil {
+		t.Errorf("expected ip_hash pool with backup member to be rejected")
+	}
+	if err := ValidateUpstreamServers(nil, "random"); err == nil {
+		t.Errorf("expected unknown load_balance_method to be rejected")
+	}
+}
+
+func TestValidateProxyLocations(t *testing.T) {
+	valid := [][]ProxyLocation{
+		nil,
+		{{Path: "/api/", MatchType: LocationMatchPrefix, TargetURL: "http://backend:6080/api/"}},
+		{{Path: "/app", MatchType: LocationMatchPrefix, TargetURL: "http://app:8080/base", StripPrefix: true}},
+		{{Path: "/", MatchType: LocationMatchExact, TargetURL: "http://landing:80"}},
+		{{Path: `^/img/(\w+)\.png$`, MatchType: LocationMatchRegex, TargetURL: "http://images:8000", Rewrite: "/static/$1.png"}},
+		{
+			{Path: "/api", MatchType: LocationMatchPrefix, TargetURL: "http://a:1"},
+			{Path: "/api", MatchType: LocationMatchExact, TargetURL: "http://b:1"},
+		},
+	}
+	for _, locations := range valid {
+		if err := ValidateProxyLocations(locations, false); err != nil {
+			t.Errorf("ValidateProxyLocations(%v) = %v, want nil", locations, err)
+		}
+	}
+
+	invalid := [][]ProxyLocation{
+		{{Path: "/", MatchType: LocationMatchPrefix, TargetURL: "http://a:1"}},
+		{{Path: "api/", MatchType: LocationMatchPrefix, TargetURL: "http://a:1"}},
+		{{Path: "/api/ { }", MatchType: LocationMatchPrefix, TargetURL: "http://a:1"}},
+		{{Path: "/api/", MatchType: "glob", TargetURL: "http://a:1"}},
+		{{Path: "/api/", MatchType: LocationMatchPrefix, TargetURL: "backend:6080"}},
+		{{Path: "/.well-known/acme-challenge/", MatchType: LocationMatchPrefix, TargetURL: "http://a:1"}},
+		{{Path: "/x", MatchType: LocationMatchExact, TargetURL: "http://a:1", StripPrefix: true}},
+		{{Path: "/x", MatchType: LocationMatchPrefix, TargetURL: "http://a:1", StripPrefix: true, Rewrite: "/y"}},
+		{{Path: "/x", MatchType: LocationMatchPrefix, TargetURL: "http://a:1/base", Rewrite: "/y"}},
+		{{Path: "/x", MatchType: LocationMatchPrefix, TargetURL: "http://a:1", Rewrite: "/$host"}},
+		{{Path: "^/(a", MatchType: LocationMatchRegex, TargetURL: "http://a:1"}},
+		{{Path: "^/a;", MatchType: LocationMatchRegex, TargetURL: "http://a:1"}},
+		{{Path: "^/a", MatchType: LocationMatchRegex, TargetURL: "http://a:1/base"}},
+		{
+			{Path: "/api/", MatchType: LocationMatchPrefix, TargetURL: "http://a:1"},
+			{Path: "/api/", MatchType: LocationMatchPrefix, TargetURL: "http://b:1"},
+		},
+	}
+	for _, locations := range invalid {
+		if err := ValidateProxyLocations(locations, false); err == nil {
+			t.Errorf("ValidateProxyLocations(%v) = nil, want error", locations)
+		}
+	}
+
+	socketIO := []ProxyLocation{{Path: "/socket.io/", MatchType: LocationMatchPrefix, TargetURL: "http://a:1"}}
+	if err := ValidateProxyLocations(socketIO, false); err != nil {
+		t.Errorf("expected /socket.io/ to be allowed without ws_enabled, got %v", err)
+	}
+	if err := ValidateProxyLocations(socketIO, true); err == nil {
+		t.Errorf("expected /socket.io/ to be reserved when ws_enabled is set")
+	}
+}
+
+func TestValidateAliases(t *testing.T) {
+	valid := [][]string{
+		nil,
+		{"www.example.com"},
+		{"www.example.com", "*.apps.example.com", "*.example.org"},
+	}
+	for _, aliases := range valid {
+		if err := ValidateAliases("example.com", aliases); err != nil {
+			t.Errorf("ValidateAliases(%v) = %v, want nil", aliases, err)
+		}
+	}
+
+	invalid := [][]string{
+		{"EXAMPLE.com"},
+		{"www.example.com", "WWW.example.com"},
+		{"*.com"},
+		{"*"},
+		{"www.*.example.com"},
+		{"*.*.example.com"},
+		{"bad domain.com"},
+		{"example.com;"},
+	}
+	for _, aliases := range invalid {
+		if err := ValidateAliases("example.com", aliases); err == nil {
+			t.Errorf("ValidateAliases(%v) = nil, want error", aliases)
+		}
+	}
+}
+
+func TestMatchServerName(t *testing.T) {
+	cases := []struct {
+		pattern, host string
+		want          bool
+	}{
+		{"example.com", "example.com", true},
+		{"example.com", "EXAMPLE.COM", true},
+		{"example.com", "www.example.com", false},
+		{"*.apps.example.com", "foo.apps.example.com", true},
+		{"*.apps.example.com", "a.b.apps.example.com", true},
+		{"*.apps.example.com", "apps.example.com", false},
+		{"*.apps.example.com", "fooapps.example.com", false},
+	}
+	for _, tc := range cases {
+		if got := MatchServerName(tc.pattern, tc.host); got != tc.want {
+			t.Errorf("MatchServerName(%q, %q) = %v, want %v", tc.pattern, tc.host, got, tc.want)
+		}
+	}
+}
+
+func TestValidateStreamProxy(t *testing.T) {
+	base := func() *StreamProxy {
+		return &StreamProxy{
+			Name:         "postgres",
+			ListenPort:   5432,
+			Protocol:     StreamProtocolTCP,
+			Targets:      []string{"db:5432"},
+			ProxyTimeout: DefaultStreamProxyTimeout,
+		}
+	}
+
+	valid := []func(*StreamProxy){
+		func(s *StreamProxy) {},
+		func(s *StreamProxy) { s.Protocol = StreamProtocolUDP; s.ListenPort = 443 },
+		func(s *StreamProxy) { s.Targets = []string{"10.0.0.1:5432", "[::1]:5432"} },
+		func(s *StreamProxy) { s.AllowedIPRanges = "10.0.0.0/8, 192.168.1.10" },
+	}
+	for i, mutate := range valid {
+		s := base()
+		mutate(s)
+		if err := ValidateStreamProxy(s, false); err != nil {
+			t.Errorf("valid case %d: ValidateStreamProxy = %v, want nil", i, err)
+		}
+	}
+
+	invalid := []func(*StreamProxy){
+		func(s *StreamProxy) { s.Name = " " },
+		func(s *StreamProxy) { s.ListenPort = 0 },
+		func(s *StreamProxy) { s.ListenPort = 70000 },
+		func(s *StreamProxy) { s.ListenPort = 443 },
+		func(s *StreamProxy) { s.Protocol = "sctp" },
+		func(s *StreamProxy) { s.Targets = nil },
+		func(s *StreamProxy) { s.Targets = []string{"db"} },
+		func(s *StreamProxy) { s.Targets = []string{"db:0"} },
+		func(s *StreamProxy) { s.Targets = []string{"db;evil:5432"} },
+		func(s *StreamProxy) { s.Targets = []string{"db:5432", "db:5432"} },
+		func(s *StreamProxy) { s.AllowedIPRanges = "10.0.0.0/8; deny" },
+		func(s *StreamProxy) { s.ProxyTimeout = 0 },
+	}
+	for i, mutate := range invalid {
+		s := base()
+		mutate(s)
+		if err := ValidateStreamProxy(s, false); err == nil {
+			t.Errorf("invalid case %d: ValidateStreamProxy = nil, want error", i)
+		}
+	}
+
+	// With HTTP/3 on, 443/udp belongs to the QUIC listeners
+	quic := base()
+	quic.Protocol = StreamProtocolUDP
+	quic.ListenPort = HTTP3Port
+	if err := ValidateStreamProxy(quic, true); err == nil {
+		t.Errorf("ValidateStreamProxy(443/udp with HTTP/3) = nil, want error")
+	}
+	quic.ListenPort = 8443
+	if err := ValidateStreamProxy(quic, true); err != nil {
+		t.Errorf("ValidateStreamProxy(8443/udp with HTTP/3) = %v, want nil", err)
+	}
+}
+
+func TestValidatePassthroughProxy(t *testing.T) {
+	base := func() *Proxy {
+		return &Proxy{
+			Domain:    "k8s.example.com",
+			TargetURL: "https://10.0.0.5:443",
+			SSLMode:   SSLModePassthrough,
+		}
+	}
+
+	if err := ValidatePassthroughProxy(base()); err != nil {
+		t.Errorf("ValidatePassthroughProxy(valid) = %v, want nil", err)
+	}
+
+	terminate := base()
+	terminate.SSLMode = SSLModeTerminate
+	terminate.SSLEnabled = true
+	terminate.Locations = []ProxyLocation{{Path: "/api/"}}
+	if err := ValidatePassthroughProxy(terminate); err != nil {
+		t.Errorf("ValidatePassthroughProxy(terminate) = %v, want nil", err)
+	}
+
+	invalid := []func(*Proxy){
+		func(p *Proxy) { p.SSLEnabled = true },
+		func(p *Proxy) { p.Locations = []ProxyLocation{{Path: "/api/"}} },
+		func(p *Proxy) { p.Aliases = []string{"www.k8s.example.com"}; p.CanonicalRedirect = true },
+		func(p *Proxy) { p.TargetURL = "https://10.0.0.5/app" },
+		func(p *Proxy) { p.UpstreamServers = []UpstreamServer{{URL: "https://10.0.0.6?x=1"}} },
+		func(p *Proxy) { p.RequireUPMLogin = true },
+		func(p *Proxy) { p.ForwardAuth = &ForwardAuth{URL: "http://authelia:9091/api/verify"} },
+		func(p *Proxy) { p.GeoIP = &GeoIPRule{Action: AccessActionDeny, Countries: []string{"DE"}} },
+		func(p *Proxy) { p.ClientAuth = &ClientAuth{Mode: ClientAuthModeOn, CABundle: "ca", VerifyDepth: 1} },
+	}
+	for i, mutate := range invalid {
+		p := base()
+		mutate(p)
+		if err := ValidatePassthroughProxy(p); err == nil {
+			t.Errorf("invalid case %d: ValidatePassthroughProxy = nil, want error", i)
+		}
+	}
+
+	if err := ValidateSSLMode("reencrypt"); err == nil {
+		t.Errorf("ValidateSSLMode(reencrypt) = nil, want error")
+	}
+}
+
+func TestValidateHostType(t *testing.T) {
+	redirect := func() *Proxy {
+		p := &Proxy{Domain: "old.example.com", TargetURL: "https://new.example.com", Type: ProxyTypeRedirect}
+		p.ApplyHostTypeDefaults()
+		return p
+	}
+	static := func() *Proxy {
+		p := &Proxy{Domain: "docs.example.com", TargetURL: "http://ignored:8080", Type: ProxyTypeStatic}
+		p.ApplyHostTypeDefaults()
+		return p
+	}
+
+	r := redirect()
+	if err := ValidateHostType(r); err != nil {
+		t.Errorf("ValidateHostType(redirect) = %v, want nil", err)
+	}
+	if r.Redirect.StatusCode != DefaultRedirectStatusCode {
+		t.Errorf("redirect status_code = %d, want %d", r.Redirect.StatusCode, DefaultRedirectStatusCode)
+	}
+	st := static()
+	if err := ValidateHostType(st); err != nil {
+		t.Errorf("ValidateHostType(static) = %v, want nil", err)
+	}
+	if st.Static.Root != "docs.example.com" || st.Static.Index != DefaultStaticIndex || st.TargetURL != "" {
+		t.Errorf("static defaults = %+v, target %q", st.Static, st.TargetURL)
+	}
+	st.Locations = []ProxyLocation{{Path: "/api/", TargetURL: "http://api:8080"}}
+	if err := ValidateHostType(st); err != nil {
+		t.Errorf("ValidateHostType(static with locations) = %v, want nil", err)
+	}
+
+	invalidRedirects := []func(*Proxy){
+		func(p *Proxy) { p.Redirect.StatusCode = 303 },
+		func(p *Proxy) { p.TargetURL = "ftp://new.example.com" },
+		func(p *Proxy) { p.TargetURL = "https://new.example.com/$host" },
+		func(p *Proxy) { p.TargetURL = "https://new.example.com/?a=1"; p.Redirect.PreserveQuery = true },
+		func(p *Proxy) { p.Locations = []ProxyLocation{{Path: "/api/"}} },
+		func(p *Proxy) { p.WSEnabled = true },
+		func(p *Proxy) { p.CacheEnabled = true },
+		func(p *Proxy) { p.SSLMode = SSLModePassthrough },
+	}
+	for i, mutate := range invalidRedirects {
+		p := redirect()
+		mutate(p)
+		if err := ValidateHostType(p); err == nil {
+			t.Errorf("invalid redirect case %d: ValidateHostType = nil, want error", i)
+		}
+	}
+
+	invalidStatics := []func(*Proxy){
+		func(p *Proxy) { p.Static.Root = "../etc" },
+		func(p *Proxy) { p.Static.Root = "/etc/nginx" },
+		func(p *Proxy) { p.Static.Root = "site/../../etc" },
+		func(p *Proxy) { p.Static.Root = "site; deny all" },
+		func(p *Proxy) { p.Static.Index = "index.html /etc/passwd" },
+		func(p *Proxy) { p.UpstreamServers = []UpstreamServer{{URL: "http://10.0.0.6"}} },
+	}
+	for i, mutate := range invalidStatics {
+		p := static()
+		mutate(p)
+		if err := ValidateHostType(p); err == nil {
+			t.Errorf("invalid static case %d: ValidateHostType = nil, want error", i)
+		}
+	}
+
+	if err := ValidateProxyType("stream"); err == nil {
+		t.Errorf("ValidateProxyType(stream) = nil, want error")
+	}
+}
+
+func TestValidateBasicAuthCredentials(t *testing.T) {
+	valid := []BasicAuthCredentialRequest{
+		{Username: "alice", Password: "correct-horse"},
+		{Username: "bob@example.com", Password: "battery-staple"},
+	}
+	if err := ValidateBasicAuthCredentials(valid); err != nil {
+		t.Errorf("ValidateBasicAuthCredentials(valid) = %v, want nil", err)
+	}
+
+	invalid := [][]BasicAuthCredentialRequest{
+		nil,
+		{{Username: "alice:admin", Password: "correct-horse"}},
+		{{Username: "alice\n", Password: "correct-horse"}},
+		{{Username: "", Password: "correct-horse"}},
+		{{Username: "alice", Password: "short"}},
+		{{Username: "alice", Password: strings.Repeat("x", 73)}},
+		{{Username: "alice", Password: "correct-horse"}, {Username: "alice", Password: "battery-staple"}},
+	}
+	for i, users := range invalid {
+		if err := ValidateBasicAuthCredentials(users); err == nil {
+			t.Errorf("invalid case %d: ValidateBasicAuthCredentials = nil, want error", i)
+		}
+	}
+}
+
+func TestValidateAccessListEntries(t *testing.T) {
+	valid := []AccessListEntry{
+		{Action: AccessActionDeny, Address: "192.168.1.13"},
+		{Action: AccessActionAllow, Address: "192.168.1.0/24"},
+		{Action: AccessActionAllow, Address: "2001:db8::/32"},
+	}
+	if err := ValidateAccessListEntries(valid); err != nil {
+		t.Errorf("ValidateAccessListEntries(valid) = %v, want nil", err)
+	}
+
+	invalid := [][]AccessListEntry{
+		nil,
+		{{Action: "permit", Address: "10.0.0.1"}},
+		{{Action: AccessActionAllow, Address: "10.0.0.0/33"}},
+		{{Action: AccessActionAllow, Address: "all"}},
+		{{Action: AccessActionAllow, Address: "10.0.0.1; deny all"}},
+		{{Action: AccessActionAllow, Address: "10.0.0.1"}, {Action: AccessActionDeny, Address: "10.0.0.1"}},
+	}
+	for i, entries := range invalid {
+		if err := ValidateAccessListEntries(entries); err == nil {
+			t.Errorf("invalid case %d: ValidateAccessListEntries = nil, want error", i)
+		}
+	}
+}
+
+func TestValidateGeoIPRule(t *testing.T) {
+	valid := []*GeoIPRule{
+		nil,
+		{},
+		{Action: AccessActionAllow, Countries: []string{"DE", "AT", "CH"}},
+		{Action: AccessActionDeny, Countries: []string{"KP"}},
+	}
+	for i, rule := range valid {
+		if err := ValidateGeoIPRule(rule); err != nil {
+			t.Errorf("valid case %d: ValidateGeoIPRule = %v, want nil", i, err)
+		}
+	}
+
+	invalid := []*GeoIPRule{
+		{Action: "block", Countries: []string{"DE"}},
+		{Action: AccessActionAllow},
+		{Action: AccessActionAllow, Countries: []string{"de"}},
+		{Action: AccessActionAllow, Countries: []string{"DEU"}},
+		{Action: AccessActionAllow, Countries: []string{"D;"}},
+		{Action: AccessActionAllow, Countries: []string{"DE", "DE"}},
+	}
+	for i, rule := range invalid {
+		if err := ValidateGeoIPRule(rule); err == nil {
+			t.Errorf("invalid case %d: ValidateGeoIPRule = nil, want error", i)
+		}
+	}
+}
+
+func TestValidateClientAuth(t *testing.T) {
+	base := func() *ClientAuth {
+		return &ClientAuth{Mode: ClientAuthModeOn, CABundle: "-----BEGIN CERTIFICATE-----", VerifyDepth: DefaultClientAuthVerifyDepth}
+	}
+
+	valid := []func(*ClientAuth){
+		func(c *ClientAuth) {},
+		func(c *ClientAuth) { c.Mode = ClientAuthModeOptional; c.VerifyDepth = 3 },
+		func(c *ClientAuth) { c.DNHeader = "X-Client-DN" },
+	}
+	for i, mutate := range valid {
+		ca := base()
+		mutate(ca)
+		if err := ValidateClientAuth(ca); err != nil {
+			t.Errorf("valid case %d: ValidateClientAuth = %v, want nil", i, err)
+		}
+	}
+	if err := ValidateClientAuth(nil); err != nil {
+		t.Errorf("ValidateClientAuth(nil) = %v, want nil", err)
+	}
+
+	invalid := []func(*ClientAuth){
+		func(c *ClientAuth) { c.Mode = "optional_no_ca" },
+		func(c *ClientAuth) { c.CABundle = "  " },
+		func(c *ClientAuth) { c.CABundle = strings.Repeat("x", maxClientCABundleSize+1) },
+		func(c *ClientAuth) { c.VerifyDepth = 0 },
+		func(c *ClientAuth) { c.VerifyDepth = maxClientAuthVerifyDepth + 1 },
+		func(c *ClientAuth) { c.DNHeader = "X-Client-DN $ssl_client_s_dn" },
+		func(c *ClientAuth) { c.DNHeader = "Connection" },
+		func(c *ClientAuth) { c.DNHeader = "host" },
+	}
+	for i, mutate := range invalid {
+		ca := base()
+		mutate(ca)
+		if err := ValidateClientAuth(ca); err == nil {
+			t.Errorf("invalid case %d: ValidateClientAuth = nil, want error", i)
+		}
+	}
+}
+
+func TestValidateTLSPolicy(t *testing.T) {
+	valid := []*TLSPolicy{
+		nil,
+		{},
+		{Profile: TLSProfileModern},
+		{Profile: TLSProfileOld, HSTS: &HSTSPolicy{Enabled: false}},
+		{Profile: TLSProfileCustom, Protocols: []string{TLSProtocol12}, Ciphers: "ECDHE-RSA-AES128-GCM-SHA256:!aNULL:@SECLEVEL=1", PreferServerCiphers: true},
+		{HSTS: &HSTSPolicy{Enabled: true, IncludeSubDomains: true, Preload: true}},
+		{HSTS: &HSTSPolicy{Enabled: true, MaxAge: 300}},
+		{OCSPStapling: true},
+		{OCSPStapling: true, Resolvers: []string{"1.1.1.1", "::1", "10.0.0.53:5353", "[2001:db8::53]:53"}, ResolverTimeout: 10},
+		{SessionTickets: true, SessionTimeout: 1440},
+	}
+	for i, policy := range valid {
+		if err := ValidateTLSPolicy(policy); err != nil {
+			t.Errorf("valid case %d: ValidateTLSPolicy = %v, want nil", i, err)
+		}
+	}
+
+	invalid := []*TLSPolicy{
+		{Profile: "strict"},
+		{Profile: TLSProfileModern, Protocols: []string{TLSProtocol12}},
+		{Profile: TLSProfileIntermediate, Ciphers: "HIGH"},
+		{PreferServerCiphers: true},
+		{Profile: TLSProfileCustom},
+		{Profile: TLSProfileCustom, Protocols: []string{"SSLv3"}},
+		{Profile: TLSProfileCustom, Protocols: []string{TLSProtocol13, TLSProtocol13}},
+		{Profile: TLSProfileCustom, Protocols: []string{TLSProtocol12}, Ciphers: "HIGH; return 200"},
+		{Profile: TLSProfileCustom, Protocols: []string{TLSProtocol12}, Ciphers: strings.Repeat("A", maxTLSCiphersLength+1)},
+		{HSTS: &HSTSPolicy{Enabled: true, MaxAge: -1}},
+		{HSTS: &HSTSPolicy{Enabled: true, MaxAge: maxHSTSMaxAge + 1}},
+		{HSTS: &HSTSPolicy{Enabled: true, Preload: true}},
+		{HSTS: &HSTSPolicy{Enabled: true, MaxAge: 86400, IncludeSubDomains: true, Preload: true}},
+		{Resolvers: []string{"1.1.1.1"}},
+		{OCSPStapling: true, Resolvers: []string{"dns.example.com"}},
+		{OCSPStapling: true, Resolvers: []string{"1.1.1.1:0"}},
+		{OCSPStapling: true, Resolvers: []string{"1.1.1.1; include /etc/passwd"}},
+		{OCSPStapling: true, ResolverTimeout: maxOCSPResolverWait + 1},
+		{SessionTimeout: -1},
+		{SessionTimeout: maxTLSSessionTimeout + 1},
+	}
+	for i, policy := range invalid {
+		if err := ValidateTLSPolicy(policy); err == nil {
+			t.Errorf("invalid case %d: ValidateTLSPolicy = nil, want error", i)
+		}
+	}
+}
+
+func TestTLSPolicy_ApplyDefaults(t *testing.T) {
+	policy := (&Proxy{}).EffectiveTLSPolicy()
+	if policy.Profile != TLSProfileIntermediate || len(policy.Protocols) != 2 || policy.Ciphers == "" {
+		t.Errorf("expected the intermediate profile by default, got %+v", policy)
+	}
+	if got := policy.HSTS.HeaderValue(); got != "max-age=31536000; includeSubDomains" {
+		t.Errorf("default HSTS header = %q", got)
+	}
+	if policy.SessionTimeout != DefaultTLSSessionTimeout || len(policy.Resolvers) != 0 {
+		t.Errorf("unexpected session or resolver defaults: %+v", policy)
+	}
+
+	hsts := &HSTSPolicy{Enabled: true, Preload: true, IncludeSubDomains: true}
+	proxy := &Proxy{TLS: &TLSPolicy{Profile: TLSProfileOld, HSTS: hsts, OCSPStapling: true}}
+	policy = proxy.EffectiveTLSPolicy()
+	if !policy.PreferServerCiphers || policy.Protocols[0] != TLSProtocol10 {
+		t.Errorf("expected the old profile's settings, got %+v", policy)
+	}
+	if got := policy.HSTS.HeaderValue(); got != "max-age=31536000; includeSubDomains; preload" {
+		t.Errorf("HSTS header = %q", got)
+	}
+	if hsts.MaxAge != 0 {
+		t.Errorf("EffectiveTLSPolicy must not modify the proxy's policy")
+	}
+	if strings.Join(policy.Resolvers, " ") != DefaultOCSPResolver || policy.ResolverTimeout != DefaultOCSPResolverTimeout {
+		t.Errorf("expected the default resolver for OCSP stapling, got %+v", policy)
+	}
+	if (&HSTSPolicy{Enabled: false, MaxAge: 600}).HeaderValue() != "" {
+		t.Errorf("expected no header when HSTS is off")
+	}
+}
+
+func TestValidateHTTP3(t *testing.T) {
+	if err := ValidateHTTP3(&Proxy{HTTP3Enabled: true}); err != nil {
+		t.Errorf("expected the default TLS profile to allow HTTP/3, got %v", err)
+	}
+	tls12 := &TLSPolicy{Profile: TLSProfileCustom, Protocols: []string{TLSProtocol12}}
+	if err := ValidateHTTP3(&Proxy{HTTP3Enabled: true, TLS: tls12}); err == nil {
+		t.Error("expected HTTP/3 without TLS 1.3 to be rejected")
+	}
+	if err := ValidateHTTP3(&Proxy{TLS: tls12}); err != nil {
+		t.Errorf("expected TLS 1.2 only without HTTP/3 to be valid, got %v", err)
+	}
+	passthrough := &Proxy{SSLMode: SSLModePassthrough, HTTP3Enabled: true}
+	if err := ValidatePassthroughProxy(passthrough); err == nil {
+		t.Error("expected HTTP/3 on a passthrough proxy to be rejected")
+	}
+}
+
+func TestValidateForwardAuth(t *testing.T) {
+	base := func() *Proxy {
+		return &Proxy{
+			Domain:    "app.example.com",
+			TargetURL: "http://app:8080",
+			ForwardAuth: &ForwardAuth{
+				URL:             "http://authelia:9091/api/verify",
+				ResponseHeaders: []string{"Remote-User", "Remote-Groups"},
+				SignInURL:       "https://auth.example.com/",
+			},
+		}
+	}
+
+	if err := ValidateForwardAuth(base()); err != nil {
+		t.Errorf("ValidateForwardAuth(valid) = %v, want nil", err)
+	}
+	disabled := base()
+	disabled.ForwardAuth = &ForwardAuth{}
+	disabled.RequireUPMLogin = true
+	if err := ValidateForwardAuth(disabled); err != nil {
+		t.Errorf("ValidateForwardAuth(empty url) = %v, want nil", err)
+	}
+
+	invalid := []func(*Proxy){
+		func(p *Proxy) { p.RequireUPMLogin = true },
+		func(p *Proxy) { p.ForwardAuth.URL = "authelia:9091" },
+		func(p *Proxy) { p.ForwardAuth.URL = "http://authelia:9091/verify; return 200" },
+		func(p *Proxy) { p.ForwardAuth.SignInURL = "javascript:alert(1)" },
+		func(p *Proxy) { p.ForwardAuth.ResponseHeaders = []string{"Remote User"} },
+		func(p *Proxy) { p.ForwardAuth.ResponseHeaders = []string{"Remote-User;"} },
+		func(p *Proxy) { p.ForwardAuth.ResponseHeaders = []string{"Remote-User", "remote-user"} },
+		func(p *Proxy) { p.ForwardAuth.ResponseHeaders = []string{"Remote-User", "Host"} },
+		func(p *Proxy) { p.ForwardAuth.ResponseHeaders = []string{"Connection"} },
+		func(p *Proxy) { p.ForwardAuth.ResponseHeaders = []string{"upgrade"} },
+		func(p *Proxy) { p.ForwardAuth.ResponseHeaders = []string{"X-Forwarded-For"} },
+		func(p *Proxy) { p.ForwardAuth.ResponseHeaders = []string{"X-Real-IP"} },
+	}
+	for i, mutate := range invalid {
+		p := base()
+		mutate(p)
+		if err := ValidateForwardAuth(p); err == nil {
+			t.Errorf("invalid case %d: ValidateForwardAuth = nil, want error", i)
+		}
+	}
+}
+
+func TestForwardAuthSignInRedirect(t *testing.T) {
+	original := "https://app.example.com/search?q=a&page=2#top"
+	cases := []struct {
+		signIn string
+		want   string
+	}{
+		{"https://auth.example.com/", "https://auth.example.com/?rd=https%3A%2F%2Fapp.example.com%2Fsearch%3Fq%3Da%26page%3D2%23top"},
+		{"https://auth.example.com/oauth2/start?provider=github", "https://auth.example.com/oauth2/start?provider=github&rd=https%3A%2F%2Fapp.example.com%2Fsearch%3Fq%3Da%26page%3D2%23top"},
+	}
+	for _, tc := range cases {
+		fa := &ForwardAuth{URL: "http://authelia:9091/api/verify", SignInURL: tc.signIn}
+		if got := fa.SignInRedirect(original); got != tc.want {
+			t.Errorf("SignInRedirect with %q = %q, want %q", tc.signIn, got, tc.want)
+		}
+	}
+}
+
+func TestValidateHeaderRules(t *testing.T) {
+	valid := []ProxyHeaderRule{
+		{Direction: HeaderDirectionRequest, Action: HeaderActionSet, Name: "X-Forwarded-Proto", Value: "https"},
+		{Direction: HeaderDirectionRequest, Action: HeaderActionSet, Name: "X-Client", Value: `$remote_addr "quoted" {braces};`},
+		{Direction: HeaderDirectionRequest, Action: HeaderActionRemove, Name: "X-Real-IP"},
+		{Direction: HeaderDirectionResponse, Action: HeaderActionRemove, Name: "X-Frame-Options"},
+		{Direction: HeaderDirectionResponse, Action: HeaderActionSet, Name: "Content-Security-Policy", Value: "frame-ancestors 'self'", Always: true},
+		{Direction: HeaderDirectionResponse, Action: HeaderActionSet, Name: "X-Forwarded-Proto", Value: "https"},
+	}
+	if err := ValidateHeaderRules(valid); err != nil {
+		t.Errorf("ValidateHeaderRules(valid) = %v, want nil", err)
+	}
+
+	invalid := []ProxyHeaderRule{
+		{Direction: "both", Action: HeaderActionSet, Name: "X-A", Value: "1"},
+		{Direction: HeaderDirectionRequest, Action: "append", Name: "X-A", Value: "1"},
+		{Direction: HeaderDirectionRequest, Action: HeaderActionSet, Name: "X A", Value: "1"},
+		{Direction: HeaderDirectionRequest, Action: HeaderActionSet, Name: "X-A;", Value: "1"},
+		{Direction: HeaderDirectionRequest, Action: HeaderActionSet, Name: "X-A", Value: "1\nadd_header X-B 2"},
+		{Direction: HeaderDirectionRequest, Action: HeaderActionSet, Name: "X-A"},
+		{Direction: HeaderDirectionRequest, Action: HeaderActionRemove, Name: "X-A", Value: "1"},
+		{Direction: HeaderDirectionRequest, Action: HeaderActionSet, Name: "Connection", Value: "close"},
+		{Direction: HeaderDirectionRequest, Action: HeaderActionRemove, Name: "Host"},
+		{Direction: HeaderDirectionRequest, Action: HeaderActionSet, Name: "X-A", Value: "1", Always: true},
+		{Direction: HeaderDirectionResponse, Action: HeaderActionSet, Name: "X-A", Value: strings.Repeat("a", 4097)},
+	}
+	for i, rule := range invalid {
+		if err := ValidateHeaderRules([]ProxyHeaderRule{rule}); err == nil {
+			t.Errorf("invalid case %d: ValidateHeaderRules(%+v) = nil, want error", i, rule)
+		}
+	}
+
+	duplicate := []ProxyHeaderRule{
+		{Direction: HeaderDirectionResponse, Action: HeaderActionSet, Name: "X-Frame-Options", Value: "SAMEORIGIN"},
+		{Direction: HeaderDirectionResponse, Action: HeaderActionRemove, Name: "x-frame-options"},
+	}
+	if err := ValidateHeaderRules(duplicate); err == nil {
+		t.Errorf("ValidateHeaderRules(duplicate) = nil, want error")
+	}
+}
+
+func TestValidateCachePolicy(t *testing.T) {
+	var defaults CachePolicy
+	defaults.ApplyDefaults()
+	if err := ValidateCachePolicy(defaults); err != nil {
+		t.Errorf("ValidateCachePolicy(defaults) = %v, want nil", err)
+	}
+
+	valid := CachePolicy{
+		ZoneSizeMB:      16,
+		MaxSizeMB:       4096,
+		InactiveMinutes: 120,
+		Valid:           []CacheValidity{{Status: "200", TTL: 3600}, {Status: "any", TTL: 5}},
+		Key:             []string{"scheme", "method", "host", "request_uri", "cookie:lang", "header:Accept-Encoding", "arg:page"},
+		BypassCookies:   []string{"session_id"},
+		BypassHeaders:   []string{"Authorization"},
+		BypassArgs:      []string{"nocache"},
+	}
+	if err := ValidateCachePolicy(valid); err != nil {
+		t.Errorf("ValidateCachePolicy(valid) = %v, want nil", err)
+	}
+
+	invalid := []func(p *CachePolicy){
+		func(p *CachePolicy) { p.ZoneSizeMB = 0 },
+		func(p *CachePolicy) { p.MaxSizeMB = -1 },
+		func(p *CachePolicy) { p.InactiveMinutes = 31 * 24 * 60 },
+		func(p *CachePolicy) { p.Valid = []CacheValidity{{Status: "2xx", TTL: 60}} },
+		func(p *CachePolicy) { p.Valid = []CacheValidity{{Status: "700", TTL: 60}} },
+		func(p *CachePolicy) { p.Valid = []CacheValidity{{Status: "200", TTL: 0}} },
+		func(p *CachePolicy) { p.Valid = []CacheValidity{{Status: "200", TTL: 60}, {Status: "200", TTL: 30}} },
+		func(p *CachePolicy) { p.Key = nil },
+		func(p *CachePolicy) { p.Key = []string{"remote_addr"} },
+		func(p *CachePolicy) { p.Key = []string{"cookie:a;b"} },
+		func(p *CachePolicy) { p.Key = []string{"header:X Y"} },
+		func(p *CachePolicy) { p.BypassCookies = []string{"$session"} },
+		func(p *CachePolicy) { p.BypassHeaders = []string{"X-A;"} },
+		func(p *CachePolicy) { p.BypassArgs = []string{"a b"} },
+	}
+	for i, mutate := range invalid {
+		policy := valid
+		mutate(&policy)
+		if err := ValidateCachePolicy(policy); err == nil {
+			t.Errorf("invalid case %d: ValidateCachePolicy(%+v) = nil, want error", i, policy)
+		}
+	}
+}
+
+func TestValidateRateLimitPolicy(t *testing.T) {
+	defaults := (&Proxy{RateLimitRPS: 15}).EffectiveRateLimitPolicy()
+	if err := ValidateRateLimitPolicy(defaults); err != nil {
+		t.Errorf("ValidateRateLimitPolicy(defaults) = %v, want nil", err)
+	}
+
+	delay := 3
+	valid := RateLimitPolicy{
+		Rate:                100,
+		Per:                 RateLimitPerMinute,
+		Burst:               10,
+		Delay:               &delay,
+		Key:                 "header:X-Tenant-ID",
+		ExemptCIDRs:         []string{"10.0.0.0/8", "192.168.1.5", "2001:db8::/32"},
+		ConnLimit:           20,
+		Status:              503,
+		ResponseBody:        `{"error": "rate limited"}`,
+		ResponseContentType: "application/json",
+		RetryAfter:          30,
+		ZoneSizeMB:          10,
+	}
+	if err := ValidateRateLimitPolicy(valid); err != nil {
+		t.Errorf("ValidateRateLimitPolicy(valid) = %v, want nil", err)
+	}
+
+	invalid := []func(p *RateLimitPolicy){
+		func(p *RateLimitPolicy) { p.Rate = 0 },
+		func(p *RateLimitPolicy) { p.Per = "hour" },
+		func(p *RateLimitPolicy) { p.Burst = 0 },
+		func(p *RateLimitPolicy) { d := 11; p.Delay = &d },
+		func(p *RateLimitPolicy) { p.Key = "remote_addr" },
+		func(p *RateLimitPolicy) { p.Key = "header:X Y" },
+		func(p *RateLimitPolicy) { p.Key = "cookie:a;b" },
+		func(p *RateLimitPolicy) { p.ExemptCIDRs = []string{"10.0.0.0/33"} },
+		func(p *RateLimitPolicy) { p.ConnLimit = -1 },
+		func(p *RateLimitPolicy) { p.Status = 200 },
+		func(p *RateLimitPolicy) { p.ResponseContentType = "text/html; charset=utf-8" },
+		func(p *RateLimitPolicy) { p.RetryAfter = -5 },
+		func(p *RateLimitPolicy) { p.ZoneSizeMB = 0 },
+	}
+	for i, mutate := range invalid {
+		policy := valid
+		mutate(&policy)
+		if err := ValidateRateLimitPolicy(policy); err == nil {
+			t.Errorf("invalid case %d: ValidateRateLimitPolicy(%+v) = nil, want error", i, policy)
+		}
+	}
+}
+
+func TestValidateHealthCheck(t *testing.T) {
+	valid := HealthCheckRequest{Path: "/healthz?full=1", ExpectedStatus: 204}.ToHealthCheck()
+	if err := ValidateHealthCheck(valid); err != nil {
+		t.Errorf("ValidateHealthCheck(valid) = %v, want nil", err)
+	}
+	if !valid.Enabled || valid.IntervalSeconds != DefaultHealthCheckIntervalSeconds {
+		t.Errorf("ToHealthCheck = %+v, want enabled with defaults", valid)
+	}
+
+	disabled := false
+	if check := (HealthCheckRequest{Enabled: &disabled}).ToHealthCheck(); check.Enabled {
+		t.Errorf("ToHealthCheck with enabled=false = %+v, want disabled", check)
+	}
+
+	invalid := []func(h *HealthCheck){
+		func(h *HealthCheck) { h.Path = "healthz" },
+		func(h *HealthCheck) { h.Path = "/health z" },
+		func(h *HealthCheck) { h.Path = "/health\r\nX: y" },
+		func(h *HealthCheck) { h.ExpectedStatus = 99 },
+		func(h *HealthCheck) { h.IntervalSeconds = 5 },
+		func(h *HealthCheck) { h.TimeoutSeconds = 0 },
+		func(h *HealthCheck) { h.TimeoutSeconds = h.IntervalSeconds },
+		func(h *HealthCheck) { h.UnhealthyThreshold = 21 },
+	}
+	for i, mutate := range invalid {
+		check := valid
+		mutate(&check)
+		if err := ValidateHealthCheck(check); err == nil {
+			t.Errorf("invalid case %d: ValidateHealthCheck(%+v) = nil, want error", i, check)
+		}
+	}
+}
+
+func TestMaintenanceApplyDefaults(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+
+	m := Maintenance{StartsAt: &past, EndsAt: &future}
+	m.ApplyDefaults(now)
+	if !m.Enabled || m.RetryAfterSeconds != DefaultMaintenanceRetryAfter {
+		t.Errorf("running window = %+v, want enabled with default retry_after", m)
+	}
+
+	m = Maintenance{Enabled: true, StartsAt: &future}
+	m.ApplyDefaults(now)
+	if m.Enabled {
+		t.Errorf("future window = %+v, want disabled until it starts", m)
+	}
+
+	m = Maintenance{Enabled: true, EndsAt: &future}
+	m.ApplyDefaults(now)
+	if !m.Enabled {
+		t.Errorf("manual maintenance with end = %+v, want enabled", m)
+	}
+}
+
+func TestValidateMaintenance(t *testing.T) {
+	now := time.Now()
+	past, future, later := now.Add(-time.Hour), now.Add(time.Hour), now.Add(2*time.Hour)
+
+	valid := Maintenance{Enabled: true, RetryAfterSeconds: 120, AllowedIPs: []string{"10.0.0.0/8", "192.168.1.5", "2001:db8::/32"}, StartsAt: &past, EndsAt: &future}
+	if err := ValidateMaintenance(&valid, now); err != nil {
+		t.Errorf("ValidateMaintenance(valid) = %v, want nil", err)
+	}
+
+	invalid := []Maintenance{
+		{RetryAfterSeconds: 0},
+		{RetryAfterSeconds: 8 * 24 * 60 * 60},
+		{RetryAfterSeconds: 60, Page: strings.Repeat("a", 256*1024+1)},
+		{RetryAfterSeconds: 60, AllowedIPs: []string{"10.0.0.0/8,1.2.3.4"}},
+		{RetryAfterSeconds: 60, AllowedIPs: []string{"any"}},
+		{RetryAfterSeconds: 60, EndsAt: &past},
+		{RetryAfterSeconds: 60, StartsAt: &later, EndsAt: &future},
+	}
+	for i, m := range invalid {
+		if err := ValidateMaintenance(&m, now); err == nil {
+			t.Errorf("invalid case %d: ValidateMaintenance(%+v) = nil, want error", i, m)
+		}
+	}
+}
+
+func TestValidateErrorPage(t *testing.T) {
+	if err := ValidateErrorPage(502, "<h1>Bad gateway</h1>"); err != nil {
+		t.Errorf("ValidateErrorPage(valid) = %v, want nil", err)
+	}
+
+	invalid := []struct {
+		code    int
+		content string
+	}{
+		{301, "<h1>Moved</h1>"},
+		{600, "<h1>Unknown</h1>"},
+		{404, "   "},
+		{500, strings.Repeat("x", MaxErrorPageSize+1)},
+	}
+	for _, tt := range invalid {
+		if err := ValidateErrorPage(tt.code, tt.content); err == nil {
+			t.Errorf("ValidateErrorPage(%d, %d bytes) = nil, want error", tt.code, len(tt.content))
+		}
+	}
+}
+
+func TestMergeErrorPages(t *testing.T) {
+	global := []ErrorPage{{StatusCode: 502, Content: "global 502"}, {StatusCode: 404, Content: "global 404"}}
+	own := []ErrorPage{{ProxyID: 3, StatusCode: 502, Content: "own 502"}, {ProxyID: 3, StatusCode: 500, Content: "own 500"}}
+
+	merged := MergeErrorPages(global, own)
+	want := []string{"global 404", "own 500", "own 502"}
+	if len(merged) != len(want) {
+		t.Fatalf("MergeErrorPages returned %d pages, want %d", len(merged), len(want))
+	}
+	for i, page := range merged {
+		if page.Content != want[i] {
+			t.Errorf("merged[%d] = %q, want %q", i, page.Content, want[i])
+		}
+	}
+}
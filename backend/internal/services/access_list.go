@@ -0,0 +1,58 @@
+package services
+
+import (
+	"fmt"
+
+	"upm-backend/internal/models"
+)
+
+// accessRuleTemplateData is one rendered allow or deny directive.
+type accessRuleTemplateData struct {
+	Action  string
+	Address string // normalized CIDR
+}
+
+// accessListTemplateData is the rendered form of an access list.
+type accessListTemplateData struct {
+	ID         int
+	Rules      []accessRuleTemplateData
+	DenyOthers bool // the list allows only its addresses
+}
+
+// buildAccessListTemplateData turns an access list into template data. The
+// list is assumed to have passed models.ValidateAccessListEntries.
+func buildAccessListTemplateData(list *models.AccessList) *accessListTemplateData {
+	data := &accessListTemplateData{ID: list.ID, DenyOthers: list.AllowsOnly()}
+	for _, e := range list.Entries {
+		for _, address := range sanitizeAllowedRanges([]string{e.Address}) {
+			data.Rules = append(data.Rules, accessRuleTemplateData{Action: e.Action, Address: address})
+		}
+	}
+	return data
+}
+
+// loadAccessLists returns the rendered access lists used by a proxy and its
+// location rules, keyed by list ID. Without a database nothing is
+// restricted.
+func (n *NginxService) loadAccessLists(proxy *models.Proxy) (map[int]*accessListTemplateData, error) {
+	lists := make(map[int]*accessListTemplateData)
+	if n.DatabaseService == nil {
+		return lists, nil
+	}
+
+	listIDs := []int{proxy.AccessListID}
+	for _, loc := range proxy.Locations {
+		listIDs = append(listIDs, loc.AccessListID)
+	}
+	for _, id := range listIDs {
+		if id == 0 || lists[id] != nil {
+			continue
+		}
+		list, err := n.DatabaseService.GetAccessList(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load access list %d: %w", id, err)
+		}
+		lists[id] = buildAccessListTemplateData(list)
+	}
+	return lists, nil
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"upm-backend/internal/models"
+)
+
+func TestGenerateProxyConfig_AccessLists_RenderRules(t *testing.T) {
+	svc := newTestNginxService(t)
+	svc.DatabaseService = newTestDatabaseService(t)
+
+	office := &models.AccessList{Name: "office", Entries: []models.AccessListEntry{
+		{Action: models.AccessActionDeny, Address: "192.168.1.13"},
+		{Action: models.AccessActionAllow, Address: "192.168.1.0/24"},
+	}}
+	blocked := &models.AccessList{Name: "blocked", Entries: []models.AccessListEntry{
+		{Action: models.AccessActionDeny, Address: "203.0.113.0/24"},
+	}}
+	for _, list := range []*models.AccessList{office, blocked} {
+		if err := svc.DatabaseService.CreateAccessList(list); err != nil {
+			t.Fatalf("CreateAccessList returned error: %v", err)
+		}
+	}
+
+	proxy := &models.Proxy{
+		ID:           19,
+		Name:         "nas",
+		Domain:       "nas.example.com",
+		TargetURL:    "http://nas:5000",
+		AccessListID: office.ID,
+		Locations: []models.ProxyLocation{
+			{Path: "/share/", MatchType: models.LocationMatchPrefix, TargetURL: "http://nas:5000", AccessListID: blocked.ID},
+		},
+		Status: "active",
+	}
+	if err := svc.GenerateProxyConfig(proxy); err != nil {
+		t.Fatalf("GenerateProxyConfig returned error: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(svc.SitesEnabledPath, "proxy-19.conf"))
+	if err != nil {
+		t.Fatalf("expected proxy config: %v", err)
+	}
+	config := string(content)
+	for _, want := range []string{
+		"deny 192.168.1.13/32;\n    allow 192.168.1.0/24;\n    deny all;",
+		"deny 203.0.113.0/24;",
+	} {
+		if !strings.Contains(config, want) {
+			t.Errorf("expected %q in config, got:\n%s", want, config)
+		}
+	}
+	if strings.Count(config, "deny all;") != 1 {
+		t.Errorf("expected only the allow list to deny other addresses, got:\n%s", config)
+	}
+}
+
+func TestMigrateDNSAllowedIPRanges_AttachesListsToProxies(t *testing.T) {
+	db := newTestDatabaseService(t)
+
+	for _, stmt := range []string{
+		`ALTER TABLE dns_records ADD COLUMN allowed_ip_ranges TEXT DEFAULT ''`,
+		`INSERT INTO dns_configs (id, provider, domain, username, password) VALUES (1, 'namecheap', 'example.com', 'user', 'secret')`,
+		`INSERT INTO dns_records (config_id, host, allowed_ip_ranges, created_at) VALUES (1, 'nas', '10.9.9.9', '2024-01-01 00:00:00')`,
+		`INSERT INTO dns_records (config_id, host, allowed_ip_ranges, created_at) VALUES (1, 'nas', '192.168.1.0/24, not-an-ip, 10.0.0.9', '2024-02-01 00:00:00')`,
+		`INSERT INTO dns_records (config_id, host, allowed_ip_ranges, created_at) VALUES (1, 'open', '10.0.0.0/8', '2024-01-01 00:00:00')`,
+		`INSERT INTO dns_records (config_id, host, allowed_ip_ranges, created_at) VALUES (1, 'open', '', '2024-02-01 00:00:00')`,
+		`INSERT INTO dns_records (config_id, host, allowed_ip_ranges) VALUES (1, 'unproxied', '10.0.0.0/8')`,
+		`INSERT INTO dns_records (config_id, host, allowed_ip_ranges) VALUES (1, '@', '172.16.0.0/12')`,
+		`INSERT INTO proxies (name, domain, target_url) VALUES ('nas', 'nas.example.com', 'http://nas:5000')`,
+		`INSERT INTO proxies (name, domain, target_url) VALUES ('open', 'open.example.com', 'http://open:80')`,
+		`INSERT INTO proxies (name, domain, target_url) VALUES ('site', 'example.com', 'http://site:80')`,
+	} {
+		if _, err := db.db.Exec(stmt); err != nil {
+			t.Fatalf("failed to set up legacy data (%s): %v", stmt, err)
+		}
+	}
+
+	if err := db.migrateDNSAllowedIPRanges(); err != nil {
+		t.Fatalf("migrateDNSAllowedIPRanges returned error: %v", err)
+	}
+
+	lists, err := db.GetAccessLists()
+	if err != nil {
+		t.Fatalf("GetAccessLists returned error: %v", err)
+	}
+	// The newest record of a host wins, and apex records never applied
+	if len(lists) != 1 || lists[0].Name != "nas.example.com" {
+		t.Fatalf("expected a list for nas.example.com only, got %+v", lists)
+	}
+	var addresses []string
+	for _, e := range lists[0].Entries {
+		if e.Action != models.AccessActionAllow {
+			t.Errorf("expected migrated entries to allow, got %q", e.Action)
+		}
+		addresses = append(addresses, e.Address)
+	}
+	if strings.Join(addresses, ",") != "192.168.1.0/24,10.0.0.9" {
+		t.Errorf("expected valid ranges to be migrated in order, got %v", addresses)
+	}
+
+	proxies, err := db.GetProxiesByAccessList(lists[0].ID)
+	if err != nil {
+		t.Fatalf("GetProxiesByAccessList returned error: %v", err)
+	}
+	if len(proxies) != 1 || proxies[0].Domain != "nas.example.com" {
+		t.Errorf("expected the list to be attached to nas.example.com, got %+v", proxies)
+	}
+	if db.columnExists("dns_records", "allowed_ip_ranges") {
+		t.Errorf("expected allowed_ip_ranges column to be dropped")
+	}
+}
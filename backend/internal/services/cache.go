@@ -0,0 +1,190 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"upm-backend/internal/models"
+)
+
+// cacheZonesConfigName is the http-context include declaring the
+// proxy_cache_path of every caching proxy. It sorts before proxy-*.conf in
+// sites-enabled, so zones are declared before they are used.
+const cacheZonesConfigName = "cache-zones.conf"
+
+// cacheTemplateData is the rendered form of a proxy's cache policy.
+type cacheTemplateData struct {
+	Zone   string
+	Key    string   // proxy_cache_key value, without quotes
+	Valid  []string // proxy_cache_valid arguments
+	Bypass string   // proxy_cache_bypass / proxy_no_cache variables, empty for none
+}
+
+// cacheZoneName is the keys_zone of a proxy's cache.
+func cacheZoneName(proxyID int) string {
+	return fmt.Sprintf("upm_cache_%d", proxyID)
+}
+
+// proxyCacheDir is the cache directory of a proxy under CachePath.
+func (n *NginxService) proxyCacheDir(proxyID int) string {
+	return filepath.Join(n.CachePath, fmt.Sprintf("proxy-%d", proxyID))
+}
+
+// headerVariable returns the nginx variable holding a request header.
+func headerVariable(name string) string {
+	return "$http_" + strings.ToLower(strings.ReplaceAll(name, "-", "_"))
+}
+
+// cacheKeyVariables maps plain cache key parts to nginx variables.
+var cacheKeyVariables = map[string]string{
+	"scheme":      "$scheme",
+	"method":      "$request_method",
+	"host":        "$host",
+	"uri":         "$uri",
+	"args":        "$args",
+	"request_uri": "$request_uri",
+}
+
+// buildCacheTemplateData turns a proxy's cache policy into template data,
+// or returns nil when nothing on the proxy is cached. The policy is assumed
+// to have passed models.ValidateCachePolicy.
+func buildCacheTemplateData(proxy *models.Proxy) *cacheTemplateData {
+	if !proxy.UsesCache() || proxy.IsPassthrough() {
+		return nil
+	}
+	policy := proxy.EffectiveCachePolicy()
+
+	var key strings.Builder
+	for _, part := range policy.Key {
+		if v, ok := cacheKeyVariables[part]; ok {
+			key.WriteString(v)
+			continue
+		}
+		kind, name, _ := strings.Cut(part, ":")
+		switch kind {
+		case "cookie":
+			key.WriteString("$cookie_" + name)
+		case "arg":
+			key.WriteString("$arg_" + name)
+		case "header":
+			key.WriteString(headerVariable(name))
+		}
+	}
+
+	var valid []string
+	for _, v := range policy.Valid {
+		valid = append(valid, fmt.Sprintf("%s %ds", v.Status, v.TTL))
+	}
+
+	var bypass []string
+	for _, name := range policy.BypassCookies {
+		bypass = append(bypass, "$cookie_"+name)
+	}
+	for _, name := range policy.BypassHeaders {
+		bypass = append(bypass, headerVariable(name))
+	}
+	for _, name := range policy.BypassArgs {
+		bypass = append(bypass, "$arg_"+name)
+	}
+
+	return &cacheTemplateData{
+		Zone:   cacheZoneName(proxy.ID),
+		Key:    key.String(),
+		Valid:  valid,
+		Bypass: strings.Join(bypass, " "),
+	}
+}
+
+// GenerateCacheZonesConfig writes the proxy_cache_path declarations of all
+// caching proxies into the shared cache-zones.conf include, or removes it
+// when no proxy caches.
+func (n *NginxService) GenerateCacheZonesConfig(proxies []models.Proxy) error {
+	var buf strings.Builder
+	for i := range proxies {
+		p := &proxies[i]
+		if !p.UsesCache() || p.IsPassthrough() {
+			continue
+		}
+		policy := p.EffectiveCachePolicy()
+		fmt.Fprintf(&buf, "# %s\nproxy_cache_path %s levels=1:2 keys_zone=%s:%dm max_size=%dm inactive=%dm use_temp_path=off;\n\n",
+			p.Domain, n.proxyCacheDir(p.ID), cacheZoneName(p.ID), policy.ZoneSizeMB, policy.MaxSizeMB, policy.InactiveMinutes)
+	}
+
+	configFile := filepath.Join(n.ConfigPath, cacheZonesConfigName)
+	enabledPath := filepath.Join(n.SitesEnabledPath, cacheZonesConfigName)
+	if buf.Len() == 0 {
+		for _, path := range []string{enabledPath, configFile} {
+			if err := n.removeFile(path); err != nil {
+				return fmt.Errorf("failed to remove cache zones config: %w", err)
+			}
+		}
+		return nil
+	}
+
+	content := []byte("# Proxy cache zones\n# This file is generated by the backend; one zone per caching proxy.\n\n" + buf.String())
+	if err := n.writeFile(configFile, content); err != nil {
+		return fmt.Errorf("failed to write cache zones config file: %w", err)
+	}
+	if err := n.writeFile(enabledPath, content); err != nil {
+		return fmt.Errorf("failed to copy cache zones config to sites-enabled: %w", err)
+	}
+	return nil
+}
+
+// syncCacheZones regenerates the cache zones include from the database.
+func (n *NginxService) syncCacheZones() error {
+	if n.DatabaseService == nil {
+		return nil
+	}
+	proxies, err := n.DatabaseService.GetProxies()
+	if err != nil {
+		return fmt.Errorf("failed to load proxies: %w", err)
+	}
+	return n.GenerateCacheZonesConfig(proxies)
+}
+
+// PurgeProxyCache deletes everything in a proxy's cache directory and
+// returns the number of cached responses removed. nginx treats the missing
+// files as cache misses, so no reload is needed.
+func (n *NginxService) PurgeProxyCache(proxyID int) (int, error) {
+	dir := n.proxyCacheDir(proxyID)
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read cache directory: %w", err)
+	}
+
+	removed := 0
+	err = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			removed++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to scan cache directory: %w", err)
+	}
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+			return 0, fmt.Errorf("failed to purge cache: %w", err)
+		}
+	}
+	return removed, nil
+}
+
+// cacheStatusHeader reports HIT, MISS, BYPASS, ... to clients. nginx skips
+// add_header when the value is empty, so uncached locations don't send it.
+var cacheStatusHeader = headerDirective{Name: "Cache-Status", Value: "$upstream_cache_status", Always: true}
+
+// addCacheStatusHeader appends the Cache-Status header unless a response
+// header rule already handles it.
+func addCacheStatusHeader(headers []headerDirective) []headerDirective {
+	if indexHeader(headers, cacheStatusHeader.Name) >= 0 {
+		return headers
+	}
+	return append(headers, cacheStatusHeader)
+}
@@ -75,8 +75,10 @@ func (c *CertificateService) ValidateCertificate(certPath, keyPath string) (*mod
 	}, nil
 }
 
-// GenerateLetsEncryptCertificate generates a certificate using Let's Encrypt
-func (c *CertificateService) GenerateLetsEncryptCertificate(domain string) (*models.Certificate, error) {
+// GenerateLetsEncryptCertificate generates a certificate using Let's Encrypt.
+// Aliases are added as subject alternative names so one certificate serves
+// every name of a proxy.
+func (c *CertificateService) GenerateLetsEncryptCertificate(domain string, aliases ...string) (*models.Certificate, error) {
 	// Check if Let's Encrypt is configured
 	if c.config.LetsEncryptEmail == "" {
 		return nil, fmt.Errorf("Let's Encrypt email not configured. Set LETSENCRYPT_EMAIL environment variable")
@@ -85,7 +87,7 @@ func (c *CertificateService) GenerateLetsEncryptCertificate(domain string) (*mod
 	// In development mode, try Let's Encrypt first, but fall back to placeholder if it fails
 	if c.config.Environment == "development" {
 		// Try Let's Encrypt first
-		cert, err := c.LetsEncrypt.GenerateCertificate(domain)
+		cert, err := c.LetsEncrypt.GenerateCertificate(domain, aliases...)
 		if err != nil {
 			// If Let's Encrypt fails in development, create a placeholder certificate
 			fmt.Printf("Let's Encrypt failed in development mode for %s: %v. Creating placeholder certificate.\n", domain, err)
@@ -94,13 +96,15 @@ func (c *CertificateService) GenerateLetsEncryptCertificate(domain string) (*mod
 		return cert, nil
 	}
 
-	// In production, validate domain is accessible
-	if err := c.LetsEncrypt.ValidateDomain(domain); err != nil {
-		return nil, fmt.Errorf("domain validation failed: %w", err)
+	// In production, validate every name is accessible
+	for _, name := range append([]string{domain}, aliases...) {
+		if err := c.LetsEncrypt.ValidateDomain(name); err != nil {
+			return nil, fmt.Errorf("domain validation failed: %w", err)
+		}
 	}
 
 	// Generate certificate using Let's Encrypt
-	cert, err := c.LetsEncrypt.GenerateCertificate(domain)
+	cert, err := c.LetsEncrypt.GenerateCertificate(domain, aliases...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate Let's Encrypt certificate: %w", err)
 	}
@@ -236,6 +240,12 @@ func (c *CertificateService) CheckCertificateExpiry(cert *models.Certificate) (b
 
 // GetCertificateInfo extracts information from a certificate file
 func (c *CertificateService) GetCertificateInfo(certPath string) (*CertificateInfo, error) {
+	return readCertificateInfo(certPath)
+}
+
+// readCertificateInfo extracts information from the first certificate of a
+// PEM file.
+func readCertificateInfo(certPath string) (*CertificateInfo, error) {
 	certData, err := os.ReadFile(certPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read certificate file: %w", err)
@@ -252,6 +262,44 @@ func (c *CertificateService) GetCertificateInfo(certPath string) (*CertificateIn
 		return nil, fmt.Errorf("failed to parse certificate: %w", err)
 	}
 
+	return newCertificateInfo(cert), nil
+}
+
+// ParseCABundle parses a PEM bundle of CA certificates, such as the client
+// CA bundle of a mutual TLS proxy. Anything but certificates is rejected,
+// so a private key pasted along with the bundle is never written to disk.
+func (c *CertificateService) ParseCABundle(bundle string) ([]CertificateInfo, error) {
+	var infos []CertificateInfo
+	rest := []byte(bundle)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("CA bundle may only contain certificates, found %s", block.Type)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate %d of CA bundle: %w", len(infos)+1, err)
+		}
+		if time.Now().After(cert.NotAfter) {
+			return nil, fmt.Errorf("CA certificate %s expired on %s", cert.Subject.CommonName, cert.NotAfter.Format("2006-01-02"))
+		}
+		infos = append(infos, *newCertificateInfo(cert))
+	}
+	if strings.TrimSpace(string(rest)) != "" {
+		return nil, fmt.Errorf("CA bundle contains data that is not PEM encoded")
+	}
+	if len(infos) == 0 {
+		return nil, fmt.Errorf("CA bundle contains no certificates")
+	}
+	return infos, nil
+}
+
+// newCertificateInfo summarizes a parsed certificate.
+func newCertificateInfo(cert *x509.Certificate) *CertificateInfo {
 	return &CertificateInfo{
 		Subject:   cert.Subject.CommonName,
 		Issuer:    cert.Issuer.CommonName,
@@ -259,7 +307,53 @@ func (c *CertificateService) GetCertificateInfo(certPath string) (*CertificateIn
 		NotAfter:  cert.NotAfter,
 		DNSNames:  cert.DNSNames,
 		IsValid:   time.Now().After(cert.NotBefore) && time.Now().Before(cert.NotAfter),
-	}, nil
+	}
+}
+
+// certificateNames returns the DNS names a stored certificate's file is
+// valid for. A certificate whose file cannot be read or names no DNS
+// names only counts for the domain it is stored under.
+func certificateNames(cert *models.Certificate) []string {
+	info, err := readCertificateInfo(cert.CertPath)
+	if err != nil || len(info.DNSNames) == 0 {
+		return []string{cert.Domain}
+	}
+	return info.DNSNames
+}
+
+// certificateNameCovers reports whether a certificate name is valid for a
+// server name. A wildcard covers exactly one label, as TLS clients match
+// it, so a wildcard server name needs the same wildcard in the certificate.
+func certificateNameCovers(certName, name string) bool {
+	certName = strings.ToLower(certName)
+	name = strings.ToLower(name)
+	if certName == name {
+		return true
+	}
+	suffix, ok := strings.CutPrefix(certName, "*.")
+	if !ok {
+		return false
+	}
+	label, rest, ok := strings.Cut(name, ".")
+	return ok && label != "" && label != "*" && rest == suffix
+}
+
+// certificateCovers reports whether a certificate is valid for every name.
+func certificateCovers(cert *models.Certificate, names []string) bool {
+	certNames := certificateNames(cert)
+	for _, name := range names {
+		covered := false
+		for _, certName := range certNames {
+			if certificateNameCovers(certName, name) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
 }
 
 // CertificateInfo contains information about a certificate
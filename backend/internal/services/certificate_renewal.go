@@ -10,11 +10,19 @@ import (
 	"upm-backend/internal/models"
 )
 
-// CertificateRenewalService periodically renews expiring Let's Encrypt certificates.
+// CertificateRenewalService periodically renews expiring Let's Encrypt
+// certificates and certificates of internal CAs, and keeps the CRLs of
+// internal CAs current.
 type CertificateRenewalService struct {
 	db       *DatabaseService
 	nginx    *NginxService
 	interval time.Duration
+	// ACMEEnabled renews Let's Encrypt certificates; it needs
+	// LETSENCRYPT_EMAIL.
+	ACMEEnabled bool
+	// InternalCA renews internal certificates; nil skips them.
+	InternalCA *InternalCAService
+
 	stopChan chan struct{}
 	wg       sync.WaitGroup
 	running  bool
@@ -24,10 +32,11 @@ type CertificateRenewalService struct {
 // NewCertificateRenewalService creates a certificate auto-renewal scheduler.
 func NewCertificateRenewalService(db *DatabaseService, nginx *NginxService, interval time.Duration) *CertificateRenewalService {
 	return &CertificateRenewalService{
-		db:       db,
-		nginx:    nginx,
-		interval: interval,
-		stopChan: make(chan struct{}),
+		db:          db,
+		nginx:       nginx,
+		interval:    interval,
+		stopChan:    make(chan struct{}),
+		ACMEEnabled: true,
 	}
 }
 
@@ -101,7 +110,9 @@ func (s *CertificateRenewalService) runRenewalCheck() {
 	}
 }
 
-// RenewEligibleCertificates renews all Let's Encrypt certificates expiring within 30 days.
+// RenewEligibleCertificates renews all Let's Encrypt certificates expiring
+// within 30 days and the internal certificates that are due, then reloads
+// nginx if a certificate or CRL it serves changed.
 func (s *CertificateRenewalService) RenewEligibleCertificates() []models.CertificateRenewResponse {
 	certificates, err := s.db.GetCertificates()
 	if err != nil {
@@ -115,11 +126,27 @@ func (s *CertificateRenewalService) RenewEligibleCertificates() []models.Certifi
 	var responses []models.CertificateRenewResponse
 	nginxReloadNeeded := false
 
+	if s.InternalCA != nil {
+		refreshed, err := s.InternalCA.RefreshCRLs()
+		if err != nil {
+			log.Printf("Warning: Failed to refresh internal CA CRLs: %v", err)
+		}
+		nginxReloadNeeded = refreshed
+	}
+
 	for _, certificate := range certificates {
 		response := models.CertificateRenewResponse{
 			Domain: certificate.Domain,
 		}
 
+		if certificate.IsInternal() {
+			if renewed := s.renewInternalCertificate(&certificate, &response); renewed && certificate.Usage == models.CertificateUsageServer {
+				nginxReloadNeeded = true
+			}
+			responses = append(responses, response)
+			continue
+		}
+
 		if !isLetsEncryptCertificate(&certificate) {
 			response.Success = false
 			response.Message = "Skipped: manual certificates must be renewed externally"
@@ -127,6 +154,13 @@ func (s *CertificateRenewalService) RenewEligibleCertificates() []models.Certifi
 			continue
 		}
 
+		if !s.ACMEEnabled {
+			response.Success = false
+			response.Message = "Skipped: Let's Encrypt is not configured (LETSENCRYPT_EMAIL not set)"
+			responses = append(responses, response)
+			continue
+		}
+
 		expiringSoon, daysUntilExpiry := certService.CheckCertificateExpiry(&certificate)
 		if !expiringSoon {
 			response.Success = false
@@ -153,7 +187,7 @@ func (s *CertificateRenewalService) RenewEligibleCertificates() []models.Certifi
 	}
 
 	if nginxReloadNeeded && s.nginx != nil {
-		if err := s.nginx.ReloadNginx(); err != nil {
+		if err := s.nginx.Apply(nil, nil); err != nil {
 			log.Printf("Warning: Failed to reload nginx after certificate renewal: %v", err)
 		} else {
 			log.Printf("Nginx reloaded successfully after certificate renewal")
@@ -163,6 +197,38 @@ func (s *CertificateRenewalService) RenewEligibleCertificates() []models.Certifi
 	return responses
 }
 
+// renewInternalCertificate renews an internal certificate when it is due
+// and reports whether it was renewed.
+func (s *CertificateRenewalService) renewInternalCertificate(certificate *models.Certificate, response *models.CertificateRenewResponse) bool {
+	switch {
+	case s.InternalCA == nil:
+		response.Message = "Skipped: internal CA is not available"
+		return false
+	case certificate.IsRevoked():
+		response.Message = "Skipped: certificate has been revoked"
+		return false
+	}
+
+	due, daysUntilExpiry := s.InternalCA.RenewalDue(certificate, time.Now())
+	if !due {
+		response.Message = fmt.Sprintf("Skipped: not due for renewal (%d days remaining)", daysUntilExpiry)
+		return false
+	}
+
+	log.Printf("Auto-renewing internal certificate for %s (ID: %d)", certificate.Domain, certificate.ID)
+	renewedCert, err := s.InternalCA.RenewCertificate(certificate)
+	if err != nil {
+		log.Printf("Certificate renewal failed for %s: %v", certificate.Domain, err)
+		response.Message = err.Error()
+		return false
+	}
+
+	response.Success = true
+	response.Message = "Certificate renewed successfully"
+	response.Certificate = renewedCert
+	return true
+}
+
 func (s *CertificateRenewalService) renewCertificate(certificate *models.Certificate, certService *CertificateService) (*models.Certificate, error) {
 	renewedCert, err := certService.RenewCertificate(certificate)
 	if err != nil {
@@ -183,6 +249,9 @@ func (s *CertificateRenewalService) renewCertificate(certificate *models.Certifi
 }
 
 func isLetsEncryptCertificate(cert *models.Certificate) bool {
+	if cert.Source != "" {
+		return cert.Source == models.CertificateSourceLetsEncrypt
+	}
 	return strings.Contains(cert.CertPath, "/etc/letsencrypt") ||
 		strings.Contains(cert.CertPath, "/etc/ssl/certs")
 }
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"upm-backend/internal/models"
+)
+
+// clientAuthTemplateData is the rendered form of a proxy's mutual TLS.
+type clientAuthTemplateData struct {
+	Mode        string
+	CAFile      string // CA bundle client certificates are verified against
+	CRLFile     string // CRLs of an internal CA's chain, empty for bundles
+	VerifyDepth int
+	Required    bool   // mode on: clients without a verified certificate are refused
+	DNHeader    string // request header carrying the client's subject DN, empty for none
+}
+
+// clientCAFileName is the file name of a proxy's client CA bundle, kept
+// next to the proxy's config and loaded by its ssl_client_certificate.
+func clientCAFileName(proxyID int) string {
+	return fmt.Sprintf("proxy-%d-client-ca.pem", proxyID)
+}
+
+// writeClientCAFile writes the CA bundle of a proxy that verifies client
+// certificates next to its config and returns the template data, or
+// removes the bundle and returns nil when the proxy does not verify them.
+// Settings are assumed to have passed models.ValidateClientAuth.
+func (n *NginxService) writeClientCAFile(proxy *models.Proxy) (*clientAuthTemplateData, error) {
+	ca := proxy.ClientAuth
+	if !ca.Enabled() || proxy.IsPassthrough() {
+		return nil, n.removeClientCAFile(proxy.ID)
+	}
+
+	bundle := ca.CABundle
+	var crlFile string
+	chainLength := 0
+	if ca.CAID != 0 {
+		var err error
+		bundle, crlFile, chainLength, err = n.internalClientCA(ca.CAID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	name := clientCAFileName(proxy.ID)
+	content := []byte(strings.TrimSpace(bundle) + "\n")
+	enabledPath := filepath.Join(n.SitesEnabledPath, name)
+	if err := n.writeFile(filepath.Join(n.ConfigPath, name), content); err != nil {
+		return nil, fmt.Errorf("failed to write client CA bundle: %w", err)
+	}
+	if err := n.writeFile(enabledPath, content); err != nil {
+		return nil, fmt.Errorf("failed to copy client CA bundle to sites-enabled: %w", err)
+	}
+
+	data := &clientAuthTemplateData{
+		Mode:        ca.Mode,
+		CAFile:      enabledPath,
+		CRLFile:     crlFile,
+		VerifyDepth: ca.VerifyDepth,
+		Required:    ca.Mode == models.ClientAuthModeOn,
+		DNHeader:    ca.DNHeader,
+	}
+	if data.VerifyDepth < 1 {
+		data.VerifyDepth = models.DefaultClientAuthVerifyDepth
+	}
+	// OpenSSL builds client chains up to the root, so the depth has to
+	// cover every CA of an internal chain
+	if data.VerifyDepth < chainLength {
+		data.VerifyDepth = chainLength
+	}
+	return data, nil
+}
+
+// internalClientCA returns the chain of an internal CA, its CRL chain file
+// and the number of CAs in the chain. The CA is read through the service's
+// database, which is bound to the transaction of an apply in progress.
+func (n *NginxService) internalClientCA(caID int) (string, string, int, error) {
+	if n.InternalCA == nil || n.DatabaseService == nil {
+		return "", "", 0, fmt.Errorf("client_auth uses internal CA %d, but the internal CA is not available", caID)
+	}
+	internalCA := &InternalCAService{db: n.DatabaseService, Dir: n.InternalCA.Dir}
+	ca, err := n.DatabaseService.GetCertificateAuthority(caID)
+	if err != nil {
+		return "", "", 0, fmt.Errorf("failed to load client CA %d: %w", caID, err)
+	}
+	chain, err := internalCA.Chain(ca)
+	if err != nil {
+		return "", "", 0, err
+	}
+	bundle, err := internalCA.ChainPEM(ca)
+	if err != nil {
+		return "", "", 0, err
+	}
+	crlFile := internalCA.CRLChainPath(caID)
+	if _, err := os.Stat(crlFile); err != nil {
+		if err := internalCA.WriteCRL(ca); err != nil {
+			return "", "", 0, err
+		}
+	}
+	return bundle, crlFile, len(chain), nil
+}
+
+// removeClientCAFile deletes a proxy's client CA bundle, if any.
+func (n *NginxService) removeClientCAFile(proxyID int) error {
+	name := clientCAFileName(proxyID)
+	for _, path := range []string{filepath.Join(n.SitesEnabledPath, name), filepath.Join(n.ConfigPath, name)} {
+		if err := n.removeFile(path); err != nil {
+			return fmt.Errorf("failed to remove client CA bundle: %w", err)
+		}
+	}
+	return nil
+}
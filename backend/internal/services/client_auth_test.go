@@ -0,0 +1,159 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"upm-backend/internal/models"
+)
+
+// newTestCAPEM returns a self-signed CA certificate and its private key,
+// both PEM encoded.
+func newTestCAPEM(t *testing.T, name string, notAfter time.Time) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:              notAfter,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}))
+}
+
+func TestParseCABundle(t *testing.T) {
+	certService := &CertificateService{}
+	later := time.Now().Add(24 * time.Hour)
+	root, rootKey := newTestCAPEM(t, "Admin Root CA", later)
+	intermediate, _ := newTestCAPEM(t, "Admin Intermediate CA", later)
+	expired, _ := newTestCAPEM(t, "Old CA", time.Now().Add(-time.Hour))
+
+	infos, err := certService.ParseCABundle(root + intermediate)
+	if err != nil {
+		t.Fatalf("ParseCABundle returned error: %v", err)
+	}
+	if len(infos) != 2 || infos[0].Subject != "Admin Root CA" || infos[1].Subject != "Admin Intermediate CA" {
+		t.Errorf("unexpected certificates: %+v", infos)
+	}
+
+	invalid := map[string]string{
+		"private key": root + rootKey,
+		"expired":     expired,
+		"not PEM":     "not a certificate",
+		"trailing":    root + "garbage",
+		"empty":       "",
+	}
+	for name, bundle := range invalid {
+		if _, err := certService.ParseCABundle(bundle); err == nil {
+			t.Errorf("%s: ParseCABundle = nil error, want error", name)
+		}
+	}
+}
+
+func TestGenerateProxyConfig_ClientAuth_RendersVerifyClient(t *testing.T) {
+	svc := newTestNginxService(t)
+	svc.DatabaseService = newTestDatabaseService(t)
+
+	caPEM, keyPEM := newTestCAPEM(t, "Admin Root CA", time.Now().Add(24*time.Hour))
+	certDir := t.TempDir()
+	certPath := filepath.Join(certDir, "admin.example.com.crt")
+	keyPath := filepath.Join(certDir, "admin.example.com.key")
+	if err := os.WriteFile(certPath, []byte(caPEM), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyPath, []byte(keyPEM), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cert := &models.Certificate{Domain: "admin.example.com", CertPath: certPath, KeyPath: keyPath, ExpiresAt: time.Now().Add(24 * time.Hour), IsValid: true}
+	if err := svc.DatabaseService.CreateCertificate(cert); err != nil {
+		t.Fatal(err)
+	}
+
+	proxy := &models.Proxy{
+		Name: "admin", Domain: "admin.example.com", TargetURL: "http://admin:8080", Status: "active", SSLEnabled: true,
+		ClientAuth: &models.ClientAuth{Mode: models.ClientAuthModeOn, CABundle: caPEM, VerifyDepth: 2, DNHeader: "X-Client-DN"},
+		HeaderRules: []models.ProxyHeaderRule{
+			{Direction: models.HeaderDirectionRequest, Action: models.HeaderActionSet, Name: "X-Client-DN", Value: "spoofed"},
+		},
+	}
+	if err := svc.DatabaseService.CreateProxy(proxy); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.GenerateProxyConfig(proxy); err != nil {
+		t.Fatalf("GenerateProxyConfig returned error: %v", err)
+	}
+
+	caFile := filepath.Join(svc.SitesEnabledPath, clientCAFileName(proxy.ID))
+	bundle, err := os.ReadFile(caFile)
+	if err != nil || string(bundle) != caPEM {
+		t.Fatalf("expected the CA bundle at %s (err %v), got:\n%s", caFile, err, bundle)
+	}
+	content, err := os.ReadFile(filepath.Join(svc.SitesEnabledPath, "proxy-1.conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := string(content)
+	for _, want := range []string{
+		"ssl_client_certificate " + caFile + ";",
+		"ssl_verify_client on;",
+		"ssl_verify_depth 2;",
+		"proxy_set_header X-Client-DN $ssl_client_s_dn;",
+	} {
+		if !strings.Contains(config, want) {
+			t.Errorf("expected %q in config, got:\n%s", want, config)
+		}
+	}
+	if strings.Contains(config, "spoofed") {
+		t.Errorf("expected the DN header to win over header rules, got:\n%s", config)
+	}
+
+	// Without SSL the HTTP server cannot verify certificates and refuses
+	// everything but ACME challenges
+	proxy.SSLEnabled = false
+	if err := os.Remove(certPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.GenerateProxyConfig(proxy); err != nil {
+		t.Fatalf("GenerateProxyConfig returned error: %v", err)
+	}
+	content, err = os.ReadFile(filepath.Join(svc.SitesEnabledPath, "proxy-1.conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "if ($uri !~ ^/\\.well-known/acme-challenge/) {\n        return 403;") {
+		t.Errorf("expected plain HTTP to be refused, got:\n%s", content)
+	}
+
+	proxy.ClientAuth = nil
+	if err := svc.GenerateProxyConfig(proxy); err != nil {
+		t.Fatalf("GenerateProxyConfig returned error: %v", err)
+	}
+	if _, err := os.Stat(caFile); !os.IsNotExist(err) {
+		t.Errorf("expected the CA bundle to be removed with the settings")
+	}
+}
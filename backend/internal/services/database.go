@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"strconv"
 	"strings"
 	"time"
 
@@ -15,14 +16,94 @@ import (
 )
 
 type DatabaseService struct {
-	db            *sql.DB
+	// db runs the queries: the database itself, or the transaction a
+	// service returned by InTransaction is bound to.
+	db            dbConn
+	root          *sql.DB
 	encryptionSvc *utils.EncryptionService
 }
 
+// dbConn is the query interface shared by *sql.DB and *sql.Tx.
+type dbConn interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// txConn is a transaction begun by a DatabaseService method.
+type txConn interface {
+	dbConn
+	Commit() error
+	Rollback() error
+}
+
+// savepoint is a transaction nested in the one a service is bound to. It
+// commits into the outer transaction, which still decides the outcome.
+type savepoint struct {
+	*sql.Tx
+	done bool
+}
+
+func (s *savepoint) Commit() error {
+	if s.done {
+		return sql.ErrTxDone
+	}
+	s.done = true
+	_, err := s.Exec(`RELEASE SAVEPOINT upm_nested`)
+	return err
+}
+
+func (s *savepoint) Rollback() error {
+	if s.done {
+		return sql.ErrTxDone
+	}
+	s.done = true
+	if _, err := s.Exec(`ROLLBACK TO SAVEPOINT upm_nested`); err != nil {
+		return err
+	}
+	_, err := s.Exec(`RELEASE SAVEPOINT upm_nested`)
+	return err
+}
+
+// begin starts a transaction, or a savepoint when the service is already
+// bound to one.
+func (d *DatabaseService) begin() (txConn, error) {
+	if tx, ok := d.db.(*sql.Tx); ok {
+		if _, err := tx.Exec(`SAVEPOINT upm_nested`); err != nil {
+			return nil, err
+		}
+		return &savepoint{Tx: tx}, nil
+	}
+	return d.root.Begin()
+}
+
+// InTransaction runs fn with a service bound to a single transaction, which
+// is committed when fn succeeds and rolled back when it fails. Called on a
+// service that is already bound, fn joins the outer transaction.
+func (d *DatabaseService) InTransaction(fn func(tx *DatabaseService) error) error {
+	if _, ok := d.db.(*sql.Tx); ok {
+		return fn(d)
+	}
+
+	tx, err := d.root.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(&DatabaseService{db: tx, root: d.root, encryptionSvc: d.encryptionSvc}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
 func NewDatabaseService() (*DatabaseService, error) {
 	cfg := config.Load()
 
-	db, err := sql.Open("sqlite", cfg.DatabasePath)
+	db, err := sql.Open("sqlite", databaseDSN(cfg.DatabasePath))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -40,6 +121,7 @@ func NewDatabaseService() (*DatabaseService, error) {
 
 	service := &DatabaseService{
 		db:            db,
+		root:          db,
 		encryptionSvc: encryptionSvc,
 	}
 
@@ -52,6 +134,17 @@ func NewDatabaseService() (*DatabaseService, error) {
 	return service, nil
 }
 
+// databaseDSN adds a busy timeout to the database path. Config applies hold
+// a write transaction while nginx validates the result, so other writers
+// wait for it instead of failing at once.
+func databaseDSN(path string) string {
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return path + sep + "_pragma=busy_timeout(10000)"
+}
+
 func (d *DatabaseService) initTables() error {
 	// Create proxies table
 	proxyTable := `
@@ -95,6 +188,489 @@ func (d *DatabaseService) initTables() error {
 		fmt.Printf("Note: rate_limit_rps column may already exist: %v\n", err)
 	}
 
+	// Migration: Add load_balance_method column to existing proxies table if it doesn't exist
+	alterTableQuery7 := `ALTER TABLE proxies ADD COLUMN load_balance_method TEXT DEFAULT '';`
+	if _, err := d.db.Exec(alterTableQuery7); err != nil {
+		// Ignore error if column already exists
+		fmt.Printf("Note: load_balance_method column may already exist: %v\n", err)
+	}
+
+	// Create proxy upstream servers table (members of a proxy's load-balanced pool)
+	upstreamServersTable := `
+	CREATE TABLE IF NOT EXISTS proxy_upstream_servers (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		proxy_id INTEGER NOT NULL,
+		url TEXT NOT NULL,
+		weight INTEGER DEFAULT 0,
+		max_fails INTEGER DEFAULT 0,
+		fail_timeout INTEGER DEFAULT 0,
+		backup BOOLEAN DEFAULT FALSE,
+		position INTEGER DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (proxy_id) REFERENCES proxies (id) ON DELETE CASCADE
+	);`
+
+	if _, err := d.db.Exec(upstreamServersTable); err != nil {
+		return fmt.Errorf("failed to create proxy_upstream_servers table: %w", err)
+	}
+
+	// Migration: Add canonical_redirect column to existing proxies table if it doesn't exist
+	alterTableQuery8 := `ALTER TABLE proxies ADD COLUMN canonical_redirect BOOLEAN DEFAULT FALSE;`
+	if _, err := d.db.Exec(alterTableQuery8); err != nil {
+		// Ignore error if column already exists
+		fmt.Printf("Note: canonical_redirect column may already exist: %v\n", err)
+	}
+
+	// Migration: Add ssl_mode column to existing proxies table if it doesn't exist
+	alterTableQuery9 := `ALTER TABLE proxies ADD COLUMN ssl_mode TEXT DEFAULT 'terminate';`
+	if _, err := d.db.Exec(alterTableQuery9); err != nil {
+		// Ignore error if column already exists
+		fmt.Printf("Note: ssl_mode column may already exist: %v\n", err)
+	}
+
+	// Create proxy domains table (aliases served alongside a proxy's primary domain)
+	proxyDomainsTable := `
+	CREATE TABLE IF NOT EXISTS proxy_domains (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		proxy_id INTEGER NOT NULL,
+		domain TEXT NOT NULL UNIQUE,
+		position INTEGER DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (proxy_id) REFERENCES proxies (id) ON DELETE CASCADE
+	);`
+
+	if _, err := d.db.Exec(proxyDomainsTable); err != nil {
+		return fmt.Errorf("failed to create proxy_domains table: %w", err)
+	}
+
+	// Create proxy locations table (path-based routing rules inside a proxy host)
+	locationsTable := `
+	CREATE TABLE IF NOT EXISTS proxy_locations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		proxy_id INTEGER NOT NULL,
+		path TEXT NOT NULL,
+		match_type TEXT NOT NULL DEFAULT 'prefix',
+		target_url TEXT NOT NULL,
+		strip_prefix BOOLEAN DEFAULT FALSE,
+		rewrite TEXT DEFAULT '',
+		ws_enabled BOOLEAN DEFAULT FALSE,
+		rate_limit_enabled BOOLEAN DEFAULT FALSE,
+		position INTEGER DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (proxy_id) REFERENCES proxies (id) ON DELETE CASCADE
+	);`
+
+	if _, err := d.db.Exec(locationsTable); err != nil {
+		return fmt.Errorf("failed to create proxy_locations table: %w", err)
+	}
+
+	// Create proxy header rules table (request/response header overrides)
+	headerRulesTable := `
+	CREATE TABLE IF NOT EXISTS proxy_header_rules (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		proxy_id INTEGER NOT NULL,
+		direction TEXT NOT NULL,
+		action TEXT NOT NULL DEFAULT 'set',
+		name TEXT NOT NULL,
+		value TEXT DEFAULT '',
+		always BOOLEAN DEFAULT FALSE,
+		position INTEGER DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (proxy_id) REFERENCES proxies (id) ON DELETE CASCADE
+	);`
+
+	if _, err := d.db.Exec(headerRulesTable); err != nil {
+		return fmt.Errorf("failed to create proxy_header_rules table: %w", err)
+	}
+
+	// Create proxy cache policies table (proxy_cache settings, one row per proxy)
+	cachePoliciesTable := `
+	CREATE TABLE IF NOT EXISTS proxy_cache_policies (
+		proxy_id INTEGER PRIMARY KEY,
+		zone_size_mb INTEGER DEFAULT 0,
+		max_size_mb INTEGER DEFAULT 0,
+		inactive_minutes INTEGER DEFAULT 0,
+		valid TEXT DEFAULT '',
+		cache_key TEXT DEFAULT '',
+		bypass_cookies TEXT DEFAULT '',
+		bypass_headers TEXT DEFAULT '',
+		bypass_args TEXT DEFAULT '',
+		status_header BOOLEAN DEFAULT FALSE,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (proxy_id) REFERENCES proxies (id) ON DELETE CASCADE
+	);`
+
+	if _, err := d.db.Exec(cachePoliciesTable); err != nil {
+		return fmt.Errorf("failed to create proxy_cache_policies table: %w", err)
+	}
+
+	// Create proxy rate limit policies table (limit_req/limit_conn settings, one row per proxy)
+	rateLimitPoliciesTable := `
+	CREATE TABLE IF NOT EXISTS proxy_rate_limit_policies (
+		proxy_id INTEGER PRIMARY KEY,
+		rate INTEGER DEFAULT 0,
+		per TEXT DEFAULT '',
+		burst INTEGER DEFAULT 0,
+		delay INTEGER,
+		rate_key TEXT DEFAULT '',
+		exempt_cidrs TEXT DEFAULT '',
+		conn_limit INTEGER DEFAULT 0,
+		status INTEGER DEFAULT 0,
+		response_body TEXT DEFAULT '',
+		response_content_type TEXT DEFAULT '',
+		retry_after INTEGER DEFAULT 0,
+		zone_size_mb INTEGER DEFAULT 0,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (proxy_id) REFERENCES proxies (id) ON DELETE CASCADE
+	);`
+
+	if _, err := d.db.Exec(rateLimitPoliciesTable); err != nil {
+		return fmt.Errorf("failed to create proxy_rate_limit_policies table: %w", err)
+	}
+
+	// Create proxy TLS policies table (ssl_* settings and HSTS, one row per proxy)
+	tlsPoliciesTable := `
+	CREATE TABLE IF NOT EXISTS proxy_tls_policies (
+		proxy_id INTEGER PRIMARY KEY,
+		profile TEXT DEFAULT '',
+		protocols TEXT DEFAULT '',
+		ciphers TEXT DEFAULT '',
+		prefer_server_ciphers BOOLEAN DEFAULT FALSE,
+		hsts_enabled BOOLEAN,
+		hsts_max_age INTEGER DEFAULT 0,
+		hsts_include_subdomains BOOLEAN DEFAULT FALSE,
+		hsts_preload BOOLEAN DEFAULT FALSE,
+		ocsp_stapling BOOLEAN DEFAULT FALSE,
+		resolvers TEXT DEFAULT '',
+		resolver_timeout INTEGER DEFAULT 0,
+		session_tickets BOOLEAN DEFAULT FALSE,
+		session_timeout INTEGER DEFAULT 0,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (proxy_id) REFERENCES proxies (id) ON DELETE CASCADE
+	);`
+
+	if _, err := d.db.Exec(tlsPoliciesTable); err != nil {
+		return fmt.Errorf("failed to create proxy_tls_policies table: %w", err)
+	}
+
+	// Create HTTP/3 listener table (the proxy whose QUIC listen carries
+	// reuseport; nginx accepts the option on one listen per address only)
+	http3ListenerTable := `
+	CREATE TABLE IF NOT EXISTS http3_listener (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		reuseport_proxy_id INTEGER NOT NULL DEFAULT 0,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := d.db.Exec(http3ListenerTable); err != nil {
+		return fmt.Errorf("failed to create http3_listener table: %w", err)
+	}
+
+	// Create proxy health check tables (probe settings and recent results)
+	healthChecksTable := `
+	CREATE TABLE IF NOT EXISTS proxy_health_checks (
+		proxy_id INTEGER PRIMARY KEY,
+		enabled BOOLEAN DEFAULT TRUE,
+		path TEXT DEFAULT '/',
+		expected_status INTEGER DEFAULT 0,
+		interval_seconds INTEGER DEFAULT 0,
+		timeout_seconds INTEGER DEFAULT 0,
+		unhealthy_threshold INTEGER DEFAULT 0,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (proxy_id) REFERENCES proxies (id) ON DELETE CASCADE
+	);`
+
+	if _, err := d.db.Exec(healthChecksTable); err != nil {
+		return fmt.Errorf("failed to create proxy_health_checks table: %w", err)
+	}
+
+	healthResultsTable := `
+	CREATE TABLE IF NOT EXISTS proxy_health_results (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		proxy_id INTEGER NOT NULL,
+		healthy BOOLEAN NOT NULL,
+		status_code INTEGER DEFAULT 0,
+		latency_ms INTEGER DEFAULT 0,
+		error TEXT DEFAULT '',
+		checked_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (proxy_id) REFERENCES proxies (id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_proxy_health_results_proxy ON proxy_health_results (proxy_id, id);`
+
+	if _, err := d.db.Exec(healthResultsTable); err != nil {
+		return fmt.Errorf("failed to create proxy_health_results table: %w", err)
+	}
+
+	// Create proxy maintenance table (maintenance mode and page, one row per proxy)
+	maintenanceTable := `
+	CREATE TABLE IF NOT EXISTS proxy_maintenance (
+		proxy_id INTEGER PRIMARY KEY,
+		enabled BOOLEAN DEFAULT FALSE,
+		page TEXT DEFAULT '',
+		retry_after_seconds INTEGER DEFAULT 0,
+		allowed_ips TEXT DEFAULT '',
+		starts_at DATETIME,
+		ends_at DATETIME,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (proxy_id) REFERENCES proxies (id) ON DELETE CASCADE
+	);`
+
+	if _, err := d.db.Exec(maintenanceTable); err != nil {
+		return fmt.Errorf("failed to create proxy_maintenance table: %w", err)
+	}
+
+	// Create error pages table (custom error pages, proxy_id 0 for global pages)
+	errorPagesTable := `
+	CREATE TABLE IF NOT EXISTS error_pages (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		proxy_id INTEGER NOT NULL DEFAULT 0,
+		status_code INTEGER NOT NULL,
+		content TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (proxy_id, status_code)
+	);`
+
+	if _, err := d.db.Exec(errorPagesTable); err != nil {
+		return fmt.Errorf("failed to create error_pages table: %w", err)
+	}
+
+	// Create stream proxies table (TCP/UDP proxies in the nginx stream context)
+	streamProxiesTable := `
+	CREATE TABLE IF NOT EXISTS stream_proxies (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		listen_port INTEGER NOT NULL,
+		protocol TEXT NOT NULL DEFAULT 'tcp',
+		targets TEXT NOT NULL,
+		allowed_ip_ranges TEXT DEFAULT '',
+		proxy_timeout INTEGER DEFAULT 600,
+		status TEXT DEFAULT 'active',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (listen_port, protocol)
+	);`
+
+	if _, err := d.db.Exec(streamProxiesTable); err != nil {
+		return fmt.Errorf("failed to create stream_proxies table: %w", err)
+	}
+
+	// Create basic auth credential set tables
+	basicAuthSetsTable := `
+	CREATE TABLE IF NOT EXISTS basic_auth_sets (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := d.db.Exec(basicAuthSetsTable); err != nil {
+		return fmt.Errorf("failed to create basic_auth_sets table: %w", err)
+	}
+
+	basicAuthUsersTable := `
+	CREATE TABLE IF NOT EXISTS basic_auth_users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		set_id INTEGER NOT NULL,
+		username TEXT NOT NULL,
+		password_hash TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (set_id, username),
+		FOREIGN KEY (set_id) REFERENCES basic_auth_sets (id) ON DELETE CASCADE
+	);`
+
+	if _, err := d.db.Exec(basicAuthUsersTable); err != nil {
+		return fmt.Errorf("failed to create basic_auth_users table: %w", err)
+	}
+
+	// Create access list tables (named client address allow/deny lists)
+	accessListsTable := `
+	CREATE TABLE IF NOT EXISTS access_lists (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := d.db.Exec(accessListsTable); err != nil {
+		return fmt.Errorf("failed to create access_lists table: %w", err)
+	}
+
+	accessListEntriesTable := `
+	CREATE TABLE IF NOT EXISTS access_list_entries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		list_id INTEGER NOT NULL,
+		action TEXT NOT NULL DEFAULT 'allow',
+		address TEXT NOT NULL,
+		position INTEGER DEFAULT 0,
+		FOREIGN KEY (list_id) REFERENCES access_lists (id) ON DELETE CASCADE
+	);`
+
+	if _, err := d.db.Exec(accessListEntriesTable); err != nil {
+		return fmt.Errorf("failed to create access_list_entries table: %w", err)
+	}
+
+	// Migration: Add basic_auth_set_id columns to proxies and proxy_locations if they don't exist
+	alterTableQuery10 := `ALTER TABLE proxies ADD COLUMN basic_auth_set_id INTEGER DEFAULT 0;`
+	if _, err := d.db.Exec(alterTableQuery10); err != nil {
+		// Ignore error if column already exists
+		fmt.Printf("Note: basic_auth_set_id column may already exist: %v\n", err)
+	}
+	alterTableQuery11 := `ALTER TABLE proxy_locations ADD COLUMN basic_auth_set_id INTEGER DEFAULT 0;`
+	if _, err := d.db.Exec(alterTableQuery11); err != nil {
+		// Ignore error if column already exists
+		fmt.Printf("Note: proxy_locations basic_auth_set_id column may already exist: %v\n", err)
+	}
+
+	// Migration: Add require_upm_login column to existing proxies table if it doesn't exist
+	alterTableQuery12 := `ALTER TABLE proxies ADD COLUMN require_upm_login BOOLEAN DEFAULT FALSE;`
+	if _, err := d.db.Exec(alterTableQuery12); err != nil {
+		// Ignore error if column already exists
+		fmt.Printf("Note: require_upm_login column may already exist: %v\n", err)
+	}
+
+	// Migration: Add forward auth columns to existing proxies table if they don't exist
+	alterTableQuery13 := `ALTER TABLE proxies ADD COLUMN forward_auth_url TEXT DEFAULT '';`
+	if _, err := d.db.Exec(alterTableQuery13); err != nil {
+		// Ignore error if column already exists
+		fmt.Printf("Note: forward_auth_url column may already exist: %v\n", err)
+	}
+	alterTableQuery14 := `ALTER TABLE proxies ADD COLUMN forward_auth_response_headers TEXT DEFAULT '';`
+	if _, err := d.db.Exec(alterTableQuery14); err != nil {
+		// Ignore error if column already exists
+		fmt.Printf("Note: forward_auth_response_headers column may already exist: %v\n", err)
+	}
+	alterTableQuery15 := `ALTER TABLE proxies ADD COLUMN forward_auth_signin_url TEXT DEFAULT '';`
+	if _, err := d.db.Exec(alterTableQuery15); err != nil {
+		// Ignore error if column already exists
+		fmt.Printf("Note: forward_auth_signin_url column may already exist: %v\n", err)
+	}
+
+	// Migration: Add cache_enabled columns to proxies and proxy_locations if they don't exist
+	alterTableQuery16 := `ALTER TABLE proxies ADD COLUMN cache_enabled BOOLEAN DEFAULT FALSE;`
+	if _, err := d.db.Exec(alterTableQuery16); err != nil {
+		// Ignore error if column already exists
+		fmt.Printf("Note: cache_enabled column may already exist: %v\n", err)
+	}
+	alterTableQuery17 := `ALTER TABLE proxy_locations ADD COLUMN cache_enabled BOOLEAN DEFAULT FALSE;`
+	if _, err := d.db.Exec(alterTableQuery17); err != nil {
+		// Ignore error if column already exists
+		fmt.Printf("Note: proxy_locations cache_enabled column may already exist: %v\n", err)
+	}
+
+	// Migration: Add host type columns to proxies if they don't exist
+	alterTableQuery18 := `ALTER TABLE proxies ADD COLUMN type TEXT DEFAULT 'proxy';`
+	if _, err := d.db.Exec(alterTableQuery18); err != nil {
+		// Ignore error if column already exists
+		fmt.Printf("Note: type column may already exist: %v\n", err)
+	}
+	alterTableQuery19 := `ALTER TABLE proxies ADD COLUMN redirect_status_code INTEGER DEFAULT 0;`
+	if _, err := d.db.Exec(alterTableQuery19); err != nil {
+		// Ignore error if column already exists
+		fmt.Printf("Note: redirect_status_code column may already exist: %v\n", err)
+	}
+	alterTableQuery20 := `ALTER TABLE proxies ADD COLUMN redirect_preserve_path BOOLEAN DEFAULT FALSE;`
+	if _, err := d.db.Exec(alterTableQuery20); err != nil {
+		// Ignore error if column already exists
+		fmt.Printf("Note: redirect_preserve_path column may already exist: %v\n", err)
+	}
+	alterTableQuery21 := `ALTER TABLE proxies ADD COLUMN redirect_preserve_query BOOLEAN DEFAULT FALSE;`
+	if _, err := d.db.Exec(alterTableQuery21); err != nil {
+		// Ignore error if column already exists
+		fmt.Printf("Note: redirect_preserve_query column may already exist: %v\n", err)
+	}
+	alterTableQuery22 := `ALTER TABLE proxies ADD COLUMN static_root TEXT DEFAULT '';`
+	if _, err := d.db.Exec(alterTableQuery22); err != nil {
+		// Ignore error if column already exists
+		fmt.Printf("Note: static_root column may already exist: %v\n", err)
+	}
+	alterTableQuery23 := `ALTER TABLE proxies ADD COLUMN static_index TEXT DEFAULT '';`
+	if _, err := d.db.Exec(alterTableQuery23); err != nil {
+		// Ignore error if column already exists
+		fmt.Printf("Note: static_index column may already exist: %v\n", err)
+	}
+	alterTableQuery24 := `ALTER TABLE proxies ADD COLUMN static_spa_fallback BOOLEAN DEFAULT FALSE;`
+	if _, err := d.db.Exec(alterTableQuery24); err != nil {
+		// Ignore error if column already exists
+		fmt.Printf("Note: static_spa_fallback column may already exist: %v\n", err)
+	}
+
+	// Migration: Add quarantine columns, set when nginx rejects a proxy's config
+	alterTableQuery25 := `ALTER TABLE proxies ADD COLUMN quarantined BOOLEAN DEFAULT FALSE;`
+	if _, err := d.db.Exec(alterTableQuery25); err != nil {
+		// Ignore error if column already exists
+		fmt.Printf("Note: quarantined column may already exist: %v\n", err)
+	}
+	alterTableQuery26 := `ALTER TABLE proxies ADD COLUMN last_error TEXT DEFAULT '';`
+	if _, err := d.db.Exec(alterTableQuery26); err != nil {
+		// Ignore error if column already exists
+		fmt.Printf("Note: last_error column may already exist: %v\n", err)
+	}
+
+	// Migration: Add per-location rate limits
+	alterTableQuery27 := `ALTER TABLE proxy_locations ADD COLUMN rate_limit_rate INTEGER DEFAULT 0;`
+	if _, err := d.db.Exec(alterTableQuery27); err != nil {
+		// Ignore error if column already exists
+		fmt.Printf("Note: rate_limit_rate column may already exist: %v\n", err)
+	}
+	alterTableQuery28 := `ALTER TABLE proxy_locations ADD COLUMN rate_limit_burst INTEGER DEFAULT 0;`
+	if _, err := d.db.Exec(alterTableQuery28); err != nil {
+		// Ignore error if column already exists
+		fmt.Printf("Note: rate_limit_burst column may already exist: %v\n", err)
+	}
+
+	// Migration: Add access_list_id columns to proxies and proxy_locations if they don't exist
+	alterTableQuery29 := `ALTER TABLE proxies ADD COLUMN access_list_id INTEGER DEFAULT 0;`
+	if _, err := d.db.Exec(alterTableQuery29); err != nil {
+		// Ignore error if column already exists
+		fmt.Printf("Note: access_list_id column may already exist: %v\n", err)
+	}
+	alterTableQuery30 := `ALTER TABLE proxy_locations ADD COLUMN access_list_id INTEGER DEFAULT 0;`
+	if _, err := d.db.Exec(alterTableQuery30); err != nil {
+		// Ignore error if column already exists
+		fmt.Printf("Note: proxy_locations access_list_id column may already exist: %v\n", err)
+	}
+
+	// Migration: Add GeoIP country rule columns to proxies table if they don't exist
+	alterTableQuery31 := `ALTER TABLE proxies ADD COLUMN geoip_action TEXT DEFAULT '';`
+	if _, err := d.db.Exec(alterTableQuery31); err != nil {
+		// Ignore error if column already exists
+		fmt.Printf("Note: geoip_action column may already exist: %v\n", err)
+	}
+	alterTableQuery32 := `ALTER TABLE proxies ADD COLUMN geoip_countries TEXT DEFAULT '';`
+	if _, err := d.db.Exec(alterTableQuery32); err != nil {
+		// Ignore error if column already exists
+		fmt.Printf("Note: geoip_countries column may already exist: %v\n", err)
+	}
+
+	// Migration: Add mutual TLS columns to proxies table if they don't exist
+	alterTableQuery33 := `ALTER TABLE proxies ADD COLUMN client_auth_mode TEXT DEFAULT '';`
+	if _, err := d.db.Exec(alterTableQuery33); err != nil {
+		// Ignore error if column already exists
+		fmt.Printf("Note: client_auth_mode column may already exist: %v\n", err)
+	}
+	alterTableQuery34 := `ALTER TABLE proxies ADD COLUMN client_auth_ca_bundle TEXT DEFAULT '';`
+	if _, err := d.db.Exec(alterTableQuery34); err != nil {
+		// Ignore error if column already exists
+		fmt.Printf("Note: client_auth_ca_bundle column may already exist: %v\n", err)
+	}
+	alterTableQuery35 := `ALTER TABLE proxies ADD COLUMN client_auth_verify_depth INTEGER DEFAULT 0;`
+	if _, err := d.db.Exec(alterTableQuery35); err != nil {
+		// Ignore error if column already exists
+		fmt.Printf("Note: client_auth_verify_depth column may already exist: %v\n", err)
+	}
+	alterTableQuery36 := `ALTER TABLE proxies ADD COLUMN client_auth_dn_header TEXT DEFAULT '';`
+	if _, err := d.db.Exec(alterTableQuery36); err != nil {
+		// Ignore error if column already exists
+		fmt.Printf("Note: client_auth_dn_header column may already exist: %v\n", err)
+	}
+	alterTableQuery37 := `ALTER TABLE proxies ADD COLUMN client_auth_ca_id INTEGER DEFAULT 0;`
+	if _, err := d.db.Exec(alterTableQuery37); err != nil {
+		// Ignore error if column already exists
+		fmt.Printf("Note: client_auth_ca_id column may already exist: %v\n", err)
+	}
+
 	// Create users table
 	userTable := `
 	CREATE TABLE IF NOT EXISTS users (
@@ -128,6 +704,97 @@ func (d *DatabaseService) initTables() error {
 		return fmt.Errorf("failed to create certificates table: %w", err)
 	}
 
+	// Migration: Add source and internal CA columns to certificates table if they don't exist
+	alterTableQuery38 := `ALTER TABLE certificates ADD COLUMN source TEXT DEFAULT '';`
+	if _, err := d.db.Exec(alterTableQuery38); err != nil {
+		// Ignore error if column already exists
+		fmt.Printf("Note: source column may already exist: %v\n", err)
+	}
+	alterTableQuery39 := `ALTER TABLE certificates ADD COLUMN usage TEXT DEFAULT 'server';`
+	if _, err := d.db.Exec(alterTableQuery39); err != nil {
+		// Ignore error if column already exists
+		fmt.Printf("Note: usage column may already exist: %v\n", err)
+	}
+	alterTableQuery40 := `ALTER TABLE certificates ADD COLUMN issuer TEXT DEFAULT '';`
+	if _, err := d.db.Exec(alterTableQuery40); err != nil {
+		// Ignore error if column already exists
+		fmt.Printf("Note: issuer column may already exist: %v\n", err)
+	}
+	alterTableQuery41 := `ALTER TABLE certificates ADD COLUMN ca_id INTEGER DEFAULT 0;`
+	if _, err := d.db.Exec(alterTableQuery41); err != nil {
+		// Ignore error if column already exists
+		fmt.Printf("Note: ca_id column may already exist: %v\n", err)
+	}
+	alterTableQuery42 := `ALTER TABLE certificates ADD COLUMN serial_number TEXT DEFAULT '';`
+	if _, err := d.db.Exec(alterTableQuery42); err != nil {
+		// Ignore error if column already exists
+		fmt.Printf("Note: serial_number column may already exist: %v\n", err)
+	}
+	alterTableQuery43 := `ALTER TABLE certificates ADD COLUMN revoked_at DATETIME;`
+	if _, err := d.db.Exec(alterTableQuery43); err != nil {
+		// Ignore error if column already exists
+		fmt.Printf("Note: revoked_at column may already exist: %v\n", err)
+	}
+
+	// Migration: Add http3_enabled column, the proxy's opt-in to QUIC listeners
+	alterTableQuery44 := `ALTER TABLE proxies ADD COLUMN http3_enabled BOOLEAN DEFAULT FALSE;`
+	if _, err := d.db.Exec(alterTableQuery44); err != nil {
+		// Ignore error if column already exists
+		fmt.Printf("Note: http3_enabled column may already exist: %v\n", err)
+	}
+
+	// Certificates stored before sources were tracked came from Let's
+	// Encrypt when they live where it writes them, and were uploaded
+	// otherwise.
+	backfillCertificateSources := `
+	UPDATE certificates SET source = CASE
+		WHEN cert_path LIKE '%/etc/letsencrypt%' OR cert_path LIKE '%/etc/ssl/certs%' THEN 'letsencrypt'
+		ELSE 'manual'
+	END
+	WHERE source IS NULL OR source = '';`
+
+	if _, err := d.db.Exec(backfillCertificateSources); err != nil {
+		return fmt.Errorf("failed to backfill certificate sources: %w", err)
+	}
+
+	// Create internal certificate authority tables
+	certificateAuthoritiesTable := `
+	CREATE TABLE IF NOT EXISTS certificate_authorities (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		parent_id INTEGER DEFAULT 0,
+		common_name TEXT NOT NULL,
+		cert_pem TEXT NOT NULL,
+		key_pem TEXT NOT NULL,
+		imported BOOLEAN DEFAULT FALSE,
+		expires_at DATETIME NOT NULL,
+		crl_number INTEGER DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := d.db.Exec(certificateAuthoritiesTable); err != nil {
+		return fmt.Errorf("failed to create certificate_authorities table: %w", err)
+	}
+
+	// Revocations outlive the certificate rows, so a deleted certificate
+	// stays on its CA's CRL.
+	certificateRevocationsTable := `
+	CREATE TABLE IF NOT EXISTS certificate_revocations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		ca_id INTEGER NOT NULL,
+		serial_number TEXT NOT NULL,
+		reason INTEGER DEFAULT 0,
+		revoked_at DATETIME NOT NULL,
+		expires_at DATETIME NOT NULL,
+		UNIQUE (ca_id, serial_number),
+		FOREIGN KEY (ca_id) REFERENCES certificate_authorities (id) ON DELETE CASCADE
+	);`
+
+	if _, err := d.db.Exec(certificateRevocationsTable); err != nil {
+		return fmt.Errorf("failed to create certificate_revocations table: %w", err)
+	}
+
 	// Create DNS configurations table
 	dnsConfigTable := `
 	CREATE TABLE IF NOT EXISTS dns_configs (
@@ -154,7 +821,6 @@ func (d *DatabaseService) initTables() error {
 		config_id INTEGER NOT NULL,
 		host TEXT NOT NULL,
 		current_ip TEXT,
-		allowed_ip_ranges TEXT,
 		last_update DATETIME,
 		is_active BOOLEAN DEFAULT TRUE,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
@@ -166,13 +832,6 @@ func (d *DatabaseService) initTables() error {
 		return fmt.Errorf("failed to create dns_records table: %w", err)
 	}
 
-	// Add allowed_ip_ranges column to existing dns_records table if it doesn't exist
-	alterTableQuery := `ALTER TABLE dns_records ADD COLUMN allowed_ip_ranges TEXT;`
-	if _, err := d.db.Exec(alterTableQuery); err != nil {
-		// Ignore error if column already exists
-		fmt.Printf("Note: allowed_ip_ranges column may already exist: %v\n", err)
-	}
-
 	// Add dynamic_dns_refresh_rate column to existing dns_records table if it doesn't exist
 	alterTableQueryDNS1 := `ALTER TABLE dns_records ADD COLUMN dynamic_dns_refresh_rate INTEGER;`
 	if _, err := d.db.Exec(alterTableQueryDNS1); err != nil {
@@ -180,36 +839,17 @@ func (d *DatabaseService) initTables() error {
 		fmt.Printf("Note: dynamic_dns_refresh_rate column may already exist: %v\n", err)
 	}
 
-	// Add include_backend column to existing dns_records table if it doesn't exist
-	alterTableQuery3 := `ALTER TABLE dns_records ADD COLUMN include_backend BOOLEAN DEFAULT FALSE;`
-	if _, err := d.db.Exec(alterTableQuery3); err != nil {
-		// Ignore error if column already exists
-		fmt.Printf("Note: include_backend column may already exist: %v\n", err)
-	}
-
-	// Add backend_url column to existing dns_records table if it doesn't exist
-	alterTableQuery4 := `ALTER TABLE dns_records ADD COLUMN backend_url TEXT DEFAULT '';`
-	if _, err := d.db.Exec(alterTableQuery4); err != nil {
-		// Ignore error if column already exists
-		fmt.Printf("Note: backend_url column may already exist: %v\n", err)
+	// Migration: Move the legacy DNS-record backend routing (is_upm_domain,
+	// then include_backend/backend_url) into /api/ location rules on the
+	// matching proxies, then drop the old columns
+	if err := d.migrateDNSBackendRoutes(); err != nil {
+		fmt.Printf("Warning: Failed to migrate DNS backend routes: %v\n", err)
 	}
 
-	// Migration: Remove old is_upm_domain column and migrate data
-	// First, check if is_upm_domain column exists
-	checkColumnQuery := `SELECT COUNT(*) FROM pragma_table_info('dns_records') WHERE name='is_upm_domain';`
-	var count int
-	if err := d.db.QueryRow(checkColumnQuery).Scan(&count); err == nil && count > 0 {
-		// Column exists, migrate data and drop it
-		migrateQuery := `UPDATE dns_records SET include_backend = is_upm_domain, backend_url = 'http://backend:6080' WHERE is_upm_domain = TRUE;`
-		if _, err := d.db.Exec(migrateQuery); err != nil {
-			fmt.Printf("Warning: Failed to migrate is_upm_domain data: %v\n", err)
-		}
-		
-		// Drop the old column
-		dropColumnQuery := `ALTER TABLE dns_records DROP COLUMN is_upm_domain;`
-		if _, err := d.db.Exec(dropColumnQuery); err != nil {
-			fmt.Printf("Warning: Failed to drop is_upm_domain column: %v\n", err)
-		}
+	// Migration: Move the allowed IP ranges of DNS records into access lists
+	// attached to the matching proxies, then drop the old column
+	if err := d.migrateDNSAllowedIPRanges(); err != nil {
+		fmt.Printf("Warning: Failed to migrate DNS allowed IP ranges: %v\n", err)
 	}
 
 	// Create ui_settings table
@@ -229,66 +869,420 @@ func (d *DatabaseService) initTables() error {
 	return nil
 }
 
-// Proxy methods
-func (d *DatabaseService) GetProxies() ([]models.Proxy, error) {
-	query := `
-		SELECT id, name, domain, target_url, ssl_enabled, ws_enabled, ssl_path, rate_limit_enabled, rate_limit_rps, status, created_at, updated_at
-		FROM proxies
-		ORDER BY created_at DESC`
-
-	rows, err := d.db.Query(query)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query proxies: %w", err)
+// columnExists reports whether table has a column with the given name.
+func (d *DatabaseService) columnExists(table, column string) bool {
+	var count int
+	query := `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?;`
+	if err := d.db.QueryRow(query, table, column).Scan(&count); err != nil {
+		return false
 	}
-	defer rows.Close()
+	return count > 0
+}
 
-	var proxies []models.Proxy
+// migrateDNSBackendRoutes converts DNS records that used to inject a /api/
+// location into their proxy's config into regular location rules. Rules are
+// only added to proxies that do not already route /api/.
+func (d *DatabaseService) migrateDNSBackendRoutes() error {
+	insertQuery := `
+		INSERT INTO proxy_locations (proxy_id, path, match_type, target_url, rate_limit_enabled, position)
+		SELECT p.id, '/api/', 'prefix', %s || '/api/', p.rate_limit_enabled,
+			(SELECT COUNT(*) FROM proxy_locations l WHERE l.proxy_id = p.id)
+		FROM proxies p
+		JOIN dns_records dr ON p.domain = dr.host || '.' || (SELECT dc.domain FROM dns_configs dc WHERE dc.id = dr.config_id)
+		WHERE %s = TRUE
+		AND NOT EXISTS (SELECT 1 FROM proxy_locations l WHERE l.proxy_id = p.id AND l.path = '/api/')`
+
+	if d.columnExists("dns_records", "is_upm_domain") {
+		if _, err := d.db.Exec(fmt.Sprintf(insertQuery, `'http://backend:6080'`, "dr.is_upm_domain")); err != nil {
+			return fmt.Errorf("failed to migrate is_upm_domain data: %w", err)
+		}
+		if _, err := d.db.Exec(`ALTER TABLE dns_records DROP COLUMN is_upm_domain;`); err != nil {
+			return fmt.Errorf("failed to drop is_upm_domain column: %w", err)
+		}
+	}
+
+	if d.columnExists("dns_records", "include_backend") {
+		backendURL := `COALESCE(NULLIF(RTRIM(dr.backend_url, '/'), ''), 'http://backend:6080')`
+		if !d.columnExists("dns_records", "backend_url") {
+			backendURL = `'http://backend:6080'`
+		}
+		if _, err := d.db.Exec(fmt.Sprintf(insertQuery, backendURL, "dr.include_backend")); err != nil {
+			return fmt.Errorf("failed to migrate include_backend data: %w", err)
+		}
+		if _, err := d.db.Exec(`ALTER TABLE dns_records DROP COLUMN include_backend;`); err != nil {
+			return fmt.Errorf("failed to drop include_backend column: %w", err)
+		}
+	}
+
+	if d.columnExists("dns_records", "backend_url") {
+		if _, err := d.db.Exec(`ALTER TABLE dns_records DROP COLUMN backend_url;`); err != nil {
+			return fmt.Errorf("failed to drop backend_url column: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migrateDNSAllowedIPRanges turns the allowed IP ranges of DNS records,
+// which used to restrict the proxy serving the record's host, into access
+// lists named after the host. A list is only created and attached when a
+// proxy for the host has no access list yet; invalid ranges are dropped.
+//
+// Records are matched to proxies the way the proxy config used to look
+// them up: the first label of the proxy's domain is the record's host and
+// the rest its zone, and the newest active record wins even when it has no
+// ranges. Apex records ("@") were never matched and are left alone.
+func (d *DatabaseService) migrateDNSAllowedIPRanges() error {
+	if !d.columnExists("dns_records", "allowed_ip_ranges") {
+		return nil
+	}
+
+	rows, err := d.db.Query(`SELECT DISTINCT domain FROM proxies WHERE access_list_id = 0 ORDER BY domain`)
+	if err != nil {
+		return fmt.Errorf("failed to query proxy domains: %w", err)
+	}
+	defer rows.Close()
+
+	var domains []string
 	for rows.Next() {
-		var proxy models.Proxy
-		err := rows.Scan(
-			&proxy.ID,
-			&proxy.Name,
-			&proxy.Domain,
-			&proxy.TargetURL,
-			&proxy.SSLEnabled,
-			&proxy.WSEnabled,
-			&proxy.SSLPath,
-			&proxy.RateLimitEnabled,
-			&proxy.RateLimitRPS,
-			&proxy.Status,
-			&proxy.CreatedAt,
-			&proxy.UpdatedAt,
-		)
+		var domain string
+		if err := rows.Scan(&domain); err != nil {
+			return fmt.Errorf("failed to scan proxy domain: %w", err)
+		}
+		domains = append(domains, domain)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	query := `
+		SELECT dr.allowed_ip_ranges
+		FROM dns_records dr
+		JOIN dns_configs dc ON dr.config_id = dc.id
+		WHERE dr.host = ? AND dc.domain = ? AND dr.is_active = TRUE
+		ORDER BY dr.created_at DESC
+		LIMIT 1`
+	for _, domain := range domains {
+		host, zone, ok := strings.Cut(domain, ".")
+		if !ok {
+			continue
+		}
+		var allowed sql.NullString
+		err := d.db.QueryRow(query, host, zone).Scan(&allowed)
+		if err == sql.ErrNoRows {
+			continue
+		}
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan proxy: %w", err)
+			return fmt.Errorf("failed to find DNS record for %s: %w", domain, err)
+		}
+
+		list := &models.AccessList{Name: domain}
+		seen := make(map[string]bool)
+		for _, r := range splitCommaList(allowed.String) {
+			entry := models.AccessListEntry{Action: models.AccessActionAllow, Address: r}
+			if seen[r] || models.ValidateAccessListEntries([]models.AccessListEntry{entry}) != nil {
+				continue
+			}
+			seen[r] = true
+			list.Entries = append(list.Entries, entry)
+		}
+		if len(list.Entries) == 0 {
+			continue
+		}
+		for i := 2; ; i++ {
+			inUse, err := d.AccessListNameInUse(list.Name, 0)
+			if err != nil {
+				return err
+			}
+			if !inUse {
+				break
+			}
+			list.Name = fmt.Sprintf("%s (%d)", domain, i)
+		}
+
+		if err := d.CreateAccessList(list); err != nil {
+			return fmt.Errorf("failed to create access list for %s: %w", domain, err)
+		}
+		if _, err := d.db.Exec(`UPDATE proxies SET access_list_id = ? WHERE domain = ? AND access_list_id = 0`, list.ID, domain); err != nil {
+			return fmt.Errorf("failed to attach access list to %s: %w", domain, err)
 		}
-		proxies = append(proxies, proxy)
 	}
 
-	return proxies, nil
+	if _, err := d.db.Exec(`ALTER TABLE dns_records DROP COLUMN allowed_ip_ranges;`); err != nil {
+		return fmt.Errorf("failed to drop allowed_ip_ranges column: %w", err)
+	}
+	return nil
 }
 
-func (d *DatabaseService) GetProxy(id int) (*models.Proxy, error) {
-	query := `
-		SELECT id, name, domain, target_url, ssl_enabled, ws_enabled, ssl_path, rate_limit_enabled, rate_limit_rps, status, created_at, updated_at
-		FROM proxies
-		WHERE id = ?`
+// Proxy methods
 
-	var proxy models.Proxy
-	err := d.db.QueryRow(query, id).Scan(
+// proxyColumns is the column list shared by every proxy SELECT; keep it in
+// sync with scanProxy.
+const proxyColumns = `id, name, domain, target_url, ssl_enabled, ws_enabled, ssl_path, rate_limit_enabled, rate_limit_rps, status, load_balance_method, canonical_redirect, ssl_mode, basic_auth_set_id, access_list_id, require_upm_login, forward_auth_url, forward_auth_response_headers, forward_auth_signin_url, geoip_action, geoip_countries, client_auth_mode, client_auth_ca_bundle, client_auth_verify_depth, client_auth_dn_header, client_auth_ca_id, cache_enabled, http3_enabled, type, redirect_status_code, redirect_preserve_path, redirect_preserve_query, static_root, static_index, static_spa_fallback, quarantined, last_error, created_at, updated_at`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanProxy(row rowScanner, proxy *models.Proxy) error {
+	var sslPath sql.NullString
+	var loadBalanceMethod sql.NullString
+	var sslMode sql.NullString
+	var forwardAuthURL, forwardAuthHeaders, forwardAuthSignIn sql.NullString
+	var geoIPAction, geoIPCountries sql.NullString
+	var clientAuthMode, clientAuthCABundle, clientAuthDNHeader sql.NullString
+	var clientAuthVerifyDepth, clientAuthCAID sql.NullInt64
+	var proxyType, staticRoot, staticIndex sql.NullString
+	var redirectCode sql.NullInt64
+	var redirectPath, redirectQuery, staticSPA, quarantined sql.NullBool
+	var lastError sql.NullString
+	err := row.Scan(
 		&proxy.ID,
 		&proxy.Name,
 		&proxy.Domain,
 		&proxy.TargetURL,
 		&proxy.SSLEnabled,
 		&proxy.WSEnabled,
-		&proxy.SSLPath,
+		&sslPath,
 		&proxy.RateLimitEnabled,
 		&proxy.RateLimitRPS,
 		&proxy.Status,
+		&loadBalanceMethod,
+		&proxy.CanonicalRedirect,
+		&sslMode,
+		&proxy.BasicAuthSetID,
+		&proxy.AccessListID,
+		&proxy.RequireUPMLogin,
+		&forwardAuthURL,
+		&forwardAuthHeaders,
+		&forwardAuthSignIn,
+		&geoIPAction,
+		&geoIPCountries,
+		&clientAuthMode,
+		&clientAuthCABundle,
+		&clientAuthVerifyDepth,
+		&clientAuthDNHeader,
+		&clientAuthCAID,
+		&proxy.CacheEnabled,
+		&proxy.HTTP3Enabled,
+		&proxyType,
+		&redirectCode,
+		&redirectPath,
+		&redirectQuery,
+		&staticRoot,
+		&staticIndex,
+		&staticSPA,
+		&quarantined,
+		&lastError,
 		&proxy.CreatedAt,
 		&proxy.UpdatedAt,
 	)
+	if err != nil {
+		return err
+	}
+	proxy.SSLPath = sslPath.String
+	proxy.Quarantined = quarantined.Bool
+	proxy.LastError = lastError.String
+	proxy.LoadBalanceMethod = loadBalanceMethod.String
+	proxy.SSLMode = sslMode.String
+	if proxy.SSLMode == "" {
+		proxy.SSLMode = models.SSLModeTerminate
+	}
+	if forwardAuthURL.String != "" {
+		proxy.ForwardAuth = &models.ForwardAuth{
+			URL:             forwardAuthURL.String,
+			ResponseHeaders: splitCommaList(forwardAuthHeaders.String),
+			SignInURL:       forwardAuthSignIn.String,
+		}
+	}
+	if geoIPAction.String != "" {
+		proxy.GeoIP = &models.GeoIPRule{
+			Action:    geoIPAction.String,
+			Countries: splitCommaList(geoIPCountries.String),
+		}
+	}
+	if clientAuthMode.String != "" {
+		proxy.ClientAuth = &models.ClientAuth{
+			Mode:        clientAuthMode.String,
+			CABundle:    clientAuthCABundle.String,
+			VerifyDepth: int(clientAuthVerifyDepth.Int64),
+			CAID:        int(clientAuthCAID.Int64),
+			DNHeader:    clientAuthDNHeader.String,
+		}
+	}
+	proxy.Type = proxyType.String
+	if proxy.Type == "" {
+		proxy.Type = models.ProxyTypeProxy
+	}
+	switch proxy.Type {
+	case models.ProxyTypeRedirect:
+		proxy.Redirect = &models.RedirectHost{
+			StatusCode:    int(redirectCode.Int64),
+			PreservePath:  redirectPath.Bool,
+			PreserveQuery: redirectQuery.Bool,
+		}
+	case models.ProxyTypeStatic:
+		proxy.Static = &models.StaticHost{
+			Root:        staticRoot.String,
+			Index:       staticIndex.String,
+			SPAFallback: staticSPA.Bool,
+		}
+	}
+	return nil
+}
+
+// hostTypeColumns flattens a proxy's type and its redirect and static
+// settings into the type, redirect_* and static_* columns.
+func hostTypeColumns(proxy *models.Proxy) []interface{} {
+	values := []interface{}{proxy.HostType(), 0, false, false, "", "", false}
+	if r := proxy.Redirect; r != nil {
+		values[1], values[2], values[3] = r.StatusCode, r.PreservePath, r.PreserveQuery
+	}
+	if st := proxy.Static; st != nil {
+		values[4], values[5], values[6] = st.Root, st.Index, st.SPAFallback
+	}
+	return values
+}
+
+// forwardAuthColumns flattens a proxy's forward auth settings into the
+// forward_auth_url, forward_auth_response_headers and
+// forward_auth_signin_url columns.
+func forwardAuthColumns(fa *models.ForwardAuth) (string, string, string) {
+	if !fa.Enabled() {
+		return "", "", ""
+	}
+	return fa.URL, strings.Join(fa.ResponseHeaders, ","), fa.SignInURL
+}
+
+// geoIPColumns flattens a country rule into the geoip_* columns.
+func geoIPColumns(rule *models.GeoIPRule) (string, string) {
+	if !rule.Enabled() {
+		return "", ""
+	}
+	return rule.Action, strings.Join(rule.Countries, ",")
+}
+
+// clientAuthColumns flattens a proxy's client certificate settings into
+// the client_auth_* columns.
+func clientAuthColumns(ca *models.ClientAuth) (string, string, int, string, int) {
+	if !ca.Enabled() {
+		return "", "", 0, "", 0
+	}
+	return ca.Mode, ca.CABundle, ca.VerifyDepth, ca.DNHeader, ca.CAID
+}
+
+// queryProxies runs a proxy SELECT and loads each proxy's related rows.
+func (d *DatabaseService) queryProxies(query string, args ...interface{}) ([]models.Proxy, error) {
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var proxies []models.Proxy
+	for rows.Next() {
+		var proxy models.Proxy
+		if err := scanProxy(rows, &proxy); err != nil {
+			return nil, fmt.Errorf("failed to scan proxy: %w", err)
+		}
+		proxies = append(proxies, proxy)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for i := range proxies {
+		if err := d.loadProxyRelations(&proxies[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return proxies, nil
+}
+
+// loadProxyRelations fills the child collections stored in their own tables.
+func (d *DatabaseService) loadProxyRelations(proxy *models.Proxy) error {
+	servers, err := d.GetUpstreamServers(proxy.ID)
+	if err != nil {
+		return err
+	}
+	proxy.UpstreamServers = servers
+
+	locations, err := d.GetProxyLocations(proxy.ID)
+	if err != nil {
+		return err
+	}
+	proxy.Locations = locations
+
+	aliases, err := d.GetProxyAliases(proxy.ID)
+	if err != nil {
+		return err
+	}
+	proxy.Aliases = aliases
+
+	headerRules, err := d.GetProxyHeaderRules(proxy.ID)
+	if err != nil {
+		return err
+	}
+	proxy.HeaderRules = headerRules
+
+	cache, err := d.GetProxyCachePolicy(proxy.ID)
+	if err != nil {
+		return err
+	}
+	proxy.Cache = cache
+
+	rateLimit, err := d.GetProxyRateLimitPolicy(proxy.ID)
+	if err != nil {
+		return err
+	}
+	proxy.RateLimit = rateLimit
+
+	tls, err := d.GetProxyTLSPolicy(proxy.ID)
+	if err != nil {
+		return err
+	}
+	proxy.TLS = tls
+
+	healthCheck, err := d.GetProxyHealthCheck(proxy.ID)
+	if err != nil {
+		return err
+	}
+	proxy.HealthCheck = healthCheck
+
+	maintenance, err := d.GetProxyMaintenance(proxy.ID)
+	if err != nil {
+		return err
+	}
+	proxy.Maintenance = maintenance
+
+	errorPages, err := d.GetErrorPages(proxy.ID)
+	if err != nil {
+		return err
+	}
+	proxy.ErrorPages = errorPages
+	return nil
+}
+
+func (d *DatabaseService) GetProxies() ([]models.Proxy, error) {
+	query := `SELECT ` + proxyColumns + ` FROM proxies ORDER BY created_at DESC`
+
+	proxies, err := d.queryProxies(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query proxies: %w", err)
+	}
+
+	return proxies, nil
+}
+
+func (d *DatabaseService) GetProxy(id int) (*models.Proxy, error) {
+	query := `SELECT ` + proxyColumns + ` FROM proxies WHERE id = ?`
+
+	var proxy models.Proxy
+	err := scanProxy(d.db.QueryRow(query, id), &proxy)
 
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("proxy not found")
@@ -297,15 +1291,33 @@ func (d *DatabaseService) GetProxy(id int) (*models.Proxy, error) {
 		return nil, fmt.Errorf("failed to query proxy: %w", err)
 	}
 
+	if err := d.loadProxyRelations(&proxy); err != nil {
+		return nil, err
+	}
+
 	return &proxy, nil
 }
 
+// GetPassthroughProxies returns every proxy in SNI passthrough mode.
+func (d *DatabaseService) GetPassthroughProxies() ([]models.Proxy, error) {
+	query := `SELECT ` + proxyColumns + ` FROM proxies WHERE ssl_mode = ? ORDER BY id`
+	return d.queryProxies(query, models.SSLModePassthrough)
+}
+
 func (d *DatabaseService) CreateProxy(proxy *models.Proxy) error {
 	query := `
-		INSERT INTO proxies (name, domain, target_url, ssl_enabled, ws_enabled, ssl_path, rate_limit_enabled, rate_limit_rps, status)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
-
-	result, err := d.db.Exec(query, proxy.Name, proxy.Domain, proxy.TargetURL, proxy.SSLEnabled, proxy.WSEnabled, proxy.SSLPath, proxy.RateLimitEnabled, proxy.RateLimitRPS, proxy.Status)
+		INSERT INTO proxies (name, domain, target_url, ssl_enabled, ws_enabled, ssl_path, rate_limit_enabled, rate_limit_rps, status, load_balance_method, canonical_redirect, ssl_mode, basic_auth_set_id, access_list_id, require_upm_login, forward_auth_url, forward_auth_response_headers, forward_auth_signin_url, geoip_action, geoip_countries, client_auth_mode, client_auth_ca_bundle, client_auth_verify_depth, client_auth_dn_header, client_auth_ca_id, cache_enabled, http3_enabled, type, redirect_status_code, redirect_preserve_path, redirect_preserve_query, static_root, static_index, static_spa_fallback)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	if proxy.SSLMode == "" {
+		proxy.SSLMode = models.SSLModeTerminate
+	}
+	faURL, faHeaders, faSignIn := forwardAuthColumns(proxy.ForwardAuth)
+	geoAction, geoCountries := geoIPColumns(proxy.GeoIP)
+	caMode, caBundle, caDepth, caDNHeader, caID := clientAuthColumns(proxy.ClientAuth)
+	args := []interface{}{proxy.Name, proxy.Domain, proxy.TargetURL, proxy.SSLEnabled, proxy.WSEnabled, proxy.SSLPath, proxy.RateLimitEnabled, proxy.RateLimitRPS, proxy.Status, proxy.LoadBalanceMethod, proxy.CanonicalRedirect, proxy.SSLMode, proxy.BasicAuthSetID, proxy.AccessListID, proxy.RequireUPMLogin, faURL, faHeaders, faSignIn, geoAction, geoCountries, caMode, caBundle, caDepth, caDNHeader, caID, proxy.CacheEnabled, proxy.HTTP3Enabled}
+	args = append(args, hostTypeColumns(proxy)...)
+	result, err := d.db.Exec(query, args...)
 	if err != nil {
 		return fmt.Errorf("failed to insert proxy: %w", err)
 	}
@@ -325,10 +1337,19 @@ func (d *DatabaseService) CreateProxy(proxy *models.Proxy) error {
 func (d *DatabaseService) UpdateProxy(proxy *models.Proxy) error {
 	query := `
 		UPDATE proxies
-		SET name = ?, domain = ?, target_url = ?, ssl_enabled = ?, ws_enabled = ?, ssl_path = ?, rate_limit_enabled = ?, rate_limit_rps = ?, status = ?, updated_at = CURRENT_TIMESTAMP
+		SET name = ?, domain = ?, target_url = ?, ssl_enabled = ?, ws_enabled = ?, ssl_path = ?, rate_limit_enabled = ?, rate_limit_rps = ?, status = ?, load_balance_method = ?, canonical_redirect = ?, ssl_mode = ?, basic_auth_set_id = ?, access_list_id = ?, require_upm_login = ?, forward_auth_url = ?, forward_auth_response_headers = ?, forward_auth_signin_url = ?, geoip_action = ?, geoip_countries = ?, client_auth_mode = ?, client_auth_ca_bundle = ?, client_auth_verify_depth = ?, client_auth_dn_header = ?, client_auth_ca_id = ?, cache_enabled = ?, http3_enabled = ?, type = ?, redirect_status_code = ?, redirect_preserve_path = ?, redirect_preserve_query = ?, static_root = ?, static_index = ?, static_spa_fallback = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?`
 
-	result, err := d.db.Exec(query, proxy.Name, proxy.Domain, proxy.TargetURL, proxy.SSLEnabled, proxy.WSEnabled, proxy.SSLPath, proxy.RateLimitEnabled, proxy.RateLimitRPS, proxy.Status, proxy.ID)
+	if proxy.SSLMode == "" {
+		proxy.SSLMode = models.SSLModeTerminate
+	}
+	faURL, faHeaders, faSignIn := forwardAuthColumns(proxy.ForwardAuth)
+	geoAction, geoCountries := geoIPColumns(proxy.GeoIP)
+	caMode, caBundle, caDepth, caDNHeader, caID := clientAuthColumns(proxy.ClientAuth)
+	args := []interface{}{proxy.Name, proxy.Domain, proxy.TargetURL, proxy.SSLEnabled, proxy.WSEnabled, proxy.SSLPath, proxy.RateLimitEnabled, proxy.RateLimitRPS, proxy.Status, proxy.LoadBalanceMethod, proxy.CanonicalRedirect, proxy.SSLMode, proxy.BasicAuthSetID, proxy.AccessListID, proxy.RequireUPMLogin, faURL, faHeaders, faSignIn, geoAction, geoCountries, caMode, caBundle, caDepth, caDNHeader, caID, proxy.CacheEnabled, proxy.HTTP3Enabled}
+	args = append(args, hostTypeColumns(proxy)...)
+	args = append(args, proxy.ID)
+	result, err := d.db.Exec(query, args...)
 	if err != nil {
 		return fmt.Errorf("failed to update proxy: %w", err)
 	}
@@ -347,23 +1368,1508 @@ func (d *DatabaseService) UpdateProxy(proxy *models.Proxy) error {
 }
 
 func (d *DatabaseService) DeleteProxy(id int) error {
-	query := `DELETE FROM proxies WHERE id = ?`
+	tx, err := d.begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
 
-	result, err := d.db.Exec(query, id)
+	// SQLite only enforces ON DELETE CASCADE with foreign_keys enabled, so
+	// child rows are removed explicitly.
+	if _, err := tx.Exec(`DELETE FROM proxy_upstream_servers WHERE proxy_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete upstream servers: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM proxy_locations WHERE proxy_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete proxy locations: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM proxy_domains WHERE proxy_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete proxy aliases: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM proxy_header_rules WHERE proxy_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete proxy header rules: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM proxy_cache_policies WHERE proxy_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete proxy cache policy: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM proxy_rate_limit_policies WHERE proxy_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete proxy rate limit policy: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM proxy_tls_policies WHERE proxy_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete proxy TLS policy: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM proxy_health_checks WHERE proxy_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete proxy health check: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM proxy_health_results WHERE proxy_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete proxy health results: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM proxy_maintenance WHERE proxy_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete proxy maintenance: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM error_pages WHERE proxy_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete proxy error pages: %w", err)
+	}
+
+	result, err := tx.Exec(`DELETE FROM proxies WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete proxy: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("proxy not found")
+	}
+
+	return tx.Commit()
+}
+
+// Upstream server methods
+func (d *DatabaseService) GetUpstreamServers(proxyID int) ([]models.UpstreamServer, error) {
+	query := `
+		SELECT id, proxy_id, url, weight, max_fails, fail_timeout, backup, created_at
+		FROM proxy_upstream_servers
+		WHERE proxy_id = ?
+		ORDER BY position, id`
+
+	rows, err := d.db.Query(query, proxyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query upstream servers: %w", err)
+	}
+	defer rows.Close()
+
+	var servers []models.UpstreamServer
+	for rows.Next() {
+		var server models.UpstreamServer
+		err := rows.Scan(
+			&server.ID,
+			&server.ProxyID,
+			&server.URL,
+			&server.Weight,
+			&server.MaxFails,
+			&server.FailTimeout,
+			&server.Backup,
+			&server.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan upstream server: %w", err)
+		}
+		servers = append(servers, server)
+	}
+
+	return servers, rows.Err()
+}
+
+// ReplaceUpstreamServers swaps a proxy's whole pool in one transaction. Pool
+// order is preserved so the rendered upstream block is stable.
+func (d *DatabaseService) ReplaceUpstreamServers(proxyID int, servers []models.UpstreamServer) error {
+	tx, err := d.begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM proxy_upstream_servers WHERE proxy_id = ?`, proxyID); err != nil {
+		return fmt.Errorf("failed to clear upstream servers: %w", err)
+	}
+
+	query := `
+		INSERT INTO proxy_upstream_servers (proxy_id, url, weight, max_fails, fail_timeout, backup, position)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`
+	for i := range servers {
+		result, err := tx.Exec(query, proxyID, servers[i].URL, servers[i].Weight, servers[i].MaxFails, servers[i].FailTimeout, servers[i].Backup, i)
+		if err != nil {
+			return fmt.Errorf("failed to insert upstream server: %w", err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get last insert id: %w", err)
+		}
+		servers[i].ID = int(id)
+		servers[i].ProxyID = proxyID
+		servers[i].CreatedAt = time.Now()
+	}
+
+	return tx.Commit()
+}
+
+// Proxy location methods
+func (d *DatabaseService) GetProxyLocations(proxyID int) ([]models.ProxyLocation, error) {
+	query := `
+		SELECT id, proxy_id, path, match_type, target_url, strip_prefix, rewrite, ws_enabled, rate_limit_enabled, rate_limit_rate, rate_limit_burst, basic_auth_set_id, access_list_id, cache_enabled, created_at
+		FROM proxy_locations
+		WHERE proxy_id = ?
+		ORDER BY position, id`
+
+	rows, err := d.db.Query(query, proxyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query proxy locations: %w", err)
+	}
+	defer rows.Close()
+
+	var locations []models.ProxyLocation
+	for rows.Next() {
+		var location models.ProxyLocation
+		var rewrite sql.NullString
+		err := rows.Scan(
+			&location.ID,
+			&location.ProxyID,
+			&location.Path,
+			&location.MatchType,
+			&location.TargetURL,
+			&location.StripPrefix,
+			&rewrite,
+			&location.WSEnabled,
+			&location.RateLimitEnabled,
+			&location.RateLimitRate,
+			&location.RateLimitBurst,
+			&location.BasicAuthSetID,
+			&location.AccessListID,
+			&location.CacheEnabled,
+			&location.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan proxy location: %w", err)
+		}
+		location.Rewrite = rewrite.String
+		locations = append(locations, location)
+	}
+
+	return locations, rows.Err()
+}
+
+// ReplaceProxyLocations swaps all of a proxy's location rules in one
+// transaction, keeping their order for regex matching.
+func (d *DatabaseService) ReplaceProxyLocations(proxyID int, locations []models.ProxyLocation) error {
+	tx, err := d.begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM proxy_locations WHERE proxy_id = ?`, proxyID); err != nil {
+		return fmt.Errorf("failed to clear proxy locations: %w", err)
+	}
+
+	query := `
+		INSERT INTO proxy_locations (proxy_id, path, match_type, target_url, strip_prefix, rewrite, ws_enabled, rate_limit_enabled, rate_limit_rate, rate_limit_burst, basic_auth_set_id, access_list_id, cache_enabled, position)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	for i := range locations {
+		l := &locations[i]
+		result, err := tx.Exec(query, proxyID, l.Path, l.MatchType, l.TargetURL, l.StripPrefix, l.Rewrite, l.WSEnabled, l.RateLimitEnabled, l.RateLimitRate, l.RateLimitBurst, l.BasicAuthSetID, l.AccessListID, l.CacheEnabled, i)
+		if err != nil {
+			return fmt.Errorf("failed to insert proxy location: %w", err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get last insert id: %w", err)
+		}
+		l.ID = int(id)
+		l.ProxyID = proxyID
+		l.CreatedAt = time.Now()
+	}
+
+	return tx.Commit()
+}
+
+// Proxy header rule methods
+func (d *DatabaseService) GetProxyHeaderRules(proxyID int) ([]models.ProxyHeaderRule, error) {
+	query := `
+		SELECT id, proxy_id, direction, action, name, value, always, created_at
+		FROM proxy_header_rules
+		WHERE proxy_id = ?
+		ORDER BY position, id`
+
+	rows, err := d.db.Query(query, proxyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query proxy header rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []models.ProxyHeaderRule
+	for rows.Next() {
+		var rule models.ProxyHeaderRule
+		var value sql.NullString
+		err := rows.Scan(
+			&rule.ID,
+			&rule.ProxyID,
+			&rule.Direction,
+			&rule.Action,
+			&rule.Name,
+			&value,
+			&rule.Always,
+			&rule.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan proxy header rule: %w", err)
+		}
+		rule.Value = value.String
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
+
+// ReplaceProxyHeaderRules swaps all of a proxy's header rules in one
+// transaction, keeping their order.
+func (d *DatabaseService) ReplaceProxyHeaderRules(proxyID int, rules []models.ProxyHeaderRule) error {
+	tx, err := d.begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM proxy_header_rules WHERE proxy_id = ?`, proxyID); err != nil {
+		return fmt.Errorf("failed to clear proxy header rules: %w", err)
+	}
+
+	query := `
+		INSERT INTO proxy_header_rules (proxy_id, direction, action, name, value, always, position)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`
+	for i := range rules {
+		r := &rules[i]
+		result, err := tx.Exec(query, proxyID, r.Direction, r.Action, r.Name, r.Value, r.Always, i)
+		if err != nil {
+			return fmt.Errorf("failed to insert proxy header rule: %w", err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get last insert id: %w", err)
+		}
+		r.ID = int(id)
+		r.ProxyID = proxyID
+		r.CreatedAt = time.Now()
+	}
+
+	return tx.Commit()
+}
+
+// Proxy cache policy methods
+
+// GetProxyCachePolicy returns the stored cache policy of a proxy, or nil
+// when the proxy uses the defaults.
+func (d *DatabaseService) GetProxyCachePolicy(proxyID int) (*models.CachePolicy, error) {
+	query := `
+		SELECT zone_size_mb, max_size_mb, inactive_minutes, valid, cache_key, bypass_cookies, bypass_headers, bypass_args, status_header
+		FROM proxy_cache_policies
+		WHERE proxy_id = ?`
+
+	var policy models.CachePolicy
+	var valid, key, bypassCookies, bypassHeaders, bypassArgs sql.NullString
+	err := d.db.QueryRow(query, proxyID).Scan(
+		&policy.ZoneSizeMB,
+		&policy.MaxSizeMB,
+		&policy.InactiveMinutes,
+		&valid,
+		&key,
+		&bypassCookies,
+		&bypassHeaders,
+		&bypassArgs,
+		&policy.StatusHeader,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get proxy cache policy: %w", err)
+	}
+
+	for _, item := range splitCommaList(valid.String) {
+		status, ttl, ok := strings.Cut(item, "=")
+		if !ok {
+			continue
+		}
+		seconds, err := strconv.Atoi(ttl)
+		if err != nil {
+			continue
+		}
+		policy.Valid = append(policy.Valid, models.CacheValidity{Status: status, TTL: seconds})
+	}
+	policy.Key = splitCommaList(key.String)
+	policy.BypassCookies = splitCommaList(bypassCookies.String)
+	policy.BypassHeaders = splitCommaList(bypassHeaders.String)
+	policy.BypassArgs = splitCommaList(bypassArgs.String)
+	return &policy, nil
+}
+
+// SetProxyCachePolicy stores a proxy's cache policy; nil removes it so the
+// defaults apply.
+func (d *DatabaseService) SetProxyCachePolicy(proxyID int, policy *models.CachePolicy) error {
+	if policy == nil {
+		if _, err := d.db.Exec(`DELETE FROM proxy_cache_policies WHERE proxy_id = ?`, proxyID); err != nil {
+			return fmt.Errorf("failed to delete proxy cache policy: %w", err)
+		}
+		return nil
+	}
+
+	valid := make([]string, 0, len(policy.Valid))
+	for _, v := range policy.Valid {
+		valid = append(valid, fmt.Sprintf("%s=%d", v.Status, v.TTL))
+	}
+
+	query := `
+		INSERT INTO proxy_cache_policies (proxy_id, zone_size_mb, max_size_mb, inactive_minutes, valid, cache_key, bypass_cookies, bypass_headers, bypass_args, status_header, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(proxy_id) DO UPDATE SET
+			zone_size_mb = excluded.zone_size_mb,
+			max_size_mb = excluded.max_size_mb,
+			inactive_minutes = excluded.inactive_minutes,
+			valid = excluded.valid,
+			cache_key = excluded.cache_key,
+			bypass_cookies = excluded.bypass_cookies,
+			bypass_headers = excluded.bypass_headers,
+			bypass_args = excluded.bypass_args,
+			status_header = excluded.status_header,
+			updated_at = CURRENT_TIMESTAMP`
+	_, err := d.db.Exec(query, proxyID, policy.ZoneSizeMB, policy.MaxSizeMB, policy.InactiveMinutes,
+		strings.Join(valid, ","), strings.Join(policy.Key, ","),
+		strings.Join(policy.BypassCookies, ","), strings.Join(policy.BypassHeaders, ","), strings.Join(policy.BypassArgs, ","),
+		policy.StatusHeader)
+	if err != nil {
+		return fmt.Errorf("failed to save proxy cache policy: %w", err)
+	}
+	return nil
+}
+
+// Proxy rate limit policy methods
+
+// GetProxyRateLimitPolicy returns the stored rate limit policy of a proxy,
+// or nil when the proxy uses the defaults.
+func (d *DatabaseService) GetProxyRateLimitPolicy(proxyID int) (*models.RateLimitPolicy, error) {
+	query := `
+		SELECT rate, per, burst, delay, rate_key, exempt_cidrs, conn_limit, status, response_body, response_content_type, retry_after, zone_size_mb
+		FROM proxy_rate_limit_policies
+		WHERE proxy_id = ?`
+
+	var policy models.RateLimitPolicy
+	var per, key, exemptCIDRs, responseBody, responseContentType sql.NullString
+	var delay sql.NullInt64
+	err := d.db.QueryRow(query, proxyID).Scan(
+		&policy.Rate,
+		&per,
+		&policy.Burst,
+		&delay,
+		&key,
+		&exemptCIDRs,
+		&policy.ConnLimit,
+		&policy.Status,
+		&responseBody,
+		&responseContentType,
+		&policy.RetryAfter,
+		&policy.ZoneSizeMB,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get proxy rate limit policy: %w", err)
+	}
+
+	policy.Per = per.String
+	if delay.Valid {
+		value := int(delay.Int64)
+		policy.Delay = &value
+	}
+	policy.Key = key.String
+	policy.ExemptCIDRs = splitCommaList(exemptCIDRs.String)
+	policy.ResponseBody = responseBody.String
+	policy.ResponseContentType = responseContentType.String
+	return &policy, nil
+}
+
+// SetProxyRateLimitPolicy stores a proxy's rate limit policy; nil removes
+// it so the defaults apply.
+func (d *DatabaseService) SetProxyRateLimitPolicy(proxyID int, policy *models.RateLimitPolicy) error {
+	if policy == nil {
+		if _, err := d.db.Exec(`DELETE FROM proxy_rate_limit_policies WHERE proxy_id = ?`, proxyID); err != nil {
+			return fmt.Errorf("failed to delete proxy rate limit policy: %w", err)
+		}
+		return nil
+	}
+
+	var delay sql.NullInt64
+	if policy.Delay != nil {
+		delay = sql.NullInt64{Int64: int64(*policy.Delay), Valid: true}
+	}
+
+	query := `
+		INSERT INTO proxy_rate_limit_policies (proxy_id, rate, per, burst, delay, rate_key, exempt_cidrs, conn_limit, status, response_body, response_content_type, retry_after, zone_size_mb, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(proxy_id) DO UPDATE SET
+			rate = excluded.rate,
+			per = excluded.per,
+			burst = excluded.burst,
+			delay = excluded.delay,
+			rate_key = excluded.rate_key,
+			exempt_cidrs = excluded.exempt_cidrs,
+			conn_limit = excluded.conn_limit,
+			status = excluded.status,
+			response_body = excluded.response_body,
+			response_content_type = excluded.response_content_type,
+			retry_after = excluded.retry_after,
+			zone_size_mb = excluded.zone_size_mb,
+			updated_at = CURRENT_TIMESTAMP`
+	_, err := d.db.Exec(query, proxyID, policy.Rate, policy.Per, policy.Burst, delay, policy.Key,
+		strings.Join(policy.ExemptCIDRs, ","), policy.ConnLimit, policy.Status,
+		policy.ResponseBody, policy.ResponseContentType, policy.RetryAfter, policy.ZoneSizeMB)
+	if err != nil {
+		return fmt.Errorf("failed to save proxy rate limit policy: %w", err)
+	}
+	return nil
+}
+
+// Proxy TLS policy methods
+
+// GetProxyTLSPolicy returns the stored TLS policy of a proxy, or nil when
+// the proxy uses the defaults.
+func (d *DatabaseService) GetProxyTLSPolicy(proxyID int) (*models.TLSPolicy, error) {
+	query := `
+		SELECT profile, protocols, ciphers, prefer_server_ciphers, hsts_enabled, hsts_max_age, hsts_include_subdomains, hsts_preload, ocsp_stapling, resolvers, resolver_timeout, session_tickets, session_timeout
+		FROM proxy_tls_policies
+		WHERE proxy_id = ?`
+
+	var policy models.TLSPolicy
+	var hsts models.HSTSPolicy
+	var profile, protocols, ciphers, resolvers sql.NullString
+	var hstsEnabled sql.NullBool
+	err := d.db.QueryRow(query, proxyID).Scan(
+		&profile,
+		&protocols,
+		&ciphers,
+		&policy.PreferServerCiphers,
+		&hstsEnabled,
+		&hsts.MaxAge,
+		&hsts.IncludeSubDomains,
+		&hsts.Preload,
+		&policy.OCSPStapling,
+		&resolvers,
+		&policy.ResolverTimeout,
+		&policy.SessionTickets,
+		&policy.SessionTimeout,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get proxy TLS policy: %w", err)
+	}
+
+	policy.Profile = profile.String
+	policy.Protocols = splitCommaList(protocols.String)
+	policy.Ciphers = ciphers.String
+	policy.Resolvers = splitCommaList(resolvers.String)
+	// A NULL hsts_enabled keeps the default header
+	if hstsEnabled.Valid {
+		hsts.Enabled = hstsEnabled.Bool
+		policy.HSTS = &hsts
+	}
+	return &policy, nil
+}
+
+// SetProxyTLSPolicy stores a proxy's TLS policy; nil removes it so the
+// defaults apply.
+func (d *DatabaseService) SetProxyTLSPolicy(proxyID int, policy *models.TLSPolicy) error {
+	if policy == nil {
+		if _, err := d.db.Exec(`DELETE FROM proxy_tls_policies WHERE proxy_id = ?`, proxyID); err != nil {
+			return fmt.Errorf("failed to delete proxy TLS policy: %w", err)
+		}
+		return nil
+	}
+
+	var hsts models.HSTSPolicy
+	var hstsEnabled sql.NullBool
+	if policy.HSTS != nil {
+		hsts = *policy.HSTS
+		hstsEnabled = sql.NullBool{Bool: hsts.Enabled, Valid: true}
+	}
+
+	query := `
+		INSERT INTO proxy_tls_policies (proxy_id, profile, protocols, ciphers, prefer_server_ciphers, hsts_enabled, hsts_max_age, hsts_include_subdomains, hsts_preload, ocsp_stapling, resolvers, resolver_timeout, session_tickets, session_timeout, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(proxy_id) DO UPDATE SET
+			profile = excluded.profile,
+			protocols = excluded.protocols,
+			ciphers = excluded.ciphers,
+			prefer_server_ciphers = excluded.prefer_server_ciphers,
+			hsts_enabled = excluded.hsts_enabled,
+			hsts_max_age = excluded.hsts_max_age,
+			hsts_include_subdomains = excluded.hsts_include_subdomains,
+			hsts_preload = excluded.hsts_preload,
+			ocsp_stapling = excluded.ocsp_stapling,
+			resolvers = excluded.resolvers,
+			resolver_timeout = excluded.resolver_timeout,
+			session_tickets = excluded.session_tickets,
+			session_timeout = excluded.session_timeout,
+			updated_at = CURRENT_TIMESTAMP`
+	_, err := d.db.Exec(query, proxyID, policy.Profile, strings.Join(policy.Protocols, ","), policy.Ciphers, policy.PreferServerCiphers,
+		hstsEnabled, hsts.MaxAge, hsts.IncludeSubDomains, hsts.Preload,
+		policy.OCSPStapling, strings.Join(policy.Resolvers, ","), policy.ResolverTimeout,
+		policy.SessionTickets, policy.SessionTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to save proxy TLS policy: %w", err)
+	}
+	return nil
+}
+
+// Proxy health check methods
+
+// GetProxyHealthCheck returns the stored health check of a proxy, or nil
+// when the proxy is probed with the defaults.
+func (d *DatabaseService) GetProxyHealthCheck(proxyID int) (*models.HealthCheck, error) {
+	query := `
+		SELECT enabled, path, expected_status, interval_seconds, timeout_seconds, unhealthy_threshold
+		FROM proxy_health_checks
+		WHERE proxy_id = ?`
+
+	var check models.HealthCheck
+	err := d.db.QueryRow(query, proxyID).Scan(
+		&check.Enabled,
+		&check.Path,
+		&check.ExpectedStatus,
+		&check.IntervalSeconds,
+		&check.TimeoutSeconds,
+		&check.UnhealthyThreshold,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get proxy health check: %w", err)
+	}
+	return &check, nil
+}
+
+// SetProxyHealthCheck stores a proxy's health check; nil removes it so the
+// defaults apply.
+func (d *DatabaseService) SetProxyHealthCheck(proxyID int, check *models.HealthCheck) error {
+	if check == nil {
+		if _, err := d.db.Exec(`DELETE FROM proxy_health_checks WHERE proxy_id = ?`, proxyID); err != nil {
+			return fmt.Errorf("failed to delete proxy health check: %w", err)
+		}
+		return nil
+	}
+
+	query := `
+		INSERT INTO proxy_health_checks (proxy_id, enabled, path, expected_status, interval_seconds, timeout_seconds, unhealthy_threshold, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(proxy_id) DO UPDATE SET
+			enabled = excluded.enabled,
+			path = excluded.path,
+			expected_status = excluded.expected_status,
+			interval_seconds = excluded.interval_seconds,
+			timeout_seconds = excluded.timeout_seconds,
+			unhealthy_threshold = excluded.unhealthy_threshold,
+			updated_at = CURRENT_TIMESTAMP`
+	_, err := d.db.Exec(query, proxyID, check.Enabled, check.Path, check.ExpectedStatus,
+		check.IntervalSeconds, check.TimeoutSeconds, check.UnhealthyThreshold)
+	if err != nil {
+		return fmt.Errorf("failed to save proxy health check: %w", err)
+	}
+	return nil
+}
+
+// AddHealthCheckResult records a probe result and prunes the proxy's
+// history to the newest keep results.
+func (d *DatabaseService) AddHealthCheckResult(result *models.HealthCheckResult, keep int) error {
+	tx, err := d.begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO proxy_health_results (proxy_id, healthy, status_code, latency_ms, error, checked_at)
+		VALUES (?, ?, ?, ?, ?, ?)`
+	res, err := tx.Exec(query, result.ProxyID, result.Healthy, result.StatusCode, result.LatencyMS, result.Error, result.CheckedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert health check result: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	result.ID = int(id)
+
+	prune := `
+		DELETE FROM proxy_health_results
+		WHERE proxy_id = ? AND id NOT IN (
+			SELECT id FROM proxy_health_results WHERE proxy_id = ? ORDER BY id DESC LIMIT ?
+		)`
+	if _, err := tx.Exec(prune, result.ProxyID, result.ProxyID, keep); err != nil {
+		return fmt.Errorf("failed to prune health check results: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// GetHealthCheckResults returns up to limit of a proxy's most recent probe
+// results, newest first.
+func (d *DatabaseService) GetHealthCheckResults(proxyID, limit int) ([]models.HealthCheckResult, error) {
+	query := `
+		SELECT id, proxy_id, healthy, status_code, latency_ms, error, checked_at
+		FROM proxy_health_results
+		WHERE proxy_id = ?
+		ORDER BY id DESC
+		LIMIT ?`
+
+	rows, err := d.db.Query(query, proxyID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query health check results: %w", err)
+	}
+	defer rows.Close()
+
+	var results []models.HealthCheckResult
+	for rows.Next() {
+		var r models.HealthCheckResult
+		var errorText sql.NullString
+		if err := rows.Scan(&r.ID, &r.ProxyID, &r.Healthy, &r.StatusCode, &r.LatencyMS, &errorText, &r.CheckedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan health check result: %w", err)
+		}
+		r.Error = errorText.String
+		results = append(results, r)
+	}
+
+	return results, rows.Err()
+}
+
+// QuarantineProxy marks a proxy whose config nginx rejected: its status
+// becomes error and message is kept as its last error.
+func (d *DatabaseService) QuarantineProxy(id int, message string) error {
+	query := `UPDATE proxies SET quarantined = TRUE, last_error = ?, status = 'error', updated_at = CURRENT_TIMESTAMP WHERE id = ?`
+	if _, err := d.db.Exec(query, message, id); err != nil {
+		return fmt.Errorf("failed to quarantine proxy: %w", err)
+	}
+	return nil
+}
+
+// ClearProxyQuarantine ends the quarantine of a proxy whose config passed
+// validation again, returning it from error to active.
+func (d *DatabaseService) ClearProxyQuarantine(id int) error {
+	query := `
+		UPDATE proxies
+		SET quarantined = FALSE, last_error = '', updated_at = CURRENT_TIMESTAMP,
+			status = CASE WHEN status = 'error' THEN 'active' ELSE status END
+		WHERE id = ?`
+	if _, err := d.db.Exec(query, id); err != nil {
+		return fmt.Errorf("failed to clear proxy quarantine: %w", err)
+	}
+	return nil
+}
+
+// GetQuarantinedProxyIDs returns the IDs of all quarantined proxies.
+func (d *DatabaseService) GetQuarantinedProxyIDs() ([]int, error) {
+	rows, err := d.db.Query(`SELECT id FROM proxies WHERE quarantined = TRUE ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query quarantined proxies: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan proxy id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// UpdateProxyStatus moves a proxy from status from to status to, so
+// background status changes don't overwrite concurrent edits of its
+// settings. It reports false and changes nothing when the stored status is
+// no longer from or the proxy was quarantined in the meantime.
+func (d *DatabaseService) UpdateProxyStatus(id int, from, to string) (bool, error) {
+	result, err := d.db.Exec(`UPDATE proxies SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND status = ? AND quarantined = FALSE`, to, id, from)
+	if err != nil {
+		return false, fmt.Errorf("failed to update proxy status: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rowsAffected > 0, nil
+}
+
+// Proxy maintenance methods
+
+// GetProxyMaintenance returns the maintenance settings of a proxy, or nil
+// when it never had any.
+func (d *DatabaseService) GetProxyMaintenance(proxyID int) (*models.Maintenance, error) {
+	query := `
+		SELECT enabled, page, retry_after_seconds, allowed_ips, starts_at, ends_at
+		FROM proxy_maintenance
+		WHERE proxy_id = ?`
+
+	var m models.Maintenance
+	var page, allowedIPs sql.NullString
+	var startsAt, endsAt sql.NullTime
+	err := d.db.QueryRow(query, proxyID).Scan(
+		&m.Enabled,
+		&page,
+		&m.RetryAfterSeconds,
+		&allowedIPs,
+		&startsAt,
+		&endsAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get proxy maintenance: %w", err)
+	}
+
+	m.Page = page.String
+	m.AllowedIPs = splitCommaList(allowedIPs.String)
+	if startsAt.Valid {
+		m.StartsAt = &startsAt.Time
+	}
+	if endsAt.Valid {
+		m.EndsAt = &endsAt.Time
+	}
+	return &m, nil
+}
+
+// SetProxyMaintenance stores a proxy's maintenance settings; nil removes
+// them.
+func (d *DatabaseService) SetProxyMaintenance(proxyID int, m *models.Maintenance) error {
+	if m == nil {
+		if _, err := d.db.Exec(`DELETE FROM proxy_maintenance WHERE proxy_id = ?`, proxyID); err != nil {
+			return fmt.Errorf("failed to delete proxy maintenance: %w", err)
+		}
+		return nil
+	}
+
+	query := `
+		INSERT INTO proxy_maintenance (proxy_id, enabled, page, retry_after_seconds, allowed_ips, starts_at, ends_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(proxy_id) DO UPDATE SET
+			enabled = excluded.enabled,
+			page = excluded.page,
+			retry_after_seconds = excluded.retry_after_seconds,
+			allowed_ips = excluded.allowed_ips,
+			starts_at = excluded.starts_at,
+			ends_at = excluded.ends_at,
+			updated_at = CURRENT_TIMESTAMP`
+	_, err := d.db.Exec(query, proxyID, m.Enabled, m.Page, m.RetryAfterSeconds,
+		strings.Join(m.AllowedIPs, ","), m.StartsAt, m.EndsAt)
+	if err != nil {
+		return fmt.Errorf("failed to save proxy maintenance: %w", err)
+	}
+	return nil
+}
+
+// Error page methods
+
+// GetErrorPages returns the custom error pages of a proxy, or the global
+// pages for proxyID 0, ordered by status code.
+func (d *DatabaseService) GetErrorPages(proxyID int) ([]models.ErrorPage, error) {
+	query := `
+		SELECT id, proxy_id, status_code, content, created_at, updated_at
+		FROM error_pages
+		WHERE proxy_id = ?
+		ORDER BY status_code`
+
+	rows, err := d.db.Query(query, proxyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query error pages: %w", err)
+	}
+	defer rows.Close()
+
+	var pages []models.ErrorPage
+	for rows.Next() {
+		var page models.ErrorPage
+		if err := rows.Scan(&page.ID, &page.ProxyID, &page.StatusCode, &page.Content, &page.CreatedAt, &page.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan error page: %w", err)
+		}
+		pages = append(pages, page)
+	}
+	return pages, rows.Err()
+}
+
+// GetErrorPage returns one custom error page of a proxy, or a global page
+// for proxyID 0; nil when there is none.
+func (d *DatabaseService) GetErrorPage(proxyID, statusCode int) (*models.ErrorPage, error) {
+	query := `
+		SELECT id, proxy_id, status_code, content, created_at, updated_at
+		FROM error_pages
+		WHERE proxy_id = ? AND status_code = ?`
+
+	var page models.ErrorPage
+	err := d.db.QueryRow(query, proxyID, statusCode).Scan(&page.ID, &page.ProxyID, &page.StatusCode, &page.Content, &page.CreatedAt, &page.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get error page: %w", err)
+	}
+	return &page, nil
+}
+
+// SetErrorPage creates or replaces the error page for the page's proxy and
+// status code.
+func (d *DatabaseService) SetErrorPage(page *models.ErrorPage) error {
+	query := `
+		INSERT INTO error_pages (proxy_id, status_code, content, updated_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(proxy_id, status_code) DO UPDATE SET
+			content = excluded.content,
+			updated_at = CURRENT_TIMESTAMP`
+	if _, err := d.db.Exec(query, page.ProxyID, page.StatusCode, page.Content); err != nil {
+		return fmt.Errorf("failed to save error page: %w", err)
+	}
+
+	saved, err := d.GetErrorPage(page.ProxyID, page.StatusCode)
+	if err != nil {
+		return err
+	}
+	*page = *saved
+	return nil
+}
+
+// DeleteErrorPage removes a custom error page, reporting whether one
+// existed.
+func (d *DatabaseService) DeleteErrorPage(proxyID, statusCode int) (bool, error) {
+	result, err := d.db.Exec(`DELETE FROM error_pages WHERE proxy_id = ? AND status_code = ?`, proxyID, statusCode)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete error page: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rowsAffected > 0, nil
+}
+
+// Proxy alias methods
+func (d *DatabaseService) GetProxyAliases(proxyID int) ([]string, error) {
+	rows, err := d.db.Query(`SELECT domain FROM proxy_domains WHERE proxy_id = ? ORDER BY position, id`, proxyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query proxy aliases: %w", err)
+	}
+	defer rows.Close()
+
+	var aliases []string
+	for rows.Next() {
+		var alias string
+		if err := rows.Scan(&alias); err != nil {
+			return nil, fmt.Errorf("failed to scan proxy alias: %w", err)
+		}
+		aliases = append(aliases, alias)
+	}
+
+	return aliases, rows.Err()
+}
+
+// ReplaceProxyAliases swaps all of a proxy's aliases in one transaction.
+func (d *DatabaseService) ReplaceProxyAliases(proxyID int, aliases []string) error {
+	tx, err := d.begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM proxy_domains WHERE proxy_id = ?`, proxyID); err != nil {
+		return fmt.Errorf("failed to clear proxy aliases: %w", err)
+	}
+	for i, alias := range aliases {
+		if _, err := tx.Exec(`INSERT INTO proxy_domains (proxy_id, domain, position) VALUES (?, ?, ?)`, proxyID, alias, i); err != nil {
+			return fmt.Errorf("failed to insert proxy alias %s: %w", alias, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ServerNameInUse reports whether name is already the domain or an alias of
+// a proxy other than exceptProxyID. Comparison is case-insensitive.
+func (d *DatabaseService) ServerNameInUse(name string, exceptProxyID int) (bool, error) {
+	query := `
+		SELECT COUNT(*) FROM (
+			SELECT id FROM proxies WHERE LOWER(domain) = LOWER(?) AND id != ?
+			UNION ALL
+			SELECT proxy_id FROM proxy_domains WHERE LOWER(domain) = LOWER(?) AND proxy_id != ?
+		)`
+
+	var count int
+	if err := d.db.QueryRow(query, name, exceptProxyID, name, exceptProxyID).Scan(&count); err != nil {
+		return false, fmt.Errorf("failed to check server name: %w", err)
+	}
+	return count > 0, nil
+}
+
+// Stream proxy methods
+
+// streamProxyColumns is the column list shared by every stream proxy SELECT;
+// keep it in sync with scanStreamProxy.
+const streamProxyColumns = `id, name, listen_port, protocol, targets, allowed_ip_ranges, proxy_timeout, status, created_at, updated_at`
+
+func scanStreamProxy(row rowScanner, stream *models.StreamProxy) error {
+	var targets string
+	var allowedIPRanges sql.NullString
+	err := row.Scan(
+		&stream.ID,
+		&stream.Name,
+		&stream.ListenPort,
+		&stream.Protocol,
+		&targets,
+		&allowedIPRanges,
+		&stream.ProxyTimeout,
+		&stream.Status,
+		&stream.CreatedAt,
+		&stream.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	stream.Targets = splitCommaList(targets)
+	stream.AllowedIPRanges = allowedIPRanges.String
+	return nil
+}
+
+// splitCommaList splits a stored comma-separated list, dropping blanks.
+func splitCommaList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func (d *DatabaseService) GetStreamProxies() ([]models.StreamProxy, error) {
+	query := `SELECT ` + streamProxyColumns + ` FROM stream_proxies ORDER BY listen_port, protocol`
+
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stream proxies: %w", err)
+	}
+	defer rows.Close()
+
+	var streams []models.StreamProxy
+	for rows.Next() {
+		var stream models.StreamProxy
+		if err := scanStreamProxy(rows, &stream); err != nil {
+			return nil, fmt.Errorf("failed to scan stream proxy: %w", err)
+		}
+		streams = append(streams, stream)
+	}
+
+	return streams, rows.Err()
+}
+
+func (d *DatabaseService) GetStreamProxy(id int) (*models.StreamProxy, error) {
+	query := `SELECT ` + streamProxyColumns + ` FROM stream_proxies WHERE id = ?`
+
+	var stream models.StreamProxy
+	err := scanStreamProxy(d.db.QueryRow(query, id), &stream)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("stream proxy not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stream proxy: %w", err)
+	}
+
+	return &stream, nil
+}
+
+func (d *DatabaseService) CreateStreamProxy(stream *models.StreamProxy) error {
+	query := `
+		INSERT INTO stream_proxies (name, listen_port, protocol, targets, allowed_ip_ranges, proxy_timeout, status)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	result, err := d.db.Exec(query, stream.Name, stream.ListenPort, stream.Protocol, strings.Join(stream.Targets, ","), stream.AllowedIPRanges, stream.ProxyTimeout, stream.Status)
+	if err != nil {
+		return fmt.Errorf("failed to insert stream proxy: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	stream.ID = int(id)
+	stream.CreatedAt = time.Now()
+	stream.UpdatedAt = time.Now()
+
+	return nil
+}
+
+func (d *DatabaseService) UpdateStreamProxy(stream *models.StreamProxy) error {
+	query := `
+		UPDATE stream_proxies
+		SET name = ?, listen_port = ?, protocol = ?, targets = ?, allowed_ip_ranges = ?, proxy_timeout = ?, status = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`
+
+	result, err := d.db.Exec(query, stream.Name, stream.ListenPort, stream.Protocol, strings.Join(stream.Targets, ","), stream.AllowedIPRanges, stream.ProxyTimeout, stream.Status, stream.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update stream proxy: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("stream proxy not found")
+	}
+
+	stream.UpdatedAt = time.Now()
+	return nil
+}
+
+func (d *DatabaseService) DeleteStreamProxy(id int) error {
+	result, err := d.db.Exec(`DELETE FROM stream_proxies WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete stream proxy: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("stream proxy not found")
+	}
+
+	return nil
+}
+
+// StreamPortInUse reports whether another stream proxy already listens on
+// port with the same protocol. TCP and UDP may share a port number.
+func (d *DatabaseService) StreamPortInUse(port int, protocol string, exceptID int) (bool, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM stream_proxies WHERE listen_port = ? AND protocol = ? AND id != ?`
+	if err := d.db.QueryRow(query, port, protocol, exceptID).Scan(&count); err != nil {
+		return false, fmt.Errorf("failed to check stream port: %w", err)
+	}
+	return count > 0, nil
+}
+
+// Basic auth methods
+func (d *DatabaseService) GetBasicAuthSets() ([]models.BasicAuthSet, error) {
+	rows, err := d.db.Query(`SELECT id, name, created_at, updated_at FROM basic_auth_sets ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query basic auth sets: %w", err)
+	}
+	defer rows.Close()
+
+	var sets []models.BasicAuthSet
+	for rows.Next() {
+		var set models.BasicAuthSet
+		if err := rows.Scan(&set.ID, &set.Name, &set.CreatedAt, &set.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan basic auth set: %w", err)
+		}
+		sets = append(sets, set)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for i := range sets {
+		users, err := d.GetBasicAuthUsers(sets[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		sets[i].Users = users
+	}
+	return sets, nil
+}
+
+func (d *DatabaseService) GetBasicAuthSet(id int) (*models.BasicAuthSet, error) {
+	var set models.BasicAuthSet
+	err := d.db.QueryRow(`SELECT id, name, created_at, updated_at FROM basic_auth_sets WHERE id = ?`, id).
+		Scan(&set.ID, &set.Name, &set.CreatedAt, &set.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("basic auth set not found")
+		}
+		return nil, fmt.Errorf("failed to get basic auth set: %w", err)
+	}
+
+	users, err := d.GetBasicAuthUsers(id)
+	if err != nil {
+		return nil, err
+	}
+	set.Users = users
+	return &set, nil
+}
+
+func (d *DatabaseService) GetBasicAuthUsers(setID int) ([]models.BasicAuthUser, error) {
+	query := `
+		SELECT id, set_id, username, password_hash, created_at, updated_at
+		FROM basic_auth_users
+		WHERE set_id = ?
+		ORDER BY username`
+
+	rows, err := d.db.Query(query, setID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query basic auth users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []models.BasicAuthUser
+	for rows.Next() {
+		var user models.BasicAuthUser
+		if err := rows.Scan(&user.ID, &user.SetID, &user.Username, &user.PasswordHash, &user.CreatedAt, &user.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan basic auth user: %w", err)
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// CreateBasicAuthSet inserts a set and its users in one transaction. Users
+// must already carry their bcrypt hashes.
+func (d *DatabaseService) CreateBasicAuthSet(set *models.BasicAuthSet) error {
+	tx, err := d.begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`INSERT INTO basic_auth_sets (name) VALUES (?)`, set.Name)
+	if err != nil {
+		return fmt.Errorf("failed to insert basic auth set: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	set.ID = int(id)
+
+	for i := range set.Users {
+		u := &set.Users[i]
+		result, err := tx.Exec(`INSERT INTO basic_auth_users (set_id, username, password_hash) VALUES (?, ?, ?)`, set.ID, u.Username, u.PasswordHash)
+		if err != nil {
+			return fmt.Errorf("failed to insert basic auth user: %w", err)
+		}
+		userID, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get last insert id: %w", err)
+		}
+		u.ID = int(userID)
+		u.SetID = set.ID
+		u.CreatedAt = time.Now()
+		u.UpdatedAt = time.Now()
+	}
+
+	set.CreatedAt = time.Now()
+	set.UpdatedAt = time.Now()
+	return tx.Commit()
+}
+
+func (d *DatabaseService) UpdateBasicAuthSet(set *models.BasicAuthSet) error {
+	result, err := d.db.Exec(`UPDATE basic_auth_sets SET name = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, set.Name, set.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update basic auth set: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("basic auth set not found")
+	}
+
+	set.UpdatedAt = time.Now()
+	return nil
+}
+
+func (d *DatabaseService) DeleteBasicAuthSet(id int) error {
+	tx, err := d.begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM basic_auth_users WHERE set_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete basic auth users: %w", err)
+	}
+	result, err := tx.Exec(`DELETE FROM basic_auth_sets WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete basic auth set: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("basic auth set not found")
+	}
+
+	return tx.Commit()
+}
+
+// SetBasicAuthUser adds a user to a set or rotates an existing user's
+// password hash.
+func (d *DatabaseService) SetBasicAuthUser(setID int, username, passwordHash string) error {
+	query := `
+		INSERT INTO basic_auth_users (set_id, username, password_hash)
+		VALUES (?, ?, ?)
+		ON CONFLICT (set_id, username) DO UPDATE SET password_hash = excluded.password_hash, updated_at = CURRENT_TIMESTAMP`
+
+	if _, err := d.db.Exec(query, setID, username, passwordHash); err != nil {
+		return fmt.Errorf("failed to save basic auth user: %w", err)
+	}
+	if _, err := d.db.Exec(`UPDATE basic_auth_sets SET updated_at = CURRENT_TIMESTAMP WHERE id = ?`, setID); err != nil {
+		return fmt.Errorf("failed to update basic auth set: %w", err)
+	}
+	return nil
+}
+
+func (d *DatabaseService) DeleteBasicAuthUser(setID int, username string) error {
+	result, err := d.db.Exec(`DELETE FROM basic_auth_users WHERE set_id = ? AND username = ?`, setID, username)
+	if err != nil {
+		return fmt.Errorf("failed to delete basic auth user: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("basic auth user not found")
+	}
+
+	if _, err := d.db.Exec(`UPDATE basic_auth_sets SET updated_at = CURRENT_TIMESTAMP WHERE id = ?`, setID); err != nil {
+		return fmt.Errorf("failed to update basic auth set: %w", err)
+	}
+	return nil
+}
+
+// BasicAuthSetNameInUse reports whether a set other than exceptID already
+// has the given name.
+func (d *DatabaseService) BasicAuthSetNameInUse(name string, exceptID int) (bool, error) {
+	var count int
+	err := d.db.QueryRow(`SELECT COUNT(*) FROM basic_auth_sets WHERE name = ? AND id != ?`, name, exceptID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check basic auth set name: %w", err)
+	}
+	return count > 0, nil
+}
+
+// GetProxiesByBasicAuthSet returns every proxy that uses the set on the
+// whole host or on any of its location rules.
+func (d *DatabaseService) GetProxiesByBasicAuthSet(setID int) ([]models.Proxy, error) {
+	query := `SELECT ` + proxyColumns + ` FROM proxies
+		WHERE basic_auth_set_id = ?
+		OR id IN (SELECT proxy_id FROM proxy_locations WHERE basic_auth_set_id = ?)
+		ORDER BY id`
+	return d.queryProxies(query, setID, setID)
+}
+
+// Access list methods
+func (d *DatabaseService) GetAccessLists() ([]models.AccessList, error) {
+	rows, err := d.db.Query(`SELECT id, name, created_at, updated_at FROM access_lists ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query access lists: %w", err)
+	}
+	defer rows.Close()
+
+	var lists []models.AccessList
+	for rows.Next() {
+		var list models.AccessList
+		if err := rows.Scan(&list.ID, &list.Name, &list.CreatedAt, &list.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan access list: %w", err)
+		}
+		lists = append(lists, list)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for i := range lists {
+		entries, err := d.GetAccessListEntries(lists[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		lists[i].Entries = entries
+	}
+	return lists, nil
+}
+
+func (d *DatabaseService) GetAccessList(id int) (*models.AccessList, error) {
+	var list models.AccessList
+	err := d.db.QueryRow(`SELECT id, name, created_at, updated_at FROM access_lists WHERE id = ?`, id).
+		Scan(&list.ID, &list.Name, &list.CreatedAt, &list.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("access list not found")
+		}
+		return nil, fmt.Errorf("failed to get access list: %w", err)
+	}
+
+	entries, err := d.GetAccessListEntries(id)
+	if err != nil {
+		return nil, err
+	}
+	list.Entries = entries
+	return &list, nil
+}
+
+// GetAccessListEntries returns the entries of a list in the order nginx
+// checks them.
+func (d *DatabaseService) GetAccessListEntries(listID int) ([]models.AccessListEntry, error) {
+	query := `
+		SELECT id, list_id, action, address
+		FROM access_list_entries
+		WHERE list_id = ?
+		ORDER BY position, id`
+
+	rows, err := d.db.Query(query, listID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query access list entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []models.AccessListEntry
+	for rows.Next() {
+		var entry models.AccessListEntry
+		if err := rows.Scan(&entry.ID, &entry.ListID, &entry.Action, &entry.Address); err != nil {
+			return nil, fmt.Errorf("failed to scan access list entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// CreateAccessList inserts a list and its entries in one transaction.
+func (d *DatabaseService) CreateAccessList(list *models.AccessList) error {
+	tx, err := d.begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`INSERT INTO access_lists (name) VALUES (?)`, list.Name)
+	if err != nil {
+		return fmt.Errorf("failed to insert access list: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	list.ID = int(id)
+
+	if err := insertAccessListEntries(tx, list.ID, list.Entries); err != nil {
+		return err
+	}
+
+	list.CreatedAt = time.Now()
+	list.UpdatedAt = time.Now()
+	return tx.Commit()
+}
+
+// UpdateAccessList renames a list and replaces all of its entries.
+func (d *DatabaseService) UpdateAccessList(list *models.AccessList) error {
+	tx, err := d.begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE access_lists SET name = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, list.Name, list.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update access list: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("access list not found")
+	}
+
+	if _, err := tx.Exec(`DELETE FROM access_list_entries WHERE list_id = ?`, list.ID); err != nil {
+		return fmt.Errorf("failed to clear access list entries: %w", err)
+	}
+	if err := insertAccessListEntries(tx, list.ID, list.Entries); err != nil {
+		return err
+	}
+
+	list.UpdatedAt = time.Now()
+	return tx.Commit()
+}
+
+// insertAccessListEntries stores entries in their order, filling in their
+// IDs.
+func insertAccessListEntries(tx dbConn, listID int, entries []models.AccessListEntry) error {
+	for i := range entries {
+		e := &entries[i]
+		result, err := tx.Exec(`INSERT INTO access_list_entries (list_id, action, address, position) VALUES (?, ?, ?, ?)`, listID, e.Action, e.Address, i)
+		if err != nil {
+			return fmt.Errorf("failed to insert access list entry: %w", err)
+		}
+		entryID, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get last insert id: %w", err)
+		}
+		e.ID = int(entryID)
+		e.ListID = listID
+	}
+	return nil
+}
+
+func (d *DatabaseService) DeleteAccessList(id int) error {
+	tx, err := d.begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM access_list_entries WHERE list_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete access list entries: %w", err)
+	}
+	result, err := tx.Exec(`DELETE FROM access_lists WHERE id = ?`, id)
 	if err != nil {
-		return fmt.Errorf("failed to delete proxy: %w", err)
+		return fmt.Errorf("failed to delete access list: %w", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		return fmt.Errorf("failed to get rows affected: %w", err)
 	}
-
 	if rowsAffected == 0 {
-		return fmt.Errorf("proxy not found")
+		return fmt.Errorf("access list not found")
 	}
 
-	return nil
+	return tx.Commit()
+}
+
+// AccessListNameInUse reports whether a list other than exceptID already
+// has the given name.
+func (d *DatabaseService) AccessListNameInUse(name string, exceptID int) (bool, error) {
+	var count int
+	err := d.db.QueryRow(`SELECT COUNT(*) FROM access_lists WHERE name = ? AND id != ?`, name, exceptID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check access list name: %w", err)
+	}
+	return count > 0, nil
+}
+
+// GetProxiesByAccessList returns every proxy that uses the list on the
+// whole host or on any of its location rules.
+func (d *DatabaseService) GetProxiesByAccessList(listID int) ([]models.Proxy, error) {
+	query := `SELECT ` + proxyColumns + ` FROM proxies
+		WHERE access_list_id = ?
+		OR id IN (SELECT proxy_id FROM proxy_locations WHERE access_list_id = ?)
+		ORDER BY id`
+	return d.queryProxies(query, listID, listID)
 }
 
 // DNS Config methods
@@ -553,7 +3059,7 @@ func (d *DatabaseService) DeleteDNSConfig(id int) error {
 // DNS Record methods
 func (d *DatabaseService) GetDNSRecords(configID int) ([]models.DNSRecord, error) {
 	query := `
-		SELECT id, config_id, host, current_ip, allowed_ip_ranges, dynamic_dns_refresh_rate, include_backend, backend_url, last_update, is_active, created_at, updated_at
+		SELECT id, config_id, host, current_ip, dynamic_dns_refresh_rate, last_update, is_active, created_at, updated_at
 		FROM dns_records
 		WHERE config_id = ?
 		ORDER BY created_at DESC`
@@ -568,9 +3074,7 @@ func (d *DatabaseService) GetDNSRecords(configID int) ([]models.DNSRecord, error
 	for rows.Next() {
 		var record models.DNSRecord
 		var currentIP sql.NullString
-		var allowedIPRanges sql.NullString
 		var dynamicDNSRefreshRate sql.NullInt32
-		var backendURL sql.NullString
 		var lastUpdate sql.NullTime
 
 		err := rows.Scan(
@@ -578,10 +3082,7 @@ func (d *DatabaseService) GetDNSRecords(configID int) ([]models.DNSRecord, error
 			&record.ConfigID,
 			&record.Host,
 			&currentIP,
-			&allowedIPRanges,
 			&dynamicDNSRefreshRate,
-			&record.IncludeBackend,
-			&backendURL,
 			&lastUpdate,
 			&record.IsActive,
 			&record.CreatedAt,
@@ -595,16 +3096,10 @@ func (d *DatabaseService) GetDNSRecords(configID int) ([]models.DNSRecord, error
 		if currentIP.Valid {
 			record.CurrentIP = currentIP.String
 		}
-		if allowedIPRanges.Valid {
-			record.AllowedIPRanges = allowedIPRanges.String
-		}
 		if dynamicDNSRefreshRate.Valid {
 			refreshRate := int(dynamicDNSRefreshRate.Int32)
 			record.DynamicDNSRefreshRate = &refreshRate
 		}
-		if backendURL.Valid {
-			record.BackendURL = backendURL.String
-		}
 		if lastUpdate.Valid {
 			record.LastUpdate = &lastUpdate.Time
 		}
@@ -617,15 +3112,13 @@ func (d *DatabaseService) GetDNSRecords(configID int) ([]models.DNSRecord, error
 
 func (d *DatabaseService) GetDNSRecord(id int) (*models.DNSRecord, error) {
 	query := `
-		SELECT id, config_id, host, current_ip, allowed_ip_ranges, dynamic_dns_refresh_rate, include_backend, backend_url, last_update, is_active, created_at, updated_at
+		SELECT id, config_id, host, current_ip, dynamic_dns_refresh_rate, last_update, is_active, created_at, updated_at
 		FROM dns_records
 		WHERE id = ?`
 
 	var record models.DNSRecord
 	var currentIP sql.NullString
-	var allowedIPRanges sql.NullString
 	var dynamicDNSRefreshRate sql.NullInt32
-	var backendURL sql.NullString
 	var lastUpdate sql.NullTime
 
 	err := d.db.QueryRow(query, id).Scan(
@@ -633,10 +3126,7 @@ func (d *DatabaseService) GetDNSRecord(id int) (*models.DNSRecord, error) {
 		&record.ConfigID,
 		&record.Host,
 		&currentIP,
-		&allowedIPRanges,
 		&dynamicDNSRefreshRate,
-		&record.IncludeBackend,
-		&backendURL,
 		&lastUpdate,
 		&record.IsActive,
 		&record.CreatedAt,
@@ -654,16 +3144,10 @@ func (d *DatabaseService) GetDNSRecord(id int) (*models.DNSRecord, error) {
 	if currentIP.Valid {
 		record.CurrentIP = currentIP.String
 	}
-	if allowedIPRanges.Valid {
-		record.AllowedIPRanges = allowedIPRanges.String
-	}
 	if dynamicDNSRefreshRate.Valid {
 		refreshRate := int(dynamicDNSRefreshRate.Int32)
 		record.DynamicDNSRefreshRate = &refreshRate
 	}
-	if backendURL.Valid {
-		record.BackendURL = backendURL.String
-	}
 	if lastUpdate.Valid {
 		record.LastUpdate = &lastUpdate.Time
 	}
@@ -673,10 +3157,10 @@ func (d *DatabaseService) GetDNSRecord(id int) (*models.DNSRecord, error) {
 
 func (d *DatabaseService) CreateDNSRecord(record *models.DNSRecord) error {
 	query := `
-		INSERT INTO dns_records (config_id, host, current_ip, allowed_ip_ranges, dynamic_dns_refresh_rate, include_backend, backend_url, is_active)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+		INSERT INTO dns_records (config_id, host, current_ip, dynamic_dns_refresh_rate, is_active)
+		VALUES (?, ?, ?, ?, ?)`
 
-	result, err := d.db.Exec(query, record.ConfigID, record.Host, record.CurrentIP, record.AllowedIPRanges, record.DynamicDNSRefreshRate, record.IncludeBackend, record.BackendURL, record.IsActive)
+	result, err := d.db.Exec(query, record.ConfigID, record.Host, record.CurrentIP, record.DynamicDNSRefreshRate, record.IsActive)
 	if err != nil {
 		return fmt.Errorf("failed to insert dns record: %w", err)
 	}
@@ -696,10 +3180,10 @@ func (d *DatabaseService) CreateDNSRecord(record *models.DNSRecord) error {
 func (d *DatabaseService) UpdateDNSRecord(record *models.DNSRecord) error {
 	query := `
 		UPDATE dns_records
-		SET host = ?, current_ip = ?, allowed_ip_ranges = ?, dynamic_dns_refresh_rate = ?, include_backend = ?, backend_url = ?, last_update = ?, is_active = ?, updated_at = CURRENT_TIMESTAMP
+		SET host = ?, current_ip = ?, dynamic_dns_refresh_rate = ?, last_update = ?, is_active = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?`
 
-	result, err := d.db.Exec(query, record.Host, record.CurrentIP, record.AllowedIPRanges, record.DynamicDNSRefreshRate, record.IncludeBackend, record.BackendURL, record.LastUpdate, record.IsActive, record.ID)
+	result, err := d.db.Exec(query, record.Host, record.CurrentIP, record.DynamicDNSRefreshRate, record.LastUpdate, record.IsActive, record.ID)
 	if err != nil {
 		return fmt.Errorf("failed to update dns record: %w", err)
 	}
@@ -737,83 +3221,62 @@ func (d *DatabaseService) DeleteDNSRecord(id int) error {
 	return nil
 }
 
-// GetDNSRecordByDomain finds a DNS record by domain name (host + domain)
-func (d *DatabaseService) GetDNSRecordByDomain(domain string) (*models.DNSRecord, error) {
-	// Split domain into host and base domain
-	parts := strings.Split(domain, ".")
-	if len(parts) < 2 {
-		return nil, fmt.Errorf("invalid domain format")
-	}
-	
-	host := parts[0]
-	baseDomain := strings.Join(parts[1:], ".")
-	
-	query := `
-		SELECT dr.id, dr.config_id, dr.host, dr.current_ip, dr.allowed_ip_ranges, dr.dynamic_dns_refresh_rate, dr.include_backend, dr.backend_url, dr.last_update, dr.is_active, dr.created_at, dr.updated_at
-		FROM dns_records dr
-		JOIN dns_configs dc ON dr.config_id = dc.id
-		WHERE dr.host = ? AND dc.domain = ? AND dr.is_active = TRUE
-		ORDER BY dr.created_at DESC
-		LIMIT 1`
+// Certificate methods
 
-	var record models.DNSRecord
-	var currentIP sql.NullString
-	var allowedIPRanges sql.NullString
-	var dynamicDNSRefreshRate sql.NullInt32
-	var backendURL sql.NullString
-	var lastUpdate sql.NullTime
+// certificateColumns is the column list shared by every certificate SELECT;
+// keep it in sync with scanCertificate.
+const certificateColumns = `id, domain, cert_path, key_path, expires_at, is_valid, source, usage, issuer, ca_id, serial_number, revoked_at, created_at, updated_at`
 
-	err := d.db.QueryRow(query, host, baseDomain).Scan(
-		&record.ID,
-		&record.ConfigID,
-		&record.Host,
-		&currentIP,
-		&allowedIPRanges,
-		&dynamicDNSRefreshRate,
-		&record.IncludeBackend,
-		&backendURL,
-		&lastUpdate,
-		&record.IsActive,
-		&record.CreatedAt,
-		&record.UpdatedAt,
+func scanCertificate(row rowScanner, cert *models.Certificate) error {
+	var source, usage, issuer, serialNumber sql.NullString
+	var caID sql.NullInt64
+	var revokedAt sql.NullTime
+	err := row.Scan(
+		&cert.ID,
+		&cert.Domain,
+		&cert.CertPath,
+		&cert.KeyPath,
+		&cert.ExpiresAt,
+		&cert.IsValid,
+		&source,
+		&usage,
+		&issuer,
+		&caID,
+		&serialNumber,
+		&revokedAt,
+		&cert.CreatedAt,
+		&cert.UpdatedAt,
 	)
-
-	if err == sql.ErrNoRows {
-		return nil, nil // No record found, not an error
-	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to query dns record by domain: %w", err)
-	}
-
-	// Handle nullable fields
-	if currentIP.Valid {
-		record.CurrentIP = currentIP.String
-	}
-	if allowedIPRanges.Valid {
-		record.AllowedIPRanges = allowedIPRanges.String
-	}
-	if dynamicDNSRefreshRate.Valid {
-		refreshRate := int(dynamicDNSRefreshRate.Int32)
-		record.DynamicDNSRefreshRate = &refreshRate
+		return err
 	}
-	if backendURL.Valid {
-		record.BackendURL = backendURL.String
+	cert.Source = source.String
+	cert.Usage = usage.String
+	if cert.Usage == "" {
+		cert.Usage = models.CertificateUsageServer
 	}
-	if lastUpdate.Valid {
-		record.LastUpdate = &lastUpdate.Time
+	cert.Issuer = issuer.String
+	cert.CAID = int(caID.Int64)
+	cert.SerialNumber = serialNumber.String
+	cert.RevokedAt = nil
+	if revokedAt.Valid {
+		t := revokedAt.Time
+		cert.RevokedAt = &t
 	}
-
-	return &record, nil
+	return nil
 }
 
-// Certificate methods
-func (d *DatabaseService) GetCertificates() ([]models.Certificate, error) {
-	query := `
-		SELECT id, domain, cert_path, key_path, expires_at, is_valid, created_at, updated_at
-		FROM certificates
-		ORDER BY created_at DESC`
+// defaultCertificateSource tells Let's Encrypt certificates, which live
+// where the ACME client writes them, from uploaded ones.
+func defaultCertificateSource(cert *models.Certificate) string {
+	if strings.Contains(cert.CertPath, "/etc/letsencrypt") || strings.Contains(cert.CertPath, "/etc/ssl/certs") {
+		return models.CertificateSourceLetsEncrypt
+	}
+	return models.CertificateSourceManual
+}
 
-	rows, err := d.db.Query(query)
+func (d *DatabaseService) queryCertificates(query string, args ...interface{}) ([]models.Certificate, error) {
+	rows, err := d.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query certificates: %w", err)
 	}
@@ -822,43 +3285,29 @@ func (d *DatabaseService) GetCertificates() ([]models.Certificate, error) {
 	var certificates []models.Certificate
 	for rows.Next() {
 		var cert models.Certificate
-		err := rows.Scan(
-			&cert.ID,
-			&cert.Domain,
-			&cert.CertPath,
-			&cert.KeyPath,
-			&cert.ExpiresAt,
-			&cert.IsValid,
-			&cert.CreatedAt,
-			&cert.UpdatedAt,
-		)
-		if err != nil {
+		if err := scanCertificate(rows, &cert); err != nil {
 			return nil, fmt.Errorf("failed to scan certificate: %w", err)
 		}
 		certificates = append(certificates, cert)
 	}
 
-	return certificates, nil
+	return certificates, rows.Err()
+}
+
+func (d *DatabaseService) GetCertificates() ([]models.Certificate, error) {
+	return d.queryCertificates(`SELECT ` + certificateColumns + ` FROM certificates ORDER BY created_at DESC`)
+}
+
+// GetCertificatesByCA returns the certificates an internal CA issued.
+func (d *DatabaseService) GetCertificatesByCA(caID int) ([]models.Certificate, error) {
+	return d.queryCertificates(`SELECT `+certificateColumns+` FROM certificates WHERE ca_id = ? ORDER BY id`, caID)
 }
 
 func (d *DatabaseService) GetCertificate(id int) (*models.Certificate, error) {
-	query := `
-		SELECT id, domain, cert_path, key_path, expires_at, is_valid, created_at, updated_at
-		FROM certificates
-		WHERE id = ?`
+	query := `SELECT ` + certificateColumns + ` FROM certificates WHERE id = ?`
 
 	var cert models.Certificate
-	err := d.db.QueryRow(query, id).Scan(
-		&cert.ID,
-		&cert.Domain,
-		&cert.CertPath,
-		&cert.KeyPath,
-		&cert.ExpiresAt,
-		&cert.IsValid,
-		&cert.CreatedAt,
-		&cert.UpdatedAt,
-	)
-	if err != nil {
+	if err := scanCertificate(d.db.QueryRow(query, id), &cert); err != nil {
 		return nil, fmt.Errorf("failed to get certificate: %w", err)
 	}
 
@@ -867,10 +3316,16 @@ func (d *DatabaseService) GetCertificate(id int) (*models.Certificate, error) {
 
 func (d *DatabaseService) CreateCertificate(cert *models.Certificate) error {
 	query := `
-		INSERT INTO certificates (domain, cert_path, key_path, expires_at, is_valid)
-		VALUES (?, ?, ?, ?, ?)`
+		INSERT INTO certificates (domain, cert_path, key_path, expires_at, is_valid, source, usage, issuer, ca_id, serial_number, revoked_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
-	result, err := d.db.Exec(query, cert.Domain, cert.CertPath, cert.KeyPath, cert.ExpiresAt, cert.IsValid)
+	if cert.Source == "" {
+		cert.Source = defaultCertificateSource(cert)
+	}
+	if cert.Usage == "" {
+		cert.Usage = models.CertificateUsageServer
+	}
+	result, err := d.db.Exec(query, cert.Domain, cert.CertPath, cert.KeyPath, cert.ExpiresAt, cert.IsValid, cert.Source, cert.Usage, cert.Issuer, cert.CAID, cert.SerialNumber, cert.RevokedAt)
 	if err != nil {
 		return fmt.Errorf("failed to insert certificate: %w", err)
 	}
@@ -890,10 +3345,16 @@ func (d *DatabaseService) CreateCertificate(cert *models.Certificate) error {
 func (d *DatabaseService) UpdateCertificate(cert *models.Certificate) error {
 	query := `
 		UPDATE certificates
-		SET domain = ?, cert_path = ?, key_path = ?, expires_at = ?, is_valid = ?, updated_at = CURRENT_TIMESTAMP
+		SET domain = ?, cert_path = ?, key_path = ?, expires_at = ?, is_valid = ?, source = ?, usage = ?, issuer = ?, ca_id = ?, serial_number = ?, revoked_at = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?`
 
-	result, err := d.db.Exec(query, cert.Domain, cert.CertPath, cert.KeyPath, cert.ExpiresAt, cert.IsValid, cert.ID)
+	if cert.Source == "" {
+		cert.Source = defaultCertificateSource(cert)
+	}
+	if cert.Usage == "" {
+		cert.Usage = models.CertificateUsageServer
+	}
+	result, err := d.db.Exec(query, cert.Domain, cert.CertPath, cert.KeyPath, cert.ExpiresAt, cert.IsValid, cert.Source, cert.Usage, cert.Issuer, cert.CAID, cert.SerialNumber, cert.RevokedAt, cert.ID)
 	if err != nil {
 		return fmt.Errorf("failed to update certificate: %w", err)
 	}
@@ -931,70 +3392,356 @@ func (d *DatabaseService) DeleteCertificate(id int) error {
 	return nil
 }
 
+// CertificateDomainInUse reports whether any certificate, including client
+// and revoked ones, is stored under domain.
+func (d *DatabaseService) CertificateDomainInUse(domain string) (bool, error) {
+	var count int
+	if err := d.db.QueryRow(`SELECT COUNT(*) FROM certificates WHERE domain = ?`, domain).Scan(&count); err != nil {
+		return false, fmt.Errorf("failed to check certificate domain: %w", err)
+	}
+	return count > 0, nil
+}
+
+// GetCertificateByDomain returns the server certificate stored for domain,
+// falling back to a wildcard certificate for its parent domain
+// (*.example.com covers www.example.com, but not a.www.example.com).
+// Client and revoked certificates are never served.
 func (d *DatabaseService) GetCertificateByDomain(domain string) (*models.Certificate, error) {
-	query := `
-		SELECT id, domain, cert_path, key_path, expires_at, is_valid, created_at, updated_at
-		FROM certificates
-		WHERE domain = ?`
+	query := `SELECT ` + certificateColumns + ` FROM certificates
+		WHERE domain = ? AND COALESCE(usage, '') != ? AND revoked_at IS NULL`
 
-	var cert models.Certificate
-	err := d.db.QueryRow(query, domain).Scan(
-		&cert.ID,
-		&cert.Domain,
-		&cert.CertPath,
-		&cert.KeyPath,
-		&cert.ExpiresAt,
-		&cert.IsValid,
-		&cert.CreatedAt,
-		&cert.UpdatedAt,
+	candidates := []string{domain}
+	if parts := strings.SplitN(domain, ".", 2); len(parts) == 2 && parts[0] != "*" && strings.Contains(parts[1], ".") {
+		candidates = append(candidates, "*."+parts[1])
+	}
+
+	for _, candidate := range candidates {
+		var cert models.Certificate
+		err := scanCertificate(d.db.QueryRow(query, candidate, models.CertificateUsageClient), &cert)
+
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to query certificate: %w", err)
+		}
+
+		return &cert, nil
+	}
+
+	return nil, fmt.Errorf("certificate not found for domain: %s", domain)
+}
+
+// GetCertificateForProxy returns a certificate valid for every server name
+// of the proxy, so its aliases are not served a certificate that leaves
+// them out. The certificates stored under the primary domain and then
+// under each alias are tried in turn.
+func (d *DatabaseService) GetCertificateForProxy(proxy *models.Proxy) (*models.Certificate, error) {
+	names := proxy.ServerNames()
+	tried := make(map[int]bool)
+	for _, name := range names {
+		cert, err := d.GetCertificateByDomain(name)
+		if err != nil || tried[cert.ID] {
+			continue
+		}
+		tried[cert.ID] = true
+		if certificateCovers(cert, names) {
+			return cert, nil
+		}
+	}
+	if len(tried) > 0 {
+		return nil, fmt.Errorf("no certificate covers every name of %s: %s", proxy.Domain, strings.Join(names, ", "))
+	}
+	return nil, fmt.Errorf("certificate not found for domain: %s", proxy.Domain)
+}
+
+// GetProxiesByDomain returns proxies whose domain or aliases match domain or
+// sit beneath it. A wildcard argument (*.example.com) matches every name
+// under example.com, and a concrete host also matches wildcard aliases
+// that cover it.
+func (d *DatabaseService) GetProxiesByDomain(domain string) ([]models.Proxy, error) {
+	base, isWildcard := strings.CutPrefix(domain, "*.")
+	names := []string{domain}
+	if !isWildcard {
+		labels := strings.Split(domain, ".")
+		for i := 1; i < len(labels)-1; i++ {
+			names = append(names, "*."+strings.Join(labels[i:], "."))
+		}
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", ")
+	query := `SELECT ` + proxyColumns + ` FROM proxies
+		WHERE domain IN (` + placeholders + `) OR domain LIKE ?
+		OR id IN (SELECT proxy_id FROM proxy_domains WHERE domain IN (` + placeholders + `) OR domain LIKE ?)`
+
+	var args []interface{}
+	for i := 0; i < 2; i++ {
+		for _, name := range names {
+			args = append(args, name)
+		}
+		args = append(args, "%."+base)
+	}
+
+	proxies, err := d.queryProxies(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query proxies by domain: %w", err)
+	}
+
+	return proxies, nil
+}
+
+// Certificate authority methods
+
+// certificateAuthorityColumns is the column list shared by every
+// certificate authority SELECT; keep it in sync with
+// scanCertificateAuthority.
+const certificateAuthorityColumns = `id, name, parent_id, common_name, cert_pem, key_pem, imported, expires_at, crl_number, created_at, updated_at`
+
+// scanCertificateAuthority reads a CA row and decrypts its private key.
+func (d *DatabaseService) scanCertificateAuthority(row rowScanner, ca *models.CertificateAuthority) error {
+	var parentID, crlNumber sql.NullInt64
+	var encryptedKey string
+	err := row.Scan(
+		&ca.ID,
+		&ca.Name,
+		&parentID,
+		&ca.CommonName,
+		&ca.Certificate,
+		&encryptedKey,
+		&ca.Imported,
+		&ca.ExpiresAt,
+		&crlNumber,
+		&ca.CreatedAt,
+		&ca.UpdatedAt,
 	)
+	if err != nil {
+		return err
+	}
+	ca.ParentID = int(parentID.Int64)
+	ca.CRLNumber = crlNumber.Int64
 
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("certificate not found for domain: %s", domain)
+	key, err := d.encryptionSvc.Decrypt(encryptedKey)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt key of certificate authority %d: %w", ca.ID, err)
 	}
+	ca.PrivateKey = key
+	return nil
+}
+
+func (d *DatabaseService) GetCertificateAuthorities() ([]models.CertificateAuthority, error) {
+	rows, err := d.db.Query(`SELECT ` + certificateAuthorityColumns + ` FROM certificate_authorities ORDER BY parent_id, name`)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query certificate: %w", err)
+		return nil, fmt.Errorf("failed to query certificate authorities: %w", err)
 	}
+	defer rows.Close()
 
-	return &cert, nil
+	var authorities []models.CertificateAuthority
+	for rows.Next() {
+		var ca models.CertificateAuthority
+		if err := d.scanCertificateAuthority(rows, &ca); err != nil {
+			return nil, fmt.Errorf("failed to scan certificate authority: %w", err)
+		}
+		authorities = append(authorities, ca)
+	}
+	return authorities, rows.Err()
 }
 
-func (d *DatabaseService) GetProxiesByDomain(domain string) ([]models.Proxy, error) {
+func (d *DatabaseService) GetCertificateAuthority(id int) (*models.CertificateAuthority, error) {
+	query := `SELECT ` + certificateAuthorityColumns + ` FROM certificate_authorities WHERE id = ?`
+
+	var ca models.CertificateAuthority
+	if err := d.scanCertificateAuthority(d.db.QueryRow(query, id), &ca); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("certificate authority not found")
+		}
+		return nil, fmt.Errorf("failed to get certificate authority: %w", err)
+	}
+	return &ca, nil
+}
+
+// CreateCertificateAuthority stores a CA with its private key encrypted.
+func (d *DatabaseService) CreateCertificateAuthority(ca *models.CertificateAuthority) error {
+	encryptedKey, err := d.encryptionSvc.Encrypt(ca.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt private key: %w", err)
+	}
+
 	query := `
-		SELECT id, name, domain, target_url, ssl_enabled, ws_enabled, ssl_path, rate_limit_enabled, rate_limit_rps, status, created_at, updated_at
-		FROM proxies
-		WHERE domain = ? OR domain LIKE ?`
+		INSERT INTO certificate_authorities (name, parent_id, common_name, cert_pem, key_pem, imported, expires_at, crl_number)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
 
-	rows, err := d.db.Query(query, domain, "%."+domain)
+	result, err := d.db.Exec(query, ca.Name, ca.ParentID, ca.CommonName, ca.Certificate, encryptedKey, ca.Imported, ca.ExpiresAt, ca.CRLNumber)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query proxies by domain: %w", err)
+		return fmt.Errorf("failed to insert certificate authority: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	ca.ID = int(id)
+	ca.CreatedAt = time.Now()
+	ca.UpdatedAt = time.Now()
+	return nil
+}
+
+// SetCertificateAuthorityCRLNumber records the number of the last CRL a
+// CA signed; CRL numbers must increase.
+func (d *DatabaseService) SetCertificateAuthorityCRLNumber(id int, number int64) error {
+	result, err := d.db.Exec(`UPDATE certificate_authorities SET crl_number = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, number, id)
+	if err != nil {
+		return fmt.Errorf("failed to update CRL number: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("certificate authority not found")
+	}
+	return nil
+}
+
+// DeleteCertificateAuthority removes a CA and its revocations.
+func (d *DatabaseService) DeleteCertificateAuthority(id int) error {
+	tx, err := d.begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM certificate_revocations WHERE ca_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete certificate revocations: %w", err)
+	}
+	result, err := tx.Exec(`DELETE FROM certificate_authorities WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete certificate authority: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("certificate authority not found")
+	}
+
+	return tx.Commit()
+}
+
+// CertificateAuthorityNameInUse reports whether a CA already has the name.
+func (d *DatabaseService) CertificateAuthorityNameInUse(name string) (bool, error) {
+	var count int
+	if err := d.db.QueryRow(`SELECT COUNT(*) FROM certificate_authorities WHERE name = ?`, name).Scan(&count); err != nil {
+		return false, fmt.Errorf("failed to check certificate authority name: %w", err)
+	}
+	return count > 0, nil
+}
+
+// GetProxiesByClientCA returns every proxy verifying client certificates
+// against an internal CA.
+func (d *DatabaseService) GetProxiesByClientCA(caID int) ([]models.Proxy, error) {
+	query := `SELECT ` + proxyColumns + ` FROM proxies WHERE client_auth_mode != '' AND client_auth_ca_id = ? ORDER BY id`
+	return d.queryProxies(query, caID)
+}
+
+// AddCertificateRevocation records a revocation; revoking a serial twice
+// keeps the first revocation.
+func (d *DatabaseService) AddCertificateRevocation(rev *models.CertificateRevocation) error {
+	query := `
+		INSERT INTO certificate_revocations (ca_id, serial_number, reason, revoked_at, expires_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (ca_id, serial_number) DO NOTHING`
+
+	if _, err := d.db.Exec(query, rev.CAID, rev.SerialNumber, rev.Reason, rev.RevokedAt, rev.ExpiresAt); err != nil {
+		return fmt.Errorf("failed to insert certificate revocation: %w", err)
+	}
+	return nil
+}
+
+// GetCertificateRevocations returns the revocations of a CA whose
+// certificates have not expired by now; expired certificates are left off
+// CRLs.
+func (d *DatabaseService) GetCertificateRevocations(caID int, now time.Time) ([]models.CertificateRevocation, error) {
+	query := `
+		SELECT id, ca_id, serial_number, reason, revoked_at, expires_at
+		FROM certificate_revocations
+		WHERE ca_id = ?
+		ORDER BY revoked_at, id`
+
+	rows, err := d.db.Query(query, caID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query certificate revocations: %w", err)
 	}
 	defer rows.Close()
 
-	var proxies []models.Proxy
+	var revocations []models.CertificateRevocation
 	for rows.Next() {
-		var proxy models.Proxy
-		err := rows.Scan(
-			&proxy.ID,
-			&proxy.Name,
-			&proxy.Domain,
-			&proxy.TargetURL,
-			&proxy.SSLEnabled,
-			&proxy.WSEnabled,
-			&proxy.SSLPath,
-			&proxy.RateLimitEnabled,
-			&proxy.RateLimitRPS,
-			&proxy.Status,
-			&proxy.CreatedAt,
-			&proxy.UpdatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan proxy: %w", err)
+		var rev models.CertificateRevocation
+		if err := rows.Scan(&rev.ID, &rev.CAID, &rev.SerialNumber, &rev.Reason, &rev.RevokedAt, &rev.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("failed to scan certificate revocation: %w", err)
+		}
+		if rev.ExpiresAt.After(now) {
+			revocations = append(revocations, rev)
 		}
-		proxies = append(proxies, proxy)
 	}
+	return revocations, rows.Err()
+}
 
-	return proxies, nil
+// GetHTTP3ReuseportOwner returns the ID of the proxy whose QUIC listen
+// carries reuseport, or 0 when no proxy does.
+func (d *DatabaseService) GetHTTP3ReuseportOwner() (int, error) {
+	var proxyID int
+	err := d.db.QueryRow(`SELECT reuseport_proxy_id FROM http3_listener WHERE id = 1`).Scan(&proxyID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get HTTP/3 reuseport owner: %w", err)
+	}
+	return proxyID, nil
+}
+
+// GetHTTP3ProxyIDs returns the proxies that serve HTTP/3 while it is
+// enabled globally: opted in, SSL enabled, terminating TLS themselves and
+// not quarantined.
+func (d *DatabaseService) GetHTTP3ProxyIDs() ([]int, error) {
+	query := `
+		SELECT id FROM proxies
+		WHERE http3_enabled = TRUE AND ssl_enabled = TRUE AND COALESCE(ssl_mode, '') != ? AND COALESCE(quarantined, FALSE) = FALSE
+		ORDER BY id`
+	rows, err := d.db.Query(query, models.SSLModePassthrough)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query HTTP/3 proxies: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan HTTP/3 proxy: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// SetHTTP3ReuseportOwner records the proxy whose QUIC listen carries
+// reuseport; 0 records that none does.
+func (d *DatabaseService) SetHTTP3ReuseportOwner(proxyID int) error {
+	query := `
+		INSERT INTO http3_listener (id, reuseport_proxy_id, updated_at)
+		VALUES (1, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(id) DO UPDATE SET
+			reuseport_proxy_id = excluded.reuseport_proxy_id,
+			updated_at = CURRENT_TIMESTAMP`
+	if _, err := d.db.Exec(query, proxyID); err != nil {
+		return fmt.Errorf("failed to set HTTP/3 reuseport owner: %w", err)
+	}
+	return nil
 }
 
 // UI Settings methods
@@ -1107,5 +3854,5 @@ func (d *DatabaseService) DeleteAdminUser() error {
 }
 
 func (d *DatabaseService) Close() error {
-	return d.db.Close()
+	return d.root.Close()
 }
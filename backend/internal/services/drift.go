@@ -0,0 +1,320 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"upm-backend/internal/models"
+)
+
+// Names of the files UPM generates, relative to the directory they are
+// written to. Files that match but that nothing renders are orphans; other
+// files, like the templates in the config directory, are left alone.
+var (
+	proxyFileRegexes = []*regexp.Regexp{
+		regexp.MustCompile(`^proxy-\d+\.conf$`),
+		regexp.MustCompile(`^proxy-\d+-auth-\d+\.htpasswd$`),
+		regexp.MustCompile(`^proxy-\d+-maintenance\.html$`),
+		regexp.MustCompile(`^proxy-\d+-client-ca\.pem$`),
+		regexp.MustCompile(`^` + regexp.QuoteMeta(cacheZonesConfigName) + `$`),
+		regexp.MustCompile(`^` + regexp.QuoteMeta(rateLimitZonesConfigName) + `$`),
+		regexp.MustCompile(`^` + regexp.QuoteMeta(geoIPConfigName) + `$`),
+	}
+	streamFileRegexes = []*regexp.Regexp{
+		regexp.MustCompile(`^stream-\d+\.conf$`),
+		regexp.MustCompile(`^` + regexp.QuoteMeta(passthroughConfigName) + `$`),
+	}
+	errorPageFileRegex = regexp.MustCompile(`^` + errorPagesDirName + `/proxy-\d+/\d{3}\.html$`)
+)
+
+// errDriftRender rolls back the transaction DetectDrift renders in.
+var errDriftRender = errors.New("drift render rolled back")
+
+// ReconcileConfig renders every generated file from the database and
+// removes the generated files nothing renders any more, such as the config
+// of a proxy whose delete failed halfway. Like the other generators it only
+// writes files; run it through Apply.
+func (n *NginxService) ReconcileConfig() error {
+	if n.DatabaseService == nil {
+		return fmt.Errorf("database service not initialized")
+	}
+	if n.stage == nil {
+		// Orphans are told apart by what the apply in progress rendered
+		return fmt.Errorf("reconcile must run through Apply")
+	}
+
+	proxies, err := n.DatabaseService.GetProxies()
+	if err != nil {
+		return fmt.Errorf("failed to load proxies: %w", err)
+	}
+	for i := range proxies {
+		if err := n.GenerateProxyConfig(&proxies[i]); err != nil {
+			return fmt.Errorf("failed to generate nginx config for %s: %w", proxies[i].Domain, err)
+		}
+	}
+	streams, err := n.DatabaseService.GetStreamProxies()
+	if err != nil {
+		return fmt.Errorf("failed to load streams: %w", err)
+	}
+	for i := range streams {
+		if err := n.GenerateStreamConfig(&streams[i]); err != nil {
+			return fmt.Errorf("failed to generate stream config for port %d: %w", streams[i].ListenPort, err)
+		}
+	}
+	// Without proxies nothing above syncs the shared files
+	if err := n.syncCacheZones(); err != nil {
+		return err
+	}
+	if err := n.syncRateLimitZones(); err != nil {
+		return err
+	}
+	if err := n.syncGeoIPConfig(); err != nil {
+		return err
+	}
+	if err := n.syncPassthroughConfig(); err != nil {
+		return err
+	}
+
+	generated, err := n.generatedFiles()
+	if err != nil {
+		return err
+	}
+	for _, path := range generated {
+		if _, ok := n.stage.files[path]; ok {
+			continue
+		}
+		if err := n.removeFile(path); err != nil {
+			return fmt.Errorf("failed to remove orphaned file: %w", err)
+		}
+		if strings.HasPrefix(path, filepath.Join(n.SitesEnabledPath, errorPagesDirName)+string(filepath.Separator)) {
+			// Error pages of a deleted proxy leave their directory behind
+			n.stage.dirs = append(n.stage.dirs, filepath.Dir(path))
+		}
+	}
+	return nil
+}
+
+// generatedFiles lists the files on disk whose names UPM generates.
+func (n *NginxService) generatedFiles() ([]string, error) {
+	dirs := []struct {
+		path    string
+		regexes []*regexp.Regexp
+		nested  bool // whether error page directories live here
+	}{
+		{n.SitesEnabledPath, proxyFileRegexes, true},
+		{n.ConfigPath, append(append([]*regexp.Regexp{}, proxyFileRegexes...), streamFileRegexes...), false},
+		{n.StreamsEnabledPath, streamFileRegexes, false},
+	}
+
+	var files []string
+	for _, dir := range dirs {
+		err := filepath.WalkDir(dir.path, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			rel, err := filepath.Rel(dir.path, path)
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() {
+				if rel == "." || (dir.nested && (rel == errorPagesDirName || filepath.Dir(rel) == errorPagesDirName)) {
+					return nil
+				}
+				return filepath.SkipDir
+			}
+			rel = filepath.ToSlash(rel)
+			if dir.nested && errorPageFileRegex.MatchString(rel) {
+				files = append(files, path)
+				return nil
+			}
+			for _, re := range dir.regexes {
+				if re.MatchString(rel) {
+					files = append(files, path)
+					break
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list generated files: %w", err)
+		}
+	}
+	return files, nil
+}
+
+// DetectDrift renders every generated file from the database in memory and
+// reports the files on disk that are missing, orphaned or differ from it.
+// Nothing is written: the render runs in a transaction that is rolled back,
+// since rendering may update proxies. The config of a quarantined proxy is
+// expected to be absent from sites-enabled.
+func (n *NginxService) DetectDrift() (*models.DriftReport, error) {
+	if n.DatabaseService == nil {
+		return nil, fmt.Errorf("database service not initialized")
+	}
+
+	applyMu.Lock()
+	defer applyMu.Unlock()
+
+	var stage *configStage
+	err := n.DatabaseService.InTransaction(func(db *DatabaseService) error {
+		staged := *n
+		staged.DatabaseService = db
+		staged.stage = newConfigStage()
+		if err := staged.ReconcileConfig(); err != nil {
+			return err
+		}
+		quarantined, err := db.GetQuarantinedProxyIDs()
+		if err != nil {
+			return err
+		}
+		for _, id := range quarantined {
+			if err := staged.removeFile(filepath.Join(n.SitesEnabledPath, fmt.Sprintf("proxy-%d.conf", id))); err != nil {
+				return err
+			}
+		}
+		stage = staged.stage
+		return errDriftRender
+	})
+	if !errors.Is(err, errDriftRender) {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(stage.files))
+	for path := range stage.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	report := &models.DriftReport{CheckedAt: time.Now(), Files: []models.ConfigDrift{}}
+	for _, path := range paths {
+		file := stage.files[path]
+		current, err := os.ReadFile(path)
+		exists := err == nil
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		switch {
+		case file.removed && exists:
+			report.Files = append(report.Files, models.ConfigDrift{
+				Path: path, Kind: models.DriftOrphaned, Diff: unifiedDiff(path, "/dev/null", current, nil),
+			})
+		case !file.removed && !exists:
+			report.Files = append(report.Files, models.ConfigDrift{
+				Path: path, Kind: models.DriftMissing, Diff: unifiedDiff("/dev/null", path, nil, file.data),
+			})
+		case !file.removed && !bytes.Equal(current, file.data):
+			report.Files = append(report.Files, models.ConfigDrift{
+				Path: path, Kind: models.DriftModified, Diff: unifiedDiff(path, path, current, file.data),
+			})
+		}
+	}
+	report.InSync = len(report.Files) == 0
+	return report, nil
+}
+
+// DriftMonitor periodically checks the generated nginx files for drift and
+// logs when drift appears, changes or is resolved.
+type DriftMonitor struct {
+	nginx    *NginxService
+	interval time.Duration
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+	running  bool
+	mu       sync.Mutex
+
+	lastDrift string // the drifted files last logged, empty when in sync
+}
+
+// NewDriftMonitor creates a drift check scheduler.
+func NewDriftMonitor(nginx *NginxService, interval time.Duration) *DriftMonitor {
+	return &DriftMonitor{
+		nginx:    nginx,
+		interval: interval,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start begins the background drift checks.
+func (m *DriftMonitor) Start() {
+	m.mu.Lock()
+	if m.running {
+		m.mu.Unlock()
+		return
+	}
+	m.running = true
+	m.stopChan = make(chan struct{})
+	m.mu.Unlock()
+
+	m.wg.Add(1)
+	go m.run()
+	log.Printf("Config drift monitor started (interval: %v)", m.interval)
+}
+
+// Stop shuts down the background drift checks.
+func (m *DriftMonitor) Stop() {
+	m.mu.Lock()
+	if !m.running {
+		m.mu.Unlock()
+		return
+	}
+	m.running = false
+	close(m.stopChan)
+	m.mu.Unlock()
+	m.wg.Wait()
+	log.Printf("Config drift monitor stopped")
+}
+
+func (m *DriftMonitor) run() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.check()
+		case <-m.stopChan:
+			return
+		}
+	}
+}
+
+// check runs one drift check and logs its outcome when it differs from
+// the previous one.
+func (m *DriftMonitor) check() {
+	report, err := m.nginx.DetectDrift()
+	if err != nil {
+		log.Printf("Config drift check failed: %v", err)
+		return
+	}
+
+	entries := make([]string, len(report.Files))
+	for i, file := range report.Files {
+		entries[i] = fmt.Sprintf("%s (%s)", file.Path, file.Kind)
+	}
+	drift := strings.Join(entries, ", ")
+	if drift == m.lastDrift {
+		return
+	}
+	if drift == "" {
+		log.Printf("Config drift resolved: nginx files match the database again")
+	} else {
+		log.Printf("Config drift detected in %d file(s): %s; see GET /api/v1/nginx/drift, fix with POST /api/v1/nginx/reconcile", len(entries), drift)
+	}
+	m.lastDrift = drift
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"upm-backend/internal/models"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	before := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	after := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\n"
+
+	want := "--- old\n+++ new\n" +
+		"@@ -1,5 +1,5 @@\n a\n-b\n+B\n c\n d\n e\n" +
+		"@@ -8,3 +8,4 @@\n h\n i\n j\n+k\n"
+	if got := unifiedDiff("old", "new", []byte(before), []byte(after)); got != want {
+		t.Errorf("unifiedDiff() =\n%s\nwant\n%s", got, want)
+	}
+	if got := unifiedDiff("old", "new", []byte(before), []byte(before)); got != "" {
+		t.Errorf("expected no diff for equal content, got\n%s", got)
+	}
+	if got := unifiedDiff("/dev/null", "new", nil, []byte("x\ny\n")); got != "--- /dev/null\n+++ new\n@@ -0,0 +1,2 @@\n+x\n+y\n" {
+		t.Errorf("unexpected diff for a new file:\n%s", got)
+	}
+}
+
+func TestDetectDrift_ReportsAndReconcilesDrift(t *testing.T) {
+	svc := newTestNginxService(t)
+	svc.DatabaseService = newTestDatabaseService(t)
+	svc.testStaged = func(dir string) error { return nil }
+
+	proxy := &models.Proxy{Name: "app", Domain: "app.example.com", TargetURL: "http://backend:8080", Status: "active"}
+	if err := svc.DatabaseService.CreateProxy(proxy); err != nil {
+		t.Fatalf("CreateProxy returned error: %v", err)
+	}
+	enabledFile := filepath.Join(svc.SitesEnabledPath, "proxy-1.conf")
+	orphanFile := filepath.Join(svc.SitesEnabledPath, "proxy-99.conf")
+
+	// Nothing rendered yet: the proxy's configs are missing
+	report, err := svc.DetectDrift()
+	if err != nil {
+		t.Fatalf("DetectDrift returned error: %v", err)
+	}
+	if report.InSync || findDrift(report, enabledFile) == nil || findDrift(report, enabledFile).Kind != models.DriftMissing {
+		t.Fatalf("expected %s to be reported missing, got %+v", enabledFile, report.Files)
+	}
+	if _, err := os.Stat(enabledFile); !os.IsNotExist(err) {
+		t.Errorf("expected drift detection to write nothing, stat err = %v", err)
+	}
+
+	if err := svc.Apply(nil, func(staged *NginxService) error { return staged.ReconcileConfig() }); err != nil {
+		t.Fatalf("reconcile returned error: %v", err)
+	}
+	if report, err := svc.DetectDrift(); err != nil || !report.InSync {
+		t.Fatalf("expected no drift after reconciling, got %+v (err %v)", report, err)
+	}
+
+	// A manual edit and a leftover config of a deleted proxy
+	content, err := os.ReadFile(enabledFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited := strings.Replace(string(content), "server_name app.example.com", "server_name edited.example.com", 1)
+	if err := os.WriteFile(enabledFile, []byte(edited), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(orphanFile, []byte("server {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err = svc.DetectDrift()
+	if err != nil {
+		t.Fatalf("DetectDrift returned error: %v", err)
+	}
+	modified := findDrift(report, enabledFile)
+	if modified == nil || modified.Kind != models.DriftModified || !strings.Contains(modified.Diff, "edited.example.com") {
+		t.Errorf("expected %s to be reported modified with a diff, got %+v", enabledFile, modified)
+	}
+	if orphan := findDrift(report, orphanFile); orphan == nil || orphan.Kind != models.DriftOrphaned {
+		t.Errorf("expected %s to be reported orphaned, got %+v", orphanFile, report.Files)
+	}
+	if drift := findDrift(report, filepath.Join(svc.ConfigPath, "proxy-template.conf")); drift != nil {
+		t.Errorf("expected templates not to be reported, got %+v", drift)
+	}
+
+	if err := svc.Apply(nil, func(staged *NginxService) error { return staged.ReconcileConfig() }); err != nil {
+		t.Fatalf("reconcile returned error: %v", err)
+	}
+	if _, err := os.Stat(orphanFile); !os.IsNotExist(err) {
+		t.Errorf("expected the orphaned config to be removed, stat err = %v", err)
+	}
+	if content, err := os.ReadFile(enabledFile); err != nil || strings.Contains(string(content), "edited.example.com") {
+		t.Errorf("expected the edited config to be regenerated, got err %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(svc.ConfigPath, "proxy-template.conf")); err != nil {
+		t.Errorf("expected the template to be kept: %v", err)
+	}
+}
+
+func TestDetectDrift_QuarantinedProxyIsExpectedOutOfSitesEnabled(t *testing.T) {
+	svc := newTestNginxService(t)
+	svc.DatabaseService = newTestDatabaseService(t)
+	svc.testStaged = func(dir string) error { return nil }
+
+	proxy := &models.Proxy{Name: "app", Domain: "app.example.com", TargetURL: "http://backend:8080", Status: "active"}
+	if err := svc.DatabaseService.CreateProxy(proxy); err != nil {
+		t.Fatalf("CreateProxy returned error: %v", err)
+	}
+	if err := svc.Apply(nil, func(staged *NginxService) error { return staged.ReconcileConfig() }); err != nil {
+		t.Fatalf("reconcile returned error: %v", err)
+	}
+	if err := svc.DatabaseService.QuarantineProxy(proxy.ID, "[emerg] broken"); err != nil {
+		t.Fatal(err)
+	}
+	enabledFile := filepath.Join(svc.SitesEnabledPath, "proxy-1.conf")
+	if err := os.Remove(enabledFile); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := svc.DetectDrift()
+	if err != nil {
+		t.Fatalf("DetectDrift returned error: %v", err)
+	}
+	if !report.InSync {
+		t.Errorf("expected a quarantined proxy's missing config not to be drift, got %+v", report.Files)
+	}
+}
+
+// findDrift returns the drift reported for path, or nil.
+func findDrift(report *models.DriftReport, path string) *models.ConfigDrift {
+	for i := range report.Files {
+		if report.Files[i].Path == path {
+			return &report.Files[i]
+		}
+	}
+	return nil
+}
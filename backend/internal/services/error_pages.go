@@ -0,0 +1,72 @@
+package services
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"upm-backend/internal/models"
+)
+
+// errorPagesDirName is the directory under sites-enabled holding the error
+// pages nginx serves, one subdirectory per proxy.
+const errorPagesDirName = "error-pages"
+
+// errorPagesTemplateData is the rendered form of a proxy's error pages.
+type errorPagesTemplateData struct {
+	Dir   string // directory holding <code>.html for every code
+	Codes []int
+}
+
+// proxyErrorPagesDir is the directory nginx serves a proxy's error pages
+// from.
+func (n *NginxService) proxyErrorPagesDir(proxyID int) string {
+	return filepath.Join(n.SitesEnabledPath, errorPagesDirName, fmt.Sprintf("proxy-%d", proxyID))
+}
+
+// writeErrorPages writes the error pages in effect for a proxy, its own and
+// the global ones, into its error pages directory and returns the template
+// data, or nil when the proxy has none. Codes in skip are left to other
+// error_page directives of the proxy.
+func (n *NginxService) writeErrorPages(proxy *models.Proxy, skip map[int]bool) (*errorPagesTemplateData, error) {
+	dir := n.proxyErrorPagesDir(proxy.ID)
+	if err := n.removeDir(dir); err != nil {
+		return nil, fmt.Errorf("failed to clear error pages: %w", err)
+	}
+	if proxy.IsPassthrough() {
+		return nil, nil
+	}
+
+	var global []models.ErrorPage
+	if n.DatabaseService != nil {
+		var err error
+		global, err = n.DatabaseService.GetErrorPages(0)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	data := &errorPagesTemplateData{Dir: dir}
+	for _, page := range models.MergeErrorPages(global, proxy.ErrorPages) {
+		if skip[page.StatusCode] {
+			continue
+		}
+		name := filepath.Join(dir, strconv.Itoa(page.StatusCode)+".html")
+		if err := n.writeFile(name, []byte(page.Content)); err != nil {
+			return nil, fmt.Errorf("failed to write error page %d: %w", page.StatusCode, err)
+		}
+		data.Codes = append(data.Codes, page.StatusCode)
+	}
+	if len(data.Codes) == 0 {
+		return nil, nil
+	}
+	return data, nil
+}
+
+// removeErrorPages deletes a proxy's error pages directory, if any.
+func (n *NginxService) removeErrorPages(proxyID int) error {
+	if err := n.removeDir(n.proxyErrorPagesDir(proxyID)); err != nil {
+		return fmt.Errorf("failed to remove error pages: %w", err)
+	}
+	return nil
+}
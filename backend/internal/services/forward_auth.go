@@ -0,0 +1,88 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"upm-backend/internal/models"
+)
+
+// ForwardAuthProbe is what a forward-auth service answered to a request
+// shaped like nginx's auth_request subrequest.
+type ForwardAuthProbe struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code"`
+	// Usable is true when nginx can act on the answer: auth_request only
+	// understands 2xx, 401 and 403 and turns anything else into a 500.
+	Usable     bool              `json:"usable"`
+	Authorized bool              `json:"authorized"`
+	Headers    map[string]string `json:"headers,omitempty"` // configured response headers present in the answer
+	Location   string            `json:"location,omitempty"`
+}
+
+// ProbeForwardAuth sends an unauthenticated request for originalURL to a
+// forward-auth service with the headers the proxy template sets, so the
+// service can be checked before (or after) pointing a proxy at it.
+// Credentials from the caller are never forwarded, so a healthy service is
+// expected to answer 401.
+func ProbeForwardAuth(fa *models.ForwardAuth, originalURL string) (*ForwardAuthProbe, error) {
+	if !fa.Enabled() {
+		return nil, fmt.Errorf("forward auth is not configured")
+	}
+
+	req, err := http.NewRequest(http.MethodGet, fa.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build forward auth request: %w", err)
+	}
+	if err := setForwardAuthHeaders(req, originalURL); err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+		// auth_request never follows redirects; report them as-is
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("forward auth service unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	probe := &ForwardAuthProbe{
+		URL:        fa.URL,
+		StatusCode: resp.StatusCode,
+		Authorized: resp.StatusCode >= 200 && resp.StatusCode < 300,
+		Location:   resp.Header.Get("Location"),
+	}
+	probe.Usable = probe.Authorized || resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden
+	for _, name := range fa.ResponseHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			if probe.Headers == nil {
+				probe.Headers = make(map[string]string)
+			}
+			probe.Headers[name] = value
+		}
+	}
+	return probe, nil
+}
+
+// setForwardAuthHeaders mirrors the X-Original-* and X-Forwarded-* headers
+// the forward_auth template block sends with the subrequest.
+func setForwardAuthHeaders(req *http.Request, originalURL string) error {
+	original, err := url.Parse(originalURL)
+	if err != nil || original.Host == "" {
+		return fmt.Errorf("invalid original URL: %s", originalURL)
+	}
+	req.Header.Set("X-Original-URL", originalURL)
+	req.Header.Set("X-Original-Method", http.MethodGet)
+	req.Header.Set("X-Forwarded-Method", http.MethodGet)
+	req.Header.Set("X-Forwarded-Proto", original.Scheme)
+	req.Header.Set("X-Forwarded-Host", original.Host)
+	req.Header.Set("X-Forwarded-Uri", original.RequestURI())
+	return nil
+}
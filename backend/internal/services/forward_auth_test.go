@@ -0,0 +1,99 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"upm-backend/internal/models"
+)
+
+// newStandInAuthServer mimics an Authelia-style verify endpoint: requests
+// carrying the session cookie are allowed and get identity headers back,
+// everything else is answered with 401.
+func newStandInAuthServer(t *testing.T, seen *http.Header) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if seen != nil {
+			*seen = r.Header.Clone()
+		}
+		if cookie, err := r.Cookie("authelia_session"); err == nil && cookie.Value == "valid" {
+			w.Header().Set("Remote-User", "alice")
+			w.Header().Set("Remote-Groups", "admins,dev")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestProbeForwardAuth_StandInServer(t *testing.T) {
+	var seen http.Header
+	srv := newStandInAuthServer(t, &seen)
+
+	fa := &models.ForwardAuth{
+		URL:             srv.URL + "/api/verify",
+		ResponseHeaders: []string{"Remote-User", "Remote-Groups"},
+	}
+	probe, err := ProbeForwardAuth(fa, "https://app.example.com/dashboard?tab=1")
+	if err != nil {
+		t.Fatalf("ProbeForwardAuth returned error: %v", err)
+	}
+	if probe.StatusCode != http.StatusUnauthorized || !probe.Usable || probe.Authorized {
+		t.Errorf("probe = %+v, want usable unauthorized 401", probe)
+	}
+
+	for header, want := range map[string]string{
+		"X-Original-URL":     "https://app.example.com/dashboard?tab=1",
+		"X-Forwarded-Proto":  "https",
+		"X-Forwarded-Host":   "app.example.com",
+		"X-Forwarded-Uri":    "/dashboard?tab=1",
+		"X-Forwarded-Method": "GET",
+	} {
+		if got := seen.Get(header); got != want {
+			t.Errorf("auth server saw %s = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestProbeForwardAuth_CopiesConfiguredHeaders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Remote-User", "alice")
+		w.Header().Set("Remote-Email", "alice@example.com")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	fa := &models.ForwardAuth{URL: srv.URL, ResponseHeaders: []string{"Remote-User", "Remote-Groups"}}
+	probe, err := ProbeForwardAuth(fa, "http://app.example.com/")
+	if err != nil {
+		t.Fatalf("ProbeForwardAuth returned error: %v", err)
+	}
+	if !probe.Authorized {
+		t.Errorf("expected 200 to be reported as authorized, got %+v", probe)
+	}
+	if len(probe.Headers) != 1 || probe.Headers["Remote-User"] != "alice" {
+		t.Errorf("expected only the configured Remote-User header, got %v", probe.Headers)
+	}
+}
+
+func TestProbeForwardAuth_UnusableStatus(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	probe, err := ProbeForwardAuth(&models.ForwardAuth{URL: srv.URL + "/wrong/path"}, "http://app.example.com/")
+	if err != nil {
+		t.Fatalf("ProbeForwardAuth returned error: %v", err)
+	}
+	if probe.Usable {
+		t.Errorf("expected 404 to be reported as unusable by auth_request, got %+v", probe)
+	}
+
+	srv.Close()
+	if _, err := ProbeForwardAuth(&models.ForwardAuth{URL: srv.URL}, "http://app.example.com/"); err == nil {
+		t.Errorf("expected error for unreachable auth service")
+	}
+}
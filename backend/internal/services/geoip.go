@@ -0,0 +1,301 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"upm-backend/internal/models"
+)
+
+// GeoIPService answers country lookups from a local MaxMind DB file, such
+// as GeoLite2-Country or DB-IP's country lite database. The file is
+// reopened when it changes on disk, so it can be kept current with
+// geoipupdate without restarting the backend.
+type GeoIPService struct {
+	Path string
+
+	mu        sync.Mutex
+	reader    *mmdbReader
+	modTime   time.Time
+	size      int64
+	countries map[uint]geoIPCountry // decoded country of each data record
+	networks  map[string][]string   // CIDRs of each country walked so far
+}
+
+// errGeoIPDisabled is returned by lookups when no database is configured.
+var errGeoIPDisabled = fmt.Errorf("no GeoIP database configured; set GEOIP_DB_PATH")
+
+// geoIPCountry is the country of a database record.
+type geoIPCountry struct {
+	Code string
+	Name string
+}
+
+// NewGeoIPService creates a GeoIP service reading the database at path;
+// an empty path disables country rules.
+func NewGeoIPService(path string) *GeoIPService {
+	return &GeoIPService{Path: path}
+}
+
+// Enabled reports whether a database is configured.
+func (g *GeoIPService) Enabled() bool {
+	return g != nil && g.Path != ""
+}
+
+// open returns a reader of the current database file. Callers hold g.mu.
+func (g *GeoIPService) open() (*mmdbReader, error) {
+	info, err := os.Stat(g.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GeoIP database: %w", err)
+	}
+	if g.reader != nil && info.ModTime().Equal(g.modTime) && info.Size() == g.size {
+		return g.reader, nil
+	}
+
+	reader, err := openMMDB(g.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GeoIP database %s: %w", g.Path, err)
+	}
+	g.reader = reader
+	g.modTime = info.ModTime()
+	g.size = info.Size()
+	g.countries = make(map[uint]geoIPCountry)
+	g.networks = make(map[string][]string)
+	return reader, nil
+}
+
+// country decodes the country of the data record at offset. The country
+// of the address's location wins over the country it is registered in.
+func (g *GeoIPService) country(reader *mmdbReader, offset uint) (geoIPCountry, error) {
+	if c, ok := g.countries[offset]; ok {
+		return c, nil
+	}
+	value, _, err := reader.data.decode(offset, 0)
+	if err != nil {
+		return geoIPCountry{}, fmt.Errorf("failed to decode GeoIP record: %w", err)
+	}
+
+	var c geoIPCountry
+	record, _ := value.(map[string]interface{})
+	for _, key := range []string{"country", "registered_country"} {
+		fields, _ := record[key].(map[string]interface{})
+		if code, _ := fields["iso_code"].(string); code != "" {
+			names, _ := fields["names"].(map[string]interface{})
+			name, _ := names["en"].(string)
+			c = geoIPCountry{Code: strings.ToUpper(code), Name: name}
+			break
+		}
+	}
+	g.countries[offset] = c
+	return c, nil
+}
+
+// Lookup returns the country an address maps to.
+func (g *GeoIPService) Lookup(ip net.IP) (*models.GeoIPLookup, error) {
+	if !g.Enabled() {
+		return nil, errGeoIPDisabled
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	reader, err := g.open()
+	if err != nil {
+		return nil, err
+	}
+	offset, prefixLen, found, err := reader.lookup(ip)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up %s: %w", ip, err)
+	}
+
+	result := &models.GeoIPLookup{
+		IP:        ip.String(),
+		Database:  reader.meta.DatabaseType,
+		BuildDate: time.Unix(int64(reader.meta.BuildEpoch), 0).UTC(),
+	}
+	if !found {
+		return result, nil
+	}
+	c, err := g.country(reader, offset)
+	if err != nil {
+		return nil, err
+	}
+	bits := 8 * net.IPv6len
+	if ip.To4() != nil {
+		bits = 8 * net.IPv4len
+	}
+	mask := net.CIDRMask(prefixLen, bits)
+	result.Network = (&net.IPNet{IP: ip.Mask(mask), Mask: mask}).String()
+	result.Country = c.Code
+	result.CountryName = c.Name
+	return result, nil
+}
+
+// CountryNetworks returns the CIDRs the database maps to each of the
+// countries. Countries not walked before are collected in a single walk of
+// the database.
+func (g *GeoIPService) CountryNetworks(countries []string) (map[string][]string, error) {
+	if !g.Enabled() {
+		return nil, errGeoIPDisabled
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	reader, err := g.open()
+	if err != nil {
+		return nil, err
+	}
+
+	missing := make(map[string]bool)
+	for _, code := range countries {
+		if _, ok := g.networks[code]; !ok {
+			missing[code] = true
+		}
+	}
+	if len(missing) > 0 {
+		found := make(map[string][]string)
+		err := reader.networks(func(network *net.IPNet, offset uint) error {
+			c, err := g.country(reader, offset)
+			if err != nil {
+				return err
+			}
+			if missing[c.Code] {
+				found[c.Code] = append(found[c.Code], network.String())
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read GeoIP networks: %w", err)
+		}
+		for code := range missing {
+			g.networks[code] = found[code]
+		}
+	}
+
+	result := make(map[string][]string, len(countries))
+	for _, code := range countries {
+		result[code] = g.networks[code]
+	}
+	return result, nil
+}
+
+// geoIPConfigName is the shared http-level include mapping client
+// addresses to countries and countries to the blocked flag of each proxy
+// with a country rule. The networks come from the GeoIP database, so nginx
+// needs no GeoIP module.
+const geoIPConfigName = "geoip-countries.conf"
+
+// geoIPCountryVariable holds the country of the client address, or "--"
+// when it is in none of the countries any rule uses.
+const geoIPCountryVariable = "$upm_geoip_country"
+
+// geoIPTemplateData is the rendered country rule of a proxy.
+type geoIPTemplateData struct {
+	Blocked string // variable that is 1 for requests the rule rejects
+}
+
+// geoIPBlockedVariable is the variable mapping countries to whether a
+// proxy rejects them.
+func geoIPBlockedVariable(proxyID int) string {
+	return fmt.Sprintf("$upm_geoip_blocked_%d", proxyID)
+}
+
+// buildGeoIPTemplateData returns the country rule of a proxy, or nil when
+// every country is let in.
+func buildGeoIPTemplateData(proxy *models.Proxy) *geoIPTemplateData {
+	if !proxy.GeoIP.Enabled() || proxy.IsPassthrough() {
+		return nil
+	}
+	return &geoIPTemplateData{Blocked: geoIPBlockedVariable(proxy.ID)}
+}
+
+// GenerateGeoIPConfig writes the shared geoip-countries.conf include for
+// the country rules of all proxies, or removes it when no proxy has one.
+// Only the networks of countries some rule names are written. When the
+// database cannot be read no address maps to a country, so allow rules
+// reject everything and deny rules let everything in until it can.
+func (n *NginxService) GenerateGeoIPConfig(proxies []models.Proxy) error {
+	var rules []*models.Proxy
+	used := make(map[string]bool)
+	var countries []string
+	for i := range proxies {
+		p := &proxies[i]
+		if buildGeoIPTemplateData(p) == nil {
+			continue
+		}
+		rules = append(rules, p)
+		for _, code := range p.GeoIP.Countries {
+			if !used[code] {
+				used[code] = true
+				countries = append(countries, code)
+			}
+		}
+	}
+
+	configFile := filepath.Join(n.ConfigPath, geoIPConfigName)
+	enabledPath := filepath.Join(n.SitesEnabledPath, geoIPConfigName)
+	if len(rules) == 0 {
+		for _, path := range []string{enabledPath, configFile} {
+			if err := n.removeFile(path); err != nil {
+				return fmt.Errorf("failed to remove GeoIP config: %w", err)
+			}
+		}
+		return nil
+	}
+
+	sort.Strings(countries)
+	networks, err := n.GeoIP.CountryNetworks(countries)
+	if err != nil {
+		log.Printf("Warning: GeoIP country rules match no addresses: %v", err)
+	}
+
+	var buf strings.Builder
+	buf.WriteString("# GeoIP country rules\n# This file is generated by the backend from the GeoIP database; the countries of every proxy country rule.\n\n")
+	fmt.Fprintf(&buf, "geo %s {\n    default --;\n", geoIPCountryVariable)
+	for _, code := range countries {
+		for _, cidr := range networks[code] {
+			fmt.Fprintf(&buf, "    %s %s;\n", cidr, code)
+		}
+	}
+	buf.WriteString("}\n")
+
+	for _, p := range rules {
+		allowed := p.GeoIP.Action == models.AccessActionAllow
+		blocked, listed := 0, 1
+		if allowed {
+			blocked, listed = 1, 0
+		}
+		fmt.Fprintf(&buf, "\n# %s\nmap %s %s {\n    default %d;\n", p.Domain, geoIPCountryVariable, geoIPBlockedVariable(p.ID), blocked)
+		for _, code := range p.GeoIP.Countries {
+			fmt.Fprintf(&buf, "    %s %d;\n", code, listed)
+		}
+		buf.WriteString("}\n")
+	}
+
+	content := []byte(buf.String())
+	if err := n.writeFile(configFile, content); err != nil {
+		return fmt.Errorf("failed to write GeoIP config file: %w", err)
+	}
+	if err := n.writeFile(enabledPath, content); err != nil {
+		return fmt.Errorf("failed to copy GeoIP config to sites-enabled: %w", err)
+	}
+	return nil
+}
+
+// syncGeoIPConfig regenerates the GeoIP include from the database.
+func (n *NginxService) syncGeoIPConfig() error {
+	if n.DatabaseService == nil {
+		return nil
+	}
+	proxies, err := n.DatabaseService.GetProxies()
+	if err != nil {
+		return fmt.Errorf("failed to load proxies: %w", err)
+	}
+	return n.GenerateGeoIPConfig(proxies)
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"upm-backend/internal/models"
+)
+
+func TestGeoIPService_Lookup(t *testing.T) {
+	path := newTestCountryDB(t).write()
+	geoip := NewGeoIPService(path)
+
+	result, err := geoip.Lookup(net.ParseIP("1.2.3.4"))
+	if err != nil {
+		t.Fatalf("Lookup returned error: %v", err)
+	}
+	if result.Country != "AU" || result.CountryName != "Australia" || result.Network != "1.2.3.0/24" || result.Database != "UPM-Test-Country" {
+		t.Errorf("unexpected lookup result: %+v", result)
+	}
+
+	result, err = geoip.Lookup(net.ParseIP("8.8.8.8"))
+	if err != nil {
+		t.Fatalf("Lookup returned error: %v", err)
+	}
+	if result.Country != "" || result.Network != "" {
+		t.Errorf("expected no country for an unknown address, got %+v", result)
+	}
+
+	// An updated database is picked up without a restart
+	db := newTestMMDB(t, 6)
+	db.insert("1.2.3.0/24", "NZ")
+	if err := os.WriteFile(path, db.bytes(), 0644); err != nil {
+		t.Fatalf("failed to update test database: %v", err)
+	}
+	result, err = geoip.Lookup(net.ParseIP("1.2.3.4"))
+	if err != nil {
+		t.Fatalf("Lookup returned error: %v", err)
+	}
+	if result.Country != "NZ" {
+		t.Errorf("expected the updated database to be used, got %+v", result)
+	}
+
+	if _, err := NewGeoIPService("").Lookup(net.ParseIP("1.2.3.4")); err == nil {
+		t.Errorf("expected lookups without a database to fail")
+	}
+}
+
+func TestGenerateGeoIPConfig_RendersCountryRules(t *testing.T) {
+	svc := newTestNginxService(t)
+	svc.GeoIP = NewGeoIPService(newTestCountryDB(t).write())
+
+	proxies := []models.Proxy{
+		{ID: 5, Name: "shop", Domain: "shop.example.com", TargetURL: "http://shop:80", Status: "active",
+			GeoIP: &models.GeoIPRule{Action: models.AccessActionAllow, Countries: []string{"AU"}}},
+		{ID: 6, Name: "blog", Domain: "blog.example.com", TargetURL: "http://blog:80", Status: "active",
+			GeoIP: &models.GeoIPRule{Action: models.AccessActionDeny, Countries: []string{"DE"}}},
+		{ID: 7, Name: "wiki", Domain: "wiki.example.com", TargetURL: "http://wiki:80", Status: "active"},
+	}
+	if err := svc.GenerateGeoIPConfig(proxies); err != nil {
+		t.Fatalf("GenerateGeoIPConfig returned error: %v", err)
+	}
+
+	includePath := filepath.Join(svc.SitesEnabledPath, geoIPConfigName)
+	content, err := os.ReadFile(includePath)
+	if err != nil {
+		t.Fatalf("expected GeoIP include: %v", err)
+	}
+	include := string(content)
+	for _, want := range []string{
+		"geo $upm_geoip_country {\n    default --;\n",
+		"    1.2.3.0/24 AU;\n",
+		"    5.6.0.0/16 DE;\n",
+		"    2001:db8::/32 DE;\n",
+		"map $upm_geoip_country $upm_geoip_blocked_5 {\n    default 1;\n    AU 0;\n}",
+		"map $upm_geoip_country $upm_geoip_blocked_6 {\n    default 0;\n    DE 1;\n}",
+	} {
+		if !strings.Contains(include, want) {
+			t.Errorf("expected %q in GeoIP include, got:\n%s", want, include)
+		}
+	}
+	if strings.Contains(include, "wiki.example.com") {
+		t.Errorf("expected proxies without a rule to be left out, got:\n%s", include)
+	}
+
+	if err := svc.GenerateProxyConfig(&proxies[0]); err != nil {
+		t.Fatalf("GenerateProxyConfig returned error: %v", err)
+	}
+	config, err := os.ReadFile(filepath.Join(svc.SitesEnabledPath, "proxy-5.conf"))
+	if err != nil {
+		t.Fatalf("expected proxy config: %v", err)
+	}
+	if !strings.Contains(string(config), "set $upm_geoip_blocked $upm_geoip_blocked_5;") {
+		t.Errorf("expected the proxy to check its country rule, got:\n%s", config)
+	}
+
+	// Without a readable database allow rules reject every address
+	svc.GeoIP = nil
+	if err := svc.GenerateGeoIPConfig(proxies); err != nil {
+		t.Fatalf("GenerateGeoIPConfig returned error: %v", err)
+	}
+	content, err = os.ReadFile(includePath)
+	if err != nil {
+		t.Fatalf("expected GeoIP include: %v", err)
+	}
+	if strings.Contains(string(content), " AU;") || !strings.Contains(string(content), "default 1;") {
+		t.Errorf("expected an include without networks, got:\n%s", content)
+	}
+
+	if err := svc.GenerateGeoIPConfig(proxies[2:]); err != nil {
+		t.Fatalf("GenerateGeoIPConfig returned error: %v", err)
+	}
+	if _, err := os.Stat(includePath); !os.IsNotExist(err) {
+		t.Errorf("expected GeoIP include to be removed when no proxy has a rule")
+	}
+}
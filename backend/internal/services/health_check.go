@@ -0,0 +1,307 @@
+package services
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"upm-backend/internal/models"
+)
+
+// healthHistoryLimit is the number of probe results kept per proxy.
+const healthHistoryLimit = 100
+
+// HealthCheckService periodically probes the target of every active proxy
+// host, records the results and moves proxies between "active" and "error"
+// when their target stops or resumes answering.
+type HealthCheckService struct {
+	db       *DatabaseService
+	tick     time.Duration // how often due checks are looked for
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+	running  bool
+	mu       sync.Mutex
+
+	stateMu  sync.Mutex
+	lastRun  map[int]time.Time // proxy ID -> start of its last probe
+	inFlight map[int]bool
+}
+
+// NewHealthCheckService creates a health check scheduler. Each proxy is
+// probed at its own interval; tick only bounds how late a probe can start.
+func NewHealthCheckService(db *DatabaseService, tick time.Duration) *HealthCheckService {
+	return &HealthCheckService{
+		db:       db,
+		tick:     tick,
+		stopChan: make(chan struct{}),
+		lastRun:  make(map[int]time.Time),
+		inFlight: make(map[int]bool),
+	}
+}
+
+// Start begins the background health check scheduler.
+func (s *HealthCheckService) Start() {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.stopChan = make(chan struct{})
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run()
+	log.Printf("Health check scheduler started (tick: %v)", s.tick)
+}
+
+// Stop shuts down the scheduler and waits for running probes to finish.
+func (s *HealthCheckService) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	close(s.stopChan)
+	s.mu.Unlock()
+	s.wg.Wait()
+	log.Printf("Health check scheduler stopped")
+}
+
+func (s *HealthCheckService) run() {
+	defer s.wg.Done()
+
+	s.runDueChecks()
+
+	ticker := time.NewTicker(s.tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.runDueChecks()
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// runDueChecks starts a probe for every proxy whose interval has elapsed.
+func (s *HealthCheckService) runDueChecks() {
+	proxies, err := s.db.GetProxies()
+	if err != nil {
+		log.Printf("Health check: failed to load proxies: %v", err)
+		return
+	}
+
+	now := time.Now()
+	existing := make(map[int]bool, len(proxies))
+
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	for i := range proxies {
+		proxy := proxies[i]
+		existing[proxy.ID] = true
+
+		// Redirect and static hosts have no target to probe
+		check := proxy.EffectiveHealthCheck()
+		if proxy.Status == "inactive" || proxy.HostType() != models.ProxyTypeProxy || !check.Enabled || s.inFlight[proxy.ID] {
+			continue
+		}
+		interval := time.Duration(check.IntervalSeconds) * time.Second
+		if last, ok := s.lastRun[proxy.ID]; ok && now.Sub(last) < interval {
+			continue
+		}
+
+		s.lastRun[proxy.ID] = now
+		s.inFlight[proxy.ID] = true
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			if _, err := s.CheckProxy(&proxy); err != nil {
+				log.Printf("Health check for proxy %d (%s) failed: %v", proxy.ID, proxy.Domain, err)
+			}
+			s.stateMu.Lock()
+			delete(s.inFlight, proxy.ID)
+			s.stateMu.Unlock()
+		}()
+	}
+
+	// Forget deleted proxies
+	for id := range s.lastRun {
+		if !existing[id] {
+			delete(s.lastRun, id)
+		}
+	}
+}
+
+// CheckProxy probes a proxy's target once, records the result and updates
+// the proxy's status when the outcome crosses the unhealthy threshold. The
+// status of a quarantined proxy stays error until its config is fixed, and
+// a status changed while the probe ran is left alone.
+func (s *HealthCheckService) CheckProxy(proxy *models.Proxy) (*models.HealthCheckResult, error) {
+	check := proxy.EffectiveHealthCheck()
+	result := probeProxyTarget(proxy, check)
+
+	if err := s.db.AddHealthCheckResult(&result, healthHistoryLimit); err != nil {
+		return nil, err
+	}
+
+	history, err := s.db.GetHealthCheckResults(proxy.ID, check.UnhealthyThreshold)
+	if err != nil {
+		return nil, err
+	}
+	status := nextProxyStatus(proxy.Status, history, check.UnhealthyThreshold)
+	if status != proxy.Status && !proxy.Quarantined {
+		updated, err := s.db.UpdateProxyStatus(proxy.ID, proxy.Status, status)
+		if err != nil {
+			return nil, err
+		}
+		if updated {
+			log.Printf("Proxy %d (%s) is now %s", proxy.ID, proxy.Domain, status)
+			proxy.Status = status
+		}
+	}
+	return &result, nil
+}
+
+// probeProxyTarget sends one probe to a proxy's target. HTTP proxies get a
+// GET for the check path with the proxy's domain as Host, like nginx sends;
+// passthrough proxies only get a TCP connect, since the backend speaks TLS
+// for names UPM holds no certificate for.
+func probeProxyTarget(proxy *models.Proxy, check models.HealthCheck) models.HealthCheckResult {
+	result := models.HealthCheckResult{ProxyID: proxy.ID, CheckedAt: time.Now()}
+	timeout := time.Duration(check.TimeoutSeconds) * time.Second
+
+	target, err := url.Parse(proxy.TargetURL)
+	if err != nil || target.Host == "" {
+		result.Error = fmt.Sprintf("invalid target URL: %s", proxy.TargetURL)
+		return result
+	}
+
+	start := time.Now()
+	if proxy.IsPassthrough() {
+		address := target.Host
+		if target.Port() == "" {
+			address = net.JoinHostPort(target.Hostname(), "443")
+		}
+		conn, err := net.DialTimeout("tcp", address, timeout)
+		result.LatencyMS = time.Since(start).Milliseconds()
+		if err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		conn.Close()
+		result.Healthy = true
+		return result
+	}
+
+	target.RawQuery = ""
+	target.Fragment = ""
+	probeURL := strings.TrimSuffix(target.String(), "/") + check.Path
+
+	req, err := http.NewRequest(http.MethodGet, probeURL, nil)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to build probe request: %v", err)
+		return result
+	}
+	req.Host = proxy.Domain
+	req.Header.Set("User-Agent", "UPM-HealthCheck/1.0")
+
+	client := &http.Client{
+		Timeout: timeout,
+		// nginx doesn't verify upstream certificates by default either
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Do(req)
+	result.LatencyMS = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	resp.Body.Close()
+
+	result.StatusCode = resp.StatusCode
+	if check.ExpectedStatus != 0 {
+		result.Healthy = resp.StatusCode == check.ExpectedStatus
+	} else {
+		result.Healthy = resp.StatusCode < 500
+	}
+	if !result.Healthy {
+		result.Error = fmt.Sprintf("unexpected status %d", resp.StatusCode)
+	}
+	return result
+}
+
+// nextProxyStatus returns the status a proxy should have given its most
+// recent results, newest first. One healthy probe recovers a proxy in
+// "error"; threshold failed probes in a row put it there.
+func nextProxyStatus(current string, history []models.HealthCheckResult, threshold int) string {
+	if len(history) == 0 || current == "inactive" {
+		return current
+	}
+	if history[0].Healthy {
+		if current == "error" {
+			return "active"
+		}
+		return current
+	}
+	if len(history) < threshold {
+		return current
+	}
+	for _, r := range history[:threshold] {
+		if r.Healthy {
+			return current
+		}
+	}
+	return "error"
+}
+
+// SummarizeProxyHealth builds the health summary of a proxy from its recent
+// results, newest first.
+func SummarizeProxyHealth(proxy *models.Proxy, history []models.HealthCheckResult) *models.ProxyHealth {
+	health := &models.ProxyHealth{
+		ProxyID: proxy.ID,
+		Status:  proxy.Status,
+		Check:   proxy.EffectiveHealthCheck(),
+		History: history,
+	}
+	if health.History == nil {
+		health.History = []models.HealthCheckResult{}
+	}
+	if len(history) == 0 {
+		return health
+	}
+
+	health.LastCheckedAt = &history[0].CheckedAt
+	for _, r := range history {
+		if r.Healthy {
+			break
+		}
+		health.ConsecutiveFailures++
+	}
+
+	healthy := 0
+	var latency int64
+	for _, r := range history {
+		if r.Healthy {
+			healthy++
+			latency += r.LatencyMS
+		}
+	}
+	health.UptimePercent = float64(healthy) * 100 / float64(len(history))
+	if healthy > 0 {
+		health.AvgLatencyMS = latency / int64(healthy)
+	}
+	return health
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"upm-backend/internal/models"
+)
+
+func TestProbeProxyTarget_HTTP(t *testing.T) {
+	var gotHost, gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHost, gotPath = r.Host, r.URL.Path
+		switch r.URL.Path {
+		case "/base/healthz":
+			w.WriteHeader(http.StatusOK)
+		case "/base/login":
+			http.Redirect(w, r, "/base/", http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	proxy := &models.Proxy{ID: 1, Domain: "app.example.com", TargetURL: server.URL + "/base/"}
+	check := models.HealthCheck{Enabled: true, Path: "/healthz", TimeoutSeconds: 2}
+
+	result := probeProxyTarget(proxy, check)
+	if !result.Healthy || result.StatusCode != http.StatusOK {
+		t.Errorf("probe = %+v, want healthy 200", result)
+	}
+	if gotHost != "app.example.com" || gotPath != "/base/healthz" {
+		t.Errorf("probe sent Host %q path %q, want app.example.com /base/healthz", gotHost, gotPath)
+	}
+
+	// Redirects are not followed; any status below 500 is healthy by default
+	check.Path = "/login"
+	if result := probeProxyTarget(proxy, check); !result.Healthy || result.StatusCode != http.StatusFound {
+		t.Errorf("probe of redirect = %+v, want healthy 302", result)
+	}
+	check.ExpectedStatus = http.StatusOK
+	if result := probeProxyTarget(proxy, check); result.Healthy || result.Error == "" {
+		t.Errorf("probe with expected_status 200 = %+v, want unhealthy", result)
+	}
+
+	check.Path = "/broken"
+	check.ExpectedStatus = 0
+	if result := probeProxyTarget(proxy, check); result.Healthy || result.StatusCode != http.StatusBadGateway {
+		t.Errorf("probe of 502 = %+v, want unhealthy 502", result)
+	}
+}
+
+func TestProbeProxyTarget_Unreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	proxy := &models.Proxy{ID: 2, Domain: "down.example.com", TargetURL: "http://" + addr}
+	result := probeProxyTarget(proxy, models.HealthCheck{Path: "/", TimeoutSeconds: 1})
+	if result.Healthy || result.Error == "" {
+		t.Errorf("probe of closed port = %+v, want unhealthy with error", result)
+	}
+}
+
+func TestProbeProxyTarget_PassthroughUsesTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	proxy := &models.Proxy{ID: 3, Domain: "tls.example.com", TargetURL: "https://" + listener.Addr().String(), SSLMode: models.SSLModePassthrough}
+	result := probeProxyTarget(proxy, models.HealthCheck{Path: "/", TimeoutSeconds: 1})
+	if !result.Healthy || result.StatusCode != 0 {
+		t.Errorf("passthrough probe = %+v, want healthy without status code", result)
+	}
+}
+
+func TestNextProxyStatus(t *testing.T) {
+	up := models.HealthCheckResult{Healthy: true}
+	down := models.HealthCheckResult{Healthy: false}
+
+	cases := []struct {
+		name    string
+		current string
+		history []models.HealthCheckResult
+		want    string
+	}{
+		{"no history", "active", nil, "active"},
+		{"healthy", "active", []models.HealthCheckResult{up, down, down}, "active"},
+		{"below threshold", "active", []models.HealthCheckResult{down, down, up}, "active"},
+		{"too few results", "active", []models.HealthCheckResult{down, down}, "active"},
+		{"threshold reached", "active", []models.HealthCheckResult{down, down, down}, "error"},
+		{"stays failed", "error", []models.HealthCheckResult{down, down, down}, "error"},
+		{"recovers", "error", []models.HealthCheckResult{up, down, down}, "active"},
+		{"inactive untouched", "inactive", []models.HealthCheckResult{down, down, down}, "inactive"},
+	}
+	for _, tc := range cases {
+		if got := nextProxyStatus(tc.current, tc.history, 3); got != tc.want {
+			t.Errorf("%s: nextProxyStatus = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCheckProxy_KeepsConcurrentStatusChanges(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	db := newTestDatabaseService(t)
+	svc := NewHealthCheckService(db, time.Minute)
+	proxy := &models.Proxy{Name: "app", Domain: "app.example.com", TargetURL: server.URL, Status: "error"}
+	if err := db.CreateProxy(proxy); err != nil {
+		t.Fatal(err)
+	}
+
+	// The proxy is quarantined while its probe runs: it must stay error
+	stale := *proxy
+	if err := db.QuarantineProxy(proxy.ID, "broken"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.CheckProxy(&stale); err != nil {
+		t.Fatalf("CheckProxy returned error: %v", err)
+	}
+	stored, err := db.GetProxy(proxy.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != "error" || stale.Status != "error" {
+		t.Errorf("expected a quarantined proxy to stay error, got %q (snapshot %q)", stored.Status, stale.Status)
+	}
+
+	// The proxy is released and then disabled while its probe runs: the
+	// probe must not turn it back on
+	if err := db.ClearProxyQuarantine(proxy.ID); err != nil {
+		t.Fatal(err)
+	}
+	if updated, err := db.UpdateProxyStatus(proxy.ID, "active", "inactive"); err != nil || !updated {
+		t.Fatalf("UpdateProxyStatus = %v, %v, want true", updated, err)
+	}
+	if _, err := svc.CheckProxy(&stale); err != nil {
+		t.Fatalf("CheckProxy returned error: %v", err)
+	}
+	if stored, _ := db.GetProxy(proxy.ID); stored.Status != "inactive" {
+		t.Errorf("expected the disabled proxy to stay inactive, got %q", stored.Status)
+	}
+}
+
+func TestSummarizeProxyHealth(t *testing.T) {
+	now := time.Now()
+	proxy := &models.Proxy{ID: 4, Status: "active"}
+	history := []models.HealthCheckResult{
+		{Healthy: false, CheckedAt: now},
+		{Healthy: false, CheckedAt: now.Add(-30 * time.Second)},
+		{Healthy: true, LatencyMS: 20, CheckedAt: now.Add(-60 * time.Second)},
+		{Healthy: true, LatencyMS: 40, CheckedAt: now.Add(-90 * time.Second)},
+	}
+
+	health := SummarizeProxyHealth(proxy, history)
+	if health.ConsecutiveFailures != 2 {
+		t.Errorf("ConsecutiveFailures = %d, want 2", health.ConsecutiveFailures)
+	}
+	if health.UptimePercent != 50 {
+		t.Errorf("UptimePercent = %v, want 50", health.UptimePercent)
+	}
+	if health.AvgLatencyMS != 30 {
+		t.Errorf("AvgLatencyMS = %d, want 30", health.AvgLatencyMS)
+	}
+	if health.LastCheckedAt == nil || !health.LastCheckedAt.Equal(now) {
+		t.Errorf("LastCheckedAt = %v, want %v", health.LastCheckedAt, now)
+	}
+	if health.Check.Path != models.DefaultHealthCheckPath || !health.Check.Enabled {
+		t.Errorf("Check = %+v, want enabled defaults", health.Check)
+	}
+
+	empty := SummarizeProxyHealth(proxy, nil)
+	if empty.History == nil || empty.LastCheckedAt != nil {
+		t.Errorf("summary without history = %+v, want empty history", empty)
+	}
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"path"
+	"strings"
+
+	"upm-backend/internal/models"
+)
+
+// redirectTemplateData is the rendered form of a redirect host.
+type redirectTemplateData struct {
+	StatusCode    int
+	Destination   string // without a trailing slash when the path is appended
+	PreservePath  bool
+	PreserveQuery bool
+}
+
+// staticTemplateData is the rendered form of a static host.
+type staticTemplateData struct {
+	Root        string // absolute directory in the nginx container
+	Index       string
+	SPAFallback bool
+}
+
+// buildRedirectTemplateData returns the template data of a redirect host, or
+// nil for other host types. The settings are assumed to have passed
+// models.ValidateHostType.
+func buildRedirectTemplateData(proxy *models.Proxy) *redirectTemplateData {
+	if proxy.HostType() != models.ProxyTypeRedirect || proxy.Redirect == nil {
+		return nil
+	}
+	data := &redirectTemplateData{
+		StatusCode:    proxy.Redirect.StatusCode,
+		Destination:   proxy.TargetURL,
+		PreservePath:  proxy.Redirect.PreservePath,
+		PreserveQuery: proxy.Redirect.PreserveQuery,
+	}
+	if data.StatusCode == 0 {
+		data.StatusCode = models.DefaultRedirectStatusCode
+	}
+	if data.PreservePath {
+		// The request path starts with a slash of its own
+		data.Destination = strings.TrimRight(data.Destination, "/")
+	}
+	return data
+}
+
+// buildStaticTemplateData returns the template data of a static host, or nil
+// for other host types.
+func (n *NginxService) buildStaticTemplateData(proxy *models.Proxy) *staticTemplateData {
+	if proxy.HostType() != models.ProxyTypeStatic || proxy.Static == nil {
+		return nil
+	}
+	index := proxy.Static.Index
+	if index == "" {
+		index = models.DefaultStaticIndex
+	}
+	return &staticTemplateData{
+		Root:        path.Join(n.StaticRootPath, proxy.Static.Root),
+		Index:       index,
+		SPAFallback: proxy.Static.SPAFallback,
+	}
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"fmt"
+
+	"upm-backend/internal/models"
+)
+
+// http3TemplateData is the QUIC listener of a proxy's HTTPS server.
+type http3TemplateData struct {
+	// Reuseport is set on exactly one proxy: nginx opens one UDP socket
+	// per worker for 443/quic and only accepts the option on one listen
+	// of the address.
+	Reuseport bool
+}
+
+// servesHTTP3 reports whether a proxy's HTTPS server gets a QUIC listener
+// when its config is rendered with sslEnabled.
+func (n *NginxService) servesHTTP3(proxy *models.Proxy, sslEnabled bool) bool {
+	return n.HTTP3Enabled && proxy.HTTP3Enabled && sslEnabled && !proxy.IsPassthrough()
+}
+
+// buildHTTP3TemplateData returns the QUIC listener of a proxy, or nil when
+// it does not serve HTTP/3. The proxy takes reuseport when it already owns
+// it or when no proxy that still serves HTTP/3 does; it only records the
+// claim once its config is written.
+func (n *NginxService) buildHTTP3TemplateData(proxy *models.Proxy, sslEnabled bool) (*http3TemplateData, error) {
+	if !n.servesHTTP3(proxy, sslEnabled) {
+		return nil, nil
+	}
+	owner, err := n.http3ReuseportOwner()
+	if err != nil {
+		return nil, err
+	}
+	return &http3TemplateData{Reuseport: owner == 0 || owner == proxy.ID}, nil
+}
+
+// altSvcHeader advertises the QUIC listener to clients that connected over
+// TCP. The upstream's own Alt-Svc is hidden, as it names the upstream's
+// ports rather than nginx's.
+func (n *NginxService) altSvcHeader() headerDirective {
+	port := n.HTTP3AltSvcPort
+	if port == "" {
+		port = "443"
+	}
+	return headerDirective{Name: "Alt-Svc", Value: quoteNginxValue(fmt.Sprintf(`h3=":%s"; ma=86400`, port)), Always: true, Hide: true}
+}
+
+// http3ReuseportOwner returns the proxy that owns reuseport, or 0 when none
+// is recorded or the recorded one no longer serves HTTP/3, for example
+// because it was quarantined. Without a database every proxy owns it.
+func (n *NginxService) http3ReuseportOwner() (int, error) {
+	if n.DatabaseService == nil || !n.HTTP3Enabled {
+		return 0, nil
+	}
+	owner, err := n.DatabaseService.GetHTTP3ReuseportOwner()
+	if err != nil || owner == 0 {
+		return 0, err
+	}
+	ids, err := n.DatabaseService.GetHTTP3ProxyIDs()
+	if err != nil {
+		return 0, err
+	}
+	for _, id := range ids {
+		if id == owner {
+			return owner, nil
+		}
+	}
+	return 0, nil
+}
+
+// syncHTTP3Reuseport records the reuseport owner after a proxy's config is
+// written. A proxy that took reuseport claims it; an owner that no longer
+// serves HTTP/3 hands it to the HTTP/3 proxy with the lowest ID, whose
+// config is regenerated to carry it.
+func (n *NginxService) syncHTTP3Reuseport(proxyID int, http3 *http3TemplateData) error {
+	if n.DatabaseService == nil {
+		return nil
+	}
+	owner, err := n.DatabaseService.GetHTTP3ReuseportOwner()
+	if err != nil {
+		return err
+	}
+	if http3 != nil {
+		if !http3.Reuseport || owner == proxyID {
+			return nil
+		}
+		return n.DatabaseService.SetHTTP3ReuseportOwner(proxyID)
+	}
+	if owner != proxyID {
+		return nil
+	}
+	return n.handOverHTTP3Reuseport(proxyID)
+}
+
+// handOverHTTP3Reuseport releases reuseport from a proxy that no longer
+// serves HTTP/3 or was removed, and regenerates the next HTTP/3 proxy so
+// it takes the option over.
+func (n *NginxService) handOverHTTP3Reuseport(fromID int) error {
+	if err := n.DatabaseService.SetHTTP3ReuseportOwner(0); err != nil {
+		return err
+	}
+	if !n.HTTP3Enabled {
+		return nil
+	}
+	ids, err := n.DatabaseService.GetHTTP3ProxyIDs()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if id == fromID {
+			continue
+		}
+		next, err := n.DatabaseService.GetProxy(id)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Handing HTTP/3 reuseport over from proxy %d to proxy %d\n", fromID, id)
+		if err := n.GenerateProxyConfig(next); err != nil {
+			return fmt.Errorf("failed to move HTTP/3 reuseport to %s: %w", next.Domain, err)
+		}
+		return nil
+	}
+	return nil
+}
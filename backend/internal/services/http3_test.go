@@ -0,0 +1,168 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"upm-backend/internal/models"
+)
+
+func newTestHTTP3Service(t *testing.T) *NginxService {
+	t.Helper()
+	svc := newTestNginxService(t)
+	svc.DatabaseService = newTestDatabaseService(t)
+	svc.HTTP3Enabled = true
+	return svc
+}
+
+func TestGenerateProxyConfig_HTTP3_SingleReuseportOwner(t *testing.T) {
+	svc := newTestHTTP3Service(t)
+
+	first := newTestSSLProxy(t, svc, "first", "first.example.com")
+	first.HTTP3Enabled = true
+	second := newTestSSLProxy(t, svc, "second", "second.example.com")
+	second.HTTP3Enabled = true
+
+	config := renderTestProxy(t, svc, first)
+	for _, want := range []string{
+		"listen 443 quic reuseport;",
+		`add_header Alt-Svc "h3=\":443\"; ma=86400" always;`,
+	} {
+		if !strings.Contains(config, want) {
+			t.Errorf("expected %q in config, got:\n%s", want, config)
+		}
+	}
+	config = renderTestProxy(t, svc, second)
+	if !strings.Contains(config, "listen 443 quic;") || strings.Contains(config, "quic reuseport") {
+		t.Errorf("expected the second proxy to listen without reuseport, got:\n%s", config)
+	}
+	if owner, err := svc.DatabaseService.GetHTTP3ReuseportOwner(); err != nil || owner != first.ID {
+		t.Errorf("expected proxy %d to own reuseport, got %d (err %v)", first.ID, owner, err)
+	}
+
+	// Regenerating the owner keeps it
+	if config := renderTestProxy(t, svc, first); !strings.Contains(config, "listen 443 quic reuseport;") {
+		t.Errorf("expected the owner to keep reuseport, got:\n%s", config)
+	}
+}
+
+func TestGenerateProxyConfig_HTTP3_HandsReuseportOver(t *testing.T) {
+	svc := newTestHTTP3Service(t)
+
+	first := newTestSSLProxy(t, svc, "first", "first.example.com")
+	first.HTTP3Enabled = true
+	second := newTestSSLProxy(t, svc, "second", "second.example.com")
+	second.HTTP3Enabled = true
+	third := newTestSSLProxy(t, svc, "third", "third.example.com")
+	third.HTTP3Enabled = true
+	renderTestProxy(t, svc, first)
+	renderTestProxy(t, svc, second)
+	renderTestProxy(t, svc, third)
+
+	// The owner opts out: the next proxy takes reuseport over
+	first.HTTP3Enabled = false
+	if err := svc.DatabaseService.UpdateProxy(first); err != nil {
+		t.Fatal(err)
+	}
+	config := renderTestProxy(t, svc, first)
+	if strings.Contains(config, "quic") || strings.Contains(config, "Alt-Svc") {
+		t.Errorf("expected no HTTP/3 after opting out, got:\n%s", config)
+	}
+	if config := readTestProxyConfig(t, svc, second.ID); !strings.Contains(config, "listen 443 quic reuseport;") {
+		t.Errorf("expected the second proxy to take reuseport over, got:\n%s", config)
+	}
+	if config := readTestProxyConfig(t, svc, third.ID); strings.Contains(config, "quic reuseport") {
+		t.Errorf("expected the third proxy to stay without reuseport, got:\n%s", config)
+	}
+
+	// The new owner is deleted: the last proxy takes over
+	if err := svc.DatabaseService.DeleteProxy(second.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.RemoveProxyConfig(second.ID); err != nil {
+		t.Fatalf("RemoveProxyConfig returned error: %v", err)
+	}
+	if config := readTestProxyConfig(t, svc, third.ID); !strings.Contains(config, "listen 443 quic reuseport;") {
+		t.Errorf("expected the third proxy to take reuseport over, got:\n%s", config)
+	}
+	if owner, err := svc.DatabaseService.GetHTTP3ReuseportOwner(); err != nil || owner != third.ID {
+		t.Errorf("expected proxy %d to own reuseport, got %d (err %v)", third.ID, owner, err)
+	}
+}
+
+func TestGenerateProxyConfig_HTTP3_TakesReuseportFromQuarantinedOwner(t *testing.T) {
+	svc := newTestHTTP3Service(t)
+
+	first := newTestSSLProxy(t, svc, "first", "first.example.com")
+	first.HTTP3Enabled = true
+	second := newTestSSLProxy(t, svc, "second", "second.example.com")
+	second.HTTP3Enabled = true
+	renderTestProxy(t, svc, first)
+	if err := svc.DatabaseService.QuarantineProxy(first.ID, "broken"); err != nil {
+		t.Fatal(err)
+	}
+
+	if config := renderTestProxy(t, svc, second); !strings.Contains(config, "listen 443 quic reuseport;") {
+		t.Errorf("expected reuseport to move off the quarantined proxy, got:\n%s", config)
+	}
+}
+
+func TestApply_HTTP3_QuarantinedOwnerHandsReuseportOver(t *testing.T) {
+	svc := newTestHTTP3Service(t)
+
+	first := newTestSSLProxy(t, svc, "first", "first.example.com")
+	first.HTTP3Enabled = true
+	second := newTestSSLProxy(t, svc, "second", "second.example.com")
+	second.HTTP3Enabled = true
+	renderTestProxy(t, svc, first)
+	renderTestProxy(t, svc, second)
+	firstFile := filepath.Join(svc.SitesEnabledPath, fmt.Sprintf("proxy-%d.conf", first.ID))
+
+	// nginx rejects the owner's config for as long as it is laid out
+	svc.testStaged = func(dir string) error {
+		if _, err := os.Stat(filepath.Join(dir, "sites", filepath.Base(firstFile))); err == nil {
+			return &ConfigTestError{Output: "nginx: [emerg] invalid parameter in " + firstFile + ":3\n"}
+		}
+		return nil
+	}
+	if err := svc.Apply(nil, func(staged *NginxService) error {
+		return staged.GenerateProxyConfig(first)
+	}); err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+
+	if config := readTestProxyConfig(t, svc, second.ID); !strings.Contains(config, "listen 443 quic reuseport;") {
+		t.Errorf("expected the second proxy to take reuseport over, got:\n%s", config)
+	}
+	if owner, err := svc.DatabaseService.GetHTTP3ReuseportOwner(); err != nil || owner != second.ID {
+		t.Errorf("expected proxy %d to own reuseport, got %d (err %v)", second.ID, owner, err)
+	}
+}
+
+func TestGenerateProxyConfig_HTTP3_RequiresGlobalSwitch(t *testing.T) {
+	svc := newTestNginxService(t)
+	svc.DatabaseService = newTestDatabaseService(t)
+	svc.HTTP3AltSvcPort = "8443"
+
+	proxy := newTestSSLProxy(t, svc, "app", "app.example.com")
+	proxy.HTTP3Enabled = true
+	config := renderTestProxy(t, svc, proxy)
+	if strings.Contains(config, "quic") || strings.Contains(config, "Alt-Svc") {
+		t.Errorf("expected no HTTP/3 while it is disabled globally, got:\n%s", config)
+	}
+
+	svc.HTTP3Enabled = true
+	config = renderTestProxy(t, svc, proxy)
+	if !strings.Contains(config, "listen 443 quic reuseport;") || !strings.Contains(config, `add_header Alt-Svc "h3=\":8443\"; ma=86400" always;`) {
+		t.Errorf("expected HTTP/3 on the advertised port, got:\n%s", config)
+	}
+
+	// Plain HTTP servers never listen on QUIC
+	plain := &models.Proxy{Name: "plain", Domain: "plain.example.org", TargetURL: "http://plain:8080", Status: "active", HTTP3Enabled: true}
+	if config := renderTestProxy(t, svc, plain); strings.Contains(config, "quic") {
+		t.Errorf("expected no QUIC listener without SSL, got:\n%s", config)
+	}
+}
@@ -0,0 +1,793 @@
+package services
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"upm-backend/internal/models"
+)
+
+// InternalCAService runs UPM's own certificate authorities. It issues
+// server certificates for LAN-only hostnames that cannot pass ACME
+// challenges and client certificates for mutual TLS, and publishes the
+// certificates each CA revoked as a CRL. Issued server certificates, their
+// keys and the CRLs are written under Dir, which nginx reads.
+type InternalCAService struct {
+	db  *DatabaseService
+	Dir string
+}
+
+// NewInternalCAService creates an internal CA service writing its files
+// under dir.
+func NewInternalCAService(db *DatabaseService, dir string) *InternalCAService {
+	return &InternalCAService{db: db, Dir: dir}
+}
+
+// CRLs are valid for a week and regenerated when less than half of that
+// remains, so a missed renewal check never lets nginx reject clients for
+// an expired CRL.
+const (
+	crlValidity      = 7 * 24 * time.Hour
+	crlRefreshBefore = crlValidity / 2
+)
+
+// maxCAChainLength bounds the walk from a CA to its root.
+const maxCAChainLength = 10
+
+// internalRenewalWindow caps how early an internal certificate is renewed.
+const internalRenewalWindow = 30 * 24 * time.Hour
+
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._@-]`)
+
+// certificateFileBase turns a domain or client name into a file name; a
+// leading wildcard becomes an underscore.
+func certificateFileBase(name string) string {
+	return unsafeFileNameChars.ReplaceAllString(name, "_")
+}
+
+// ServerCertificatePaths returns where a server certificate for domain and
+// its key are written.
+func (s *InternalCAService) ServerCertificatePaths(domain string) (string, string) {
+	base := filepath.Join(s.Dir, "server", certificateFileBase(domain))
+	return base + ".crt", base + ".key"
+}
+
+// clientCertificatePath returns where a client certificate is written.
+func (s *InternalCAService) clientCertificatePath(name string) string {
+	return filepath.Join(s.Dir, "client", certificateFileBase(name)+".crt")
+}
+
+// CRLPath returns the CRL of a CA.
+func (s *InternalCAService) CRLPath(caID int) string {
+	return filepath.Join(s.Dir, "crl", fmt.Sprintf("ca-%d.crl", caID))
+}
+
+// CRLChainPath returns the CRLs of a CA and its parents in one file, as
+// nginx's ssl_crl needs a CRL for every CA of a client's chain.
+func (s *InternalCAService) CRLChainPath(caID int) string {
+	return filepath.Join(s.Dir, "crl", fmt.Sprintf("ca-%d-chain.crl", caID))
+}
+
+func writeInternalFile(path string, content []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, content, perm); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+func newSerialNumber() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+	return serial, nil
+}
+
+// serialHex formats a serial number the way it is stored.
+func serialHex(serial *big.Int) string {
+	return strings.ToUpper(serial.Text(16))
+}
+
+func encodeCertificatePEM(der []byte) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func encodePrivateKeyPEM(key crypto.Signer) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode private key: %w", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// parseCertificatePEM parses the first certificate of a PEM file.
+func parseCertificatePEM(data string) (*x509.Certificate, error) {
+	rest := []byte(data)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return nil, fmt.Errorf("no PEM encoded certificate found")
+		}
+		if block.Type == "CERTIFICATE" {
+			return x509.ParseCertificate(block.Bytes)
+		}
+	}
+}
+
+// parsePrivateKeyPEM parses a PKCS #8, PKCS #1 or SEC 1 private key.
+func parsePrivateKeyPEM(data string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, fmt.Errorf("no PEM encoded private key found")
+	}
+	if strings.Contains(block.Type, "ENCRYPTED") || block.Headers["Proc-Type"] != "" {
+		return nil, fmt.Errorf("private key must not be encrypted")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", key)
+		}
+		return signer, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("failed to parse private key")
+}
+
+// publicKeysEqual reports whether a private key belongs to a certificate.
+func publicKeysEqual(key crypto.Signer, cert *x509.Certificate) bool {
+	switch pub := key.Public().(type) {
+	case *ecdsa.PublicKey:
+		return pub.Equal(cert.PublicKey)
+	case *rsa.PublicKey:
+		return pub.Equal(cert.PublicKey)
+	case ed25519.PublicKey:
+		return pub.Equal(cert.PublicKey)
+	}
+	return false
+}
+
+// parseAuthority returns the certificate and key of a stored CA.
+func parseAuthority(ca *models.CertificateAuthority) (*x509.Certificate, crypto.Signer, error) {
+	cert, err := parseCertificatePEM(ca.Certificate)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse certificate of CA %s: %w", ca.Name, err)
+	}
+	key, err := parsePrivateKeyPEM(ca.PrivateKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse private key of CA %s: %w", ca.Name, err)
+	}
+	return cert, key, nil
+}
+
+// checkAuthorityCertificate makes sure a CA certificate can sign
+// certificates and CRLs now.
+func checkAuthorityCertificate(cert *x509.Certificate, now time.Time) error {
+	if !cert.BasicConstraintsValid || !cert.IsCA {
+		return fmt.Errorf("%s is not a CA certificate", cert.Subject.CommonName)
+	}
+	if cert.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return fmt.Errorf("CA %s may not sign certificates (missing certificate sign key usage)", cert.Subject.CommonName)
+	}
+	if cert.KeyUsage&x509.KeyUsageCRLSign == 0 {
+		return fmt.Errorf("CA %s may not sign CRLs (missing CRL sign key usage), so its certificates could not be revoked", cert.Subject.CommonName)
+	}
+	if now.After(cert.NotAfter) {
+		return fmt.Errorf("CA %s expired on %s", cert.Subject.CommonName, cert.NotAfter.Format("2006-01-02"))
+	}
+	if now.Before(cert.NotBefore) {
+		return fmt.Errorf("CA %s is not valid before %s", cert.Subject.CommonName, cert.NotBefore.Format("2006-01-02"))
+	}
+	return nil
+}
+
+// loadAuthority returns a CA that can sign now, with its certificate and
+// key.
+func (s *InternalCAService) loadAuthority(id int) (*models.CertificateAuthority, *x509.Certificate, crypto.Signer, error) {
+	ca, err := s.db.GetCertificateAuthority(id)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	cert, key, err := parseAuthority(ca)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if err := checkAuthorityCertificate(cert, time.Now()); err != nil {
+		return nil, nil, nil, err
+	}
+	return ca, cert, key, nil
+}
+
+// Chain returns a CA followed by its parents up to the root.
+func (s *InternalCAService) Chain(ca *models.CertificateAuthority) ([]*models.CertificateAuthority, error) {
+	chain := []*models.CertificateAuthority{ca}
+	for current := ca; !current.IsRoot(); {
+		if len(chain) >= maxCAChainLength {
+			return nil, fmt.Errorf("chain of CA %s is longer than %d certificates", ca.Name, maxCAChainLength)
+		}
+		parent, err := s.db.GetCertificateAuthority(current.ParentID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load parent of CA %s: %w", current.Name, err)
+		}
+		chain = append(chain, parent)
+		current = parent
+	}
+	return chain, nil
+}
+
+// ChainPEM returns the certificates of a CA and its parents, ending with
+// the root. It is what clients and proxies trust for certificates the CA
+// issues.
+func (s *InternalCAService) ChainPEM(ca *models.CertificateAuthority) (string, error) {
+	chain, err := s.Chain(ca)
+	if err != nil {
+		return "", err
+	}
+	var buf strings.Builder
+	for _, c := range chain {
+		buf.WriteString(strings.TrimSpace(c.Certificate) + "\n")
+	}
+	return buf.String(), nil
+}
+
+// servedChainPEM is the chain a server sends after its certificate: the
+// issuing CA and its parents without the root, which clients already
+// trust.
+func (s *InternalCAService) servedChainPEM(ca *models.CertificateAuthority) (string, error) {
+	chain, err := s.Chain(ca)
+	if err != nil {
+		return "", err
+	}
+	var buf strings.Builder
+	for _, c := range chain[:len(chain)-1] {
+		buf.WriteString(strings.TrimSpace(c.Certificate) + "\n")
+	}
+	return buf.String(), nil
+}
+
+// CreateAuthority generates a root CA, or an intermediate signed by
+// req.ParentID. Intermediates only sign leaf certificates and never
+// outlive their parent. The request is assumed to have passed
+// models.ValidateCertificateAuthorityRequest.
+func (s *InternalCAService) CreateAuthority(req *models.CertificateAuthorityCreateRequest) (*models.CertificateAuthority, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate CA key: %w", err)
+	}
+	serial, err := newSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: req.CommonName},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(0, 0, req.ValidityDays),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+	}
+
+	parentCert, parentKey := template, crypto.Signer(key)
+	if req.ParentID != 0 {
+		_, parentCert, parentKey, err = s.loadAuthority(req.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		if parentCert.MaxPathLen == 0 && parentCert.MaxPathLenZero {
+			return nil, fmt.Errorf("CA %s may not sign intermediate CAs", parentCert.Subject.CommonName)
+		}
+		template.MaxPathLenZero = true
+		if template.NotAfter.After(parentCert.NotAfter) {
+			template.NotAfter = parentCert.NotAfter
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, key.Public(), parentKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign CA certificate: %w", err)
+	}
+	keyPEM, err := encodePrivateKeyPEM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	ca := &models.CertificateAuthority{
+		Name:        req.Name,
+		ParentID:    req.ParentID,
+		CommonName:  req.CommonName,
+		Certificate: encodeCertificatePEM(der),
+		PrivateKey:  keyPEM,
+		ExpiresAt:   template.NotAfter,
+	}
+	if err := s.db.CreateCertificateAuthority(ca); err != nil {
+		return nil, err
+	}
+	if err := s.WriteCRL(ca); err != nil {
+		return nil, err
+	}
+	return ca, nil
+}
+
+// ImportAuthority stores an existing CA certificate and its private key.
+// An intermediate must name the stored CA that signed it; any other
+// certificate must be a self-signed root.
+func (s *InternalCAService) ImportAuthority(req *models.CertificateAuthorityImportRequest) (*models.CertificateAuthority, error) {
+	cert, err := parseCertificatePEM(req.Certificate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate: %w", err)
+	}
+	key, err := parsePrivateKeyPEM(req.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+	if !publicKeysEqual(key, cert) {
+		return nil, fmt.Errorf("private key does not belong to the certificate")
+	}
+	if err := checkAuthorityCertificate(cert, time.Now()); err != nil {
+		return nil, err
+	}
+
+	selfSigned := bytes.Equal(cert.RawIssuer, cert.RawSubject) && cert.CheckSignatureFrom(cert) == nil
+	if req.ParentID != 0 {
+		if selfSigned {
+			return nil, fmt.Errorf("certificate is a self-signed root and cannot have a parent CA")
+		}
+		parent, err := s.db.GetCertificateAuthority(req.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		parentCert, _, err := parseAuthority(parent)
+		if err != nil {
+			return nil, err
+		}
+		if err := cert.CheckSignatureFrom(parentCert); err != nil {
+			return nil, fmt.Errorf("certificate is not signed by CA %s: %w", parent.Name, err)
+		}
+	} else if !selfSigned {
+		return nil, fmt.Errorf("certificate is not a self-signed root; set parent_id to the CA that signed it")
+	}
+
+	// Store the certificate alone, whatever else the PEM contained
+	keyPEM, err := encodePrivateKeyPEM(key)
+	if err != nil {
+		return nil, err
+	}
+	ca := &models.CertificateAuthority{
+		Name:        req.Name,
+		ParentID:    req.ParentID,
+		CommonName:  cert.Subject.CommonName,
+		Certificate: encodeCertificatePEM(cert.Raw),
+		PrivateKey:  keyPEM,
+		Imported:    true,
+		ExpiresAt:   cert.NotAfter,
+	}
+	if ca.CommonName == "" {
+		ca.CommonName = req.Name
+	}
+	if err := s.db.CreateCertificateAuthority(ca); err != nil {
+		return nil, err
+	}
+	if err := s.WriteCRL(ca); err != nil {
+		return nil, err
+	}
+	return ca, nil
+}
+
+// leafTemplate returns the certificate template of an internal server or
+// client certificate.
+func leafTemplate(req *models.InternalCertificateRequest, serial *big.Int, notBefore, notAfter time.Time) *x509.Certificate {
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: req.CommonName},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+	}
+	if req.Usage == models.CertificateUsageClient {
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+		return template
+	}
+
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	seen := make(map[string]bool)
+	for _, name := range append([]string{req.CommonName}, req.DNSNames...) {
+		name = strings.ToLower(name)
+		if !seen[name] {
+			seen[name] = true
+			template.DNSNames = append(template.DNSNames, name)
+		}
+	}
+	for _, addr := range req.IPAddresses {
+		template.IPAddresses = append(template.IPAddresses, net.ParseIP(addr))
+	}
+	return template
+}
+
+// IssueCertificate issues a server or client certificate from a CA and
+// records it in the certificates table. A server certificate and its key
+// are written where nginx serves them from; the private key of a client
+// certificate is returned once and not kept. The request is assumed to
+// have passed models.ValidateInternalCertificateRequest.
+func (s *InternalCAService) IssueCertificate(caID int, req *models.InternalCertificateRequest) (*models.IssuedCertificate, error) {
+	ca, caCert, caKey, err := s.loadAuthority(caID)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	serial, err := newSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	notAfter := now.AddDate(0, 0, req.ValidityDays)
+	if notAfter.After(caCert.NotAfter) {
+		notAfter = caCert.NotAfter
+	}
+	der, err := x509.CreateCertificate(rand.Reader, leafTemplate(req, serial, now.Add(-time.Hour), notAfter), caCert, key.Public(), caKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign certificate: %w", err)
+	}
+	keyPEM, err := encodePrivateKeyPEM(key)
+	if err != nil {
+		return nil, err
+	}
+	chainPEM, err := s.ChainPEM(ca)
+	if err != nil {
+		return nil, err
+	}
+
+	cert := &models.Certificate{
+		Domain:       req.CommonName,
+		ExpiresAt:    notAfter,
+		IsValid:      true,
+		Source:       models.CertificateSourceInternal,
+		Usage:        req.Usage,
+		Issuer:       ca.CommonName,
+		CAID:         ca.ID,
+		SerialNumber: serialHex(serial),
+	}
+	issued := &models.IssuedCertificate{
+		Certificate:    cert,
+		CertificatePEM: encodeCertificatePEM(der),
+		ChainPEM:       chainPEM,
+	}
+
+	if req.Usage == models.CertificateUsageClient {
+		cert.CertPath = s.clientCertificatePath(req.CommonName)
+		issued.PrivateKeyPEM = keyPEM
+	} else {
+		cert.CertPath, cert.KeyPath = s.ServerCertificatePaths(req.CommonName)
+		if err := writeInternalFile(cert.KeyPath, []byte(keyPEM), 0600); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.writeLeaf(cert, ca, issued.CertificatePEM); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.CreateCertificate(cert); err != nil {
+		if removeErr := RemoveCertificateFiles(cert); removeErr != nil {
+			fmt.Printf("Warning: failed to remove files of unsaved certificate %s: %v\n", cert.Domain, removeErr)
+		}
+		return nil, err
+	}
+	return issued, nil
+}
+
+// writeLeaf writes an issued certificate to its CertPath. Server
+// certificates are followed by the chain nginx sends to clients.
+func (s *InternalCAService) writeLeaf(cert *models.Certificate, ca *models.CertificateAuthority, certPEM string) error {
+	content := certPEM
+	if cert.Usage != models.CertificateUsageClient {
+		chain, err := s.servedChainPEM(ca)
+		if err != nil {
+			return err
+		}
+		content += chain
+	}
+	return writeInternalFile(cert.CertPath, []byte(content), 0644)
+}
+
+// CertificatePEM returns an issued certificate and the chain of its CA,
+// for instance to hand a renewed client certificate to its user.
+func (s *InternalCAService) CertificatePEM(cert *models.Certificate) (string, string, error) {
+	if !cert.IsInternal() {
+		return "", "", fmt.Errorf("certificate for %s was not issued by an internal CA", cert.Domain)
+	}
+	content, err := os.ReadFile(cert.CertPath)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read certificate: %w", err)
+	}
+	leaf, err := parseCertificatePEM(string(content))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	ca, err := s.db.GetCertificateAuthority(cert.CAID)
+	if err != nil {
+		return "", "", err
+	}
+	chain, err := s.ChainPEM(ca)
+	if err != nil {
+		return "", "", err
+	}
+	return encodeCertificatePEM(leaf.Raw), chain, nil
+}
+
+// RenewalDue reports whether an internal certificate should be renewed
+// and the days until it expires. Certificates are renewed when a third of
+// their lifetime is left, but no earlier than 30 days before they expire.
+func (s *InternalCAService) RenewalDue(cert *models.Certificate, now time.Time) (bool, int) {
+	remaining := cert.ExpiresAt.Sub(now)
+	days := int(remaining.Hours() / 24)
+
+	window := internalRenewalWindow
+	if content, err := os.ReadFile(cert.CertPath); err == nil {
+		if leaf, err := parseCertificatePEM(string(content)); err == nil {
+			if third := leaf.NotAfter.Sub(leaf.NotBefore) / 3; third < window {
+				window = third
+			}
+		}
+	}
+	return remaining <= window, days
+}
+
+// RenewCertificate re-issues an internal certificate from its CA with the
+// same key, names and lifetime and a new serial number. Clients of a
+// renewed client certificate need the new certificate, see CertificatePEM.
+func (s *InternalCAService) RenewCertificate(cert *models.Certificate) (*models.Certificate, error) {
+	if !cert.IsInternal() {
+		return nil, fmt.Errorf("certificate for %s was not issued by an internal CA", cert.Domain)
+	}
+	if cert.IsRevoked() {
+		return nil, fmt.Errorf("certificate for %s has been revoked", cert.Domain)
+	}
+	content, err := os.ReadFile(cert.CertPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate: %w", err)
+	}
+	old, err := parseCertificatePEM(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	ca, caCert, caKey, err := s.loadAuthority(cert.CAID)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := newSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	notAfter := now.Add(old.NotAfter.Sub(old.NotBefore))
+	if notAfter.After(caCert.NotAfter) {
+		notAfter = caCert.NotAfter
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               old.Subject,
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              notAfter,
+		BasicConstraintsValid: true,
+		KeyUsage:              old.KeyUsage,
+		ExtKeyUsage:           old.ExtKeyUsage,
+		DNSNames:              old.DNSNames,
+		IPAddresses:           old.IPAddresses,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, old.PublicKey, caKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign certificate: %w", err)
+	}
+	if err := s.writeLeaf(cert, ca, encodeCertificatePEM(der)); err != nil {
+		return nil, err
+	}
+
+	cert.ExpiresAt = notAfter
+	cert.IsValid = true
+	cert.Issuer = ca.CommonName
+	cert.SerialNumber = serialHex(serial)
+	if err := s.db.UpdateCertificate(cert); err != nil {
+		return nil, fmt.Errorf("failed to update certificate: %w", err)
+	}
+	return cert, nil
+}
+
+// RevokeCertificate puts an internal certificate on its CA's CRL and
+// marks it invalid. nginx has to be reloaded to pick up the new CRL.
+func (s *InternalCAService) RevokeCertificate(cert *models.Certificate, reason int) error {
+	if !cert.IsInternal() {
+		return fmt.Errorf("certificate for %s was not issued by an internal CA", cert.Domain)
+	}
+	if cert.IsRevoked() {
+		return fmt.Errorf("certificate for %s is already revoked", cert.Domain)
+	}
+	ca, err := s.db.GetCertificateAuthority(cert.CAID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err = s.db.InTransaction(func(tx *DatabaseService) error {
+		rev := &models.CertificateRevocation{
+			CAID:         cert.CAID,
+			SerialNumber: cert.SerialNumber,
+			Reason:       reason,
+			RevokedAt:    now,
+			ExpiresAt:    cert.ExpiresAt,
+		}
+		if err := tx.AddCertificateRevocation(rev); err != nil {
+			return err
+		}
+		cert.RevokedAt = &now
+		cert.IsValid = false
+		return tx.UpdateCertificate(cert)
+	})
+	if err != nil {
+		return err
+	}
+	return s.WriteCRL(ca)
+}
+
+// WriteCRL signs a new CRL of a CA listing its unexpired revoked
+// certificates, and rewrites the CRL chain files that include it.
+func (s *InternalCAService) WriteCRL(ca *models.CertificateAuthority) error {
+	caCert, caKey, err := parseAuthority(ca)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	revocations, err := s.db.GetCertificateRevocations(ca.ID, now)
+	if err != nil {
+		return err
+	}
+
+	var entries []x509.RevocationListEntry
+	for _, rev := range revocations {
+		serial, ok := new(big.Int).SetString(rev.SerialNumber, 16)
+		if !ok {
+			return fmt.Errorf("invalid serial number %q revoked by CA %s", rev.SerialNumber, ca.Name)
+		}
+		entries = append(entries, x509.RevocationListEntry{
+			SerialNumber:   serial,
+			RevocationTime: rev.RevokedAt,
+			ReasonCode:     rev.Reason,
+		})
+	}
+
+	number := ca.CRLNumber + 1
+	template := &x509.RevocationList{
+		Number:                    big.NewInt(number),
+		ThisUpdate:                now,
+		NextUpdate:                now.Add(crlValidity),
+		RevokedCertificateEntries: entries,
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, template, caCert, caKey)
+	if err != nil {
+		return fmt.Errorf("failed to sign CRL of CA %s: %w", ca.Name, err)
+	}
+	if err := s.db.SetCertificateAuthorityCRLNumber(ca.ID, number); err != nil {
+		return err
+	}
+	ca.CRLNumber = number
+
+	content := pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der})
+	if err := writeInternalFile(s.CRLPath(ca.ID), content, 0644); err != nil {
+		return err
+	}
+	return s.writeCRLChains()
+}
+
+// writeCRLChains rewrites the CRL chain file of every CA from the CRLs of
+// the CA and its parents.
+func (s *InternalCAService) writeCRLChains() error {
+	authorities, err := s.db.GetCertificateAuthorities()
+	if err != nil {
+		return err
+	}
+	for i := range authorities {
+		chain, err := s.Chain(&authorities[i])
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		for _, c := range chain {
+			crl, err := os.ReadFile(s.CRLPath(c.ID))
+			if err != nil {
+				// Written once the CA's own CRL is
+				continue
+			}
+			buf.Write(bytes.TrimSpace(crl))
+			buf.WriteString("\n")
+		}
+		if err := writeInternalFile(s.CRLChainPath(authorities[i].ID), buf.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadCRL returns the current PEM CRL of a CA, writing it first when it is
+// missing.
+func (s *InternalCAService) ReadCRL(ca *models.CertificateAuthority) ([]byte, error) {
+	content, err := os.ReadFile(s.CRLPath(ca.ID))
+	if os.IsNotExist(err) {
+		if err := s.WriteCRL(ca); err != nil {
+			return nil, err
+		}
+		content, err = os.ReadFile(s.CRLPath(ca.ID))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CRL: %w", err)
+	}
+	return content, nil
+}
+
+// RefreshCRLs regenerates the CRLs that are missing or close to their
+// next update and reports whether any changed, in which case nginx has to
+// be reloaded.
+func (s *InternalCAService) RefreshCRLs() (bool, error) {
+	authorities, err := s.db.GetCertificateAuthorities()
+	if err != nil {
+		return false, err
+	}
+	refreshed := false
+	now := time.Now()
+	for i := range authorities {
+		ca := &authorities[i]
+		if content, err := os.ReadFile(s.CRLPath(ca.ID)); err == nil {
+			if block, _ := pem.Decode(content); block != nil {
+				if crl, err := x509.ParseRevocationList(block.Bytes); err == nil && crl.NextUpdate.Sub(now) > crlRefreshBefore {
+					continue
+				}
+			}
+		}
+		if err := s.WriteCRL(ca); err != nil {
+			return refreshed, err
+		}
+		refreshed = true
+	}
+	return refreshed, nil
+}
+
+// RemoveAuthorityFiles deletes the CRL files of a deleted CA.
+func (s *InternalCAService) RemoveAuthorityFiles(caID int) error {
+	for _, path := range []string{s.CRLPath(caID), s.CRLChainPath(caID)} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,318 @@
+package services
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"upm-backend/internal/models"
+)
+
+// newTestInternalCA returns an internal CA service with a root and an
+// intermediate signed by it.
+func newTestInternalCA(t *testing.T) (*InternalCAService, *models.CertificateAuthority, *models.CertificateAuthority) {
+	t.Helper()
+	svc := NewInternalCAService(newTestDatabaseService(t), t.TempDir())
+	root, err := svc.CreateAuthority(&models.CertificateAuthorityCreateRequest{Name: "root", CommonName: "UPM Test Root", ValidityDays: 3650})
+	if err != nil {
+		t.Fatalf("CreateAuthority(root) returned error: %v", err)
+	}
+	intermediate, err := svc.CreateAuthority(&models.CertificateAuthorityCreateRequest{Name: "lan", CommonName: "UPM Test LAN CA", ParentID: root.ID, ValidityDays: 1825})
+	if err != nil {
+		t.Fatalf("CreateAuthority(intermediate) returned error: %v", err)
+	}
+	return svc, root, intermediate
+}
+
+// verifyTestChain verifies a leaf certificate file against a root.
+func verifyTestChain(t *testing.T, certFile string, root *models.CertificateAuthority, chainPEM string, usage x509.ExtKeyUsage) *x509.Certificate {
+	t.Helper()
+	content, err := os.ReadFile(certFile)
+	if err != nil {
+		t.Fatalf("failed to read issued certificate: %v", err)
+	}
+	leaf, err := parseCertificatePEM(string(content))
+	if err != nil {
+		t.Fatalf("failed to parse issued certificate: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM([]byte(root.Certificate))
+	intermediates := x509.NewCertPool()
+	intermediates.AppendCertsFromPEM([]byte(chainPEM))
+	if _, err := leaf.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates, KeyUsages: []x509.ExtKeyUsage{usage}}); err != nil {
+		t.Fatalf("issued certificate does not verify: %v", err)
+	}
+	return leaf
+}
+
+func TestInternalCA_IssueServerAndClientCertificates(t *testing.T) {
+	svc, root, intermediate := newTestInternalCA(t)
+
+	if _, err := svc.CreateAuthority(&models.CertificateAuthorityCreateRequest{Name: "nested", CommonName: "Nested", ParentID: intermediate.ID, ValidityDays: 30}); err == nil {
+		t.Errorf("expected intermediates to be unable to sign further CAs")
+	}
+
+	issued, err := svc.IssueCertificate(intermediate.ID, &models.InternalCertificateRequest{
+		Usage: models.CertificateUsageServer, CommonName: "nas.lan", DNSNames: []string{"files.lan"}, IPAddresses: []string{"192.168.1.10"}, ValidityDays: 90,
+	})
+	if err != nil {
+		t.Fatalf("IssueCertificate(server) returned error: %v", err)
+	}
+	cert := issued.Certificate
+	if cert.Source != models.CertificateSourceInternal || cert.Issuer != "UPM Test LAN CA" || cert.CAID != intermediate.ID || cert.SerialNumber == "" {
+		t.Errorf("unexpected certificate record: %+v", cert)
+	}
+	if issued.PrivateKeyPEM != "" || !isValidPEMFile(cert.KeyPath, "PRIVATE KEY") {
+		t.Errorf("expected the server key on disk only, got key %q at %s", issued.PrivateKeyPEM, cert.KeyPath)
+	}
+	leaf := verifyTestChain(t, cert.CertPath, root, issued.ChainPEM, x509.ExtKeyUsageServerAuth)
+	if err := leaf.VerifyHostname("files.lan"); err != nil {
+		t.Errorf("expected the DNS names in the certificate: %v", err)
+	}
+	if err := leaf.VerifyHostname("192.168.1.10"); err != nil {
+		t.Errorf("expected the IP address in the certificate: %v", err)
+	}
+	served, _ := os.ReadFile(cert.CertPath)
+	if strings.Count(string(served), "BEGIN CERTIFICATE") != 2 {
+		t.Errorf("expected the served file to hold the certificate and the intermediate, got:\n%s", served)
+	}
+	if found, err := svc.db.GetCertificateByDomain("nas.lan"); err != nil || found.ID != cert.ID {
+		t.Errorf("expected proxies for nas.lan to use the certificate, got %+v, %v", found, err)
+	}
+
+	client, err := svc.IssueCertificate(intermediate.ID, &models.InternalCertificateRequest{
+		Usage: models.CertificateUsageClient, CommonName: "alice@example.com", ValidityDays: 365,
+	})
+	if err != nil {
+		t.Fatalf("IssueCertificate(client) returned error: %v", err)
+	}
+	if client.PrivateKeyPEM == "" || client.Certificate.KeyPath != "" {
+		t.Errorf("expected the client key to be returned and not kept, got key path %q", client.Certificate.KeyPath)
+	}
+	verifyTestChain(t, client.Certificate.CertPath, root, client.ChainPEM, x509.ExtKeyUsageClientAuth)
+	if _, err := svc.db.GetCertificateByDomain("alice@example.com"); err == nil {
+		t.Errorf("expected client certificates never to be served")
+	}
+}
+
+func TestInternalCA_RevokeWritesCRL(t *testing.T) {
+	svc, root, intermediate := newTestInternalCA(t)
+	issued, err := svc.IssueCertificate(intermediate.ID, &models.InternalCertificateRequest{
+		Usage: models.CertificateUsageClient, CommonName: "bob", ValidityDays: 30,
+	})
+	if err != nil {
+		t.Fatalf("IssueCertificate returned error: %v", err)
+	}
+	cert := issued.Certificate
+
+	if err := svc.RevokeCertificate(cert, models.CertificateRevocationReasons["key_compromise"]); err != nil {
+		t.Fatalf("RevokeCertificate returned error: %v", err)
+	}
+	if err := svc.RevokeCertificate(cert, 0); err == nil {
+		t.Errorf("expected revoking twice to fail")
+	}
+	stored, err := svc.db.GetCertificate(cert.ID)
+	if err != nil || !stored.IsRevoked() || stored.IsValid {
+		t.Fatalf("expected the stored certificate to be revoked, got %+v, %v", stored, err)
+	}
+
+	content, err := os.ReadFile(svc.CRLPath(intermediate.ID))
+	if err != nil {
+		t.Fatalf("expected a CRL: %v", err)
+	}
+	block, _ := pem.Decode(content)
+	crl, err := x509.ParseRevocationList(block.Bytes)
+	if err != nil {
+		t.Fatalf("failed to parse CRL: %v", err)
+	}
+	intermediateCert, _ := parseCertificatePEM(intermediate.Certificate)
+	if err := crl.CheckSignatureFrom(intermediateCert); err != nil {
+		t.Errorf("expected the CRL to be signed by the intermediate: %v", err)
+	}
+	if len(crl.RevokedCertificateEntries) != 1 || serialHex(crl.RevokedCertificateEntries[0].SerialNumber) != cert.SerialNumber || crl.RevokedCertificateEntries[0].ReasonCode != 1 {
+		t.Errorf("expected the revoked serial %s on the CRL, got %+v", cert.SerialNumber, crl.RevokedCertificateEntries)
+	}
+	if crl.Number.Int64() < 2 {
+		t.Errorf("expected the CRL number to increase, got %v", crl.Number)
+	}
+
+	chain, err := os.ReadFile(svc.CRLChainPath(intermediate.ID))
+	if err != nil || strings.Count(string(chain), "BEGIN X509 CRL") != 2 {
+		t.Errorf("expected the CRLs of the intermediate and the root in the chain file, got:\n%s", chain)
+	}
+	if chain, _ := os.ReadFile(svc.CRLChainPath(root.ID)); strings.Count(string(chain), "BEGIN X509 CRL") != 1 {
+		t.Errorf("expected only the root's CRL in its chain file, got:\n%s", chain)
+	}
+
+	// Revocations outlive the certificate row
+	if err := svc.db.DeleteCertificate(cert.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.WriteCRL(intermediate); err != nil {
+		t.Fatalf("WriteCRL returned error: %v", err)
+	}
+	content, _ = os.ReadFile(svc.CRLPath(intermediate.ID))
+	block, _ = pem.Decode(content)
+	if crl, err := x509.ParseRevocationList(block.Bytes); err != nil || len(crl.RevokedCertificateEntries) != 1 {
+		t.Errorf("expected the deleted certificate to stay on the CRL, got %v", err)
+	}
+
+	if refreshed, err := svc.RefreshCRLs(); err != nil || refreshed {
+		t.Errorf("expected current CRLs to be left alone, got %v, %v", refreshed, err)
+	}
+	if err := os.Remove(svc.CRLPath(root.ID)); err != nil {
+		t.Fatal(err)
+	}
+	if refreshed, err := svc.RefreshCRLs(); err != nil || !refreshed {
+		t.Errorf("expected a missing CRL to be written, got %v, %v", refreshed, err)
+	}
+}
+
+func TestInternalCA_RenewKeepsKeyAndNames(t *testing.T) {
+	svc, root, intermediate := newTestInternalCA(t)
+	issued, err := svc.IssueCertificate(intermediate.ID, &models.InternalCertificateRequest{
+		Usage: models.CertificateUsageServer, CommonName: "*.apps.lan", ValidityDays: 90,
+	})
+	if err != nil {
+		t.Fatalf("IssueCertificate returned error: %v", err)
+	}
+	cert := issued.Certificate
+	if filepath.Base(cert.CertPath) != "_.apps.lan.crt" {
+		t.Errorf("expected a file name without the wildcard, got %s", cert.CertPath)
+	}
+	oldSerial := cert.SerialNumber
+	oldLeaf, _ := parseCertificatePEM(issued.CertificatePEM)
+
+	if due, _ := svc.RenewalDue(cert, cert.ExpiresAt.Add(-31*24*time.Hour)); due {
+		t.Errorf("expected a 90 day certificate not to be due with 31 days left")
+	}
+	if due, _ := svc.RenewalDue(cert, cert.ExpiresAt.Add(-29*24*time.Hour)); !due {
+		t.Errorf("expected a 90 day certificate to be due with 29 days left")
+	}
+
+	renewed, err := svc.RenewCertificate(cert)
+	if err != nil {
+		t.Fatalf("RenewCertificate returned error: %v", err)
+	}
+	if renewed.SerialNumber == oldSerial {
+		t.Errorf("expected a new serial number")
+	}
+	chain, _ := svc.ChainPEM(intermediate)
+	leaf := verifyTestChain(t, cert.CertPath, root, chain, x509.ExtKeyUsageServerAuth)
+	if !publicKeysEqual(mustParseTestKey(t, cert.KeyPath), leaf) || !leaf.NotAfter.After(oldLeaf.NotAfter.Add(-time.Minute)) {
+		t.Errorf("expected the renewed certificate to keep its key and lifetime")
+	}
+	if err := leaf.VerifyHostname("grafana.apps.lan"); err != nil {
+		t.Errorf("expected the renewed certificate to keep its names: %v", err)
+	}
+
+	if err := svc.RevokeCertificate(renewed, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.RenewCertificate(renewed); err == nil {
+		t.Errorf("expected revoked certificates not to be renewed")
+	}
+	if _, err := svc.db.GetCertificateByDomain("grafana.apps.lan"); err == nil {
+		t.Errorf("expected revoked certificates never to be served")
+	}
+}
+
+func mustParseTestKey(t *testing.T, path string) crypto.Signer {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := parsePrivateKeyPEM(string(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestInternalCA_ImportAuthority(t *testing.T) {
+	source, root, _ := newTestInternalCA(t)
+	stored, err := source.db.GetCertificateAuthority(root.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := NewInternalCAService(newTestDatabaseService(t), t.TempDir())
+	imported, err := svc.ImportAuthority(&models.CertificateAuthorityImportRequest{Name: "corp", Certificate: stored.Certificate, PrivateKey: stored.PrivateKey})
+	if err != nil {
+		t.Fatalf("ImportAuthority returned error: %v", err)
+	}
+	if !imported.Imported || imported.CommonName != "UPM Test Root" || !imported.IsRoot() {
+		t.Errorf("unexpected imported CA: %+v", imported)
+	}
+	if _, err := os.Stat(svc.CRLPath(imported.ID)); err != nil {
+		t.Errorf("expected a CRL for the imported CA: %v", err)
+	}
+
+	otherCert, otherKey := newTestCAPEM(t, "Other", time.Now().Add(24*time.Hour))
+	cases := []struct {
+		name string
+		req  models.CertificateAuthorityImportRequest
+	}{
+		{"mismatched key", models.CertificateAuthorityImportRequest{Name: "a", Certificate: stored.Certificate, PrivateKey: otherKey}},
+		{"no CRL sign usage", models.CertificateAuthorityImportRequest{Name: "b", Certificate: otherCert, PrivateKey: otherKey}},
+		{"wrong parent", models.CertificateAuthorityImportRequest{Name: "c", Certificate: stored.Certificate, PrivateKey: stored.PrivateKey, ParentID: imported.ID}},
+	}
+	for _, tc := range cases {
+		if _, err := svc.ImportAuthority(&tc.req); err == nil {
+			t.Errorf("%s: expected ImportAuthority to fail", tc.name)
+		}
+	}
+}
+
+func TestGenerateProxyConfig_ClientAuth_InternalCA(t *testing.T) {
+	svc := newTestNginxService(t)
+	internalCA, root, intermediate := newTestInternalCA(t)
+	svc.DatabaseService = internalCA.db
+	svc.InternalCA = internalCA
+
+	if _, err := internalCA.IssueCertificate(intermediate.ID, &models.InternalCertificateRequest{
+		Usage: models.CertificateUsageServer, CommonName: "admin.lan", ValidityDays: 90,
+	}); err != nil {
+		t.Fatalf("IssueCertificate returned error: %v", err)
+	}
+
+	proxy := &models.Proxy{
+		Name: "admin", Domain: "admin.lan", TargetURL: "http://admin:8080", Status: "active", SSLEnabled: true,
+		ClientAuth: &models.ClientAuth{Mode: models.ClientAuthModeOn, CAID: intermediate.ID, VerifyDepth: 1},
+	}
+	if err := svc.DatabaseService.CreateProxy(proxy); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.GenerateProxyConfig(proxy); err != nil {
+		t.Fatalf("GenerateProxyConfig returned error: %v", err)
+	}
+
+	bundle, err := os.ReadFile(filepath.Join(svc.SitesEnabledPath, clientCAFileName(proxy.ID)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(bundle), strings.TrimSpace(intermediate.Certificate)) || !strings.Contains(string(bundle), strings.TrimSpace(root.Certificate)) {
+		t.Errorf("expected the intermediate and the root in the CA bundle, got:\n%s", bundle)
+	}
+	content, err := os.ReadFile(filepath.Join(svc.SitesEnabledPath, "proxy-1.conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := string(content)
+	for _, want := range []string{
+		"ssl_certificate " + filepath.Join(internalCA.Dir, "server", "admin.lan.crt") + ";",
+		"ssl_verify_client on;",
+		"ssl_verify_depth 2;",
+		"ssl_crl " + internalCA.CRLChainPath(intermediate.ID) + ";",
+	} {
+		if !strings.Contains(config, want) {
+			t.Errorf("expected %q in config, got:\n%s", want, config)
+		}
+	}
+}
@@ -81,8 +81,9 @@ func NewLetsEncryptService(certPath, webroot string) *LetsEncryptService {
 	}
 }
 
-// GenerateCertificate generates a Let's Encrypt certificate for the given domain
-func (l *LetsEncryptService) GenerateCertificate(domain string) (*models.Certificate, error) {
+// GenerateCertificate generates a Let's Encrypt certificate for the given domain,
+// adding any aliases as further subject alternative names
+func (l *LetsEncryptService) GenerateCertificate(domain string, aliases ...string) (*models.Certificate, error) {
 	// Create user
 	user, err := l.createOrGetUser()
 	if err != nil {
@@ -103,7 +104,7 @@ func (l *LetsEncryptService) GenerateCertificate(domain string) (*models.Certifi
 
 	// Request certificate
 	request := certificate.ObtainRequest{
-		Domains: []string{domain},
+		Domains: append([]string{domain}, aliases...),
 		Bundle:  true,
 	}
 
@@ -207,8 +208,18 @@ func (l *LetsEncryptService) RenewCertificate(cert *models.Certificate) (*models
 		return cert, fmt.Errorf("certificate is not close to expiration (%d days remaining). If the certificate is actually expired, please delete and recreate it.", daysUntilExpiry)
 	}
 
+	// Keep the names the certificate was issued for besides its domain
+	var aliases []string
+	if certInfo != nil {
+		for _, name := range certInfo.DNSNames {
+			if !strings.EqualFold(name, cert.Domain) {
+				aliases = append(aliases, name)
+			}
+		}
+	}
+
 	// Generate new certificate
-	newCert, err := l.GenerateCertificate(cert.Domain)
+	newCert, err := l.GenerateCertificate(cert.Domain, aliases...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to renew certificate: %w", err)
 	}
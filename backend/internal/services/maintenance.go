@@ -0,0 +1,128 @@
+package services
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"upm-backend/internal/models"
+)
+
+// defaultMaintenancePage is served when a proxy in maintenance mode has no
+// page of its own.
+const defaultMaintenancePage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Down for maintenance</title>
+<style>
+body { font-family: system-ui, sans-serif; background: #f3f4f6; color: #111827; display: flex; align-items: center; justify-content: center; min-height: 100vh; margin: 0; }
+main { text-align: center; padding: 2rem; }
+h1 { font-size: 1.5rem; margin: 0 0 .5rem; }
+p { color: #4b5563; margin: 0; }
+</style>
+</head>
+<body>
+<main>
+<h1>Down for maintenance</h1>
+<p>This site is undergoing scheduled maintenance. Please check back soon.</p>
+</main>
+</body>
+</html>
+`
+
+// maintenanceTemplateData is the rendered form of a proxy's maintenance
+// mode.
+type maintenanceTemplateData struct {
+	Geo        string   // geo variable exempting AllowedIPs, empty without an allowlist
+	AllowedIPs []string // normalized CIDRs
+	PageFile   string
+	RetryAfter int
+}
+
+// Flag is the value the maintenance check starts from: the geo variable,
+// or 1 when every client gets the maintenance page.
+func (m *maintenanceTemplateData) Flag() string {
+	if m.Geo != "" {
+		return m.Geo
+	}
+	return "1"
+}
+
+// maintenancePageName is the file name of a proxy's maintenance page.
+func maintenancePageName(proxyID int) string {
+	return fmt.Sprintf("proxy-%d-maintenance.html", proxyID)
+}
+
+// writeMaintenancePage writes the maintenance page of a proxy in
+// maintenance mode next to its config and returns the template data, or
+// removes the page and returns nil when the proxy is not in maintenance.
+func (n *NginxService) writeMaintenancePage(proxy *models.Proxy) (*maintenanceTemplateData, error) {
+	name := maintenancePageName(proxy.ID)
+	if !proxy.InMaintenance() {
+		return nil, n.removeMaintenancePage(proxy.ID)
+	}
+
+	page := proxy.Maintenance.Page
+	if page == "" {
+		page = defaultMaintenancePage
+	}
+	enabledPath := filepath.Join(n.SitesEnabledPath, name)
+	if err := n.writeFile(filepath.Join(n.ConfigPath, name), []byte(page)); err != nil {
+		return nil, fmt.Errorf("failed to write maintenance page: %w", err)
+	}
+	if err := n.writeFile(enabledPath, []byte(page)); err != nil {
+		return nil, fmt.Errorf("failed to copy maintenance page to sites-enabled: %w", err)
+	}
+
+	data := &maintenanceTemplateData{
+		AllowedIPs: sanitizeAllowedRanges(proxy.Maintenance.AllowedIPs),
+		PageFile:   enabledPath,
+		RetryAfter: proxy.Maintenance.RetryAfterSeconds,
+	}
+	if data.RetryAfter < 1 {
+		data.RetryAfter = models.DefaultMaintenanceRetryAfter
+	}
+	if len(data.AllowedIPs) > 0 {
+		data.Geo = fmt.Sprintf("$upm_maintenance_%d", proxy.ID)
+	}
+	return data, nil
+}
+
+// removeMaintenancePage deletes a proxy's maintenance page, if any.
+func (n *NginxService) removeMaintenancePage(proxyID int) error {
+	name := maintenancePageName(proxyID)
+	for _, path := range []string{filepath.Join(n.SitesEnabledPath, name), filepath.Join(n.ConfigPath, name)} {
+		if err := n.removeFile(path); err != nil {
+			return fmt.Errorf("failed to remove maintenance page: %w", err)
+		}
+	}
+	return nil
+}
+
+// ApplyMaintenanceState turns maintenance mode of a proxy on or off and
+// reloads nginx. Ending maintenance also clears its schedule. It is called
+// by the scheduler when a maintenance window starts or ends.
+func (n *NginxService) ApplyMaintenanceState(proxyID int, enabled bool) error {
+	if n.DatabaseService == nil {
+		return fmt.Errorf("database service not initialized")
+	}
+	proxy, err := n.DatabaseService.GetProxy(proxyID)
+	if err != nil {
+		return fmt.Errorf("failed to load proxy: %w", err)
+	}
+	if proxy.Maintenance == nil {
+		return nil
+	}
+
+	proxy.Maintenance.Enabled = enabled
+	if !enabled {
+		proxy.Maintenance.StartsAt = nil
+		proxy.Maintenance.EndsAt = nil
+	}
+	return n.Apply(func(db *DatabaseService) error {
+		return db.SetProxyMaintenance(proxy.ID, proxy.Maintenance)
+	}, func(staged *NginxService) error {
+		return staged.UpdateProxyConfig(proxy)
+	})
+}
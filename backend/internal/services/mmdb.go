@@ -0,0 +1,395 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"os"
+)
+
+// mmdbReader reads MaxMind DB files such as GeoLite2-Country or the DB-IP
+// lite databases (https://maxmind.github.io/MaxMind-DB/). It implements
+// what country rules need: looking up an address and walking every network
+// of the search tree.
+type mmdbReader struct {
+	meta      mmdbMetadata
+	tree      []byte      // binary search tree
+	data      mmdbDecoder // data section
+	nodeBytes uint
+	ipv4Start uint // node (or record) of ::/96 in IPv6 databases
+}
+
+// mmdbMetadata is the part of the metadata map the reader uses.
+type mmdbMetadata struct {
+	NodeCount    uint
+	RecordSize   uint
+	IPVersion    uint
+	DatabaseType string
+	BuildEpoch   uint64
+}
+
+// mmdbMetadataMarker precedes the metadata map at the end of the file.
+var mmdbMetadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// mmdbDataSeparator is the run of zero bytes between the search tree and
+// the data section.
+const mmdbDataSeparator = 16
+
+// Data field types.
+const (
+	mmdbExtended uint = iota
+	mmdbPointer
+	mmdbString
+	mmdbDouble
+	mmdbBytes
+	mmdbUint16
+	mmdbUint32
+	mmdbMap
+	mmdbInt32
+	mmdbUint64
+	mmdbUint128
+	mmdbArray
+	mmdbContainer
+	mmdbEndMarker
+	mmdbBool
+	mmdbFloat
+)
+
+// mmdbMaxDepth bounds the nesting of decoded maps and arrays.
+const mmdbMaxDepth = 32
+
+func openMMDB(path string) (*mmdbReader, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseMMDB(buf)
+}
+
+func parseMMDB(buf []byte) (*mmdbReader, error) {
+	markerAt := bytes.LastIndex(buf, mmdbMetadataMarker)
+	if markerAt < 0 {
+		return nil, fmt.Errorf("not a MaxMind DB file: metadata marker not found")
+	}
+	raw, _, err := mmdbDecoder(buf[markerAt+len(mmdbMetadataMarker):]).decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode metadata: %w", err)
+	}
+	fields, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("metadata is not a map")
+	}
+
+	meta := mmdbMetadata{
+		NodeCount:  uint(mmdbUint(fields["node_count"])),
+		RecordSize: uint(mmdbUint(fields["record_size"])),
+		IPVersion:  uint(mmdbUint(fields["ip_version"])),
+		BuildEpoch: mmdbUint(fields["build_epoch"]),
+	}
+	meta.DatabaseType, _ = fields["database_type"].(string)
+	if meta.RecordSize != 24 && meta.RecordSize != 28 && meta.RecordSize != 32 {
+		return nil, fmt.Errorf("unsupported record size %d", meta.RecordSize)
+	}
+	if meta.IPVersion != 4 && meta.IPVersion != 6 {
+		return nil, fmt.Errorf("unsupported IP version %d", meta.IPVersion)
+	}
+
+	r := &mmdbReader{meta: meta, nodeBytes: meta.RecordSize / 4}
+	treeSize := meta.NodeCount * r.nodeBytes
+	if treeSize+mmdbDataSeparator > uint(markerAt) {
+		return nil, fmt.Errorf("search tree is larger than the file")
+	}
+	r.tree = buf[:treeSize]
+	r.data = mmdbDecoder(buf[treeSize+mmdbDataSeparator : markerAt])
+
+	// IPv4 addresses live under ::/96 of IPv6 databases
+	if meta.IPVersion == 6 {
+		for i := 0; i < 96 && r.ipv4Start < meta.NodeCount; i++ {
+			if r.ipv4Start, err = r.record(r.ipv4Start, 0); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return r, nil
+}
+
+// record returns the left (bit 0) or right (bit 1) record of a node.
+func (r *mmdbReader) record(node, bit uint) (uint, error) {
+	off := node * r.nodeBytes
+	if off+r.nodeBytes > uint(len(r.tree)) {
+		return 0, fmt.Errorf("search tree node %d out of range", node)
+	}
+	b := r.tree[off : off+r.nodeBytes]
+	switch r.meta.RecordSize {
+	case 24:
+		b = b[bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2]), nil
+	case 28:
+		if bit == 0 {
+			return uint(b[3]&0xf0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2]), nil
+		}
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6]), nil
+	default:
+		b = b[bit*4:]
+		return uint(b[0])<<24 | uint(b[1])<<16 | uint(b[2])<<8 | uint(b[3]), nil
+	}
+}
+
+// dataOffset turns a record pointing into the data section into an offset
+// within it.
+func (r *mmdbReader) dataOffset(record uint) (uint, error) {
+	off := record - r.meta.NodeCount - mmdbDataSeparator
+	if record < r.meta.NodeCount+mmdbDataSeparator || off >= uint(len(r.data)) {
+		return 0, fmt.Errorf("invalid data pointer %d", record)
+	}
+	return off, nil
+}
+
+// lookup finds the data of the network containing ip. found is false when
+// the database has no data for it; prefixLen is the length of the matched
+// network, counted in IPv4 bits for IPv4 addresses.
+func (r *mmdbReader) lookup(ip net.IP) (offset uint, prefixLen int, found bool, err error) {
+	node := uint(0)
+	addr := ip.To4()
+	if addr != nil {
+		if r.meta.IPVersion == 6 {
+			node = r.ipv4Start
+		}
+	} else {
+		if r.meta.IPVersion == 4 {
+			return 0, 0, false, fmt.Errorf("the database only contains IPv4 addresses")
+		}
+		addr = ip.To16()
+	}
+
+	for ; prefixLen < len(addr)*8 && node < r.meta.NodeCount; prefixLen++ {
+		bit := uint(addr[prefixLen/8]>>(7-prefixLen%8)) & 1
+		if node, err = r.record(node, bit); err != nil {
+			return 0, 0, false, err
+		}
+	}
+	if node <= r.meta.NodeCount {
+		// An empty record, or a tree deeper than the address
+		return 0, prefixLen, false, nil
+	}
+	offset, err = r.dataOffset(node)
+	return offset, prefixLen, err == nil, err
+}
+
+// networks calls fn with every network of the database that has data.
+// IPv4 networks are reported once in their IPv4 form; the aliases IPv6
+// databases keep of the IPv4 space (::ffff:0:0/96, 2002::/16) are skipped.
+func (r *mmdbReader) networks(fn func(network *net.IPNet, offset uint) error) error {
+	if r.meta.IPVersion == 4 {
+		return r.walk(0, make(net.IP, net.IPv4len), 0, fn)
+	}
+	if err := r.walk(r.ipv4Start, make(net.IP, net.IPv4len), 0, fn); err != nil {
+		return err
+	}
+	return r.walk(0, make(net.IP, net.IPv6len), 0, fn)
+}
+
+func (r *mmdbReader) walk(record uint, ip net.IP, depth int, fn func(*net.IPNet, uint) error) error {
+	nodeCount := r.meta.NodeCount
+	switch {
+	case record == nodeCount:
+		return nil
+	case record > nodeCount:
+		if len(ip) == net.IPv6len && ip.To4() != nil {
+			// IPv4-mapped addresses are covered by the IPv4 walk
+			return nil
+		}
+		offset, err := r.dataOffset(record)
+		if err != nil {
+			return err
+		}
+		network := &net.IPNet{IP: append(net.IP(nil), ip...), Mask: net.CIDRMask(depth, len(ip)*8)}
+		return fn(network, offset)
+	case len(ip) == net.IPv6len && depth > 0 && record == r.ipv4Start:
+		return nil
+	case depth >= len(ip)*8:
+		return fmt.Errorf("search tree is deeper than an address")
+	}
+
+	for bit := uint(0); bit < 2; bit++ {
+		child, err := r.record(record, bit)
+		if err != nil {
+			return err
+		}
+		if bit == 1 {
+			ip[depth/8] |= 0x80 >> (depth % 8)
+		}
+		if err := r.walk(child, ip, depth+1, fn); err != nil {
+			return err
+		}
+		ip[depth/8] &^= 0x80 >> (depth % 8)
+	}
+	return nil
+}
+
+// mmdbDecoder decodes fields of a data section. Pointers are offsets from
+// the start of the section.
+type mmdbDecoder []byte
+
+// decode returns the field at offset and the offset following it. Maps
+// decode to map[string]interface{}, arrays to []interface{}, unsigned
+// integers up to 64 bits to uint64 and uint128 to *big.Int.
+func (d mmdbDecoder) decode(offset uint, depth int) (interface{}, uint, error) {
+	if depth > mmdbMaxDepth {
+		return nil, 0, fmt.Errorf("data nested too deeply")
+	}
+	typ, size, offset, err := d.control(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if typ == mmdbPointer {
+		target, next, err := d.pointer(size, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, _, err := d.decode(target, depth+1)
+		return value, next, err
+	}
+
+	switch typ {
+	case mmdbMap:
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			key, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			name, ok := key.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("map key at offset %d is not a string", offset)
+			}
+			if m[name], offset, err = d.decode(next, depth+1); err != nil {
+				return nil, 0, err
+			}
+		}
+		return m, offset, nil
+	case mmdbArray:
+		a := make([]interface{}, 0, size)
+		for i := uint(0); i < size; i++ {
+			value, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, value)
+			offset = next
+		}
+		return a, offset, nil
+	case mmdbBool:
+		return size != 0, offset, nil
+	}
+
+	if offset+size > uint(len(d)) {
+		return nil, 0, fmt.Errorf("field at offset %d runs past the end of the data", offset)
+	}
+	b := d[offset : offset+size]
+	next := offset + size
+	switch typ {
+	case mmdbString:
+		return string(b), next, nil
+	case mmdbBytes:
+		return append([]byte(nil), b...), next, nil
+	case mmdbDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("invalid double size %d", size)
+		}
+		return math.Float64frombits(uint64(mmdbUintBytes(b))), next, nil
+	case mmdbFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("invalid float size %d", size)
+		}
+		return math.Float32frombits(uint32(mmdbUintBytes(b))), next, nil
+	case mmdbUint16, mmdbUint32, mmdbUint64:
+		if size > 8 {
+			return nil, 0, fmt.Errorf("invalid integer size %d", size)
+		}
+		return mmdbUintBytes(b), next, nil
+	case mmdbInt32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("invalid integer size %d", size)
+		}
+		return int64(int32(uint32(mmdbUintBytes(b)))), next, nil
+	case mmdbUint128:
+		return new(big.Int).SetBytes(b), next, nil
+	}
+	return nil, 0, fmt.Errorf("unsupported data type %d at offset %d", typ, offset)
+}
+
+// control decodes the control byte of a field, returning its type, its
+// size (the raw size bits for pointers) and the offset of its payload.
+func (d mmdbDecoder) control(offset uint) (typ, size, next uint, err error) {
+	if offset >= uint(len(d)) {
+		return 0, 0, 0, fmt.Errorf("offset %d is past the end of the data", offset)
+	}
+	ctrl := d[offset]
+	offset++
+	typ = uint(ctrl >> 5)
+	if typ == mmdbExtended {
+		if offset >= uint(len(d)) {
+			return 0, 0, 0, fmt.Errorf("truncated extended type at offset %d", offset)
+		}
+		typ = 7 + uint(d[offset])
+		offset++
+	}
+	size = uint(ctrl & 0x1f)
+	if typ == mmdbPointer || size < 29 {
+		return typ, size, offset, nil
+	}
+
+	n := size - 28
+	if offset+n > uint(len(d)) {
+		return 0, 0, 0, fmt.Errorf("truncated size at offset %d", offset)
+	}
+	extra := uint(mmdbUintBytes(d[offset : offset+n]))
+	switch size {
+	case 29:
+		size = 29 + extra
+	case 30:
+		size = 285 + extra
+	default:
+		size = 65821 + extra
+	}
+	return typ, size, offset + n, nil
+}
+
+// pointer decodes the target of a pointer field from its size bits.
+func (d mmdbDecoder) pointer(size, offset uint) (target, next uint, err error) {
+	n := (size>>3)&3 + 1
+	if offset+n > uint(len(d)) {
+		return 0, 0, fmt.Errorf("truncated pointer at offset %d", offset)
+	}
+	target = size & 7
+	if n == 4 {
+		target = 0
+	}
+	target = target<<(8*n) | uint(mmdbUintBytes(d[offset:offset+n]))
+	switch n {
+	case 2:
+		target += 2048
+	case 3:
+		target += 526336
+	}
+	return target, offset + n, nil
+}
+
+// mmdbUintBytes decodes a big-endian unsigned integer of up to 8 bytes.
+func mmdbUintBytes(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+// mmdbUint returns a decoded unsigned integer, or 0 for anything else.
+func mmdbUint(value interface{}) uint64 {
+	v, _ := value.(uint64)
+	return v
+}
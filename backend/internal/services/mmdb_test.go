@@ -0,0 +1,273 @@
+package services
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// testMMDBNode is a search tree node of a database built by testMMDB. A
+// nil child is an empty record and a child with a country a data record.
+type testMMDBNode struct {
+	children [2]*testMMDBNode
+	country  string
+}
+
+// testMMDB builds small MaxMind DB files mapping networks to countries.
+type testMMDB struct {
+	t         *testing.T
+	ipVersion int
+	root      *testMMDBNode
+}
+
+func newTestMMDB(t *testing.T, ipVersion int) *testMMDB {
+	return &testMMDB{t: t, ipVersion: ipVersion, root: &testMMDBNode{}}
+}
+
+// path returns the bits leading to a network in the tree. IPv4 networks
+// of IPv6 databases live under ::/96.
+func (m *testMMDB) path(cidr string) []uint8 {
+	m.t.Helper()
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		m.t.Fatalf("invalid test network %s: %v", cidr, err)
+	}
+	ones, _ := network.Mask.Size()
+	addr := []byte(network.IP)
+	if len(addr) == net.IPv4len && m.ipVersion == 6 {
+		addr = append(make([]byte, 12), addr...)
+		ones += 96
+	}
+	bits := make([]uint8, ones)
+	for i := range bits {
+		bits[i] = addr[i/8] >> (7 - i%8) & 1
+	}
+	return bits
+}
+
+// node returns the node reached by bits, creating missing nodes.
+func (m *testMMDB) node(bits []uint8) *testMMDBNode {
+	node := m.root
+	for _, b := range bits {
+		if node.children[b] == nil {
+			node.children[b] = &testMMDBNode{}
+		}
+		node = node.children[b]
+	}
+	return node
+}
+
+func (m *testMMDB) insert(cidr, country string) {
+	bits := m.path(cidr)
+	parent := m.node(bits[:len(bits)-1])
+	parent.children[bits[len(bits)-1]] = &testMMDBNode{country: country}
+}
+
+// alias points an IPv6 network at the IPv4 space, like GeoLite2 does for
+// ::ffff:0:0/96 and 2002::/16.
+func (m *testMMDB) alias(cidr string) {
+	ipv4 := m.node(make([]uint8, 96))
+	bits := m.path(cidr)
+	parent := m.node(bits[:len(bits)-1])
+	parent.children[bits[len(bits)-1]] = ipv4
+}
+
+var testCountryNames = map[string]string{"AU": "Australia", "DE": "Germany", "NZ": "New Zealand"}
+
+func (m *testMMDB) bytes() []byte {
+	// Number the nodes breadth first; aliased nodes are numbered once
+	index := make(map[*testMMDBNode]int)
+	var nodes []*testMMDBNode
+	for queue := []*testMMDBNode{m.root}; len(queue) > 0; queue = queue[1:] {
+		n := queue[0]
+		if _, ok := index[n]; ok {
+			continue
+		}
+		index[n] = len(nodes)
+		nodes = append(nodes, n)
+		for _, c := range n.children {
+			if c != nil && c.country == "" {
+				queue = append(queue, c)
+			}
+		}
+	}
+
+	var data []byte
+	offsets := make(map[string]int)
+	var tree []byte
+	for _, n := range nodes {
+		for _, c := range n.children {
+			record := len(nodes)
+			switch {
+			case c == nil:
+			case c.country != "":
+				if _, ok := offsets[c.country]; !ok {
+					offsets[c.country] = len(data)
+					data = append(data, testMMDBMap(
+						"country", testMMDBMap(
+							"iso_code", testMMDBString(c.country),
+							"names", testMMDBMap("en", testMMDBString(testCountryNames[c.country])),
+						),
+					)...)
+				}
+				record = len(nodes) + mmdbDataSeparator + offsets[c.country]
+			default:
+				record = index[c]
+			}
+			tree = append(tree, byte(record>>16), byte(record>>8), byte(record))
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.Write(tree)
+	buf.Write(make([]byte, mmdbDataSeparator))
+	buf.Write(data)
+	buf.Write(mmdbMetadataMarker)
+	buf.Write(testMMDBMap(
+		"node_count", testMMDBUint(mmdbUint32, uint64(len(nodes))),
+		"record_size", testMMDBUint(mmdbUint16, 24),
+		"ip_version", testMMDBUint(mmdbUint16, uint64(m.ipVersion)),
+		"database_type", testMMDBString("UPM-Test-Country"),
+		"build_epoch", testMMDBUint(mmdbUint64, 1700000000),
+	))
+	return buf.Bytes()
+}
+
+// write saves the database in a temporary directory and returns its path.
+func (m *testMMDB) write() string {
+	m.t.Helper()
+	path := filepath.Join(m.t.TempDir(), "country.mmdb")
+	if err := os.WriteFile(path, m.bytes(), 0644); err != nil {
+		m.t.Fatalf("failed to write test database: %v", err)
+	}
+	return path
+}
+
+func testMMDBControl(typ uint, size int) []byte {
+	if typ > mmdbMap {
+		return []byte{byte(size), byte(typ - 7)}
+	}
+	return []byte{byte(typ<<5) | byte(size)}
+}
+
+func testMMDBString(s string) []byte {
+	return append(testMMDBControl(mmdbString, len(s)), s...)
+}
+
+func testMMDBUint(typ uint, v uint64) []byte {
+	var b []byte
+	for ; v > 0; v >>= 8 {
+		b = append([]byte{byte(v)}, b...)
+	}
+	return append(testMMDBControl(typ, len(b)), b...)
+}
+
+// testMMDBMap encodes a map from alternating keys and encoded values.
+func testMMDBMap(pairs ...interface{}) []byte {
+	out := testMMDBControl(mmdbMap, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		out = append(out, testMMDBString(pairs[i].(string))...)
+		out = append(out, pairs[i+1].([]byte)...)
+	}
+	return out
+}
+
+func newTestCountryDB(t *testing.T) *testMMDB {
+	db := newTestMMDB(t, 6)
+	db.insert("1.2.3.0/24", "AU")
+	db.insert("5.6.0.0/16", "DE")
+	db.insert("2001:db8::/32", "DE")
+	db.alias("::ffff:0:0/96")
+	db.alias("2002::/16")
+	return db
+}
+
+func TestMMDBReader_LookupAndNetworks(t *testing.T) {
+	reader, err := parseMMDB(newTestCountryDB(t).bytes())
+	if err != nil {
+		t.Fatalf("parseMMDB returned error: %v", err)
+	}
+	if reader.meta.DatabaseType != "UPM-Test-Country" || reader.meta.BuildEpoch != 1700000000 {
+		t.Errorf("unexpected metadata: %+v", reader.meta)
+	}
+
+	cases := []struct {
+		ip        string
+		found     bool
+		prefixLen int
+	}{
+		{"1.2.3.4", true, 24},
+		{"::ffff:5.6.7.8", true, 16},
+		{"2001:db8::1", true, 32},
+		{"8.8.8.8", false, 0},
+		{"2001:4860::8888", false, 0},
+	}
+	for _, tc := range cases {
+		_, prefixLen, found, err := reader.lookup(net.ParseIP(tc.ip))
+		if err != nil {
+			t.Errorf("lookup(%s) returned error: %v", tc.ip, err)
+			continue
+		}
+		if found != tc.found || (found && prefixLen != tc.prefixLen) {
+			t.Errorf("lookup(%s) = found %v /%d, want found %v /%d", tc.ip, found, prefixLen, tc.found, tc.prefixLen)
+		}
+	}
+
+	var networks []string
+	err = reader.networks(func(network *net.IPNet, offset uint) error {
+		networks = append(networks, network.String())
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("networks returned error: %v", err)
+	}
+	sort.Strings(networks)
+	want := []string{"1.2.3.0/24", "2001:db8::/32", "5.6.0.0/16"}
+	if len(networks) != len(want) {
+		t.Fatalf("networks = %v, want %v (aliases must be skipped)", networks, want)
+	}
+	for i := range want {
+		if networks[i] != want[i] {
+			t.Errorf("networks[%d] = %s, want %s", i, networks[i], want[i])
+		}
+	}
+}
+
+func TestMMDBReader_IPv4Database(t *testing.T) {
+	db := newTestMMDB(t, 4)
+	db.insert("10.0.0.0/8", "DE")
+	reader, err := parseMMDB(db.bytes())
+	if err != nil {
+		t.Fatalf("parseMMDB returned error: %v", err)
+	}
+	if _, prefixLen, found, err := reader.lookup(net.ParseIP("10.1.2.3")); err != nil || !found || prefixLen != 8 {
+		t.Errorf("lookup(10.1.2.3) = found %v /%d, %v; want found /8", found, prefixLen, err)
+	}
+	if _, _, _, err := reader.lookup(net.ParseIP("2001:db8::1")); err == nil {
+		t.Errorf("expected IPv6 lookups in an IPv4 database to fail")
+	}
+}
+
+func TestMMDBDecoder_PointersAndLongFields(t *testing.T) {
+	long := bytes.Repeat([]byte("x"), 300)
+	data := testMMDBString("DE")        // offset 0
+	data = append(data, 0x20, 0x00)     // offset 3: pointer to offset 0
+	data = append(data, 0x5e, 0x00, 15) // offset 5: string of 285+15 bytes
+	data = append(data, long...)
+
+	value, next, err := mmdbDecoder(data).decode(3, 0)
+	if err != nil || value != "DE" || next != 5 {
+		t.Errorf("decode(pointer) = %v, %d, %v; want DE, 5, nil", value, next, err)
+	}
+	value, next, err = mmdbDecoder(data).decode(5, 0)
+	if err != nil || value != string(long) || next != uint(len(data)) {
+		t.Errorf("decode(long string) = %d bytes, next %d, %v; want 300 bytes, next %d", len(value.(string)), next, err, len(data))
+	}
+
+	if _, err := parseMMDB([]byte("not a database")); err == nil {
+		t.Errorf("expected parseMMDB to reject a file without metadata")
+	}
+}
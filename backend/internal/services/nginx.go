@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"fmt"
 	"net"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"text/template"
 
@@ -14,57 +16,127 @@ import (
 )
 
 type NginxService struct {
-	ConfigPath       string
-	ReloadCommand    string
-	TemplatePath     string
-	ContainerName    string
-	DatabaseService  *DatabaseService
-	SitesEnabledPath string
+	ConfigPath         string
+	ReloadCommand      string
+	TemplatePath       string
+	StreamTemplatePath string
+	SNITemplatePath    string // SNI router for passthrough proxies
+	ContainerName      string
+	DatabaseService    *DatabaseService
+	SitesEnabledPath   string
+	StreamsEnabledPath string
+	CachePath          string // proxy_cache directories, shared with nginx
+	StaticRootPath     string // static host roots, as mounted in nginx
+	// BackendURL is the UPM backend as reached from nginx, used by proxies
+	// that require UPM login.
+	BackendURL string
+	// GeoIP maps client addresses to countries for proxy country rules.
+	GeoIP *GeoIPService
+	// InternalCA provides the chains and CRLs of internal CAs that proxies
+	// verify client certificates against.
+	InternalCA *InternalCAService
+	// HTTP3Enabled gives the HTTPS servers of proxies that opt in a QUIC
+	// listener; HTTP3AltSvcPort is the UDP port advertised to clients.
+	HTTP3Enabled    bool
+	HTTP3AltSvcPort string
+	// MainConfigPath is nginx.conf as seen by nginx; staged configurations
+	// are validated against a copy of it.
+	MainConfigPath string
+
+	stage      *configStage           // file changes of the apply in progress
+	testStaged func(dir string) error // replaces nginx -t on staged configs in tests
 }
 
 func NewNginxService(configPath, reloadCommand, containerName string, dbService *DatabaseService) *NginxService {
 	return &NginxService{
-		ConfigPath:       configPath,
-		ReloadCommand:    reloadCommand,
-		TemplatePath:     filepath.Join(configPath, "proxy-template.conf"),
-		ContainerName:    containerName,
-		DatabaseService:  dbService,
-		SitesEnabledPath: "/etc/nginx/sites-enabled",
+		ConfigPath:         configPath,
+		ReloadCommand:      reloadCommand,
+		TemplatePath:       filepath.Join(configPath, "proxy-template.conf"),
+		StreamTemplatePath: filepath.Join(configPath, "stream-template.conf"),
+		SNITemplatePath:    filepath.Join(configPath, "passthrough-template.conf"),
+		ContainerName:      containerName,
+		DatabaseService:    dbService,
+		SitesEnabledPath:   "/etc/nginx/sites-enabled",
+		StreamsEnabledPath: "/etc/nginx/streams-enabled",
+		CachePath:          "/var/cache/nginx/upm",
+		StaticRootPath:     "/var/www/sites",
+		BackendURL:         "http://backend:6080",
+		HTTP3AltSvcPort:    "443",
+		MainConfigPath:     "/etc/nginx/nginx.conf",
 	}
 }
 
 // GenerateProxyConfig generates nginx configuration for a proxy
 func (n *NginxService) GenerateProxyConfig(proxy *models.Proxy) error {
+	rendered, err := n.renderProxyConfig(proxy)
+	if err != nil {
+		return err
+	}
+
+	// Persist the SSL state the certificate files allowed
+	if n.DatabaseService != nil && rendered.sslEnabled != proxy.SSLEnabled {
+		proxy.SSLEnabled = rendered.sslEnabled
+		if rendered.sslEnabled {
+			proxy.SSLPath = rendered.certPath
+		}
+		if err := n.DatabaseService.UpdateProxy(proxy); err != nil {
+			fmt.Printf("Failed to persist SSL state for %s: %v\n", proxy.Domain, err)
+		} else if rendered.sslEnabled {
+			fmt.Printf("Auto-enabled SSL for %s based on valid certificate files\n", proxy.Domain)
+		}
+	}
+
+	// Write config file
+	configFile := filepath.Join(n.ConfigPath, fmt.Sprintf("proxy-%d.conf", proxy.ID))
+	if err := n.writeFile(configFile, rendered.config); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+
+	// Copy config file to sites-enabled directory (shared volume)
+	enabledPath := filepath.Join(n.SitesEnabledPath, fmt.Sprintf("proxy-%d.conf", proxy.ID))
+	if err := n.writeFile(enabledPath, rendered.config); err != nil {
+		return fmt.Errorf("failed to copy config to sites-enabled: %w", err)
+	}
+
+	if err := n.syncCacheZones(); err != nil {
+		return err
+	}
+	if err := n.syncRateLimitZones(); err != nil {
+		return err
+	}
+	if err := n.syncGeoIPConfig(); err != nil {
+		return err
+	}
+	if err := n.syncHTTP3Reuseport(proxy.ID, rendered.http3); err != nil {
+		return err
+	}
+
+	// The proxy may have joined or left passthrough mode
+	return n.syncPassthroughConfig()
+}
+
+// renderedProxyConfig is the output of renderProxyConfig.
+type renderedProxyConfig struct {
+	config     []byte
+	sslEnabled bool   // SSL state the certificate files allow
+	certPath   string // certificate the config uses
+	http3      *http3TemplateData
+}
+
+// renderProxyConfig renders the nginx config of a proxy without writing it
+// or changing the proxy. The files the config refers to, such as htpasswd
+// files and error pages, are written through n, so a preview renders on a
+// copy of the service with a stage of its own.
+func (n *NginxService) renderProxyConfig(proxy *models.Proxy) (*renderedProxyConfig, error) {
 	// Read the template
 	tmpl, err := template.ParseFiles(n.TemplatePath)
 	if err != nil {
-		return fmt.Errorf("failed to parse template: %w", err)
+		return nil, fmt.Errorf("failed to parse template: %w", err)
 	}
 
-	// Get allowed IP ranges and backend configuration from DNS record
-	var allowedRanges []string
-	var includeBackend bool
-	var backendURL string
-	if n.DatabaseService != nil {
-		dnsRecord, err := n.DatabaseService.GetDNSRecordByDomain(proxy.Domain)
-		if err != nil {
-			return fmt.Errorf("failed to get DNS record for domain %s: %w", proxy.Domain, err)
-		}
-		if dnsRecord != nil {
-			// Parse comma-separated IP ranges
-			if dnsRecord.AllowedIPRanges != "" {
-				ranges := strings.Split(dnsRecord.AllowedIPRanges, ",")
-				for _, r := range ranges {
-					r = strings.TrimSpace(r)
-					if r != "" {
-						allowedRanges = append(allowedRanges, r)
-					}
-				}
-			}
-			// Get backend configuration
-			includeBackend = dnsRecord.IncludeBackend
-			backendURL = dnsRecord.BackendURL
-		}
+	accessLists, err := n.loadAccessLists(proxy)
+	if err != nil {
+		return nil, err
 	}
 
 	// Prepare template data
@@ -73,7 +145,7 @@ func (n *NginxService) GenerateProxyConfig(proxy *models.Proxy) error {
 	keyPath := fmt.Sprintf("/etc/ssl/certs/%s.key", proxy.Domain)
 	var hasCertInDB bool
 	if n.DatabaseService != nil {
-		cert, err := n.DatabaseService.GetCertificateByDomain(proxy.Domain)
+		cert, err := n.DatabaseService.GetCertificateForProxy(proxy)
 		if err != nil {
 			fmt.Printf("Certificate lookup for %s: %v\n", proxy.Domain, err)
 		} else if cert != nil {
@@ -93,22 +165,23 @@ func (n *NginxService) GenerateProxyConfig(proxy *models.Proxy) error {
 	sslEnabled := proxy.SSLEnabled
 	fmt.Printf("Proxy %s: initial SSL state=%v, hasCertInDB=%v\n", proxy.Domain, sslEnabled, hasCertInDB)
 
+	if sslEnabled && proxy.IsPassthrough() {
+		return nil, fmt.Errorf("cannot enable SSL for %s: passthrough proxies leave TLS to the backend", proxy.Domain)
+	}
+	// While any proxy is in passthrough mode the stream SNI router owns
+	// port 443, and the HTTPS server listens behind it instead.
+	sniFallback, err := n.sniFallbackAddress()
+	if err != nil {
+		return nil, err
+	}
+
 	// If proxy not marked SSL but certificate exists in DB, check files and auto-enable if valid
-	if !sslEnabled && hasCertInDB {
+	if !sslEnabled && hasCertInDB && !proxy.IsPassthrough() {
 		certValid := isValidPEMFile(certPath, "CERTIFICATE")
 		keyValid := isValidPEMFile(keyPath, "PRIVATE KEY")
 		fmt.Printf("Checking cert files for %s: cert=%v (path: %s), key=%v (path: %s)\n", proxy.Domain, certValid, certPath, keyValid, keyPath)
 		if certValid && keyValid {
 			sslEnabled = true
-			if n.DatabaseService != nil {
-				proxy.SSLEnabled = true
-				proxy.SSLPath = certPath
-				if err := n.DatabaseService.UpdateProxy(proxy); err != nil {
-					fmt.Printf("Failed to persist SSL enable for %s: %v\n", proxy.Domain, err)
-				} else {
-					fmt.Printf("Auto-enabled SSL for %s based on valid certificate files\n", proxy.Domain)
-				}
-			}
 		} else {
 			fmt.Printf("Certificate exists in DB for %s but files are invalid - SSL not enabled. cert=%v, key=%v\n", proxy.Domain, certValid, keyValid)
 		}
@@ -121,83 +194,795 @@ func (n *NginxService) GenerateProxyConfig(proxy *models.Proxy) error {
 		if !certValid || !keyValid {
 			fmt.Printf("SSL disabled for %s: certificate files invalid (cert=%v, key=%v). Paths: %s, %s\n", proxy.Domain, certValid, keyValid, certPath, keyPath)
 			sslEnabled = false
-			// Update DB to reflect disabled state
-			if n.DatabaseService != nil {
-				proxy.SSLEnabled = false
-				_ = n.DatabaseService.UpdateProxy(proxy)
-			}
 		}
 	}
 
-	sanitizedRanges := sanitizeAllowedRanges(allowedRanges)
+	// With an upstream pool, proxy_pass names the pool instead of TargetURL.
+	// Passthrough proxies get their pool in the SNI router instead.
+	var upstream *upstreamTemplateData
+	if !proxy.IsPassthrough() {
+		upstream = buildUpstreamTemplateData(proxy)
+	}
+	passTarget := proxy.TargetURL
+	if upstream != nil {
+		passTarget = upstream.Scheme + "://" + upstream.Name
+	}
 
+	var canonicalHost string
+	if proxy.CanonicalRedirect && len(proxy.Aliases) > 0 {
+		canonicalHost = strings.ToLower(proxy.Domain)
+	}
+
+	basicAuthFiles, err := n.writeBasicAuthFiles(proxy)
+	if err != nil {
+		return nil, err
+	}
+	// The limit zones themselves are declared in the shared include
+	rateLimit, locationRateLimits := buildRateLimitTemplateData(proxy)
+	rateLimitServer := buildRateLimitServerTemplateData(proxy)
+	locations := buildLocationTemplateData(proxy.Locations, basicAuthFiles, accessLists, locationRateLimits)
+	maintenance, err := n.writeMaintenancePage(proxy)
+	if err != nil {
+		return nil, err
+	}
+	var upmAuthURL string
+	if proxy.RequireUPMLogin {
+		upmAuthURL = strings.TrimSuffix(n.BackendURL, "/")
+	}
+	forwardAuth := buildForwardAuthTemplateData(proxy, n.BackendURL)
+	clientAuth, err := n.writeClientCAFile(proxy)
+	if err != nil {
+		return nil, err
+	}
+	// Maintenance, login redirects and custom rate limit responses keep
+	// their own error_page for 503, 401 and the rejection status
+	ownedCodes := make(map[int]bool)
+	if maintenance != nil {
+		ownedCodes[503] = true
+	}
+	if upmAuthURL != "" || (forwardAuth != nil && forwardAuth.SignIn != "") {
+		ownedCodes[401] = true
+	}
+	if rateLimitServer != nil && rateLimitServer.Respond {
+		ownedCodes[rateLimitServer.Status] = true
+	}
+	errorPages, err := n.writeErrorPages(proxy, ownedCodes)
+	if err != nil {
+		return nil, err
+	}
+	requestHeaders := buildRequestHeaders(proxy.HeaderRules, forwardAuth, clientAuth)
+	responseHeaders := buildResponseHeaders(nil, proxy.HeaderRules)
+	tls := buildTLSTemplateData(proxy)
+	http3, err := n.buildHTTP3TemplateData(proxy, sslEnabled)
+	if err != nil {
+		return nil, err
+	}
+	httpsDefaults := securityHeaders(proxy.EffectiveTLSPolicy().HSTS)
+	if http3 != nil {
+		httpsDefaults = append(httpsDefaults, n.altSvcHeader())
+	}
+	httpsHeaders := buildResponseHeaders(httpsDefaults, proxy.HeaderRules)
+	cache := buildCacheTemplateData(proxy)
+	if cache != nil && proxy.EffectiveCachePolicy().StatusHeader {
+		responseHeaders = addCacheStatusHeader(responseHeaders)
+		httpsHeaders = addCacheStatusHeader(httpsHeaders)
+	}
 	data := struct {
-		Domain           string
-		TargetURL        string
-		SSLEnabled       bool
-		WSEnabled        bool
-		SSLPath          string
-		CertPath         string
-		KeyPath          string
-		AllowedRanges    []string
-		IncludeBackend   bool
-		BackendURL       string
-		RateLimitEnabled bool
-		RateLimitZone    string
-		RateLimitRPS     int
-		RateLimitBurst   int
+		Domain          string
+		ServerNames     string
+		CanonicalHost   string // set when aliases redirect to Domain
+		TargetURL       string
+		Upstream        *upstreamTemplateData
+		SSLEnabled      bool
+		Passthrough     bool   // only redirects port 80; TLS is routed by SNI
+		SNIFallback     string // listen address behind the SNI router, empty to listen on 443
+		WSEnabled       bool
+		SSLPath         string
+		CertPath        string
+		KeyPath         string
+		AccessList      *accessListTemplateData // nil when every address is allowed
+		BasicAuthFile   string                  // htpasswd path, empty when the host is open
+		UPMAuthURL      string                  // UPM backend for auth_request, empty unless login is required
+		ForwardAuth     *forwardAuthTemplateData
+		GeoIP           *geoIPTemplateData      // nil when every country is let in
+		ClientAuth      *clientAuthTemplateData // nil when client certificates are not verified
+		TLS             *tlsTemplateData
+		HTTP3           *http3TemplateData // nil when the HTTPS server has no QUIC listener
+		AuthRequest     bool               // some auth_request is active; ACME opts out
+		RequestHeaders  []headerDirective  // proxy_set_header lines of every proxying location
+		ResponseHeaders []headerDirective  // response header rules for the HTTP server
+		HTTPSHeaders    []headerDirective  // security headers with the response rules applied
+		Cache           *cacheTemplateData
+		CacheRoot       bool // location / is cached
+		Maintenance     *maintenanceTemplateData
+		ErrorPages      *errorPagesTemplateData
+		Redirect        *redirectTemplateData // set for redirect hosts
+		Static          *staticTemplateData   // set for static hosts
+		Locations       []locationTemplateData
+		RateLimit       *rateLimitTemplateData       // limit of location /, nil when off
+		RateLimitServer *rateLimitServerTemplateData // set when anything is limited
 	}{
-		Domain:           proxy.Domain,
-		TargetURL:        proxy.TargetURL,
-		SSLEnabled:       sslEnabled,
-		WSEnabled:        proxy.WSEnabled,
-		SSLPath:          "/etc/nginx/ssl",
-		CertPath:         certPath,
-		KeyPath:          keyPath,
-		AllowedRanges:    sanitizedRanges,
-		IncludeBackend:   includeBackend,
-		BackendURL:       backendURL,
-		RateLimitEnabled: proxy.RateLimitEnabled,
-		RateLimitZone:    fmt.Sprintf("proxy_%d", proxy.ID),
-		RateLimitRPS:     proxy.RateLimitRPS,
-		RateLimitBurst:   proxy.RateLimitRPS * 2,
+		Domain:          proxy.Domain,
+		ServerNames:     strings.Join(proxy.ServerNames(), " "),
+		CanonicalHost:   canonicalHost,
+		TargetURL:       passTarget,
+		Upstream:        upstream,
+		SSLEnabled:      sslEnabled,
+		Passthrough:     proxy.IsPassthrough(),
+		SNIFallback:     sniFallback,
+		WSEnabled:       proxy.WSEnabled,
+		SSLPath:         "/etc/nginx/ssl",
+		CertPath:        certPath,
+		KeyPath:         keyPath,
+		AccessList:      accessLists[proxy.AccessListID],
+		BasicAuthFile:   basicAuthFiles[proxy.BasicAuthSetID],
+		UPMAuthURL:      upmAuthURL,
+		ForwardAuth:     forwardAuth,
+		GeoIP:           buildGeoIPTemplateData(proxy),
+		ClientAuth:      clientAuth,
+		TLS:             tls,
+		HTTP3:           http3,
+		AuthRequest:     upmAuthURL != "" || forwardAuth != nil,
+		RequestHeaders:  requestHeaders,
+		ResponseHeaders: responseHeaders,
+		HTTPSHeaders:    httpsHeaders,
+		Cache:           cache,
+		CacheRoot:       cache != nil && proxy.CacheEnabled,
+		Maintenance:     maintenance,
+		ErrorPages:      errorPages,
+		Redirect:        buildRedirectTemplateData(proxy),
+		Static:          n.buildStaticTemplateData(proxy),
+		Locations:       locations,
+		RateLimit:       rateLimit,
+		RateLimitServer: rateLimitServer,
 	}
 
 	// Generate config content
 	var buf bytes.Buffer
 	if err := tmpl.Execute(&buf, data); err != nil {
-		return fmt.Errorf("failed to execute template: %w", err)
+		return nil, fmt.Errorf("failed to execute template: %w", err)
 	}
 
-	// Write config file
-	configFile := filepath.Join(n.ConfigPath, fmt.Sprintf("proxy-%d.conf", proxy.ID))
-	if err := os.WriteFile(configFile, buf.Bytes(), 0644); err != nil {
-		return fmt.Errorf("failed to write config file: %w", err)
+	return &renderedProxyConfig{config: buf.Bytes(), sslEnabled: sslEnabled, certPath: certPath, http3: http3}, nil
+}
+
+// headerDirective is one rendered proxy_set_header or add_header line.
+// Value is either quoted or a bare nginx variable.
+type headerDirective struct {
+	Name   string
+	Value  string // empty for a removed response header
+	Always bool   // add_header ... always
+	Hide   bool   // proxy_hide_header the upstream's copy first
+}
+
+// defaultRequestHeaders are sent to the upstream by every proxying location.
+var defaultRequestHeaders = []headerDirective{
+	{Name: "Host", Value: "$host"},
+	{Name: "X-Real-IP", Value: "$remote_addr"},
+	{Name: "X-Forwarded-For", Value: "$proxy_add_x_forwarded_for"},
+	{Name: "X-Forwarded-Proto", Value: "$scheme"},
+}
+
+// defaultSecurityHeaders are added to every response of an HTTPS server
+// unless a response header rule overrides or removes them. The proxy's
+// TLS policy replaces the Strict-Transport-Security header.
+var defaultSecurityHeaders = []headerDirective{
+	{Name: "Strict-Transport-Security", Value: `"max-age=31536000; includeSubDomains"`, Always: true},
+	{Name: "X-Frame-Options", Value: "DENY"},
+	{Name: "X-Content-Type-Options", Value: "nosniff"},
+	{Name: "X-XSS-Protection", Value: `"1; mode=block"`},
+}
+
+// quoteNginxValue renders s as a double-quoted nginx string. Backslashes and
+// quotes are escaped so the value cannot end the string early; nginx
+// variables are still expanded.
+func quoteNginxValue(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+// indexHeader returns the position of the header named name, compared
+// case-insensitively, or -1.
+func indexHeader(headers []headerDirective, name string) int {
+	for i, h := range headers {
+		if strings.EqualFold(h.Name, name) {
+			return i
+		}
 	}
+	return -1
+}
 
-	// Copy config file to sites-enabled directory (shared volume)
-	enabledPath := filepath.Join(n.SitesEnabledPath, fmt.Sprintf("proxy-%d.conf", proxy.ID))
-	if err := os.WriteFile(enabledPath, buf.Bytes(), 0644); err != nil {
-		return fmt.Errorf("failed to copy config to sites-enabled: %w", err)
+// buildRequestHeaders merges the standard upstream headers with the proxy's
+// request header rules. A rule replaces the standard header of the same
+// name in place; removing a header sets it to "" so nginx drops it, even
+// when the client sent it. Forward-auth identity headers and the client
+// certificate DN come last and win over any rule, so clients cannot spoof
+// them.
+func buildRequestHeaders(rules []models.ProxyHeaderRule, forwardAuth *forwardAuthTemplateData, clientAuth *clientAuthTemplateData) []headerDirective {
+	headers := append([]headerDirective(nil), defaultRequestHeaders...)
+	set := func(h headerDirective) {
+		if i := indexHeader(headers, h.Name); i >= 0 {
+			headers[i] = h
+			return
+		}
+		headers = append(headers, h)
 	}
 
-	return nil
+	for _, rule := range rules {
+		if rule.Direction != models.HeaderDirectionRequest {
+			continue
+		}
+		value := `""`
+		if rule.Action == models.HeaderActionSet {
+			value = quoteNginxValue(rule.Value)
+		}
+		set(headerDirective{Name: rule.Name, Value: value})
+	}
+	if forwardAuth != nil {
+		for _, h := range forwardAuth.Headers {
+			set(headerDirective{Name: h.Name, Value: h.Var})
+		}
+	}
+	if clientAuth != nil && clientAuth.DNHeader != "" {
+		set(headerDirective{Name: clientAuth.DNHeader, Value: "$ssl_client_s_dn"})
+	}
+	return headers
+}
+
+// buildResponseHeaders applies the proxy's response header rules on top of
+// defaults. Set and removed headers also hide the upstream's own copy, so
+// a rule truly overrides the header instead of duplicating it.
+func buildResponseHeaders(defaults []headerDirective, rules []models.ProxyHeaderRule) []headerDirective {
+	headers := append([]headerDirective(nil), defaults...)
+	for _, rule := range rules {
+		if rule.Direction != models.HeaderDirectionResponse {
+			continue
+		}
+		h := headerDirective{Name: rule.Name, Hide: true}
+		if rule.Action == models.HeaderActionSet {
+			h.Value = quoteNginxValue(rule.Value)
+			h.Always = rule.Always
+		}
+		if i := indexHeader(headers, h.Name); i >= 0 {
+			headers[i] = h
+		} else {
+			headers = append(headers, h)
+		}
+	}
+	return headers
+}
+
+// forwardAuthTemplateData is the rendered form of a proxy's external
+// forward auth.
+type forwardAuthTemplateData struct {
+	URL     string
+	SignIn  string // UPM backend endpoint redirecting to the sign-in URL, empty to answer 401
+	Headers []forwardAuthHeader
+}
+
+// forwardAuthHeader is a response header copied from the auth service to
+// the upstream through an nginx variable.
+type forwardAuthHeader struct {
+	Name     string // header name sent upstream
+	Upstream string // $upstream_http_ variable holding the auth response header
+	Var      string // variable set by auth_request_set
+}
+
+// buildForwardAuthTemplateData turns forward auth settings into template
+// data, or returns nil when forward auth is off. Settings are assumed to
+// have passed models.ValidateForwardAuth.
+//
+// nginx cannot URL-encode the original URL into the sign-in redirect, so a
+// 401 is answered by the UPM backend, which builds the redirect from the
+// proxy's stored sign-in URL.
+func buildForwardAuthTemplateData(proxy *models.Proxy, backendURL string) *forwardAuthTemplateData {
+	fa := proxy.ForwardAuth
+	if !fa.Enabled() {
+		return nil
+	}
+
+	data := &forwardAuthTemplateData{URL: fa.URL}
+	if fa.SignInURL != "" {
+		data.SignIn = fmt.Sprintf("%s/api/v1/auth/forward-auth/%d/sign-in", strings.TrimSuffix(backendURL, "/"), proxy.ID)
+	}
+	for _, name := range fa.ResponseHeaders {
+		key := strings.ToLower(strings.ReplaceAll(name, "-", "_"))
+		data.Headers = append(data.Headers, forwardAuthHeader{
+			Name:     name,
+			Upstream: "$upstream_http_" + key,
+			Var:      "$upm_auth_" + key,
+		})
+	}
+	return data
+}
+
+// locationTemplateData is the rendered form of a proxy location rule.
+type locationTemplateData struct {
+	Modifier      string // "", "= " or "~ "
+	Path          string
+	Rewrite       string // rewrite regex and replacement, empty for none
+	ProxyPass     string
+	WSEnabled     bool
+	RateLimit     *rateLimitTemplateData  // nil when the rule is not limited
+	BasicAuthFile string                  // htpasswd path overriding the host's, if any
+	AccessList    *accessListTemplateData // overrides the host's, if any
+	CacheEnabled  bool
+}
+
+// buildLocationTemplateData turns location rules into template data. Rules
+// are assumed to have passed models.ValidateProxyLocations. Whenever the
+// request path is rewritten, proxy_pass carries only the target's origin so
+// nginx forwards the rewritten URI unchanged. basicAuthFiles maps credential
+// set IDs to their htpasswd paths, accessLists maps access list IDs to
+// their rendered lists and rateLimits holds the limit of each rule.
+func buildLocationTemplateData(rules []models.ProxyLocation, basicAuthFiles map[int]string, accessLists map[int]*accessListTemplateData, rateLimits []*rateLimitTemplateData) []locationTemplateData {
+	var locations []locationTemplateData
+	for i, rule := range rules {
+		target, err := url.Parse(rule.TargetURL)
+		if err != nil || target.Host == "" {
+			continue
+		}
+		origin := target.Scheme + "://" + target.Host
+
+		loc := locationTemplateData{
+			Path:          rule.Path,
+			ProxyPass:     rule.TargetURL,
+			WSEnabled:     rule.WSEnabled,
+			RateLimit:     rateLimits[i],
+			BasicAuthFile: basicAuthFiles[rule.BasicAuthSetID],
+			AccessList:    accessLists[rule.AccessListID],
+			CacheEnabled:  rule.CacheEnabled,
+		}
+
+		switch rule.MatchType {
+		case models.LocationMatchExact:
+			loc.Modifier = "= "
+			if rule.Rewrite != "" {
+				loc.Rewrite = "^ " + rule.Rewrite
+				loc.ProxyPass = origin
+			}
+		case models.LocationMatchRegex:
+			loc.Modifier = "~ "
+			loc.ProxyPass = origin
+			if rule.Rewrite != "" {
+				loc.Rewrite = rule.Path + " " + rule.Rewrite
+			}
+		default:
+			// Match the prefix with or without its trailing slash and keep
+			// the remainder of the path in $1.
+			pattern := "^" + regexp.QuoteMeta(strings.TrimSuffix(rule.Path, "/")) + "/?(.*)$"
+			if rule.StripPrefix {
+				loc.Rewrite = pattern + " " + strings.TrimSuffix(target.Path, "/") + "/$1"
+				loc.ProxyPass = origin
+			} else if rule.Rewrite != "" {
+				loc.Rewrite = pattern + " " + strings.TrimSuffix(rule.Rewrite, "/") + "/$1"
+				loc.ProxyPass = origin
+			}
+		}
+
+		locations = append(locations, loc)
+	}
+	return locations
+}
+
+// upstreamTemplateData is the rendered form of a proxy's upstream pool.
+type upstreamTemplateData struct {
+	Name    string
+	Scheme  string
+	Method  string // empty for round-robin
+	Servers []upstreamServerTemplateData
+}
+
+type upstreamServerTemplateData struct {
+	Address string // host:port
+	Params  string // pre-rendered server parameters, each with a leading space
+}
+
+// buildUpstreamTemplateData turns a proxy's pool into template data, or
+// returns nil when the proxy has no pool and proxies straight to TargetURL.
+// Members are assumed to have passed models.ValidateUpstreamServers.
+func buildUpstreamTemplateData(proxy *models.Proxy) *upstreamTemplateData {
+	if len(proxy.UpstreamServers) == 0 {
+		return nil
+	}
+
+	upstream := &upstreamTemplateData{
+		Name: fmt.Sprintf("proxy_%d_upstream", proxy.ID),
+	}
+	if proxy.LoadBalanceMethod != models.LoadBalanceRoundRobin {
+		upstream.Method = proxy.LoadBalanceMethod
+	}
+
+	for _, s := range proxy.UpstreamServers {
+		parsed, err := url.Parse(s.URL)
+		if err != nil || parsed.Host == "" {
+			continue
+		}
+		if upstream.Scheme == "" {
+			upstream.Scheme = parsed.Scheme
+		}
+
+		address := parsed.Host
+		if parsed.Port() == "" {
+			port := "80"
+			if parsed.Scheme == "https" {
+				port = "443"
+			}
+			address = net.JoinHostPort(parsed.Hostname(), port)
+		}
+
+		var params strings.Builder
+		if s.Weight > 0 {
+			fmt.Fprintf(&params, " weight=%d", s.Weight)
+		}
+		if s.MaxFails > 0 {
+			fmt.Fprintf(&params, " max_fails=%d", s.MaxFails)
+		}
+		if s.FailTimeout > 0 {
+			fmt.Fprintf(&params, " fail_timeout=%ds", s.FailTimeout)
+		}
+		if s.Backup {
+			params.WriteString(" backup")
+		}
+
+		upstream.Servers = append(upstream.Servers, upstreamServerTemplateData{
+			Address: address,
+			Params:  params.String(),
+		})
+	}
+
+	if len(upstream.Servers) == 0 {
+		return nil
+	}
+	return upstream
 }
 
 // RemoveProxyConfig removes nginx configuration for a proxy
 func (n *NginxService) RemoveProxyConfig(proxyID int) error {
 	// Remove config file from sites-enabled directory (shared volume)
 	enabledPath := filepath.Join(n.SitesEnabledPath, fmt.Sprintf("proxy-%d.conf", proxyID))
-	if err := os.Remove(enabledPath); err != nil && !os.IsNotExist(err) {
+	if err := n.removeFile(enabledPath); err != nil {
 		return fmt.Errorf("failed to remove config from sites-enabled: %w", err)
 	}
 
 	// Remove config file from backend container
 	configFile := filepath.Join(n.ConfigPath, fmt.Sprintf("proxy-%d.conf", proxyID))
-	if err := os.Remove(configFile); err != nil && !os.IsNotExist(err) {
+	if err := n.removeFile(configFile); err != nil {
 		return fmt.Errorf("failed to remove config file: %w", err)
 	}
 
+	if err := n.removeStaleBasicAuthFiles(proxyID, nil); err != nil {
+		return err
+	}
+	if err := n.removeMaintenancePage(proxyID); err != nil {
+		return err
+	}
+	if err := n.removeErrorPages(proxyID); err != nil {
+		return err
+	}
+	if err := n.removeClientCAFile(proxyID); err != nil {
+		return err
+	}
+
+	if err := n.syncCacheZones(); err != nil {
+		return err
+	}
+	if err := n.syncRateLimitZones(); err != nil {
+		return err
+	}
+	if err := n.syncGeoIPConfig(); err != nil {
+		return err
+	}
+	// The cached responses cannot be restored, so they are only purged
+	// once the proxy is gone for good
+	err := n.afterApply(func() error {
+		if err := os.RemoveAll(n.proxyCacheDir(proxyID)); err != nil {
+			return fmt.Errorf("failed to remove cache directory: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := n.syncHTTP3Reuseport(proxyID, nil); err != nil {
+		return err
+	}
+
+	return n.syncPassthroughConfig()
+}
+
+// basicAuthFileName is the htpasswd file for one credential set of a proxy,
+// written next to proxy-<id>.conf. It does not end in .conf, so nginx's
+// sites-enabled include skips it.
+func basicAuthFileName(proxyID, setID int) string {
+	return fmt.Sprintf("proxy-%d-auth-%d.htpasswd", proxyID, setID)
+}
+
+// writeBasicAuthFiles writes an htpasswd file for every credential set the
+// proxy uses and returns the path nginx should read for each set ID. Files
+// for sets the proxy no longer uses are removed.
+func (n *NginxService) writeBasicAuthFiles(proxy *models.Proxy) (map[int]string, error) {
+	setIDs := make(map[int]bool)
+	if proxy.BasicAuthSetID != 0 {
+		setIDs[proxy.BasicAuthSetID] = true
+	}
+	for _, loc := range proxy.Locations {
+		if loc.BasicAuthSetID != 0 {
+			setIDs[loc.BasicAuthSetID] = true
+		}
+	}
+
+	files := make(map[int]string)
+	for setID := range setIDs {
+		name := basicAuthFileName(proxy.ID, setID)
+		files[setID] = filepath.Join(n.SitesEnabledPath, name)
+
+		if n.DatabaseService == nil {
+			continue
+		}
+		set, err := n.DatabaseService.GetBasicAuthSet(setID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load basic auth set %d: %w", setID, err)
+		}
+		content := renderHtpasswd(set.Users)
+		if err := n.writeFile(filepath.Join(n.ConfigPath, name), content); err != nil {
+			return nil, fmt.Errorf("failed to write htpasswd file: %w", err)
+		}
+		if err := n.writeFile(files[setID], content); err != nil {
+			return nil, fmt.Errorf("failed to copy htpasswd file to sites-enabled: %w", err)
+		}
+	}
+
+	if err := n.removeStaleBasicAuthFiles(proxy.ID, setIDs); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// removeStaleBasicAuthFiles deletes a proxy's htpasswd files except those
+// for the sets in keep.
+func (n *NginxService) removeStaleBasicAuthFiles(proxyID int, keep map[int]bool) error {
+	for _, dir := range []string{n.SitesEnabledPath, n.ConfigPath} {
+		matches, err := n.globFiles(filepath.Join(dir, fmt.Sprintf("proxy-%d-auth-*.htpasswd", proxyID)))
+		if err != nil {
+			return fmt.Errorf("failed to list htpasswd files: %w", err)
+		}
+		for _, path := range matches {
+			var setID int
+			if _, err := fmt.Sscanf(filepath.Base(path), "proxy-%d-auth-%d.htpasswd", new(int), &setID); err == nil && keep[setID] {
+				continue
+			}
+			if err := n.removeFile(path); err != nil {
+				return fmt.Errorf("failed to remove htpasswd file: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// renderHtpasswd renders users as htpasswd lines of username:bcrypt-hash.
+func renderHtpasswd(users []models.BasicAuthUser) []byte {
+	var buf bytes.Buffer
+	for _, u := range users {
+		fmt.Fprintf(&buf, "%s:%s\n", u.Username, u.PasswordHash)
+	}
+	return buf.Bytes()
+}
+
+// passthroughConfigName is the stream config shared by all passthrough proxies.
+const passthroughConfigName = "sni-passthrough.conf"
+
+// passthroughRouteData is one proxy's entry in the SNI router.
+type passthroughRouteData struct {
+	ServerNames []string
+	Upstream    *upstreamTemplateData
+}
+
+// GeneratePassthroughConfig renders the stream {} SNI router that listens on
+// port 443 and forwards each TLS connection, still encrypted, to the proxy
+// whose server names match the ClientHello. Any other connection goes to the
+// http servers behind the router. With no passthrough proxies the router is
+// removed so the http servers can bind 443 again.
+func (n *NginxService) GeneratePassthroughConfig(proxies []models.Proxy) error {
+	var routes []passthroughRouteData
+	for i := range proxies {
+		if route := buildPassthroughRouteData(&proxies[i]); route != nil {
+			routes = append(routes, *route)
+		}
+	}
+
+	configFile := filepath.Join(n.ConfigPath, passthroughConfigName)
+	enabledPath := filepath.Join(n.StreamsEnabledPath, passthroughConfigName)
+	if len(routes) == 0 {
+		for _, path := range []string{enabledPath, configFile} {
+			if err := n.removeFile(path); err != nil {
+				return fmt.Errorf("failed to remove passthrough config: %w", err)
+			}
+		}
+		return nil
+	}
+
+	tmpl, err := template.ParseFiles(n.SNITemplatePath)
+	if err != nil {
+		return fmt.Errorf("failed to parse passthrough template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	data := struct {
+		Routes      []passthroughRouteData
+		Fallback    string
+		Passthrough string
+	}{
+		Routes:      routes,
+		Fallback:    sniFallbackListen,
+		Passthrough: sniPassthroughListen,
+	}
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return fmt.Errorf("failed to execute passthrough template: %w", err)
+	}
+
+	if err := n.writeFile(configFile, buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write passthrough config file: %w", err)
+	}
+	if err := n.writeFile(enabledPath, buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to copy passthrough config to streams-enabled: %w", err)
+	}
+
+	return nil
+}
+
+// syncPassthroughConfig regenerates the SNI router from the database. When
+// the router appears or goes away, the SSL proxies and a generated admin
+// config are re-rendered so their HTTPS servers move between port 443 and
+// the router's fallback listener.
+func (n *NginxService) syncPassthroughConfig() error {
+	if n.DatabaseService == nil {
+		return nil
+	}
+	proxies, err := n.DatabaseService.GetPassthroughProxies()
+	if err != nil {
+		return fmt.Errorf("failed to load passthrough proxies: %w", err)
+	}
+
+	routerPath := filepath.Join(n.StreamsEnabledPath, passthroughConfigName)
+	hadRouter := n.fileExists(routerPath)
+
+	if err := n.GeneratePassthroughConfig(proxies); err != nil {
+		return err
+	}
+
+	if hadRouter == n.fileExists(routerPath) {
+		return nil
+	}
+	all, err := n.DatabaseService.GetProxies()
+	if err != nil {
+		return fmt.Errorf("failed to load proxies: %w", err)
+	}
+	for i := range all {
+		if !all[i].SSLEnabled || all[i].IsPassthrough() {
+			continue
+		}
+		if err := n.GenerateProxyConfig(&all[i]); err != nil {
+			return fmt.Errorf("failed to move HTTPS server of %s: %w", all[i].Domain, err)
+		}
+	}
+	if !n.fileExists(filepath.Join(n.ConfigPath, "upm-admin.conf")) {
+		return nil
+	}
+	allowedRanges, err := n.GetAdminIPRestrictions()
+	if err != nil {
+		return err
+	}
+	return n.UpdateAdminConfig(allowedRanges)
+}
+
+// The SNI router sends every connection on with a PROXY protocol header
+// that carries the client address. Connections for passthrough proxies go
+// through sniPassthroughListen, which strips the header again before the
+// backend sees it; all others go to the http servers on sniFallbackListen.
+// Unix sockets keep both hops off the TCP ports stream proxies may use.
+const (
+	sniFallbackListen    = "unix:/var/run/nginx-upm-https.sock"
+	sniPassthroughListen = "unix:/var/run/nginx-upm-passthrough.sock"
+)
+
+// sniFallbackAddress returns the address TLS-terminating http servers
+// listen on: the SNI router's fallback while passthrough proxies exist, or
+// "" for port 443.
+func (n *NginxService) sniFallbackAddress() (string, error) {
+	if n.DatabaseService == nil {
+		return "", nil
+	}
+	proxies, err := n.DatabaseService.GetPassthroughProxies()
+	if err != nil {
+		return "", fmt.Errorf("failed to load passthrough proxies: %w", err)
+	}
+	if len(proxies) == 0 {
+		return "", nil
+	}
+	return sniFallbackListen, nil
+}
+
+// buildPassthroughRouteData reuses the pool rendering for a passthrough
+// proxy; a proxy without a pool becomes a single-member pool of TargetURL.
+// The stream upstream has no ip_hash, so it is replaced by a consistent
+// hash of the client address.
+func buildPassthroughRouteData(proxy *models.Proxy) *passthroughRouteData {
+	pool := *proxy
+	if len(pool.UpstreamServers) == 0 {
+		pool.UpstreamServers = []models.UpstreamServer{{URL: proxy.TargetURL}}
+	}
+	upstream := buildUpstreamTemplateData(&pool)
+	if upstream == nil {
+		return nil
+	}
+	upstream.Name = fmt.Sprintf("passthrough_%d_upstream", proxy.ID)
+	if upstream.Method == models.LoadBalanceIPHash {
+		upstream.Method = "hash $remote_addr consistent"
+	}
+
+	names := make([]string, 0, len(proxy.Aliases)+1)
+	for _, name := range proxy.ServerNames() {
+		names = append(names, strings.ToLower(name))
+	}
+	return &passthroughRouteData{ServerNames: names, Upstream: upstream}
+}
+
+// GenerateStreamConfig generates the nginx stream {} configuration for a
+// TCP/UDP stream proxy
+func (n *NginxService) GenerateStreamConfig(stream *models.StreamProxy) error {
+	tmpl, err := template.ParseFiles(n.StreamTemplatePath)
+	if err != nil {
+		return fmt.Errorf("failed to parse stream template: %w", err)
+	}
+
+	data := struct {
+		ID            int
+		ListenPort    int
+		UDP           bool
+		Targets       []string
+		AllowedRanges []string
+		ProxyTimeout  int
+	}{
+		ID:            stream.ID,
+		ListenPort:    stream.ListenPort,
+		UDP:           stream.Protocol == models.StreamProtocolUDP,
+		Targets:       stream.Targets,
+		AllowedRanges: sanitizeAllowedRanges(strings.Split(stream.AllowedIPRanges, ",")),
+		ProxyTimeout:  stream.ProxyTimeout,
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return fmt.Errorf("failed to execute stream template: %w", err)
+	}
+
+	configFile := filepath.Join(n.ConfigPath, fmt.Sprintf("stream-%d.conf", stream.ID))
+	if err := n.writeFile(configFile, buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write stream config file: %w", err)
+	}
+
+	enabledPath := filepath.Join(n.StreamsEnabledPath, fmt.Sprintf("stream-%d.conf", stream.ID))
+	if err := n.writeFile(enabledPath, buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to copy stream config to streams-enabled: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveStreamConfig removes nginx configuration for a stream proxy
+func (n *NginxService) RemoveStreamConfig(streamID int) error {
+	enabledPath := filepath.Join(n.StreamsEnabledPath, fmt.Sprintf("stream-%d.conf", streamID))
+	if err := n.removeFile(enabledPath); err != nil {
+		return fmt.Errorf("failed to remove stream config from streams-enabled: %w", err)
+	}
+
+	configFile := filepath.Join(n.ConfigPath, fmt.Sprintf("stream-%d.conf", streamID))
+	if err := n.removeFile(configFile); err != nil {
+		return fmt.Errorf("failed to remove stream config file: %w", err)
+	}
+
 	return nil
 }
 
@@ -373,24 +1158,13 @@ func isValidPEMFile(path string, marker string) bool {
 	return strings.Contains(content, needle)
 }
 
-// UpdateProxyConfig updates nginx configuration for a proxy
+// UpdateProxyConfig regenerates the nginx configuration of an existing
+// proxy. Like GenerateProxyConfig it only writes files; the apply it runs
+// in validates them and reloads nginx.
 func (n *NginxService) UpdateProxyConfig(proxy *models.Proxy) error {
-	// Remove old config from sites-enabled directory (shared volume)
-	enabledPath := filepath.Join(n.SitesEnabledPath, fmt.Sprintf("proxy-%d.conf", proxy.ID))
-	if err := os.Remove(enabledPath); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("failed to remove old config from sites-enabled: %w", err)
-	}
-
-	// Generate new config (this will overwrite the existing config file and copy to sites-enabled)
 	if err := n.GenerateProxyConfig(proxy); err != nil {
 		return fmt.Errorf("failed to generate new config: %w", err)
 	}
-
-	// Reload nginx to apply the new configuration
-	if err := n.ReloadNginx(); err != nil {
-		return fmt.Errorf("failed to reload nginx: %w", err)
-	}
-
 	return nil
 }
 
@@ -405,11 +1179,19 @@ func (n *NginxService) UpdateAdminConfig(allowedRanges []string) error {
 		return fmt.Errorf("failed to parse admin template: %w", err)
 	}
 
+	// The admin HTTPS server moves behind the SNI router while it holds 443
+	sniFallback, err := n.sniFallbackAddress()
+	if err != nil {
+		return err
+	}
+
 	// Prepare template data
 	data := struct {
 		AllowedRanges []string
+		SNIFallback   string
 	}{
 		AllowedRanges: allowedRanges,
+		SNIFallback:   sniFallback,
 	}
 
 	// Generate the configuration
@@ -419,7 +1201,7 @@ func (n *NginxService) UpdateAdminConfig(allowedRanges []string) error {
 	}
 
 	// Write to the output file
-	if err := os.WriteFile(outputPath, buf.Bytes(), 0644); err != nil {
+	if err := n.writeFile(outputPath, buf.Bytes()); err != nil {
 		return fmt.Errorf("failed to write admin config: %w", err)
 	}
 
@@ -455,7 +1237,7 @@ func (n *NginxService) copyConfigToContainer(localPath, containerPath string) er
 func (n *NginxService) GetAdminIPRestrictions() ([]string, error) {
 	// Read the current config from the local file
 	configPath := filepath.Join(n.ConfigPath, "upm-admin.conf")
-	content, err := os.ReadFile(configPath)
+	content, err := n.readFile(configPath)
 	if err != nil {
 		// If the processed config doesn't exist, return default ranges
 		return []string{"192.168.50.0/24", "10.6.0.1/32"}, nil
@@ -463,15 +1245,18 @@ func (n *NginxService) GetAdminIPRestrictions() ([]string, error) {
 
 	// Parse the config to extract allow directives
 	var allowedRanges []string
+	seen := make(map[string]bool)
 	lines := strings.Split(string(content), "\n")
 	
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
 		if strings.HasPrefix(line, "allow ") {
-			// Extract the IP range after "allow "
+			// Extract the IP range after "allow "; the HTTP and HTTPS
+			// servers repeat the same list
 			rangeStr := strings.TrimSpace(strings.TrimPrefix(line, "allow"))
 			rangeStr = strings.TrimSuffix(rangeStr, ";")
-			if rangeStr != "" {
+			if rangeStr != "" && !seen[rangeStr] {
+				seen[rangeStr] = true
 				allowedRanges = append(allowedRanges, rangeStr)
 			}
 		}
@@ -483,7 +1268,7 @@ func (n *NginxService) GetAdminIPRestrictions() ([]string, error) {
 // processConfigWithEnvSubst processes a config file using envsubst to substitute environment variables
 func (n *NginxService) processConfigWithEnvSubst(inputPath, outputPath string) error {
 	// Read the input file
-	content, err := os.ReadFile(inputPath)
+	content, err := n.readFile(inputPath)
 	if err != nil {
 		return fmt.Errorf("failed to read input file: %w", err)
 	}
@@ -500,7 +1285,7 @@ func (n *NginxService) processConfigWithEnvSubst(inputPath, outputPath string) e
 	}
 
 	// Write the processed content to the output file
-	if err := os.WriteFile(outputPath, buf.Bytes(), 0644); err != nil {
+	if err := n.writeFile(outputPath, buf.Bytes()); err != nil {
 		return fmt.Errorf("failed to write processed config: %w", err)
 	}
 
@@ -0,0 +1,499 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// applyMu serializes applies: nginx has a single configuration, and each
+// apply validates it as a whole.
+var applyMu sync.Mutex
+
+// stagingDirName is the directory under sites-enabled where an apply lays
+// out the configuration it validates. Hidden names are not matched by the
+// *.conf includes of nginx.conf, so the live configuration never sees it.
+const stagingDirName = ".upm-staging"
+
+// ConfigTestError reports that nginx -t rejected a configuration. Output is
+// the output of nginx with staging paths mapped back to the live ones.
+type ConfigTestError struct {
+	Output string
+}
+
+func (e *ConfigTestError) Error() string {
+	return "nginx config test failed: " + strings.TrimSpace(e.Output)
+}
+
+// stagedFile is the pending content of one file, or its removal.
+type stagedFile struct {
+	data    []byte
+	removed bool
+}
+
+// configStage collects the file changes of one apply until they have been
+// validated and are swapped into place.
+type configStage struct {
+	files       map[string]*stagedFile
+	dirs        []string       // directories removed once their files are gone
+	afterCommit []func() error // cleanups run once the apply has committed
+}
+
+func newConfigStage() *configStage {
+	return &configStage{files: make(map[string]*stagedFile)}
+}
+
+// previousFile is the content a swap replaced.
+type previousFile struct {
+	path    string
+	data    []byte
+	existed bool
+}
+
+// configSwap records the files a committed stage replaced so the swap can
+// be undone.
+type configSwap struct {
+	previous []previousFile
+}
+
+// Apply commits a change to the database and to the nginx configuration
+// together. update runs in a database transaction; render then generates
+// the affected configs with a service bound to that transaction, whose file
+// writes are staged instead of touching the live configuration. The staged
+// configuration is validated with nginx -t, swapped into place file by file
+// and nginx is reloaded; a proxy whose own config fails validation is
+// quarantined instead of failing the apply. If any step fails, the
+// previous files are restored and the transaction is rolled back. Either
+// function may be nil; with neither, Apply validates and reloads the
+// current configuration.
+func (n *NginxService) Apply(update func(db *DatabaseService) error, render func(staged *NginxService) error) error {
+	applyMu.Lock()
+	defer applyMu.Unlock()
+
+	var swap *configSwap
+	var stage *configStage
+	run := func(db *DatabaseService) error {
+		if update != nil {
+			if err := update(db); err != nil {
+				return err
+			}
+		}
+
+		staged := *n
+		staged.DatabaseService = db
+		staged.stage = newConfigStage()
+		if render != nil {
+			if err := render(&staged); err != nil {
+				return fmt.Errorf("failed to generate nginx config: %w", err)
+			}
+		}
+
+		if err := n.testAndQuarantine(&staged); err != nil {
+			return err
+		}
+		var err error
+		if swap, err = staged.stage.commit(); err != nil {
+			return fmt.Errorf("failed to swap nginx config: %w", err)
+		}
+		if err := n.ReloadNginx(); err != nil {
+			n.restoreSwap(swap, false)
+			swap = nil
+			return fmt.Errorf("failed to reload nginx: %w", err)
+		}
+		stage = staged.stage
+		return nil
+	}
+
+	var err error
+	if n.DatabaseService == nil {
+		err = run(nil)
+	} else if err = n.DatabaseService.InTransaction(run); err != nil && swap != nil {
+		// nginx already runs the new files but the transaction did not
+		// commit
+		n.restoreSwap(swap, true)
+	}
+	if err != nil {
+		return err
+	}
+
+	// The change is live and cannot be undone anymore, so a failed cleanup
+	// is only logged
+	for _, cleanup := range stage.afterCommit {
+		if err := cleanup(); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}
+	return nil
+}
+
+// restoreSwap puts back the files a swap replaced, reloading nginx when it
+// had already picked them up. Failures are logged: the caller is already
+// reporting the error that made the rollback necessary.
+func (n *NginxService) restoreSwap(swap *configSwap, reload bool) {
+	if err := swap.restore(); err != nil {
+		fmt.Printf("Warning: failed to restore nginx config: %v\n", err)
+		return
+	}
+	if reload {
+		if err := n.ReloadNginx(); err != nil {
+			fmt.Printf("Warning: failed to reload restored nginx config: %v\n", err)
+		}
+	}
+}
+
+// stagingDir is where testStage lays out the configuration under test.
+func (n *NginxService) stagingDir() string {
+	return filepath.Join(n.SitesEnabledPath, stagingDirName)
+}
+
+// testStage validates the configuration nginx would load once the staged
+// changes of staged were swapped in. The sites-enabled and streams-enabled
+// configs are laid out in the staging directory and nginx -t is run against
+// a copy of the main config whose includes point there instead.
+//
+// Other staged files, such as client CA bundles, are not live yet either:
+// they are copied under the staging directory at their absolute path, and
+// the staged configs refer to those copies.
+func (n *NginxService) testStage(staged *NginxService) error {
+	dir := n.stagingDir()
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to clear staging directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	replacer, err := stageSupportFiles(staged, filepath.Join(dir, "files"))
+	if err != nil {
+		return err
+	}
+	for _, layout := range []struct{ live, sub string }{
+		{n.SitesEnabledPath, "sites"},
+		{n.StreamsEnabledPath, "streams"},
+	} {
+		target := filepath.Join(dir, layout.sub)
+		if err := os.MkdirAll(target, 0755); err != nil {
+			return fmt.Errorf("failed to create staging directory: %w", err)
+		}
+		paths, err := staged.globFiles(filepath.Join(layout.live, "*.conf"))
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			data, err := staged.readFile(path)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", path, err)
+			}
+			data = []byte(replacer.Replace(string(data)))
+			if err := os.WriteFile(filepath.Join(target, filepath.Base(path)), data, 0644); err != nil {
+				return fmt.Errorf("failed to stage %s: %w", path, err)
+			}
+		}
+	}
+
+	if n.testStaged != nil {
+		return n.testStaged(dir)
+	}
+	return n.runStagedTest(dir)
+}
+
+// stageSupportFiles copies every staged file that is not a .conf under
+// root, keeping its absolute path, and returns the replacer that points
+// configs at the copies. Longer paths are replaced first so a path never
+// matches the start of another.
+func stageSupportFiles(staged *NginxService, root string) (*strings.Replacer, error) {
+	var paths []string
+	for path, file := range staged.stage.files {
+		if !file.removed && filepath.Ext(path) != ".conf" {
+			paths = append(paths, path)
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool { return len(paths[i]) > len(paths[j]) })
+
+	var pairs []string
+	for _, path := range paths {
+		target := filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create staging directory: %w", err)
+		}
+		if err := os.WriteFile(target, staged.stage.files[path].data, 0644); err != nil {
+			return nil, fmt.Errorf("failed to stage %s: %w", path, err)
+		}
+		pairs = append(pairs, path, target)
+	}
+	return strings.NewReplacer(pairs...), nil
+}
+
+// runStagedTest runs nginx -t on the staged configuration, inside the nginx
+// container when nginx runs in Docker. It is skipped when nginx is not
+// available, like TestNginxConfig.
+func (n *NginxService) runStagedTest(dir string) error {
+	mainConfig := filepath.Join(dir, "nginx.conf")
+	script := fmt.Sprintf("sed -e %s -e %s %s > %s && nginx -t -c %s",
+		shellQuote("s#"+n.SitesEnabledPath+"/#"+filepath.Join(dir, "sites")+"/#"),
+		shellQuote("s#"+n.StreamsEnabledPath+"/#"+filepath.Join(dir, "streams")+"/#"),
+		shellQuote(n.MainConfigPath), shellQuote(mainConfig), shellQuote(mainConfig))
+
+	var cmd *exec.Cmd
+	if n.isDockerEnvironment() {
+		cmd = exec.Command("docker", "exec", n.ContainerName, "sh", "-c", script)
+	} else {
+		if _, err := exec.LookPath("nginx"); err != nil {
+			// nginx not available, skip test
+			return nil
+		}
+		cmd = exec.Command("sh", "-c", script)
+	}
+
+	output, err := cmd.CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	if nginxUnavailable(string(output), err) {
+		fmt.Printf("Nginx not available, skipping config test: %s\n", string(output))
+		return nil
+	}
+	out := string(output)
+	out = strings.ReplaceAll(out, filepath.Join(dir, "files"), "")
+	out = strings.ReplaceAll(out, filepath.Join(dir, "sites"), n.SitesEnabledPath)
+	out = strings.ReplaceAll(out, filepath.Join(dir, "streams"), n.StreamsEnabledPath)
+	return &ConfigTestError{Output: out}
+}
+
+// nginxUnavailable reports whether a failed nginx command never reached
+// nginx because the container or the binary is missing.
+func nginxUnavailable(output string, err error) bool {
+	if errors.Is(err, exec.ErrNotFound) {
+		return true
+	}
+	return strings.Contains(output, "No such container") ||
+		strings.Contains(output, "is not running") ||
+		strings.Contains(output, "nginx: not found") ||
+		strings.Contains(output, "nginx: command not found")
+}
+
+// shellQuote quotes s as a single sh word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// writeFile writes a generated file, staging it during an apply.
+// Otherwise the file is replaced atomically.
+func (n *NginxService) writeFile(path string, data []byte) error {
+	if n.stage != nil {
+		n.stage.files[path] = &stagedFile{data: data}
+		return nil
+	}
+	return writeFileAtomic(path, data)
+}
+
+// removeFile removes a generated file, staging the removal during an apply.
+// A missing file is not an error.
+func (n *NginxService) removeFile(path string) error {
+	if n.stage != nil {
+		n.stage.files[path] = &stagedFile{removed: true}
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// removeDir removes a directory of generated files, staging the removal of
+// every file in it during an apply.
+func (n *NginxService) removeDir(dir string) error {
+	if n.stage == nil {
+		return os.RemoveAll(dir)
+	}
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !entry.IsDir() {
+			n.stage.files[path] = &stagedFile{removed: true}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	prefix := dir + string(filepath.Separator)
+	for path, file := range n.stage.files {
+		if strings.HasPrefix(path, prefix) {
+			file.removed = true
+			file.data = nil
+		}
+	}
+	n.stage.dirs = append(n.stage.dirs, dir)
+	return nil
+}
+
+// afterApply runs cleanup once the apply in progress has committed, or right
+// away outside of an apply. It is for changes that cannot be staged or
+// restored, such as purging a cache.
+func (n *NginxService) afterApply(cleanup func() error) error {
+	if n.stage != nil {
+		n.stage.afterCommit = append(n.stage.afterCommit, cleanup)
+		return nil
+	}
+	return cleanup()
+}
+
+// readFile reads a generated file as the apply in progress would leave it.
+func (n *NginxService) readFile(path string) ([]byte, error) {
+	if n.stage != nil {
+		if file, ok := n.stage.files[path]; ok {
+			if file.removed {
+				return nil, &fs.PathError{Op: "open", Path: path, Err: fs.ErrNotExist}
+			}
+			return file.data, nil
+		}
+	}
+	return os.ReadFile(path)
+}
+
+// fileExists reports whether a generated file exists as the apply in
+// progress would leave it.
+func (n *NginxService) fileExists(path string) bool {
+	if n.stage != nil {
+		if file, ok := n.stage.files[path]; ok {
+			return !file.removed
+		}
+	}
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// globFiles lists the files matching pattern as the apply in progress would
+// leave them, sorted.
+func (n *NginxService) globFiles(pattern string) ([]string, error) {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[string]bool)
+	for _, path := range matches {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			set[path] = true
+		}
+	}
+	if n.stage != nil {
+		for path, file := range n.stage.files {
+			if ok, _ := filepath.Match(pattern, path); ok {
+				set[path] = !file.removed
+			}
+		}
+	}
+
+	var paths []string
+	for path, exists := range set {
+		if exists {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// commit swaps the staged files into place. Unchanged files are left
+// alone. If a file cannot be swapped, the ones already swapped are
+// restored.
+func (s *configStage) commit() (*configSwap, error) {
+	paths := make([]string, 0, len(s.files))
+	for path := range s.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	swap := &configSwap{}
+	for _, path := range paths {
+		prev := previousFile{path: path}
+		data, err := os.ReadFile(path)
+		switch {
+		case err == nil:
+			prev.data, prev.existed = data, true
+		case !os.IsNotExist(err):
+			swap.restore()
+			return nil, err
+		}
+
+		file := s.files[path]
+		if file.removed {
+			if !prev.existed {
+				continue
+			}
+			err = os.Remove(path)
+		} else {
+			if prev.existed && bytes.Equal(prev.data, file.data) {
+				continue
+			}
+			err = writeFileAtomic(path, file.data)
+		}
+		if err != nil {
+			swap.restore()
+			return nil, err
+		}
+		swap.previous = append(swap.previous, prev)
+	}
+
+	// Only succeeds for directories the swap emptied
+	for _, dir := range s.dirs {
+		os.Remove(dir)
+	}
+	return swap, nil
+}
+
+// restore puts back the files the swap replaced, newest first.
+func (s *configSwap) restore() error {
+	var firstErr error
+	for i := len(s.previous) - 1; i >= 0; i-- {
+		prev := s.previous[i]
+		var err error
+		if prev.existed {
+			err = writeFileAtomic(prev.path, prev.data)
+		} else if err = os.Remove(prev.path); os.IsNotExist(err) {
+			err = nil
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// writeFileAtomic replaces path with data through a temporary file in the
+// same directory, so nginx never reads a partially written file. The
+// temporary name is hidden and does not end in .conf.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"upm-backend/internal/models"
+)
+
+// newTestDatabaseService opens a fresh SQLite database in a temp
+// directory.
+func newTestDatabaseService(t *testing.T) *DatabaseService {
+	t.Helper()
+
+	t.Setenv("GO_ENV", "development")
+	t.Setenv("DB_PATH", filepath.Join(t.TempDir(), "upm.db"))
+	db, err := NewDatabaseService()
+	if err != nil {
+		t.Fatalf("NewDatabaseService returned error: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestApply_ValidatesStagedConfigBeforeSwapping(t *testing.T) {
+	svc := newTestNginxService(t)
+	enabledFile := filepath.Join(svc.SitesEnabledPath, "proxy-1.conf")
+
+	tested := false
+	svc.testStaged = func(dir string) error {
+		tested = true
+		if _, err := os.Stat(enabledFile); !os.IsNotExist(err) {
+			t.Errorf("expected live config to be untouched during the test, stat err = %v", err)
+		}
+		staged, err := os.ReadFile(filepath.Join(dir, "sites", "proxy-1.conf"))
+		if err != nil {
+			t.Fatalf("expected staged config: %v", err)
+		}
+		if !strings.Contains(string(staged), "server_name app.example.com") {
+			t.Errorf("staged config is missing the server, got:\n%s", staged)
+		}
+		return nil
+	}
+
+	proxy := &models.Proxy{ID: 1, Domain: "app.example.com", TargetURL: "http://backend:8080"}
+	err := svc.Apply(nil, func(staged *NginxService) error {
+		return staged.GenerateProxyConfig(proxy)
+	})
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	if !tested {
+		t.Fatal("expected the staged config to be tested")
+	}
+	if _, err := os.Stat(enabledFile); err != nil {
+		t.Errorf("expected config to be swapped into sites-enabled: %v", err)
+	}
+	if _, err := os.Stat(svc.stagingDir()); !os.IsNotExist(err) {
+		t.Errorf("expected staging directory to be removed, stat err = %v", err)
+	}
+}
+
+func TestApply_StagedConfigUsesStagedClientCABundle(t *testing.T) {
+	svc := newTestNginxService(t)
+	svc.DatabaseService = newTestDatabaseService(t)
+
+	caPEM, _ := newTestCAPEM(t, "Client Root CA", time.Now().Add(24*time.Hour))
+	proxy := newTestSSLProxy(t, svc, "admin", "admin.example.com")
+	proxy.ClientAuth = &models.ClientAuth{Mode: models.ClientAuthModeOn, CABundle: caPEM}
+	if err := svc.DatabaseService.CreateProxy(proxy); err != nil {
+		t.Fatal(err)
+	}
+	liveBundle := filepath.Join(svc.SitesEnabledPath, clientCAFileName(proxy.ID))
+
+	tested := false
+	svc.testStaged = func(dir string) error {
+		tested = true
+		staged, err := os.ReadFile(filepath.Join(dir, "sites", fmt.Sprintf("proxy-%d.conf", proxy.ID)))
+		if err != nil {
+			t.Fatalf("expected staged config: %v", err)
+		}
+		match := regexp.MustCompile(`ssl_client_certificate (\S+);`).FindStringSubmatch(string(staged))
+		if match == nil {
+			t.Fatalf("expected ssl_client_certificate in staged config, got:\n%s", staged)
+		}
+		if !strings.HasPrefix(match[1], dir+string(filepath.Separator)) {
+			t.Errorf("expected the staged config to load the staged bundle, got %s", match[1])
+		}
+		bundle, err := os.ReadFile(match[1])
+		if err != nil {
+			t.Fatalf("expected the bundle to exist while nginx -t runs: %v", err)
+		}
+		if strings.TrimSpace(string(bundle)) != strings.TrimSpace(caPEM) {
+			t.Errorf("expected the staged bundle to hold the new CAs, got:\n%s", bundle)
+		}
+		if _, err := os.Stat(liveBundle); !os.IsNotExist(err) {
+			t.Errorf("expected the live bundle to be untouched during the test, stat err = %v", err)
+		}
+		return nil
+	}
+
+	if err := svc.Apply(nil, func(staged *NginxService) error {
+		return staged.GenerateProxyConfig(proxy)
+	}); err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	if !tested {
+		t.Fatal("expected the staged config to be tested")
+	}
+	config := readTestProxyConfig(t, svc, proxy.ID)
+	if !strings.Contains(config, "ssl_client_certificate "+liveBundle+";") {
+		t.Errorf("expected the live config to load the live bundle, got:\n%s", config)
+	}
+	if _, err := os.Stat(liveBundle); err != nil {
+		t.Errorf("expected the bundle to be swapped into sites-enabled: %v", err)
+	}
+}
+
+func TestApply_FailedConfigTestKeepsLiveFilesAndRollsBack(t *testing.T) {
+	svc := newTestNginxService(t)
+	svc.DatabaseService = newTestDatabaseService(t)
+
+	// A config written before the apply must survive it unchanged
+	enabledFile := filepath.Join(svc.SitesEnabledPath, "proxy-1.conf")
+	if err := os.WriteFile(enabledFile, []byte("# previous\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	svc.testStaged = func(dir string) error {
+		return &ConfigTestError{Output: "nginx: [emerg] unknown directive"}
+	}
+
+	proxy := &models.Proxy{Name: "app", Domain: "app.example.com", TargetURL: "http://backend:8080", Status: "active"}
+	err := svc.Apply(func(db *DatabaseService) error {
+		if err := db.CreateProxy(proxy); err != nil {
+			return err
+		}
+		// Nested transactions join the apply's
+		return db.ReplaceUpstreamServers(proxy.ID, []models.UpstreamServer{{URL: "http://backend:8081", Weight: 1}})
+	}, func(staged *NginxService) error {
+		return staged.GenerateProxyConfig(proxy)
+	})
+
+	var testErr *ConfigTestError
+	if !errors.As(err, &testErr) {
+		t.Fatalf("expected a ConfigTestError, got %v", err)
+	}
+	content, err := os.ReadFile(enabledFile)
+	if err != nil || string(content) != "# previous\n" {
+		t.Errorf("expected live config to be untouched, got %q (err %v)", content, err)
+	}
+
+	proxies, err := svc.DatabaseService.GetProxies()
+	if err != nil {
+		t.Fatalf("GetProxies returned error: %v", err)
+	}
+	if len(proxies) != 0 {
+		t.Errorf("expected the proxy insert to be rolled back, got %d proxies", len(proxies))
+	}
+}
+
+func TestApply_FailedReloadRestoresPreviousFiles(t *testing.T) {
+	svc := newTestNginxService(t)
+	svc.ReloadCommand = "false"
+	svc.testStaged = func(dir string) error { return nil }
+
+	enabledFile := filepath.Join(svc.SitesEnabledPath, "proxy-1.conf")
+	if err := os.WriteFile(enabledFile, []byte("# previous\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := svc.Apply(nil, func(staged *NginxService) error {
+		if err := staged.GenerateProxyConfig(&models.Proxy{ID: 1, Domain: "app.example.com", TargetURL: "http://backend:8080"}); err != nil {
+			return err
+		}
+		return staged.GenerateProxyConfig(&models.Proxy{ID: 2, Domain: "new.example.com", TargetURL: "http://backend:8080"})
+	})
+	if err == nil || !strings.Contains(err.Error(), "failed to reload nginx") {
+		t.Fatalf("expected a reload error, got %v", err)
+	}
+
+	content, err := os.ReadFile(enabledFile)
+	if err != nil || string(content) != "# previous\n" {
+		t.Errorf("expected the previous config to be restored, got %q (err %v)", content, err)
+	}
+	if _, err := os.Stat(filepath.Join(svc.SitesEnabledPath, "proxy-2.conf")); !os.IsNotExist(err) {
+		t.Errorf("expected the new config to be removed again, stat err = %v", err)
+	}
+}
+
+func TestApply_RemovedConfigIsLeftOutOfStagedLayout(t *testing.T) {
+	svc := newTestNginxService(t)
+	svc.testStaged = func(dir string) error {
+		if _, err := os.Stat(filepath.Join(dir, "sites", "proxy-5.conf")); !os.IsNotExist(err) {
+			t.Errorf("expected removed config to be left out of the staged layout, stat err = %v", err)
+		}
+		return nil
+	}
+
+	proxy := &models.Proxy{ID: 5, Domain: "gone.example.com", TargetURL: "http://backend:8080"}
+	if err := svc.GenerateProxyConfig(proxy); err != nil {
+		t.Fatalf("GenerateProxyConfig returned error: %v", err)
+	}
+
+	if err := svc.Apply(nil, func(staged *NginxService) error {
+		return staged.RemoveProxyConfig(proxy.ID)
+	}); err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(svc.SitesEnabledPath, "proxy-5.conf")); !os.IsNotExist(err) {
+		t.Errorf("expected config to be removed, stat err = %v", err)
+	}
+}
+
+func TestApply_FailedDeleteKeepsProxyCache(t *testing.T) {
+	svc := newTestNginxService(t)
+	svc.DatabaseService = newTestDatabaseService(t)
+
+	proxy := &models.Proxy{Name: "app", Domain: "app.example.com", TargetURL: "http://backend:8080", Status: "active"}
+	if err := svc.DatabaseService.CreateProxy(proxy); err != nil {
+		t.Fatal(err)
+	}
+	cachedFile := filepath.Join(svc.proxyCacheDir(proxy.ID), "a", "0b", "cached")
+	if err := os.MkdirAll(filepath.Dir(cachedFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cachedFile, []byte("response"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	deleteProxy := func() error {
+		return svc.Apply(func(db *DatabaseService) error {
+			return db.DeleteProxy(proxy.ID)
+		}, func(staged *NginxService) error {
+			return staged.RemoveProxyConfig(proxy.ID)
+		})
+	}
+
+	svc.testStaged = func(dir string) error {
+		return &ConfigTestError{Output: "nginx: [emerg] unknown directive"}
+	}
+	var testErr *ConfigTestError
+	if err := deleteProxy(); !errors.As(err, &testErr) {
+		t.Fatalf("expected a ConfigTestError, got %v", err)
+	}
+	if _, err := svc.DatabaseService.GetProxy(proxy.ID); err != nil {
+		t.Fatalf("expected the delete to be rolled back: %v", err)
+	}
+	if _, err := os.Stat(cachedFile); err != nil {
+		t.Errorf("expected the cache of a proxy that was not deleted to be kept: %v", err)
+	}
+
+	svc.testStaged = func(dir string) error { return nil }
+	if err := deleteProxy(); err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	if _, err := os.Stat(svc.proxyCacheDir(proxy.ID)); !os.IsNotExist(err) {
+		t.Errorf("expected the cache to be purged once the proxy is deleted, stat err = %v", err)
+	}
+}
@@ -11,7 +11,7 @@ import (
 )
 
 // newTestNginxService sets up a NginxService pointed at temp directories so
-// GenerateProxyConfig/RemoveProxyConfig can be exercised without touching
+// the Generate*/Remove* config methods can be exercised without touching
 // the real /etc/nginx paths. No DatabaseService is wired in, so DNS/cert
 // lookups inside GenerateProxyConfig are skipped.
 func newTestNginxService(t *testing.T) *NginxService {
@@ -19,18 +19,23 @@ func newTestNginxService(t *testing.T) *NginxService {
 
 	configDir := t.TempDir()
 	sitesEnabledDir := t.TempDir()
+	streamsEnabledDir := t.TempDir()
 
-	templateSrc := findProxyTemplate(t)
-	templateData, err := os.ReadFile(templateSrc)
-	if err != nil {
-		t.Fatalf("failed to read real proxy template: %v", err)
-	}
-	if err := os.WriteFile(filepath.Join(configDir, "proxy-template.conf"), templateData, 0644); err != nil {
-		t.Fatalf("failed to write template into temp config dir: %v", err)
+	templateDir := filepath.Dir(findProxyTemplate(t))
+	for _, name := range []string{"proxy-template.conf", "stream-template.conf", "passthrough-template.conf"} {
+		templateData, err := os.ReadFile(filepath.Join(templateDir, name))
+		if err != nil {
+			t.Fatalf("failed to read real template %s: %v", name, err)
+		}
+		if err := os.WriteFile(filepath.Join(configDir, name), templateData, 0644); err != nil {
+			t.Fatalf("failed to write template %s into temp config dir: %v", name, err)
+		}
 	}
 
 	svc := NewNginxService(configDir, "true", "", nil)
 	svc.SitesEnabledPath = sitesEnabledDir
+	svc.StreamsEnabledPath = streamsEnabledDir
+	svc.CachePath = t.TempDir()
 	return svc
 }
 
@@ -156,12 +161,23 @@ func TestGenerateProxyConfig_RateLimitEnabled_RendersLimitReqDirectives(t *testi
 		t.Fatalf("expected config file: %v", err)
 	}
 
-	if !strings.Contains(string(content), "limit_req_zone $binary_remote_addr zone=proxy_4:10m rate=15r/s;") {
-		t.Errorf("expected limit_req_zone directive, got:\n%s", content)
-	}
 	if !strings.Contains(string(content), "limit_req zone=proxy_4 burst=30 nodelay;") {
 		t.Errorf("expected limit_req directive with burst=2x rate, got:\n%s", content)
 	}
+	if strings.Contains(string(content), "limit_req_zone") {
+		t.Errorf("expected the zone to be declared in the shared include, got:\n%s", content)
+	}
+
+	if err := svc.GenerateRateLimitZonesConfig([]models.Proxy{*proxy}); err != nil {
+		t.Fatalf("GenerateRateLimitZonesConfig returned error: %v", err)
+	}
+	zones, err := os.ReadFile(filepath.Join(svc.SitesEnabledPath, "rate-limit-zones.conf"))
+	if err != nil {
+		t.Fatalf("expected rate limit zones include: %v", err)
+	}
+	if !strings.Contains(string(zones), "limit_req_zone $binary_remote_addr zone=proxy_4:10m rate=15r/s;") {
+		t.Errorf("expected limit_req_zone directive, got:\n%s", zones)
+	}
 }
 
 func TestGenerateProxyConfig_RateLimitDisabled_NoLimitReqDirectives(t *testing.T) {
@@ -187,6 +203,149 @@ func TestGenerateProxyConfig_RateLimitDisabled_NoLimitReqDirectives(t *testing.T
 	}
 }
 
+func TestGenerateProxyConfig_UpstreamPool_RendersUpstreamBlock(t *testing.T) {
+	svc := newTestNginxService(t)
+
+	proxy := &models.Proxy{
+		ID:                6,
+		Domain:            "pool.example.com",
+		TargetURL:         "http://app1:8080",
+		LoadBalanceMethod: models.LoadBalanceLeastConn,
+		UpstreamServers: []models.UpstreamServer{
+			{URL: "http://app1:8080", Weight: 3},
+			{URL: "http://app2", MaxFails: 2, FailTimeout: 15},
+			{URL: "http://app3:8080", Backup: true},
+		},
+	}
+	if err := svc.GenerateProxyConfig(proxy); err != nil {
+		t.Fatalf("GenerateProxyConfig returned error: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(svc.SitesEnabledPath, "proxy-6.conf"))
+	if err != nil {
+		t.Fatalf("expected config file: %v", err)
+	}
+
+	for _, want := range []string{
+		"upstream proxy_6_upstream {",
+		"least_conn;",
+		"server app1:8080 weight=3;",
+		"server app2:80 max_fails=2 fail_timeout=15s;",
+		"server app3:8080 backup;",
+		"proxy_pass http://proxy_6_upstream;",
+	} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("expected %q in rendered config, got:\n%s", want, content)
+		}
+	}
+	if strings.Contains(string(content), "proxy_pass http://app1:8080;") {
+		t.Errorf("expected proxy_pass to target the pool, not target_url")
+	}
+}
+
+func TestGenerateProxyConfig_Locations_RendersRules(t *testing.T) {
+	svc := newTestNginxService(t)
+
+	proxy := &models.Proxy{
+		ID:        7,
+		Domain:    "multi.example.com",
+		TargetURL: "http://web:3000",
+		Locations: []models.ProxyLocation{
+			{Path: "/api/", MatchType: models.LocationMatchPrefix, TargetURL: "http://backend:6080/api/", RateLimitEnabled: true},
+			{Path: "/grafana/", MatchType: models.LocationMatchPrefix, TargetURL: "http://grafana:3000", StripPrefix: true, WSEnabled: true},
+			{Path: "/v1/", MatchType: models.LocationMatchPrefix, TargetURL: "http://api:8080", Rewrite: "/v2/"},
+			{Path: "/healthz", MatchType: models.LocationMatchExact, TargetURL: "http://status:9000/health"},
+			{Path: `^/img/(\w+)\.png$`, MatchType: models.LocationMatchRegex, TargetURL: "http://images:8000", Rewrite: "/static/$1.png"},
+		},
+	}
+	if err := svc.GenerateProxyConfig(proxy); err != nil {
+		t.Fatalf("GenerateProxyConfig returned error: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(svc.SitesEnabledPath, "proxy-7.conf"))
+	if err != nil {
+		t.Fatalf("expected config file: %v", err)
+	}
+	config := string(content)
+
+	for _, want := range []string{
+		"location /api/ {",
+		"proxy_pass http://backend:6080/api/;",
+		"location /grafana/ {",
+		`rewrite ^/grafana/?(.*)$ /$1 break;`,
+		"proxy_pass http://grafana:3000;",
+		`rewrite ^/v1/?(.*)$ /v2/$1 break;`,
+		"location = /healthz {",
+		"proxy_pass http://status:9000/health;",
+		`location ~ ^/img/(\w+)\.png$ {`,
+		`rewrite ^/img/(\w+)\.png$ /static/$1.png break;`,
+		"proxy_pass http://images:8000;",
+		"location / {",
+		"proxy_pass http://web:3000;",
+	} {
+		if !strings.Contains(config, want) {
+			t.Errorf("expected %q in rendered config, got:\n%s", want, config)
+		}
+	}
+
+	if n := strings.Count(config, "limit_req zone=proxy_7"); n != 1 {
+		t.Errorf("expected exactly 1 limit_req directive, got %d", n)
+	}
+
+	// Only the /api/ rule is rate limited, so the zone must be declared even
+	// though the proxy itself has rate limiting off.
+	if err := svc.GenerateRateLimitZonesConfig([]models.Proxy{*proxy}); err != nil {
+		t.Fatalf("GenerateRateLimitZonesConfig returned error: %v", err)
+	}
+	zones, err := os.ReadFile(filepath.Join(svc.SitesEnabledPath, rateLimitZonesConfigName))
+	if err != nil {
+		t.Fatalf("expected rate limit zones include: %v", err)
+	}
+	if !strings.Contains(string(zones), "limit_req_zone $binary_remote_addr zone=proxy_7:10m") {
+		t.Errorf("expected limit_req_zone for the rate-limited location, got:\n%s", zones)
+	}
+}
+
+func TestGenerateProxyConfig_Aliases_RendersServerNamesAndCanonicalRedirect(t *testing.T) {
+	svc := newTestNginxService(t)
+
+	proxy := &models.Proxy{
+		ID:                8,
+		Domain:            "Example.com",
+		TargetURL:         "http://web:3000",
+		Aliases:           []string{"www.example.com", "*.apps.example.com"},
+		CanonicalRedirect: true,
+	}
+	if err := svc.GenerateProxyConfig(proxy); err != nil {
+		t.Fatalf("GenerateProxyConfig returned error: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(svc.SitesEnabledPath, "proxy-8.conf"))
+	if err != nil {
+		t.Fatalf("expected config file: %v", err)
+	}
+	config := string(content)
+
+	for _, want := range []string{
+		"server_name Example.com www.example.com *.apps.example.com;",
+		"if ($host != example.com) {",
+		"return 301 $scheme://example.com$request_uri;",
+	} {
+		if !strings.Contains(config, want) {
+			t.Errorf("expected %q in rendered config, got:\n%s", want, config)
+		}
+	}
+
+	proxy.CanonicalRedirect = false
+	if err := svc.GenerateProxyConfig(proxy); err != nil {
+		t.Fatalf("GenerateProxyConfig returned error: %v", err)
+	}
+	content, _ = os.ReadFile(filepath.Join(svc.SitesEnabledPath, "proxy-8.conf"))
+	if strings.Contains(string(content), "if ($host") {
+		t.Errorf("expected no canonical redirect when canonical_redirect is off, got:\n%s", content)
+	}
+}
+
 // TestGenerateProxyConfig_RenderedConfigIsValidNginxSyntax renders configs
 // with rate limiting on and off and, if the nginx binary is available,
 // actually runs `nginx -t` against them to catch template syntax errors that
@@ -203,12 +362,75 @@ func TestGenerateProxyConfig_RenderedConfigIsValidNginxSyntax(t *testing.T) {
 		{ID: 10, Domain: "a.example.com", TargetURL: "http://127.0.0.1:8080", RateLimitEnabled: true, RateLimitRPS: 15},
 		{ID: 11, Domain: "b.example.com", TargetURL: "http://127.0.0.1:9090", RateLimitEnabled: false, SSLEnabled: true},
 		{ID: 12, Domain: "c.example.com", TargetURL: "http://127.0.0.1:7000", RateLimitEnabled: true, RateLimitRPS: 5, WSEnabled: true},
+		{ID: 29, Domain: "q.example.com", TargetURL: "http://127.0.0.1:7018", RateLimitEnabled: true, RateLimit: &models.RateLimitPolicy{
+			Rate: 120, Per: models.RateLimitPerMinute, Delay: new(int), Key: "header:X-Tenant", ExemptCIDRs: []string{"10.0.0.0/8", "2001:db8::/32"},
+			ConnLimit: 20, ResponseBody: `{"error": "slow down"}`, ResponseContentType: "application/json", RetryAfter: 30,
+		}, Locations: []models.ProxyLocation{
+			{Path: "/login", MatchType: models.LocationMatchPrefix, TargetURL: "http://127.0.0.1:7019", RateLimitEnabled: true, RateLimitRate: 10, RateLimitBurst: 5},
+		}},
+		{ID: 13, Domain: "d.example.com", TargetURL: "http://127.0.0.1:7001", LoadBalanceMethod: models.LoadBalanceIPHash, UpstreamServers: []models.UpstreamServer{
+			{URL: "http://127.0.0.1:7001", Weight: 2}, {URL: "http://127.0.0.1:7002"},
+		}},
+		{ID: 15, Domain: "f.example.com", TargetURL: "http://127.0.0.1:7007", Aliases: []string{"www.f.example.com", "*.f.example.org"}, CanonicalRedirect: true},
+		{ID: 16, Domain: "g.example.com", TargetURL: "http://127.0.0.1:7008", RequireUPMLogin: true},
+		{ID: 18, Domain: "i.example.com", TargetURL: "http://127.0.0.1:7010", HeaderRules: []models.ProxyHeaderRule{
+			{Direction: models.HeaderDirectionRequest, Action: models.HeaderActionSet, Name: "X-Env", Value: `say "hi" \ $host`},
+			{Direction: models.HeaderDirectionResponse, Action: models.HeaderActionRemove, Name: "X-Frame-Options"},
+			{Direction: models.HeaderDirectionResponse, Action: models.HeaderActionSet, Name: "X-Robots-Tag", Value: "noindex", Always: true},
+		}},
+		{ID: 20, Domain: "j.example.com", TargetURL: "http://127.0.0.1:7011", CacheEnabled: true, Cache: &models.CachePolicy{
+			Key: []string{"scheme", "host", "request_uri", "cookie:lang"}, BypassCookies: []string{"session"}, BypassHeaders: []string{"Authorization"}, StatusHeader: true,
+		}, Locations: []models.ProxyLocation{
+			{Path: "/live/", MatchType: models.LocationMatchPrefix, TargetURL: "http://127.0.0.1:7012"},
+			{Path: "/static/", MatchType: models.LocationMatchPrefix, TargetURL: "http://127.0.0.1:7013", CacheEnabled: true},
+		}},
+		{ID: 22, Domain: "k.example.com", TargetURL: "http://127.0.0.1:7014", RequireUPMLogin: true, Maintenance: &models.Maintenance{
+			Enabled: true, RetryAfterSeconds: 600, AllowedIPs: []string{"10.0.0.0/8", "2001:db8::1"},
+		}},
+		{ID: 23, Domain: "l.example.com", TargetURL: "http://127.0.0.1:7015", Maintenance: &models.Maintenance{Enabled: true, Page: "<h1>Back soon</h1>"}},
+		{ID: 28, Domain: "p.example.com", TargetURL: "http://127.0.0.1:7017", ErrorPages: []models.ErrorPage{
+			{StatusCode: 403, Content: "<h1>Forbidden</h1>"}, {StatusCode: 502, Content: "<h1>Bad gateway</h1>"},
+		}},
+		{ID: 25, Domain: "m.example.com", TargetURL: "https://new.example.com/", Type: models.ProxyTypeRedirect, RateLimitEnabled: true, Redirect: &models.RedirectHost{StatusCode: 308, PreservePath: true}},
+		{ID: 26, Domain: "n.example.com", TargetURL: "https://new.example.com", Type: models.ProxyTypeRedirect, Redirect: &models.RedirectHost{StatusCode: 302, PreserveQuery: true}},
+		{ID: 27, Domain: "o.example.com", Type: models.ProxyTypeStatic, RateLimitEnabled: true, Static: &models.StaticHost{Root: "o.example.com/dist", Index: "index.html", SPAFallback: true}, Locations: []models.ProxyLocation{
+			{Path: "/api/", MatchType: models.LocationMatchPrefix, TargetURL: "http://127.0.0.1:7016"},
+		}},
+		{ID: 17, Domain: "h.example.com", TargetURL: "http://127.0.0.1:7009", WSEnabled: true, ForwardAuth: &models.ForwardAuth{
+			URL: "http://127.0.0.1:9091/api/verify", ResponseHeaders: []string{"Remote-User"}, SignInURL: "https://auth.example.com/",
+		}},
+		{ID: 14, Domain: "e.example.com", TargetURL: "http://127.0.0.1:7003", WSEnabled: true, Locations: []models.ProxyLocation{
+			{Path: "/api/", MatchType: models.LocationMatchPrefix, TargetURL: "http://127.0.0.1:6080/api/", RateLimitEnabled: true},
+			{Path: "/app", MatchType: models.LocationMatchPrefix, TargetURL: "http://127.0.0.1:7004", StripPrefix: true, WSEnabled: true},
+			{Path: "/health", MatchType: models.LocationMatchExact, TargetURL: "http://127.0.0.1:7005", Rewrite: "/status"},
+			{Path: `^/files/(\d+)\.png$`, MatchType: models.LocationMatchRegex, TargetURL: "http://127.0.0.1:7006", Rewrite: "/img/$1"},
+		}},
 	}
 	for _, p := range proxies {
 		if err := svc.GenerateProxyConfig(p); err != nil {
 			t.Fatalf("GenerateProxyConfig(%d) returned error: %v", p.ID, err)
 		}
 	}
+	var cached []models.Proxy
+	for _, p := range proxies {
+		cached = append(cached, *p)
+	}
+	if err := svc.GenerateCacheZonesConfig(cached); err != nil {
+		t.Fatalf("GenerateCacheZonesConfig returned error: %v", err)
+	}
+	if err := svc.GenerateRateLimitZonesConfig(cached); err != nil {
+		t.Fatalf("GenerateRateLimitZonesConfig returned error: %v", err)
+	}
+
+	streams := []*models.StreamProxy{
+		{ID: 1, ListenPort: 5432, Protocol: models.StreamProtocolTCP, Targets: []string{"127.0.0.1:5433"}, AllowedIPRanges: "10.0.0.0/8", ProxyTimeout: 600},
+		{ID: 2, ListenPort: 27015, Protocol: models.StreamProtocolUDP, Targets: []string{"127.0.0.1:27016", "127.0.0.1:27017"}, ProxyTimeout: 30},
+	}
+	for _, st := range streams {
+		if err := svc.GenerateStreamConfig(st); err != nil {
+			t.Fatalf("GenerateStreamConfig(%d) returned error: %v", st.ID, err)
+		}
+	}
 
 	// Build a minimal, self-contained nginx.conf that includes the rendered
 	// site configs, mirroring the http-context inclusion used in production.
@@ -224,6 +446,9 @@ http {
     }
     include ` + filepath.Join(svc.SitesEnabledPath, "*.conf") + `;
 }
+stream {
+    include ` + filepath.Join(svc.StreamsEnabledPath, "*.conf") + `;
+}
 `
 	mainConfPath := filepath.Join(nginxRoot, "nginx.conf")
 	if err := os.WriteFile(mainConfPath, []byte(mainConf), 0644); err != nil {
@@ -242,6 +467,968 @@ http {
 	}
 }
 
+func TestGenerateStreamConfig_RendersStreamServer(t *testing.T) {
+	svc := newTestNginxService(t)
+
+	stream := &models.StreamProxy{
+		ID:              3,
+		ListenPort:      1883,
+		Protocol:        models.StreamProtocolUDP,
+		Targets:         []string{"mqtt1:1883", "10.0.0.5:1883"},
+		AllowedIPRanges: "192.168.1.0/24, 10.0.0.9",
+		ProxyTimeout:    120,
+	}
+	if err := svc.GenerateStreamConfig(stream); err != nil {
+		t.Fatalf("GenerateStreamConfig returned error: %v", err)
+	}
+
+	for _, path := range []string{
+		filepath.Join(svc.ConfigPath, "stream-3.conf"),
+		filepath.Join(svc.StreamsEnabledPath, "stream-3.conf"),
+	} {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("expected stream config at %s: %v", path, err)
+		}
+		for _, want := range []string{
+			"upstream stream_3_upstream {",
+			"server mqtt1:1883;",
+			"server 10.0.0.5:1883;",
+			"listen 1883 udp;",
+			"allow 192.168.1.0/24;",
+			"allow 10.0.0.9/32;",
+			"deny all;",
+			"proxy_pass stream_3_upstream;",
+			"proxy_timeout 120s;",
+		} {
+			if !strings.Contains(string(content), want) {
+				t.Errorf("expected %q in %s, got:\n%s", want, path, content)
+			}
+		}
+	}
+
+	if err := svc.RemoveStreamConfig(3); err != nil {
+		t.Fatalf("RemoveStreamConfig returned error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(svc.StreamsEnabledPath, "stream-3.conf")); !os.IsNotExist(err) {
+		t.Errorf("expected stream config to be removed from streams-enabled")
+	}
+}
+
+func TestGenerateProxyConfig_BasicAuth_RendersDirectives(t *testing.T) {
+	svc := newTestNginxService(t)
+
+	// A file left over from a set the proxy no longer uses
+	stale := filepath.Join(svc.SitesEnabledPath, "proxy-17-auth-9.htpasswd")
+	if err := os.WriteFile(stale, []byte("old:hash\n"), 0644); err != nil {
+		t.Fatalf("failed to write stale htpasswd file: %v", err)
+	}
+
+	proxy := &models.Proxy{
+		ID:             17,
+		Name:           "grafana",
+		Domain:         "grafana.example.com",
+		TargetURL:      "http://grafana:3000",
+		BasicAuthSetID: 2,
+		Locations: []models.ProxyLocation{
+			{Path: "/admin/", MatchType: models.LocationMatchPrefix, TargetURL: "http://grafana:3000", BasicAuthSetID: 3},
+		},
+		Status: "active",
+	}
+	if err := svc.GenerateProxyConfig(proxy); err != nil {
+		t.Fatalf("GenerateProxyConfig returned error: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(svc.SitesEnabledPath, "proxy-17.conf"))
+	if err != nil {
+		t.Fatalf("expected proxy config: %v", err)
+	}
+	config := string(content)
+	for _, want := range []string{
+		`auth_basic "Restricted";`,
+		"auth_basic_user_file " + filepath.Join(svc.SitesEnabledPath, "proxy-17-auth-2.htpasswd") + ";",
+		"auth_basic_user_file " + filepath.Join(svc.SitesEnabledPath, "proxy-17-auth-3.htpasswd") + ";",
+		"auth_basic off;",
+	} {
+		if !strings.Contains(config, want) {
+			t.Errorf("expected %q in config, got:\n%s", want, config)
+		}
+	}
+
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("expected htpasswd file of an unused set to be removed")
+	}
+}
+
+func TestGenerateProxyConfig_RequireUPMLogin_RendersAuthRequest(t *testing.T) {
+	svc := newTestNginxService(t)
+	svc.BackendURL = "http://backend:6080"
+
+	proxy := &models.Proxy{
+		ID:              18,
+		Name:            "wiki",
+		Domain:          "wiki.example.com",
+		TargetURL:       "http://wiki:3000",
+		RequireUPMLogin: true,
+		Status:          "active",
+	}
+	if err := svc.GenerateProxyConfig(proxy); err != nil {
+		t.Fatalf("GenerateProxyConfig returned error: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(svc.SitesEnabledPath, "proxy-18.conf"))
+	if err != nil {
+		t.Fatalf("expected proxy config: %v", err)
+	}
+	config := string(content)
+	for _, want := range []string{
+		"auth_request /_upm/verify;",
+		"error_page 401 = @upm_login;",
+		"proxy_pass http://backend:6080/api/v1/auth/verify;",
+		"proxy_pass http://backend:6080/api/v1/auth/sso/;",
+		"return 302 $scheme://$host/_upm/login?rd=$scheme://$host$request_uri;",
+	} {
+		if !strings.Contains(config, want) {
+			t.Errorf("expected %q in config, got:\n%s", want, config)
+		}
+	}
+
+	// The ACME challenge must stay reachable without a session
+	acme := config[strings.Index(config, "location /.well-known/acme-challenge/"):]
+	acme = acme[:strings.Index(acme, "}")]
+	if !strings.Contains(acme, "auth_request off;") {
+		t.Errorf("expected auth_request off in ACME location, got:\n%s", acme)
+	}
+
+	proxy.RequireUPMLogin = false
+	if err := svc.GenerateProxyConfig(proxy); err != nil {
+		t.Fatalf("GenerateProxyConfig returned error: %v", err)
+	}
+	content, _ = os.ReadFile(filepath.Join(svc.SitesEnabledPath, "proxy-18.conf"))
+	if strings.Contains(string(content), "auth_request") {
+		t.Errorf("expected no auth_request without require_upm_login, got:\n%s", content)
+	}
+}
+
+func TestGenerateProxyConfig_ForwardAuth_RendersAuthRequest(t *testing.T) {
+	svc := newTestNginxService(t)
+
+	proxy := &models.Proxy{
+		ID:        19,
+		Name:      "grafana",
+		Domain:    "grafana.example.com",
+		TargetURL: "http://grafana:3000",
+		WSEnabled: true,
+		ForwardAuth: &models.ForwardAuth{
+			URL:             "http://authelia:9091/api/verify",
+			ResponseHeaders: []string{"Remote-User", "Remote-Groups"},
+			SignInURL:       "https://auth.example.com/",
+		},
+		Locations: []models.ProxyLocation{
+			{Path: "/api/", MatchType: models.LocationMatchPrefix, TargetURL: "http://grafana-api:3000"},
+		},
+		Status: "active",
+	}
+	if err := svc.GenerateProxyConfig(proxy); err != nil {
+		t.Fatalf("GenerateProxyConfig returned error: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(svc.SitesEnabledPath, "proxy-19.conf"))
+	if err != nil {
+		t.Fatalf("expected proxy config: %v", err)
+	}
+	config := string(content)
+	for _, want := range []string{
+		"auth_request /_upm/forward-auth;",
+		"auth_request_set $upm_auth_remote_user $upstream_http_remote_user;",
+		"auth_request_set $upm_auth_remote_groups $upstream_http_remote_groups;",
+		"error_page 401 = /_upm/forward-auth/sign-in;",
+		"proxy_pass http://authelia:9091/api/verify;",
+		"proxy_pass http://backend:6080/api/v1/auth/forward-auth/19/sign-in;",
+	} {
+		if !strings.Contains(config, want) {
+			t.Errorf("expected %q in config, got:\n%s", want, config)
+		}
+	}
+
+	// Every proxying location (catch-all, rule and the three WebSocket
+	// locations) must forward the identity headers, since a location's own
+	// proxy_set_header directives stop inheritance from the server block.
+	if got := strings.Count(config, "proxy_set_header Remote-User $upm_auth_remote_user;"); got != 5 {
+		t.Errorf("expected Remote-User forwarded in 5 locations, got %d:\n%s", got, config)
+	}
+	if strings.Contains(config, "@upm_login") {
+		t.Errorf("expected no UPM login block with forward auth")
+	}
+
+	proxy.ForwardAuth.SignInURL = ""
+	if err := svc.GenerateProxyConfig(proxy); err != nil {
+		t.Fatalf("GenerateProxyConfig returned error: %v", err)
+	}
+	content, _ = os.ReadFile(filepath.Join(svc.SitesEnabledPath, "proxy-19.conf"))
+	if strings.Contains(string(content), "sign-in") {
+		t.Errorf("expected a plain 401 without a sign-in URL, got:\n%s", content)
+	}
+}
+
+func TestGenerateProxyConfig_HeaderRules_RendersOverrides(t *testing.T) {
+	svc := newTestNginxService(t)
+
+	proxy := &models.Proxy{
+		ID:        20,
+		Name:      "dashboard",
+		Domain:    "dash.example.com",
+		TargetURL: "http://dash:8080",
+		HeaderRules: []models.ProxyHeaderRule{
+			{Direction: models.HeaderDirectionRequest, Action: models.HeaderActionSet, Name: "X-Forwarded-Proto", Value: "https"},
+			{Direction: models.HeaderDirectionRequest, Action: models.HeaderActionSet, Name: "X-App-Env", Value: `prod"; return 200 "pwned`},
+			{Direction: models.HeaderDirectionRequest, Action: models.HeaderActionRemove, Name: "X-Real-IP"},
+			{Direction: models.HeaderDirectionResponse, Action: models.HeaderActionRemove, Name: "X-Frame-Options"},
+			{Direction: models.HeaderDirectionResponse, Action: models.HeaderActionSet, Name: "Content-Security-Policy", Value: "frame-ancestors 'self' https://home.example.com", Always: true},
+		},
+		Locations: []models.ProxyLocation{
+			{Path: "/api/", MatchType: models.LocationMatchPrefix, TargetURL: "http://dash-api:8080"},
+		},
+		Status: "active",
+	}
+	if err := svc.GenerateProxyConfig(proxy); err != nil {
+		t.Fatalf("GenerateProxyConfig returned error: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(svc.SitesEnabledPath, "proxy-20.conf"))
+	if err != nil {
+		t.Fatalf("expected proxy config: %v", err)
+	}
+	config := string(content)
+	for _, want := range []string{
+		`proxy_set_header X-Forwarded-Proto "https";`,
+		`proxy_set_header X-App-Env "prod\"; return 200 \"pwned";`,
+		`proxy_set_header X-Real-IP "";`,
+		"proxy_hide_header X-Frame-Options;",
+		"proxy_hide_header Content-Security-Policy;",
+		`add_header Content-Security-Policy "frame-ancestors 'self' https://home.example.com" always;`,
+	} {
+		if !strings.Contains(config, want) {
+			t.Errorf("expected %q in config, got:\n%s", want, config)
+		}
+	}
+	for _, unwanted := range []string{
+		"proxy_set_header X-Forwarded-Proto $scheme;",
+		"proxy_set_header X-Real-IP $remote_addr;",
+		"add_header X-Frame-Options",
+	} {
+		if strings.Contains(config, unwanted) {
+			t.Errorf("expected %q to be overridden, got:\n%s", unwanted, config)
+		}
+	}
+	// Both the catch-all and the location rule carry the request headers
+	if got := strings.Count(config, `proxy_set_header X-Forwarded-Proto "https";`); got != 2 {
+		t.Errorf("expected request header rule in 2 locations, got %d", got)
+	}
+}
+
+func TestBuildResponseHeaders_OverridesSecurityHeaders(t *testing.T) {
+	rules := []models.ProxyHeaderRule{
+		{Direction: models.HeaderDirectionResponse, Action: models.HeaderActionRemove, Name: "x-frame-options"},
+		{Direction: models.HeaderDirectionResponse, Action: models.HeaderActionSet, Name: "Strict-Transport-Security", Value: "max-age=63072000"},
+		{Direction: models.HeaderDirectionRequest, Action: models.HeaderActionSet, Name: "X-Extra", Value: "ignored"},
+	}
+	got := buildResponseHeaders(defaultSecurityHeaders, rules)
+
+	want := []headerDirective{
+		{Name: "Strict-Transport-Security", Value: `"max-age=63072000"`, Hide: true},
+		{Name: "x-frame-options", Hide: true},
+		{Name: "X-Content-Type-Options", Value: "nosniff"},
+		{Name: "X-XSS-Protection", Value: `"1; mode=block"`},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("buildResponseHeaders = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("header %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if len(defaultSecurityHeaders) != 4 || defaultSecurityHeaders[1].Value != "DENY" {
+		t.Errorf("buildResponseHeaders must not modify the defaults, got %+v", defaultSecurityHeaders)
+	}
+}
+
+func TestGenerateProxyConfig_Cache_RendersProxyCache(t *testing.T) {
+	svc := newTestNginxService(t)
+
+	proxy := &models.Proxy{
+		ID:        21,
+		Name:      "docs",
+		Domain:    "docs.example.com",
+		TargetURL: "http://docs:8080",
+		Cache: &models.CachePolicy{
+			Valid:         []models.CacheValidity{{Status: "200", TTL: 300}, {Status: "any", TTL: 10}},
+			Key:           []string{"host", "request_uri", "header:Accept-Language"},
+			BypassCookies: []string{"session"},
+			BypassArgs:    []string{"nocache"},
+			StatusHeader:  true,
+		},
+		Locations: []models.ProxyLocation{
+			{Path: "/assets/", MatchType: models.LocationMatchPrefix, TargetURL: "http://docs-assets:8080", CacheEnabled: true},
+		},
+		Status: "active",
+	}
+	if err := svc.GenerateProxyConfig(proxy); err != nil {
+		t.Fatalf("GenerateProxyConfig returned error: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(svc.SitesEnabledPath, "proxy-21.conf"))
+	if err != nil {
+		t.Fatalf("expected proxy config: %v", err)
+	}
+	config := string(content)
+	for _, want := range []string{
+		"proxy_cache upm_cache_21;",
+		`proxy_cache_key "$host$request_uri$http_accept_language";`,
+		"proxy_cache_valid 200 300s;",
+		"proxy_cache_valid any 10s;",
+		"proxy_cache_bypass $cookie_session $arg_nocache;",
+		"proxy_no_cache $cookie_session $arg_nocache;",
+		"add_header Cache-Status $upstream_cache_status always;",
+	} {
+		if !strings.Contains(config, want) {
+			t.Errorf("expected %q in config, got:\n%s", want, config)
+		}
+	}
+	// Only the location rule caches; location / keeps buffering off
+	if got := strings.Count(config, "proxy_cache upm_cache_21;"); got != 1 {
+		t.Errorf("expected 1 cached location, got %d:\n%s", got, config)
+	}
+
+	proxy.CacheEnabled = true
+	if err := svc.GenerateProxyConfig(proxy); err != nil {
+		t.Fatalf("GenerateProxyConfig returned error: %v", err)
+	}
+	content, _ = os.ReadFile(filepath.Join(svc.SitesEnabledPath, "proxy-21.conf"))
+	if got := strings.Count(string(content), "proxy_cache upm_cache_21;"); got != 2 {
+		t.Errorf("expected location / and the rule cached, got %d:\n%s", got, content)
+	}
+
+	proxy.CacheEnabled = false
+	proxy.Locations[0].CacheEnabled = false
+	if err := svc.GenerateProxyConfig(proxy); err != nil {
+		t.Fatalf("GenerateProxyConfig returned error: %v", err)
+	}
+	content, _ = os.ReadFile(filepath.Join(svc.SitesEnabledPath, "proxy-21.conf"))
+	if strings.Contains(string(content), "upm_cache_21") || strings.Contains(string(content), "Cache-Status") {
+		t.Errorf("expected no caching when disabled, got:\n%s", content)
+	}
+}
+
+func TestGenerateCacheZonesConfig(t *testing.T) {
+	svc := newTestNginxService(t)
+
+	proxies := []models.Proxy{
+		{ID: 1, Domain: "cached.example.com", CacheEnabled: true},
+		{ID: 2, Domain: "plain.example.com"},
+		{ID: 3, Domain: "partial.example.com", Cache: &models.CachePolicy{ZoneSizeMB: 32, MaxSizeMB: 2048, InactiveMinutes: 5}, Locations: []models.ProxyLocation{
+			{Path: "/static/", CacheEnabled: true},
+		}},
+	}
+	if err := svc.GenerateCacheZonesConfig(proxies); err != nil {
+		t.Fatalf("GenerateCacheZonesConfig returned error: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(svc.SitesEnabledPath, cacheZonesConfigName))
+	if err != nil {
+		t.Fatalf("expected cache zones config: %v", err)
+	}
+	config := string(content)
+	for _, want := range []string{
+		"proxy_cache_path " + filepath.Join(svc.CachePath, "proxy-1") + " levels=1:2 keys_zone=upm_cache_1:10m max_size=1024m inactive=60m use_temp_path=off;",
+		"proxy_cache_path " + filepath.Join(svc.CachePath, "proxy-3") + " levels=1:2 keys_zone=upm_cache_3:32m max_size=2048m inactive=5m use_temp_path=off;",
+	} {
+		if !strings.Contains(config, want) {
+			t.Errorf("expected %q in config, got:\n%s", want, config)
+		}
+	}
+	if strings.Contains(config, "upm_cache_2") {
+		t.Errorf("expected no zone for a proxy without caching, got:\n%s", config)
+	}
+
+	if err := svc.GenerateCacheZonesConfig(proxies[1:2]); err != nil {
+		t.Fatalf("GenerateCacheZonesConfig returned error: %v", err)
+	}
+	for _, path := range []string{filepath.Join(svc.ConfigPath, cacheZonesConfigName), filepath.Join(svc.SitesEnabledPath, cacheZonesConfigName)} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed when nothing caches, stat err = %v", path, err)
+		}
+	}
+}
+
+func TestGenerateProxyConfig_RateLimitPolicy_RendersLimits(t *testing.T) {
+	svc := newTestNginxService(t)
+
+	delay := 5
+	proxy := &models.Proxy{
+		ID:               22,
+		Domain:           "api.example.com",
+		TargetURL:        "http://api:8080",
+		RateLimitEnabled: true,
+		RateLimit: &models.RateLimitPolicy{
+			Rate:                60,
+			Per:                 models.RateLimitPerMinute,
+			Burst:               20,
+			Delay:               &delay,
+			Key:                 models.RateLimitKeyAPIKey,
+			ConnLimit:           10,
+			ResponseBody:        `{"error": "too many requests"}`,
+			ResponseContentType: "application/json",
+			RetryAfter:          60,
+		},
+		Locations: []models.ProxyLocation{
+			{Path: "/login", MatchType: models.LocationMatchExact, TargetURL: "http://api:8080", RateLimitEnabled: true, RateLimitRate: 5},
+			{Path: "/search", MatchType: models.LocationMatchPrefix, TargetURL: "http://api:8080", RateLimitEnabled: true},
+		},
+	}
+	if err := svc.GenerateProxyConfig(proxy); err != nil {
+		t.Fatalf("GenerateProxyConfig returned error: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(svc.SitesEnabledPath, "proxy-22.conf"))
+	if err != nil {
+		t.Fatalf("expected config file: %v", err)
+	}
+	config := string(content)
+	for _, want := range []string{
+		"limit_req zone=proxy_22 burst=20 delay=5;",
+		"limit_req zone=proxy_22_loc_0 burst=10 delay=5;",
+		"limit_conn proxy_22_conn 10;",
+		"limit_req_status 429;",
+		"limit_conn_status 429;",
+		"error_page 429 @upm_rate_limited;",
+		"add_header Retry-After 60 always;",
+		"default_type application/json;",
+		`return 429 "{\"error\": \"too many requests\"}";`,
+	} {
+		if !strings.Contains(config, want) {
+			t.Errorf("expected %q in rendered config, got:\n%s", want, config)
+		}
+	}
+	// location / and /search share the proxy's zone
+	if n := strings.Count(config, "limit_req zone=proxy_22 "); n != 2 {
+		t.Errorf("expected 2 limit_req directives on the shared zone, got %d", n)
+	}
+}
+
+func TestGenerateRateLimitZonesConfig(t *testing.T) {
+	svc := newTestNginxService(t)
+
+	proxies := []models.Proxy{
+		{ID: 1, Domain: "limited.example.com", RateLimitEnabled: true, RateLimitRPS: 15},
+		{ID: 2, Domain: "plain.example.com"},
+		{ID: 3, Domain: "tenant.example.com", RateLimit: &models.RateLimitPolicy{
+			Rate: 120, Per: models.RateLimitPerMinute, Key: "cookie:session", ExemptCIDRs: []string{"10.0.0.0/8"}, ConnLimit: 4, ZoneSizeMB: 32,
+		}, Locations: []models.ProxyLocation{
+			{Path: "/login", RateLimitEnabled: true, RateLimitRate: 6},
+		}},
+		{ID: 4, Domain: "redirect.example.com", Type: models.ProxyTypeRedirect, RateLimitEnabled: true},
+	}
+	if err := svc.GenerateRateLimitZonesConfig(proxies); err != nil {
+		t.Fatalf("GenerateRateLimitZonesConfig returned error: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(svc.SitesEnabledPath, rateLimitZonesConfigName))
+	if err != nil {
+		t.Fatalf("expected rate limit zones config: %v", err)
+	}
+	config := string(content)
+	for _, want := range []string{
+		"limit_req_zone $binary_remote_addr zone=proxy_1:10m rate=15r/s;",
+		"geo $upm_rate_limit_exempt_3 {",
+		"    10.0.0.0/8 1;",
+		"map $cookie_session $upm_rate_limit_client_3 {\n    \"\" $binary_remote_addr;\n    default $cookie_session;\n}",
+		"map $upm_rate_limit_exempt_3 $upm_rate_limit_key_3 {",
+		"    default $upm_rate_limit_client_3;",
+		"limit_req_zone $upm_rate_limit_key_3 zone=proxy_3:32m rate=120r/m;",
+		"limit_req_zone $upm_rate_limit_key_3 zone=proxy_3_loc_0:32m rate=6r/m;",
+		"limit_conn_zone $upm_rate_limit_key_3 zone=proxy_3_conn:32m;",
+	} {
+		if !strings.Contains(config, want) {
+			t.Errorf("expected %q in config, got:\n%s", want, config)
+		}
+	}
+	for _, unwanted := range []string{"zone=proxy_2", "zone=proxy_4", "$upm_rate_limit_client_1"} {
+		if strings.Contains(config, unwanted) {
+			t.Errorf("expected no %q for a proxy without rate limiting, got:\n%s", unwanted, config)
+		}
+	}
+
+	if err := svc.GenerateRateLimitZonesConfig(proxies[1:2]); err != nil {
+		t.Fatalf("GenerateRateLimitZonesConfig returned error: %v", err)
+	}
+	for _, path := range []string{filepath.Join(svc.ConfigPath, rateLimitZonesConfigName), filepath.Join(svc.SitesEnabledPath, rateLimitZonesConfigName)} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed when nothing is limited, stat err = %v", path, err)
+		}
+	}
+}
+
+func TestGenerateRateLimitZonesConfig_MissingKeyFallsBackToClientAddress(t *testing.T) {
+	svc := newTestNginxService(t)
+
+	proxies := []models.Proxy{
+		{ID: 5, Domain: "api.example.com", RateLimit: &models.RateLimitPolicy{Rate: 10, Key: models.RateLimitKeyAPIKey}, RateLimitEnabled: true},
+		{ID: 6, Domain: "tenant.example.com", RateLimit: &models.RateLimitPolicy{Rate: 10, Key: "header:X-Tenant"}, RateLimitEnabled: true},
+	}
+	if err := svc.GenerateRateLimitZonesConfig(proxies); err != nil {
+		t.Fatalf("GenerateRateLimitZonesConfig returned error: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(svc.SitesEnabledPath, rateLimitZonesConfigName))
+	if err != nil {
+		t.Fatalf("expected rate limit zones config: %v", err)
+	}
+	config := string(content)
+	for _, want := range []string{
+		"map $http_x_api_key $upm_rate_limit_client_5 {\n    \"\" $binary_remote_addr;\n    default $http_x_api_key;\n}",
+		"limit_req_zone $upm_rate_limit_client_5 zone=proxy_5:10m rate=10r/s;",
+		"map $http_x_tenant $upm_rate_limit_client_6 {\n    \"\" $binary_remote_addr;\n    default $http_x_tenant;\n}",
+		"limit_req_zone $upm_rate_limit_client_6 zone=proxy_6:10m rate=10r/s;",
+	} {
+		if !strings.Contains(config, want) {
+			t.Errorf("expected %q in config, got:\n%s", want, config)
+		}
+	}
+}
+
+func TestPurgeProxyCache(t *testing.T) {
+	svc := newTestNginxService(t)
+
+	if removed, err := svc.PurgeProxyCache(5); err != nil || removed != 0 {
+		t.Errorf("PurgeProxyCache on a missing cache = (%d, %v), want (0, nil)", removed, err)
+	}
+
+	dir := svc.proxyCacheDir(5)
+	for _, name := range []string{"a/1f/entry1", "a/2e/entry2", "b/3d/entry3"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("cached"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	other := filepath.Join(svc.proxyCacheDir(6), "c/4c/entry4")
+	if err := os.MkdirAll(filepath.Dir(other), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(other, []byte("cached"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := svc.PurgeProxyCache(5)
+	if err != nil {
+		t.Fatalf("PurgeProxyCache returned error: %v", err)
+	}
+	if removed != 3 {
+		t.Errorf("PurgeProxyCache removed %d entries, want 3", removed)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 0 {
+		t.Errorf("expected an empty cache directory, got %v (err %v)", entries, err)
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Errorf("expected other proxies' caches to be kept: %v", err)
+	}
+}
+
+func TestGenerateProxyConfig_Maintenance_ServesPage(t *testing.T) {
+	svc := newTestNginxService(t)
+
+	proxy := &models.Proxy{
+		ID:        24,
+		Name:      "shop",
+		Domain:    "shop.example.com",
+		TargetURL: "http://shop:8080",
+		Maintenance: &models.Maintenance{
+			Enabled:           true,
+			Page:              "<h1>Upgrading the shop</h1>",
+			RetryAfterSeconds: 900,
+			AllowedIPs:        []string{"192.168.1.17/24", "2001:db8::1"},
+		},
+		Status: "active",
+	}
+	if err := svc.GenerateProxyConfig(proxy); err != nil {
+		t.Fatalf("GenerateProxyConfig returned error: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(svc.SitesEnabledPath, "proxy-24.conf"))
+	if err != nil {
+		t.Fatalf("expected proxy config: %v", err)
+	}
+	config := string(content)
+	pagePath := filepath.Join(svc.SitesEnabledPath, "proxy-24-maintenance.html")
+	for _, want := range []string{
+		"geo $upm_maintenance_24 {",
+		"192.168.1.0/24 0;",
+		"2001:db8::1/128 0;",
+		"set $upm_maintenance $upm_maintenance_24;",
+		"return 503;",
+		"error_page 503 /_upm/maintenance.html;",
+		"alias " + pagePath + ";",
+		"add_header Retry-After 900 always;",
+	} {
+		if !strings.Contains(config, want) {
+			t.Errorf("expected %q in config, got:\n%s", want, config)
+		}
+	}
+	page, err := os.ReadFile(pagePath)
+	if err != nil || string(page) != "<h1>Upgrading the shop</h1>" {
+		t.Errorf("expected the stored maintenance page, got %q (err %v)", page, err)
+	}
+
+	// Without an allowlist everyone gets the built-in page
+	proxy.Maintenance.AllowedIPs = nil
+	proxy.Maintenance.Page = ""
+	if err := svc.GenerateProxyConfig(proxy); err != nil {
+		t.Fatalf("GenerateProxyConfig returned error: %v", err)
+	}
+	content, _ = os.ReadFile(filepath.Join(svc.SitesEnabledPath, "proxy-24.conf"))
+	if !strings.Contains(string(content), "set $upm_maintenance 1;") || strings.Contains(string(content), "geo ") {
+		t.Errorf("expected maintenance for every client without geo, got:\n%s", content)
+	}
+	page, _ = os.ReadFile(pagePath)
+	if string(page) != defaultMaintenancePage {
+		t.Errorf("expected the built-in maintenance page, got %q", page)
+	}
+
+	proxy.Maintenance.Enabled = false
+	if err := svc.GenerateProxyConfig(proxy); err != nil {
+		t.Fatalf("GenerateProxyConfig returned error: %v", err)
+	}
+	content, _ = os.ReadFile(filepath.Join(svc.SitesEnabledPath, "proxy-24.conf"))
+	if strings.Contains(string(content), "upm_maintenance") {
+		t.Errorf("expected no maintenance block when disabled, got:\n%s", content)
+	}
+	if _, err := os.Stat(pagePath); !os.IsNotExist(err) {
+		t.Errorf("expected the maintenance page to be removed, stat err = %v", err)
+	}
+}
+
+func TestGenerateProxyConfig_RedirectHost_RendersReturn(t *testing.T) {
+	svc := newTestNginxService(t)
+
+	tests := []struct {
+		name     string
+		redirect models.RedirectHost
+		want     []string
+	}{
+		{"path and query", models.RedirectHost{StatusCode: 301, PreservePath: true, PreserveQuery: true}, []string{"return 301 https://new.example.com$request_uri;"}},
+		{"path only", models.RedirectHost{StatusCode: 308, PreservePath: true}, []string{`if ($request_uri ~ "^([^?]*)") {`, "return 308 https://new.example.com$1;"}},
+		{"query only", models.RedirectHost{StatusCode: 307, PreserveQuery: true}, []string{"return 307 https://new.example.com/$is_args$args;"}},
+		{"destination only", models.RedirectHost{StatusCode: 302}, []string{"return 302 https://new.example.com/;"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redirect := tt.redirect
+			proxy := &models.Proxy{
+				ID:               25,
+				Domain:           "old.example.com",
+				TargetURL:        "https://new.example.com/",
+				Type:             models.ProxyTypeRedirect,
+				Redirect:         &redirect,
+				RateLimitEnabled: true,
+				Status:           "active",
+			}
+			if err := svc.GenerateProxyConfig(proxy); err != nil {
+				t.Fatalf("GenerateProxyConfig returned error: %v", err)
+			}
+			content, err := os.ReadFile(filepath.Join(svc.SitesEnabledPath, "proxy-25.conf"))
+			if err != nil {
+				t.Fatalf("expected proxy config: %v", err)
+			}
+			config := string(content)
+			for _, want := range append(tt.want, "location /.well-known/acme-challenge/ {") {
+				if !strings.Contains(config, want) {
+					t.Errorf("expected %q in config, got:\n%s", want, config)
+				}
+			}
+			for _, unwanted := range []string{"proxy_pass", "limit_req"} {
+				if strings.Contains(config, unwanted) {
+					t.Errorf("did not expect %q in a redirect host, got:\n%s", unwanted, config)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateProxyConfig_StaticHost_ServesFiles(t *testing.T) {
+	svc := newTestNginxService(t)
+	svc.StaticRootPath = "/var/www/sites"
+
+	proxy := &models.Proxy{
+		ID:     26,
+		Domain: "docs.example.com",
+		Type:   models.ProxyTypeStatic,
+		Static: &models.StaticHost{Root: "docs/public", Index: "index.html"},
+		Locations: []models.ProxyLocation{
+			{Path: "/api/", MatchType: models.LocationMatchPrefix, TargetURL: "http://api:8080"},
+		},
+		Status: "active",
+	}
+	if err := svc.GenerateProxyConfig(proxy); err != nil {
+		t.Fatalf("GenerateProxyConfig returned error: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(svc.SitesEnabledPath, "proxy-26.conf"))
+	if err != nil {
+		t.Fatalf("expected proxy config: %v", err)
+	}
+	config := string(content)
+	for _, want := range []string{
+		"root /var/www/sites/docs/public;",
+		"index index.html;",
+		"try_files $uri $uri/ =404;",
+		"location /api/ {",
+		"proxy_pass http://api:8080;",
+	} {
+		if !strings.Contains(config, want) {
+			t.Errorf("expected %q in config, got:\n%s", want, config)
+		}
+	}
+	if strings.Count(config, "proxy_pass") != 1 {
+		t.Errorf("expected only the location rule to proxy, got:\n%s", config)
+	}
+
+	proxy.Static.SPAFallback = true
+	if err := svc.GenerateProxyConfig(proxy); err != nil {
+		t.Fatalf("GenerateProxyConfig returned error: %v", err)
+	}
+	content, _ = os.ReadFile(filepath.Join(svc.SitesEnabledPath, "proxy-26.conf"))
+	if !strings.Contains(string(content), "try_files $uri $uri/ /index.html;") {
+		t.Errorf("expected the SPA fallback to the index, got:\n%s", content)
+	}
+}
+
+func TestGenerateProxyConfig_ErrorPages_RendersErrorPage(t *testing.T) {
+	svc := newTestNginxService(t)
+
+	proxy := &models.Proxy{
+		ID:        28,
+		Domain:    "app.example.com",
+		TargetURL: "http://app:8080",
+		ErrorPages: []models.ErrorPage{
+			{ProxyID: 28, StatusCode: 502, Content: "<h1>App is restarting</h1>"},
+			{ProxyID: 28, StatusCode: 404, Content: "<h1>Nothing here</h1>"},
+			{ProxyID: 28, StatusCode: 503, Content: "<h1>Unavailable</h1>"},
+		},
+		Status: "active",
+	}
+	if err := svc.GenerateProxyConfig(proxy); err != nil {
+		t.Fatalf("GenerateProxyConfig returned error: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(svc.SitesEnabledPath, "proxy-28.conf"))
+	if err != nil {
+		t.Fatalf("expected proxy config: %v", err)
+	}
+	config := string(content)
+	dir := filepath.Join(svc.SitesEnabledPath, "error-pages", "proxy-28")
+	for _, want := range []string{
+		"proxy_intercept_errors on;",
+		"error_page 404 /_upm/errors/404.html;",
+		"error_page 502 /_upm/errors/502.html;",
+		"error_page 503 /_upm/errors/503.html;",
+		"location ^~ /_upm/errors/ {",
+		"alias " + dir + "/;",
+	} {
+		if !strings.Contains(config, want) {
+			t.Errorf("expected %q in config, got:\n%s", want, config)
+		}
+	}
+	page, err := os.ReadFile(filepath.Join(dir, "502.html"))
+	if err != nil || string(page) != "<h1>App is restarting</h1>" {
+		t.Errorf("expected the stored 502 page, got %q (err %v)", page, err)
+	}
+
+	// Maintenance keeps its own page for 503
+	proxy.Maintenance = &models.Maintenance{Enabled: true}
+	if err := svc.GenerateProxyConfig(proxy); err != nil {
+		t.Fatalf("GenerateProxyConfig returned error: %v", err)
+	}
+	content, _ = os.ReadFile(filepath.Join(svc.SitesEnabledPath, "proxy-28.conf"))
+	if strings.Contains(string(content), "error_page 503 /_upm/errors/") {
+		t.Errorf("expected no custom 503 page during maintenance, got:\n%s", content)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "503.html")); !os.IsNotExist(err) {
+		t.Errorf("expected the unused 503 page to be removed, stat err = %v", err)
+	}
+
+	if err := svc.RemoveProxyConfig(28); err != nil {
+		t.Fatalf("RemoveProxyConfig returned error: %v", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("expected the error pages directory to be removed, stat err = %v", err)
+	}
+}
+
+func TestRenderHtpasswd(t *testing.T) {
+	users := []models.BasicAuthUser{
+		{Username: "alice", PasswordHash: "$2a$10$abc"},
+		{Username: "bob", PasswordHash: "$2a$10$def"},
+	}
+	got := string(renderHtpasswd(users))
+	want := "alice:$2a$10$abc\nbob:$2a$10$def\n"
+	if got != want {
+		t.Errorf("renderHtpasswd = %q, want %q", got, want)
+	}
+}
+
+func TestGenerateProxyConfig_Passthrough_OnlyRedirectsHTTP(t *testing.T) {
+	svc := newTestNginxService(t)
+
+	proxy := &models.Proxy{
+		ID:               16,
+		Name:             "k8s",
+		Domain:           "k8s.example.com",
+		TargetURL:        "https://10.0.0.5:443",
+		RateLimitEnabled: true,
+		RateLimitRPS:     15,
+		SSLMode:          models.SSLModePassthrough,
+		Status:           "active",
+	}
+	if err := svc.GenerateProxyConfig(proxy); err != nil {
+		t.Fatalf("GenerateProxyConfig returned error: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(svc.SitesEnabledPath, "proxy-16.conf"))
+	if err != nil {
+		t.Fatalf("expected proxy config: %v", err)
+	}
+	config := string(content)
+	if !strings.Contains(config, "return 301 https://$host$request_uri;") {
+		t.Errorf("expected HTTP to HTTPS redirect, got:\n%s", config)
+	}
+	for _, unwanted := range []string{"listen 443", "proxy_pass", "limit_req_zone", "upstream "} {
+		if strings.Contains(config, unwanted) {
+			t.Errorf("did not expect %q in passthrough http config, got:\n%s", unwanted, config)
+		}
+	}
+}
+
+func TestGeneratePassthroughConfig_RoutesBySNI(t *testing.T) {
+	svc := newTestNginxService(t)
+
+	proxies := []models.Proxy{
+		{
+			ID:        4,
+			Domain:    "k8s.example.com",
+			TargetURL: "https://10.0.0.5",
+			Aliases:   []string{"*.apps.example.com"},
+			SSLMode:   models.SSLModePassthrough,
+		},
+		{
+			ID:                7,
+			Domain:            "PVE.example.com",
+			TargetURL:         "https://pve1:8006",
+			LoadBalanceMethod: models.LoadBalanceIPHash,
+			UpstreamServers: []models.UpstreamServer{
+				{URL: "https://pve1:8006"},
+				{URL: "https://pve2:8006", Weight: 2},
+			},
+			SSLMode: models.SSLModePassthrough,
+		},
+	}
+	if err := svc.GeneratePassthroughConfig(proxies); err != nil {
+		t.Fatalf("GeneratePassthroughConfig returned error: %v", err)
+	}
+
+	enabledPath := filepath.Join(svc.StreamsEnabledPath, "sni-passthrough.conf")
+	content, err := os.ReadFile(enabledPath)
+	if err != nil {
+		t.Fatalf("expected SNI router config: %v", err)
+	}
+	config := string(content)
+	for _, want := range []string{
+		"upstream passthrough_4_upstream {",
+		"server 10.0.0.5:443;",
+		"upstream passthrough_7_upstream {",
+		"hash $remote_addr consistent;",
+		"server pve2:8006 weight=2;",
+		"map $ssl_preread_server_name $passthrough_upstream {",
+		"hostnames;",
+		"k8s.example.com passthrough_4_upstream;",
+		"*.apps.example.com passthrough_4_upstream;",
+		"pve.example.com passthrough_7_upstream;",
+		"listen 443;",
+		"ssl_preread on;",
+		"proxy_protocol on;",
+		"proxy_pass $sni_route;",
+		"default unix:/var/run/nginx-upm-https.sock;",
+		"k8s.example.com unix:/var/run/nginx-upm-passthrough.sock;",
+		"listen unix:/var/run/nginx-upm-passthrough.sock proxy_protocol;",
+		"proxy_pass $passthrough_upstream;",
+	} {
+		if !strings.Contains(config, want) {
+			t.Errorf("expected %q in SNI router, got:\n%s", want, config)
+		}
+	}
+	if strings.Contains(config, "ip_hash") {
+		t.Errorf("ip_hash is not valid in the stream context, got:\n%s", config)
+	}
+
+	// With no passthrough proxies left the router must go away so the http
+	// servers can bind 443 again.
+	if err := svc.GeneratePassthroughConfig(nil); err != nil {
+		t.Fatalf("GeneratePassthroughConfig(nil) returned error: %v", err)
+	}
+	if _, err := os.Stat(enabledPath); !os.IsNotExist(err) {
+		t.Errorf("expected SNI router to be removed when no passthrough proxies remain")
+	}
+}
+
+func TestGenerateProxyConfig_SSLProxyMovesBehindSNIRouter(t *testing.T) {
+	svc := newTestNginxService(t)
+	svc.DatabaseService = newTestDatabaseService(t)
+
+	app := newTestSSLProxy(t, svc, "app", "app.example.com")
+	if config := renderTestProxy(t, svc, app); !strings.Contains(config, "listen 443 ssl;") {
+		t.Fatalf("expected the SSL proxy on port 443 without passthrough proxies, got:\n%s", config)
+	}
+
+	// A passthrough proxy takes port 443: the SSL proxy moves behind the router
+	passthrough := &models.Proxy{Name: "k8s", Domain: "k8s.example.com", TargetURL: "https://10.0.0.5", Status: "active", SSLMode: models.SSLModePassthrough}
+	renderTestProxy(t, svc, passthrough)
+	config := readTestProxyConfig(t, svc, app.ID)
+	for _, want := range []string{
+		"listen unix:/var/run/nginx-upm-https.sock ssl proxy_protocol;",
+		"real_ip_header proxy_protocol;",
+		"port_in_redirect off;",
+	} {
+		if !strings.Contains(config, want) {
+			t.Errorf("expected %q behind the SNI router, got:\n%s", want, config)
+		}
+	}
+	if strings.Contains(config, "listen 443 ssl;") {
+		t.Errorf("expected the SSL proxy to leave port 443 to the SNI router, got:\n%s", config)
+	}
+
+	// Later SSL proxies and regenerations work alongside passthrough
+	other := newTestSSLProxy(t, svc, "other", "other.example.com")
+	if config := renderTestProxy(t, svc, other); !strings.Contains(config, "listen unix:/var/run/nginx-upm-https.sock ssl proxy_protocol;") {
+		t.Errorf("expected a new SSL proxy behind the SNI router, got:\n%s", config)
+	}
+
+	// The last passthrough proxy goes: port 443 returns to the http servers
+	if err := svc.DatabaseService.DeleteProxy(passthrough.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.RemoveProxyConfig(passthrough.ID); err != nil {
+		t.Fatalf("RemoveProxyConfig returned error: %v", err)
+	}
+	for _, id := range []int{app.ID, other.ID} {
+		if config := readTestProxyConfig(t, svc, id); !strings.Contains(config, "listen 443 ssl;") {
+			t.Errorf("expected proxy %d back on port 443, got:\n%s", id, config)
+		}
+	}
+}
+
 func TestRemoveProxyConfig_RemovesBothFiles(t *testing.T) {
 	svc := newTestNginxService(t)
 
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"upm-backend/internal/models"
+)
+
+// PreviewProxyConfig renders the config proxy would get and diffs it
+// against the config nginx runs for it now. Nothing is written and the
+// database is only read. A proxy without an ID is previewed as a new one.
+func (n *NginxService) PreviewProxyConfig(proxy *models.Proxy) (*models.ProxyConfigPreview, error) {
+	// The files the config refers to are rendered into a stage that is
+	// thrown away
+	preview := *n
+	preview.stage = newConfigStage()
+	rendered, err := preview.renderProxyConfig(proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	var current []byte
+	var path string
+	beforeName, afterName := "/dev/null", "new-proxy.conf"
+	if proxy.ID != 0 {
+		path = filepath.Join(n.SitesEnabledPath, fmt.Sprintf("proxy-%d.conf", proxy.ID))
+		afterName = path
+		current, err = os.ReadFile(path)
+		if err == nil {
+			beforeName = path
+		} else if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read current config: %w", err)
+		}
+	}
+
+	diff := unifiedDiff(beforeName, afterName, current, rendered.config)
+	return &models.ProxyConfigPreview{
+		Path:       path,
+		Config:     string(rendered.config),
+		Changed:    diff != "",
+		Diff:       diff,
+		SSLEnabled: rendered.sslEnabled,
+	}, nil
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"upm-backend/internal/models"
+)
+
+func TestPreviewProxyConfig_NewProxyWritesNothing(t *testing.T) {
+	svc := newTestNginxService(t)
+
+	preview, err := svc.PreviewProxyConfig(&models.Proxy{Domain: "app.example.com", TargetURL: "http://backend:8080"})
+	if err != nil {
+		t.Fatalf("PreviewProxyConfig returned error: %v", err)
+	}
+	if !strings.Contains(preview.Config, "server_name app.example.com") {
+		t.Errorf("expected the rendered server, got:\n%s", preview.Config)
+	}
+	if preview.Path != "" || !preview.Changed || !strings.HasPrefix(preview.Diff, "--- /dev/null\n") {
+		t.Errorf("expected a new file diff, got path %q changed %v diff:\n%s", preview.Path, preview.Changed, preview.Diff)
+	}
+
+	for _, dir := range []string{svc.SitesEnabledPath, svc.StreamsEnabledPath} {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 0 {
+			t.Errorf("expected the preview to write nothing to %s, found %d entries", dir, len(entries))
+		}
+	}
+	if _, err := os.Stat(filepath.Join(svc.ConfigPath, "proxy-0.conf")); !os.IsNotExist(err) {
+		t.Errorf("expected no config file to be written, stat err = %v", err)
+	}
+}
+
+func TestPreviewProxyConfig_DiffsAgainstCurrentConfig(t *testing.T) {
+	svc := newTestNginxService(t)
+	svc.DatabaseService = newTestDatabaseService(t)
+
+	// SSL is requested but there is no certificate: the preview renders
+	// without it and must not persist that like a real apply does
+	proxy := &models.Proxy{Name: "app", Domain: "app.example.com", TargetURL: "http://backend:8080", Status: "active", SSLEnabled: true}
+	if err := svc.DatabaseService.CreateProxy(proxy); err != nil {
+		t.Fatalf("CreateProxy returned error: %v", err)
+	}
+	current := *proxy
+	current.SSLEnabled = false
+	if err := svc.GenerateProxyConfig(&current); err != nil {
+		t.Fatalf("GenerateProxyConfig returned error: %v", err)
+	}
+	if err := svc.DatabaseService.UpdateProxy(proxy); err != nil {
+		t.Fatal(err)
+	}
+	enabledFile := filepath.Join(svc.SitesEnabledPath, "proxy-1.conf")
+	before, err := os.ReadFile(enabledFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unchanged, err := svc.PreviewProxyConfig(proxy)
+	if err != nil {
+		t.Fatalf("PreviewProxyConfig returned error: %v", err)
+	}
+	if unchanged.Changed || unchanged.Diff != "" || unchanged.Path != enabledFile || unchanged.SSLEnabled {
+		t.Errorf("expected no change, got %+v", unchanged)
+	}
+
+	edited := *proxy
+	edited.Aliases = []string{"www.app.example.com"}
+	preview, err := svc.PreviewProxyConfig(&edited)
+	if err != nil {
+		t.Fatalf("PreviewProxyConfig returned error: %v", err)
+	}
+	if !preview.Changed || !strings.Contains(preview.Diff, "+") || !strings.Contains(preview.Diff, "www.app.example.com") {
+		t.Errorf("expected a diff adding the alias, got:\n%s", preview.Diff)
+	}
+
+	after, err := os.ReadFile(enabledFile)
+	if err != nil || string(after) != string(before) {
+		t.Errorf("expected the config on disk to be untouched (err %v)", err)
+	}
+	saved, err := svc.DatabaseService.GetProxy(proxy.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !saved.SSLEnabled {
+		t.Error("expected the preview not to persist the SSL state")
+	}
+}
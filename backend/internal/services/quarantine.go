@@ -0,0 +1,175 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// configErrorLocationRegex matches the file and line nginx -t names at
+	// the end of an error, as in
+	// `[emerg] unknown directive "foo" in /etc/nginx/sites-enabled/proxy-3.conf:12`.
+	configErrorLocationRegex = regexp.MustCompile(`^(.*) in (\S+):(\d+)$`)
+	// configErrorQuotedRegex matches the quoted arguments of an error, such
+	// as the certificate path of `[emerg] cannot load certificate "..."`.
+	configErrorQuotedRegex = regexp.MustCompile(`"([^"]+)"`)
+	// proxyConfigNameRegex matches the name of a proxy's generated config.
+	proxyConfigNameRegex = regexp.MustCompile(`^proxy-(\d+)\.conf$`)
+)
+
+// ConfigTestFailure is the error nginx -t stopped at. File and Line are
+// empty when nginx did not name a location, as for certificates it cannot
+// load.
+type ConfigTestFailure struct {
+	Message string
+	File    string
+	Line    int
+}
+
+// ParseConfigTestOutput returns the first error in the output of nginx -t.
+func ParseConfigTestOutput(output string) (ConfigTestFailure, bool) {
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "nginx:"))
+		if !strings.HasPrefix(line, "[emerg]") && !strings.HasPrefix(line, "[alert]") && !strings.HasPrefix(line, "[crit]") {
+			continue
+		}
+		failure := ConfigTestFailure{Message: line}
+		if match := configErrorLocationRegex.FindStringSubmatch(line); match != nil {
+			failure.File = match[2]
+			failure.Line, _ = strconv.Atoi(match[3])
+		}
+		return failure, true
+	}
+	return ConfigTestFailure{}, false
+}
+
+// testAndQuarantine validates the staged configuration like testStage.
+// When nginx rejects the config of a single proxy, that config is taken out
+// of the staged sites-enabled, the proxy is quarantined and the rest is
+// validated again, so one broken proxy does not block every other change.
+// The copy in the config directory is kept for inspection. Quarantined
+// proxies whose regenerated config passes leave quarantine.
+func (n *NginxService) testAndQuarantine(staged *NginxService) error {
+	quarantined := make(map[int]bool)
+	for {
+		err := n.testStage(staged)
+		if staged.DatabaseService == nil {
+			return err
+		}
+		if err == nil {
+			return staged.releaseQuarantines(quarantined)
+		}
+		var testErr *ConfigTestError
+		if !errors.As(err, &testErr) {
+			return err
+		}
+
+		failure, ok := ParseConfigTestOutput(testErr.Output)
+		if !ok {
+			return err
+		}
+		id, ok := staged.brokenProxyID(failure)
+		if !ok || quarantined[id] {
+			return err
+		}
+		if err := staged.quarantineProxy(id, failure.Message); err != nil {
+			return err
+		}
+		quarantined[id] = true
+	}
+}
+
+// brokenProxyID returns the proxy whose config caused a failure: the one
+// nginx names, or else the only proxy config that contains one of the
+// quoted arguments of the error.
+func (n *NginxService) brokenProxyID(failure ConfigTestFailure) (int, bool) {
+	if failure.File != "" {
+		if filepath.Dir(failure.File) != n.SitesEnabledPath {
+			return 0, false
+		}
+		return proxyIDFromConfigName(filepath.Base(failure.File))
+	}
+
+	paths, err := n.globFiles(filepath.Join(n.SitesEnabledPath, "proxy-*.conf"))
+	if err != nil {
+		return 0, false
+	}
+	for _, match := range configErrorQuotedRegex.FindAllStringSubmatch(failure.Message, -1) {
+		found, foundID := 0, 0
+		for _, path := range paths {
+			data, err := n.readFile(path)
+			if err != nil || !strings.Contains(string(data), match[1]) {
+				continue
+			}
+			if id, ok := proxyIDFromConfigName(filepath.Base(path)); ok {
+				found++
+				foundID = id
+			}
+		}
+		if found == 1 {
+			return foundID, true
+		}
+	}
+	return 0, false
+}
+
+// proxyIDFromConfigName parses the ID out of a proxy-<id>.conf file name.
+func proxyIDFromConfigName(name string) (int, bool) {
+	match := proxyConfigNameRegex.FindStringSubmatch(name)
+	if match == nil {
+		return 0, false
+	}
+	id, err := strconv.Atoi(match[1])
+	return id, err == nil
+}
+
+// quarantineProxy takes a proxy's config out of sites-enabled and records
+// why on the proxy. When the proxy owned the HTTP/3 reuseport option, the
+// next HTTP/3 proxy is regenerated to take it over.
+func (n *NginxService) quarantineProxy(id int, message string) error {
+	enabledPath := filepath.Join(n.SitesEnabledPath, fmt.Sprintf("proxy-%d.conf", id))
+	if err := n.removeFile(enabledPath); err != nil {
+		return fmt.Errorf("failed to remove config from sites-enabled: %w", err)
+	}
+	if err := n.DatabaseService.QuarantineProxy(id, message); err != nil {
+		return err
+	}
+	fmt.Printf("Quarantined proxy %d, nginx rejected its config: %s\n", id, message)
+
+	owner, err := n.DatabaseService.GetHTTP3ReuseportOwner()
+	if err != nil {
+		return err
+	}
+	if owner == id {
+		return n.handOverHTTP3Reuseport(id)
+	}
+	return nil
+}
+
+// releaseQuarantines ends the quarantine of proxies whose config this
+// apply put back into sites-enabled. Proxies quarantined by this apply are
+// skipped.
+func (n *NginxService) releaseQuarantines(quarantined map[int]bool) error {
+	ids, err := n.DatabaseService.GetQuarantinedProxyIDs()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if quarantined[id] {
+			continue
+		}
+		file, ok := n.stage.files[filepath.Join(n.SitesEnabledPath, fmt.Sprintf("proxy-%d.conf", id))]
+		if !ok || file.removed {
+			continue
+		}
+		if err := n.DatabaseService.ClearProxyQuarantine(id); err != nil {
+			return err
+		}
+		fmt.Printf("Released proxy %d from quarantine\n", id)
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"upm-backend/internal/models"
+)
+
+func TestParseConfigTestOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   ConfigTestFailure
+		wantOK bool
+	}{
+		{
+			name: "error with location",
+			output: "nginx: [emerg] unknown directive \"foo\" in /etc/nginx/sites-enabled/proxy-3.conf:12\n" +
+				"nginx: configuration file /tmp/nginx.conf test failed\n",
+			want: ConfigTestFailure{
+				Message: "[emerg] unknown directive \"foo\" in /etc/nginx/sites-enabled/proxy-3.conf:12",
+				File:    "/etc/nginx/sites-enabled/proxy-3.conf",
+				Line:    12,
+			},
+			wantOK: true,
+		},
+		{
+			name:   "error without location",
+			output: "nginx: [emerg] cannot load certificate \"/etc/letsencrypt/live/a.example.com/fullchain.pem\": BIO_new_file() failed\n",
+			want: ConfigTestFailure{
+				Message: "[emerg] cannot load certificate \"/etc/letsencrypt/live/a.example.com/fullchain.pem\": BIO_new_file() failed",
+			},
+			wantOK: true,
+		},
+		{
+			name:   "warnings only",
+			output: "nginx: [warn] conflicting server name \"a.example.com\" on 0.0.0.0:80, ignored\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseConfigTestOutput(tt.output)
+			if ok != tt.wantOK {
+				t.Fatalf("ParseConfigTestOutput() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if got != tt.want {
+				t.Errorf("ParseConfigTestOutput() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApply_QuarantinesBrokenProxyAndAppliesTheRest(t *testing.T) {
+	svc := newTestNginxService(t)
+	svc.DatabaseService = newTestDatabaseService(t)
+
+	var proxies []*models.Proxy
+	for _, domain := range []string{"good.example.com", "bad.example.com"} {
+		proxy := &models.Proxy{Name: domain, Domain: domain, TargetURL: "http://backend:8080", Status: "active"}
+		if err := svc.DatabaseService.CreateProxy(proxy); err != nil {
+			t.Fatalf("CreateProxy returned error: %v", err)
+		}
+		proxies = append(proxies, proxy)
+	}
+	good, bad := proxies[0], proxies[1]
+	badFile := filepath.Join(svc.SitesEnabledPath, "proxy-2.conf")
+
+	// nginx rejects the bad proxy's config for as long as it is laid out
+	svc.testStaged = func(dir string) error {
+		if _, err := os.Stat(filepath.Join(dir, "sites", "proxy-2.conf")); err == nil {
+			return &ConfigTestError{Output: "nginx: [emerg] host not found in upstream \"backend:8080\" in " + badFile + ":14\n"}
+		}
+		return nil
+	}
+	renderAll := func(staged *NginxService) error {
+		for _, proxy := range proxies {
+			if err := staged.GenerateProxyConfig(proxy); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := svc.Apply(nil, renderAll); err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(svc.SitesEnabledPath, "proxy-1.conf")); err != nil {
+		t.Errorf("expected the good proxy to be applied: %v", err)
+	}
+	if _, err := os.Stat(badFile); !os.IsNotExist(err) {
+		t.Errorf("expected the bad proxy to be left out of sites-enabled, stat err = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(svc.ConfigPath, "proxy-2.conf")); err != nil {
+		t.Errorf("expected the bad proxy's config to be kept for inspection: %v", err)
+	}
+
+	saved, err := svc.DatabaseService.GetProxy(bad.ID)
+	if err != nil {
+		t.Fatalf("GetProxy returned error: %v", err)
+	}
+	if !saved.Quarantined || saved.Status != "error" || !strings.Contains(saved.LastError, "host not found in upstream") {
+		t.Errorf("expected the bad proxy to be quarantined, got quarantined=%v status=%q last_error=%q", saved.Quarantined, saved.Status, saved.LastError)
+	}
+	if saved, err := svc.DatabaseService.GetProxy(good.ID); err != nil || saved.Quarantined || saved.Status != "active" {
+		t.Errorf("expected the good proxy to stay active, got %+v (err %v)", saved, err)
+	}
+
+	// Once nginx accepts the config again the proxy leaves quarantine
+	svc.testStaged = func(dir string) error { return nil }
+	if err := svc.Apply(nil, renderAll); err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	saved, err = svc.DatabaseService.GetProxy(bad.ID)
+	if err != nil {
+		t.Fatalf("GetProxy returned error: %v", err)
+	}
+	if saved.Quarantined || saved.Status != "active" || saved.LastError != "" {
+		t.Errorf("expected the quarantine to be released, got quarantined=%v status=%q last_error=%q", saved.Quarantined, saved.Status, saved.LastError)
+	}
+	if _, err := os.Stat(badFile); err != nil {
+		t.Errorf("expected the fixed config to be back in sites-enabled: %v", err)
+	}
+}
+
+func TestApply_QuarantinesProxyNamedByQuotedArgument(t *testing.T) {
+	svc := newTestNginxService(t)
+	svc.DatabaseService = newTestDatabaseService(t)
+
+	proxy := &models.Proxy{Name: "app", Domain: "app.example.com", TargetURL: "http://backend:8080", Status: "active"}
+	if err := svc.DatabaseService.CreateProxy(proxy); err != nil {
+		t.Fatalf("CreateProxy returned error: %v", err)
+	}
+	svc.testStaged = func(dir string) error {
+		if _, err := os.Stat(filepath.Join(dir, "sites", "proxy-1.conf")); err == nil {
+			return &ConfigTestError{Output: "nginx: [emerg] invalid server name or wildcard \"app.example.com\" on 0.0.0.0:80\n"}
+		}
+		return nil
+	}
+
+	if err := svc.Apply(nil, func(staged *NginxService) error {
+		return staged.GenerateProxyConfig(proxy)
+	}); err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	saved, err := svc.DatabaseService.GetProxy(proxy.ID)
+	if err != nil {
+		t.Fatalf("GetProxy returned error: %v", err)
+	}
+	if !saved.Quarantined {
+		t.Error("expected the proxy whose config holds the quoted name to be quarantined")
+	}
+}
+
+func TestApply_FailureOutsideProxyConfigsIsNotQuarantined(t *testing.T) {
+	svc := newTestNginxService(t)
+	svc.DatabaseService = newTestDatabaseService(t)
+
+	proxy := &models.Proxy{Name: "app", Domain: "app.example.com", TargetURL: "http://backend:8080", Status: "active"}
+	if err := svc.DatabaseService.CreateProxy(proxy); err != nil {
+		t.Fatalf("CreateProxy returned error: %v", err)
+	}
+	svc.testStaged = func(dir string) error {
+		return &ConfigTestError{Output: "nginx: [emerg] unexpected \"}\" in " + filepath.Join(svc.SitesEnabledPath, "upm-admin.conf") + ":3\n"}
+	}
+
+	err := svc.Apply(nil, func(staged *NginxService) error {
+		return staged.GenerateProxyConfig(proxy)
+	})
+	var testErr *ConfigTestError
+	if !errors.As(err, &testErr) {
+		t.Fatalf("expected a ConfigTestError, got %v", err)
+	}
+	saved, err := svc.DatabaseService.GetProxy(proxy.ID)
+	if err != nil {
+		t.Fatalf("GetProxy returned error: %v", err)
+	}
+	if saved.Quarantined {
+		t.Error("expected no proxy to be quarantined for a failure in another file")
+	}
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"upm-backend/internal/models"
+)
+
+// rateLimitZonesConfigName is the shared include declaring the limit zones
+// of every proxy at http level. Declaring them in one file keeps zones of
+// different proxies from colliding.
+const rateLimitZonesConfigName = "rate-limit-zones.conf"
+
+// rateLimitTemplateData is the rendered limit_req and limit_conn of one
+// location.
+type rateLimitTemplateData struct {
+	Zone      string
+	Burst     int
+	Delay     string // " nodelay", " delay=N" or "" to slow down the whole burst
+	ConnZone  string // empty without a connection limit
+	ConnLimit int
+}
+
+// rateLimitServerTemplateData is the rendered handling of rejected requests,
+// set whenever any location of a proxy is limited.
+type rateLimitServerTemplateData struct {
+	Status      int
+	Respond     bool   // rejected requests get the custom response below
+	Body        string // quoted; empty keeps nginx's stock page
+	ContentType string
+	RetryAfter  int
+}
+
+// rateLimitZoneName is the limit_req zone shared by a proxy and its
+// location rules without a limit of their own.
+func rateLimitZoneName(proxyID int) string {
+	return fmt.Sprintf("proxy_%d", proxyID)
+}
+
+// locationRateLimitZoneName is the limit_req zone of the location rule at
+// index i of a proxy.
+func locationRateLimitZoneName(proxyID, i int) string {
+	return fmt.Sprintf("proxy_%d_loc_%d", proxyID, i)
+}
+
+// rateLimitConnZoneName is the limit_conn zone of a proxy.
+func rateLimitConnZoneName(proxyID int) string {
+	return fmt.Sprintf("proxy_%d_conn", proxyID)
+}
+
+// rateLimitKeyVariable maps a rate limit key to the nginx variable holding
+// it.
+func rateLimitKeyVariable(key string) string {
+	switch key {
+	case models.RateLimitKeyAPIKey:
+		return "$http_x_api_key"
+	case models.RateLimitKeyIP:
+		return "$binary_remote_addr"
+	}
+	kind, name, _ := strings.Cut(key, ":")
+	if kind == "cookie" {
+		return "$cookie_" + name
+	}
+	return headerVariable(name)
+}
+
+// rateLimitDelay renders the delay parameter of limit_req for a burst.
+func rateLimitDelay(policy models.RateLimitPolicy, burst int) string {
+	switch {
+	case policy.Delay == nil || *policy.Delay >= burst:
+		return " nodelay"
+	case *policy.Delay > 0:
+		return fmt.Sprintf(" delay=%d", *policy.Delay)
+	}
+	return ""
+}
+
+// buildRateLimitTemplateData returns the limit of location / and of each
+// location rule of a proxy, nil where requests are not limited. The policy
+// is assumed to have passed models.ValidateRateLimitPolicy.
+func buildRateLimitTemplateData(proxy *models.Proxy) (root *rateLimitTemplateData, locations []*rateLimitTemplateData) {
+	locations = make([]*rateLimitTemplateData, len(proxy.Locations))
+	if !proxy.UsesRateLimit() {
+		return nil, locations
+	}
+	policy := proxy.EffectiveRateLimitPolicy()
+
+	shared := rateLimitTemplateData{
+		Zone:  rateLimitZoneName(proxy.ID),
+		Burst: policy.Burst,
+		Delay: rateLimitDelay(policy, policy.Burst),
+	}
+	if policy.ConnLimit > 0 {
+		shared.ConnZone = rateLimitConnZoneName(proxy.ID)
+		shared.ConnLimit = policy.ConnLimit
+	}
+	if proxy.RateLimitEnabled {
+		root = &shared
+	}
+
+	for i, loc := range proxy.Locations {
+		if !loc.RateLimitEnabled {
+			continue
+		}
+		limit := shared
+		if loc.RateLimitRate > 0 {
+			limit.Zone = locationRateLimitZoneName(proxy.ID, i)
+			limit.Burst = loc.RateLimitBurst
+			if limit.Burst == 0 {
+				limit.Burst = loc.RateLimitRate * 2
+			}
+			limit.Delay = rateLimitDelay(policy, limit.Burst)
+		}
+		locations[i] = &limit
+	}
+	return root, locations
+}
+
+// buildRateLimitServerTemplateData returns how a proxy answers rejected
+// requests, or nil when nothing on it is limited.
+func buildRateLimitServerTemplateData(proxy *models.Proxy) *rateLimitServerTemplateData {
+	if !proxy.UsesRateLimit() {
+		return nil
+	}
+	policy := proxy.EffectiveRateLimitPolicy()
+	data := &rateLimitServerTemplateData{
+		Status:     policy.Status,
+		Respond:    policy.CustomResponse(),
+		RetryAfter: policy.RetryAfter,
+	}
+	if policy.ResponseBody != "" {
+		data.Body = quoteNginxValue(policy.ResponseBody)
+		data.ContentType = policy.ResponseContentType
+	}
+	return data
+}
+
+// GenerateRateLimitZonesConfig writes the limit zones of all rate limited
+// proxies into the shared rate-limit-zones.conf include, or removes it when
+// no proxy is limited. Exempt addresses get an empty key through a geo and
+// map pair, since nginx does not limit requests whose key is empty; for the
+// same reason a header or cookie key falls back to the client address when
+// the request lacks it, so leaving it out does not escape the limit.
+func (n *NginxService) GenerateRateLimitZonesConfig(proxies []models.Proxy) error {
+	var buf strings.Builder
+	for i := range proxies {
+		p := &proxies[i]
+		if !p.UsesRateLimit() {
+			continue
+		}
+		policy := p.EffectiveRateLimitPolicy()
+		unit := "r/s"
+		if policy.Per == models.RateLimitPerMinute {
+			unit = "r/m"
+		}
+
+		fmt.Fprintf(&buf, "# %s\n", p.Domain)
+		key := rateLimitKeyVariable(policy.Key)
+		if policy.Key != models.RateLimitKeyIP {
+			client := fmt.Sprintf("$upm_rate_limit_client_%d", p.ID)
+			fmt.Fprintf(&buf, "map %s %s {\n    \"\" $binary_remote_addr;\n    default %s;\n}\n", key, client, key)
+			key = client
+		}
+		if len(policy.ExemptCIDRs) > 0 {
+			exempt := fmt.Sprintf("$upm_rate_limit_exempt_%d", p.ID)
+			fmt.Fprintf(&buf, "geo %s {\n    default 0;\n", exempt)
+			for _, cidr := range policy.ExemptCIDRs {
+				fmt.Fprintf(&buf, "    %s 1;\n", cidr)
+			}
+			limited := fmt.Sprintf("$upm_rate_limit_key_%d", p.ID)
+			fmt.Fprintf(&buf, "}\nmap %s %s {\n    1 \"\";\n    default %s;\n}\n", exempt, limited, key)
+			key = limited
+		}
+
+		fmt.Fprintf(&buf, "limit_req_zone %s zone=%s:%dm rate=%d%s;\n", key, rateLimitZoneName(p.ID), policy.ZoneSizeMB, policy.Rate, unit)
+		for j, loc := range p.Locations {
+			if loc.RateLimitEnabled && loc.RateLimitRate > 0 {
+				fmt.Fprintf(&buf, "limit_req_zone %s zone=%s:%dm rate=%d%s;\n", key, locationRateLimitZoneName(p.ID, j), policy.ZoneSizeMB, loc.RateLimitRate, unit)
+			}
+		}
+		if policy.ConnLimit > 0 {
+			fmt.Fprintf(&buf, "limit_conn_zone %s zone=%s:%dm;\n", key, rateLimitConnZoneName(p.ID), policy.ZoneSizeMB)
+		}
+		buf.WriteString("\n")
+	}
+
+	configFile := filepath.Join(n.ConfigPath, rateLimitZonesConfigName)
+	enabledPath := filepath.Join(n.SitesEnabledPath, rateLimitZonesConfigName)
+	if buf.Len() == 0 {
+		for _, path := range []string{enabledPath, configFile} {
+			if err := n.removeFile(path); err != nil {
+				return fmt.Errorf("failed to remove rate limit zones config: %w", err)
+			}
+		}
+		return nil
+	}
+
+	content := []byte("# Proxy rate limit zones\n# This file is generated by the backend; the zones of every rate limited proxy.\n\n" + buf.String())
+	if err := n.writeFile(configFile, content); err != nil {
+		return fmt.Errorf("failed to write rate limit zones config file: %w", err)
+	}
+	if err := n.writeFile(enabledPath, content); err != nil {
+		return fmt.Errorf("failed to copy rate limit zones config to sites-enabled: %w", err)
+	}
+	return nil
+}
+
+// syncRateLimitZones regenerates the rate limit zones include from the
+// database.
+func (n *NginxService) syncRateLimitZones() error {
+	if n.DatabaseService == nil {
+		return nil
+	}
+	proxies, err := n.DatabaseService.GetProxies()
+	if err != nil {
+		return fmt.Errorf("failed to load proxies: %w", err)
+	}
+	return n.GenerateRateLimitZonesConfig(proxies)
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"sync"
 	"time"
+
+	"upm-backend/internal/models"
 )
 
 // ScheduledJob represents a scheduled DNS update job
@@ -17,18 +19,27 @@ type ScheduledJob struct {
 	PausedAt    *time.Time
 }
 
-// SchedulerService manages scheduled DNS update jobs
+// MaintenanceHandler turns maintenance mode of a proxy on or off when a
+// scheduled maintenance window starts or ends.
+type MaintenanceHandler func(proxyID int, enabled bool) error
+
+// SchedulerService manages scheduled DNS update jobs and proxy maintenance
+// windows
 type SchedulerService struct {
 	dnsService *DNSService
 	jobs       map[int]*ScheduledJob // RecordID -> Job
 	mutex      sync.RWMutex
+
+	maintenanceHandler MaintenanceHandler
+	maintenanceTimers  map[int][]*time.Timer // ProxyID -> pending start/end timers
 }
 
 // NewSchedulerService creates a new scheduler service
 func NewSchedulerService(dnsService *DNSService) *SchedulerService {
 	return &SchedulerService{
-		dnsService: dnsService,
-		jobs:       make(map[int]*ScheduledJob),
+		dnsService:        dnsService,
+		jobs:              make(map[int]*ScheduledJob),
+		maintenanceTimers: make(map[int][]*time.Timer),
 	}
 }
 
@@ -239,3 +250,83 @@ func (s *SchedulerService) LoadAndStartJobs() error {
 
 	return nil
 }
+
+// SetMaintenanceHandler sets the function called when a maintenance window
+// starts or ends. Without one, maintenance windows are not scheduled.
+func (s *SchedulerService) SetMaintenanceHandler(handler MaintenanceHandler) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.maintenanceHandler = handler
+}
+
+// ScheduleMaintenance replaces the pending timers of a proxy's maintenance
+// window. Windows whose start passed while the backend was down begin
+// immediately; nil or unscheduled settings just cancel the timers.
+func (s *SchedulerService) ScheduleMaintenance(proxyID int, m *models.Maintenance) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.cancelMaintenanceLocked(proxyID)
+	if m == nil || s.maintenanceHandler == nil {
+		return
+	}
+
+	now := time.Now()
+	var timers []*time.Timer
+	if m.StartsAt != nil && !m.Enabled && (m.EndsAt == nil || m.EndsAt.After(now)) {
+		timers = append(timers, s.maintenanceTimer(proxyID, *m.StartsAt, true))
+	}
+	if m.EndsAt != nil {
+		timers = append(timers, s.maintenanceTimer(proxyID, *m.EndsAt, false))
+	}
+	if len(timers) > 0 {
+		s.maintenanceTimers[proxyID] = timers
+		fmt.Printf("Scheduled maintenance window for proxy %d\n", proxyID)
+	}
+}
+
+// CancelMaintenance stops the pending maintenance timers of a proxy
+func (s *SchedulerService) CancelMaintenance(proxyID int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.cancelMaintenanceLocked(proxyID)
+}
+
+func (s *SchedulerService) cancelMaintenanceLocked(proxyID int) {
+	for _, timer := range s.maintenanceTimers[proxyID] {
+		timer.Stop()
+	}
+	delete(s.maintenanceTimers, proxyID)
+}
+
+// maintenanceTimer calls the maintenance handler at the given time
+func (s *SchedulerService) maintenanceTimer(proxyID int, at time.Time, enabled bool) *time.Timer {
+	handler := s.maintenanceHandler
+	return time.AfterFunc(time.Until(at), func() {
+		state := "ended"
+		if enabled {
+			state = "started"
+		}
+		if err := handler(proxyID, enabled); err != nil {
+			fmt.Printf("Failed to apply maintenance window for proxy %d: %v\n", proxyID, err)
+			return
+		}
+		fmt.Printf("Maintenance window for proxy %d %s\n", proxyID, state)
+	})
+}
+
+// LoadMaintenanceWindows schedules the stored maintenance windows of all
+// proxies
+func (s *SchedulerService) LoadMaintenanceWindows() error {
+	proxies, err := s.dnsService.DbService.GetProxies()
+	if err != nil {
+		return fmt.Errorf("failed to get proxies: %w", err)
+	}
+
+	for _, proxy := range proxies {
+		if proxy.Maintenance != nil && (proxy.Maintenance.StartsAt != nil || proxy.Maintenance.EndsAt != nil) {
+			s.ScheduleMaintenance(proxy.ID, proxy.Maintenance)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"upm-backend/internal/models"
+)
+
+type maintenanceCall struct {
+	proxyID int
+	enabled bool
+}
+
+func newMaintenanceScheduler(t *testing.T) (*SchedulerService, chan maintenanceCall) {
+	t.Helper()
+	calls := make(chan maintenanceCall, 4)
+	s := NewSchedulerService(nil)
+	s.SetMaintenanceHandler(func(proxyID int, enabled bool) error {
+		calls <- maintenanceCall{proxyID, enabled}
+		return nil
+	})
+	return s, calls
+}
+
+func expectMaintenanceCall(t *testing.T, calls chan maintenanceCall, want maintenanceCall) {
+	t.Helper()
+	select {
+	case got := <-calls:
+		if got != want {
+			t.Errorf("maintenance handler called with %+v, want %+v", got, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("maintenance handler not called, want %+v", want)
+	}
+}
+
+func TestScheduleMaintenance_RunsWindow(t *testing.T) {
+	s, calls := newMaintenanceScheduler(t)
+
+	start := time.Now().Add(-time.Minute) // started while the backend was down
+	end := time.Now().Add(200 * time.Millisecond)
+	s.ScheduleMaintenance(7, &models.Maintenance{StartsAt: &start, EndsAt: &end})
+
+	expectMaintenanceCall(t, calls, maintenanceCall{7, true})
+	expectMaintenanceCall(t, calls, maintenanceCall{7, false})
+}
+
+func TestScheduleMaintenance_SkipsEndedWindowStart(t *testing.T) {
+	s, calls := newMaintenanceScheduler(t)
+
+	start := time.Now().Add(-time.Hour)
+	end := time.Now().Add(-time.Minute)
+	s.ScheduleMaintenance(8, &models.Maintenance{StartsAt: &start, EndsAt: &end})
+
+	// Only the end fires, so the proxy is switched off rather than on
+	expectMaintenanceCall(t, calls, maintenanceCall{8, false})
+	select {
+	case got := <-calls:
+		t.Errorf("unexpected maintenance handler call %+v", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestCancelMaintenance_StopsTimers(t *testing.T) {
+	s, calls := newMaintenanceScheduler(t)
+
+	start := time.Now().Add(100 * time.Millisecond)
+	s.ScheduleMaintenance(9, &models.Maintenance{StartsAt: &start})
+	s.CancelMaintenance(9)
+
+	select {
+	case got := <-calls:
+		t.Errorf("unexpected maintenance handler call after cancel %+v", got)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
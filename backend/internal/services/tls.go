@@ -0,0 +1,73 @@
+package services
+
+import (
+	"net"
+	"strings"
+
+	"upm-backend/internal/models"
+)
+
+// tlsTemplateData is the rendered form of a proxy's TLS policy.
+type tlsTemplateData struct {
+	Profile             string
+	Protocols           string // ssl_protocols
+	Ciphers             string // ssl_ciphers, empty for OpenSSL's default
+	PreferServerCiphers string // on, off
+	SessionTimeout      int    // minutes
+	SessionTickets      string // on, off
+	OCSPStapling        bool
+	Resolvers           string // resolver addresses, IPv6 in brackets
+	ResolverTimeout     int    // seconds
+}
+
+// onOff renders a flag as an nginx on/off value.
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+// buildTLSTemplateData turns a proxy's TLS policy into template data. The
+// policy is assumed to have passed models.ValidateTLSPolicy.
+func buildTLSTemplateData(proxy *models.Proxy) *tlsTemplateData {
+	policy := proxy.EffectiveTLSPolicy()
+	data := &tlsTemplateData{
+		Profile:             policy.Profile,
+		Protocols:           strings.Join(policy.Protocols, " "),
+		Ciphers:             policy.Ciphers,
+		PreferServerCiphers: onOff(policy.PreferServerCiphers),
+		SessionTimeout:      policy.SessionTimeout,
+		SessionTickets:      onOff(policy.SessionTickets),
+		OCSPStapling:        policy.OCSPStapling,
+		ResolverTimeout:     policy.ResolverTimeout,
+	}
+	resolvers := make([]string, 0, len(policy.Resolvers))
+	for _, r := range policy.Resolvers {
+		// nginx only takes IPv6 resolvers in brackets
+		if ip := net.ParseIP(r); ip != nil && ip.To4() == nil {
+			r = "[" + r + "]"
+		}
+		resolvers = append(resolvers, r)
+	}
+	data.Resolvers = strings.Join(resolvers, " ")
+	return data
+}
+
+// securityHeaders returns the default security headers with the
+// Strict-Transport-Security header of an HSTS policy, or without it when
+// the policy turns HSTS off.
+func securityHeaders(hsts *models.HSTSPolicy) []headerDirective {
+	headers := make([]headerDirective, 0, len(defaultSecurityHeaders))
+	for _, h := range defaultSecurityHeaders {
+		if strings.EqualFold(h.Name, "Strict-Transport-Security") {
+			value := hsts.HeaderValue()
+			if value == "" {
+				continue
+			}
+			h.Value = quoteNginxValue(value)
+		}
+		headers = append(headers, h)
+	}
+	return headers
+}
@@ -0,0 +1,300 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"upm-backend/internal/models"
+)
+
+// newTestSSLProxy stores a certificate for domain and returns an SSL
+// proxy for it. svc must have a DatabaseService.
+func newTestSSLProxy(t *testing.T, svc *NginxService, name, domain string) *models.Proxy {
+	t.Helper()
+	certPEM, keyPEM := newTestCAPEM(t, domain, time.Now().Add(24*time.Hour))
+	certDir := t.TempDir()
+	certPath := filepath.Join(certDir, domain+".crt")
+	keyPath := filepath.Join(certDir, domain+".key")
+	if err := os.WriteFile(certPath, []byte(certPEM), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyPath, []byte(keyPEM), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cert := &models.Certificate{Domain: domain, CertPath: certPath, KeyPath: keyPath, ExpiresAt: time.Now().Add(24 * time.Hour), IsValid: true}
+	if err := svc.DatabaseService.CreateCertificate(cert); err != nil {
+		t.Fatal(err)
+	}
+	return &models.Proxy{Name: name, Domain: domain, TargetURL: "http://" + name + ":8080", Status: "active", SSLEnabled: true}
+}
+
+// newTestServerCertificate stores a certificate for domain whose file is
+// valid for names.
+func newTestServerCertificate(t *testing.T, db *DatabaseService, domain string, names ...string) *models.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: domain},
+		DNSNames:     names,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certDir := t.TempDir()
+	certPath := filepath.Join(certDir, domain+".crt")
+	keyPath := filepath.Join(certDir, domain+".key")
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cert := &models.Certificate{Domain: domain, CertPath: certPath, KeyPath: keyPath, ExpiresAt: template.NotAfter, IsValid: true}
+	if err := db.CreateCertificate(cert); err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// renderTestProxy stores proxy and returns its generated config.
+func renderTestProxy(t *testing.T, svc *NginxService, proxy *models.Proxy) string {
+	t.Helper()
+	if proxy.ID == 0 {
+		if err := svc.DatabaseService.CreateProxy(proxy); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := svc.GenerateProxyConfig(proxy); err != nil {
+		t.Fatalf("GenerateProxyConfig returned error: %v", err)
+	}
+	return readTestProxyConfig(t, svc, proxy.ID)
+}
+
+// readTestProxyConfig returns the enabled config of a proxy.
+func readTestProxyConfig(t *testing.T, svc *NginxService, proxyID int) string {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join(svc.SitesEnabledPath, fmt.Sprintf("proxy-%d.conf", proxyID)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestGenerateProxyConfig_TLS_DefaultsToIntermediate(t *testing.T) {
+	svc := newTestNginxService(t)
+	svc.DatabaseService = newTestDatabaseService(t)
+
+	config := renderTestProxy(t, svc, newTestSSLProxy(t, svc, "app", "app.example.com"))
+	for _, want := range []string{
+		"# SSL settings: intermediate TLS profile",
+		"ssl_protocols TLSv1.2 TLSv1.3;",
+		"ssl_ciphers " + models.TLSProfiles[models.TLSProfileIntermediate].Ciphers + ";",
+		"ssl_prefer_server_ciphers off;",
+		"ssl_session_timeout 10m;",
+		"ssl_session_tickets off;",
+		`add_header Strict-Transport-Security "max-age=31536000; includeSubDomains" always;`,
+	} {
+		if !strings.Contains(config, want) {
+			t.Errorf("expected %q in config, got:\n%s", want, config)
+		}
+	}
+	for _, unwanted := range []string{"ssl_stapling", "resolver"} {
+		if strings.Contains(config, unwanted) {
+			t.Errorf("expected no %q without OCSP stapling, got:\n%s", unwanted, config)
+		}
+	}
+}
+
+func TestGenerateProxyConfig_TLS_RendersPolicy(t *testing.T) {
+	svc := newTestNginxService(t)
+	svc.DatabaseService = newTestDatabaseService(t)
+
+	proxy := newTestSSLProxy(t, svc, "app", "app.example.com")
+	proxy.TLS = &models.TLSPolicy{
+		Profile:         models.TLSProfileModern,
+		HSTS:            &models.HSTSPolicy{Enabled: true, MaxAge: 63072000, IncludeSubDomains: true, Preload: true},
+		OCSPStapling:    true,
+		Resolvers:       []string{"1.1.1.1", "2606:4700:4700::1111", "[2001:db8::53]:5353"},
+		SessionTickets:  true,
+		SessionTimeout:  1440,
+		ResolverTimeout: 3,
+	}
+	config := renderTestProxy(t, svc, proxy)
+	for _, want := range []string{
+		"ssl_protocols TLSv1.3;",
+		"ssl_session_timeout 1440m;",
+		"ssl_session_tickets on;",
+		"ssl_stapling on;",
+		"ssl_stapling_verify on;",
+		"resolver 1.1.1.1 [2606:4700:4700::1111] [2001:db8::53]:5353 valid=300s;",
+		"resolver_timeout 3s;",
+		`add_header Strict-Transport-Security "max-age=63072000; includeSubDomains; preload" always;`,
+	} {
+		if !strings.Contains(config, want) {
+			t.Errorf("expected %q in config, got:\n%s", want, config)
+		}
+	}
+	if strings.Contains(config, "ssl_ciphers") {
+		t.Errorf("expected the modern profile to leave TLS 1.3 ciphers to OpenSSL, got:\n%s", config)
+	}
+
+	proxy.TLS = &models.TLSPolicy{
+		Profile:             models.TLSProfileCustom,
+		Protocols:           []string{models.TLSProtocol12},
+		Ciphers:             "ECDHE-RSA-AES256-GCM-SHA384",
+		PreferServerCiphers: true,
+		HSTS:                &models.HSTSPolicy{Enabled: false},
+	}
+	config = renderTestProxy(t, svc, proxy)
+	for _, want := range []string{
+		"# SSL settings: custom TLS profile",
+		"ssl_protocols TLSv1.2;",
+		"ssl_ciphers ECDHE-RSA-AES256-GCM-SHA384;",
+		"ssl_prefer_server_ciphers on;",
+	} {
+		if !strings.Contains(config, want) {
+			t.Errorf("expected %q in config, got:\n%s", want, config)
+		}
+	}
+	if strings.Contains(config, "Strict-Transport-Security") {
+		t.Errorf("expected HSTS to be off, got:\n%s", config)
+	}
+
+	// A response header rule still wins over the policy's header
+	proxy.TLS = nil
+	proxy.HeaderRules = []models.ProxyHeaderRule{
+		{Direction: models.HeaderDirectionResponse, Action: models.HeaderActionSet, Name: "Strict-Transport-Security", Value: "max-age=60"},
+	}
+	config = renderTestProxy(t, svc, proxy)
+	if !strings.Contains(config, `add_header Strict-Transport-Security "max-age=60";`) || strings.Contains(config, "max-age=31536000") {
+		t.Errorf("expected the header rule to replace HSTS, got:\n%s", config)
+	}
+}
+
+func TestProxyTLSPolicy_RoundTrip(t *testing.T) {
+	db := newTestDatabaseService(t)
+	proxy := &models.Proxy{Name: "app", Domain: "app.example.com", TargetURL: "http://app:8080", Status: "active"}
+	if err := db.CreateProxy(proxy); err != nil {
+		t.Fatal(err)
+	}
+
+	policy := &models.TLSPolicy{
+		Profile:      models.TLSProfileCustom,
+		Protocols:    []string{models.TLSProtocol12, models.TLSProtocol13},
+		Ciphers:      "HIGH:!aNULL",
+		OCSPStapling: true,
+		Resolvers:    []string{"9.9.9.9", "[::1]:53"},
+	}
+	if err := db.SetProxyTLSPolicy(proxy.ID, policy); err != nil {
+		t.Fatal(err)
+	}
+	got, err := db.GetProxyTLSPolicy(proxy.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Profile != policy.Profile || strings.Join(got.Protocols, " ") != "TLSv1.2 TLSv1.3" || got.Ciphers != policy.Ciphers ||
+		!got.OCSPStapling || strings.Join(got.Resolvers, " ") != "9.9.9.9 [::1]:53" {
+		t.Errorf("unexpected policy: %+v", got)
+	}
+	if got.HSTS != nil {
+		t.Errorf("expected an unset HSTS policy to stay unset, got %+v", got.HSTS)
+	}
+
+	policy.HSTS = &models.HSTSPolicy{Enabled: false, IncludeSubDomains: true}
+	if err := db.SetProxyTLSPolicy(proxy.ID, policy); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := db.GetProxy(proxy.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.TLS == nil || loaded.TLS.HSTS == nil || loaded.TLS.HSTS.Enabled || !loaded.TLS.HSTS.IncludeSubDomains {
+		t.Errorf("expected the disabled HSTS policy to be loaded with the proxy, got %+v", loaded.TLS)
+	}
+
+	if err := db.SetProxyTLSPolicy(proxy.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := db.GetProxyTLSPolicy(proxy.ID); err != nil || got != nil {
+		t.Errorf("expected the policy to be removed, got %+v, %v", got, err)
+	}
+}
+
+func TestGetCertificateForProxy_RequiresEveryServerName(t *testing.T) {
+	svc := newTestNginxService(t)
+	db := newTestDatabaseService(t)
+	svc.DatabaseService = db
+
+	partial := newTestServerCertificate(t, db, "app.example.com", "app.example.com", "www.app.example.com")
+	proxy := &models.Proxy{
+		Name:       "app",
+		Domain:     "app.example.com",
+		Aliases:    []string{"www.app.example.com", "app.example.org"},
+		TargetURL:  "http://app:8080",
+		Status:     "active",
+		SSLEnabled: true,
+	}
+	if cert, err := db.GetCertificateForProxy(proxy); err == nil {
+		t.Fatalf("expected no certificate while app.example.org is uncovered, got %s", cert.CertPath)
+	}
+	config := renderTestProxy(t, svc, proxy)
+	if strings.Contains(config, partial.CertPath) || strings.Contains(config, "listen 443") {
+		t.Errorf("expected the proxy not to serve a certificate missing one of its aliases, got:\n%s", config)
+	}
+
+	full := newTestServerCertificate(t, db, "app.example.org", "app.example.org", "app.example.com", "*.app.example.com")
+	cert, err := db.GetCertificateForProxy(proxy)
+	if err != nil {
+		t.Fatalf("GetCertificateForProxy returned error: %v", err)
+	}
+	if cert.ID != full.ID {
+		t.Errorf("expected the certificate covering every name, got %s", cert.CertPath)
+	}
+	config = renderTestProxy(t, svc, proxy)
+	if !strings.Contains(config, "ssl_certificate "+full.CertPath+";") {
+		t.Errorf("expected the covering certificate in config, got:\n%s", config)
+	}
+}
+
+func TestCertificateNameCovers(t *testing.T) {
+	tests := []struct {
+		certName, name string
+		want           bool
+	}{
+		{"app.example.com", "APP.example.com", true},
+		{"*.example.com", "www.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"*.example.com", "a.b.example.com", false},
+		{"*.example.com", "*.example.com", true},
+		{"app.example.com", "*.example.com", false},
+	}
+	for _, tt := range tests {
+		if got := certificateNameCovers(tt.certName, tt.name); got != tt.want {
+			t.Errorf("certificateNameCovers(%q, %q) = %v, want %v", tt.certName, tt.name, got, tt.want)
+		}
+	}
+}
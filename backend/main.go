@@ -126,11 +126,32 @@ func main() {
 	nginxReloadCmd := os.Getenv("NGINX_RELOAD_CMD")
 	nginxContainerName := os.Getenv("NGINX_CONTAINER_NAME")
 
+	// Initialize GeoIP service
+	geoIPService := services.NewGeoIPService(cfg.GeoIPDatabasePath)
+	handlers.SetGeoIPService(geoIPService)
+	if geoIPService.Enabled() {
+		log.Printf("GeoIP database: %s", cfg.GeoIPDatabasePath)
+	} else {
+		log.Printf("GeoIP country rules disabled - GEOIP_DB_PATH not set")
+	}
+
+	// Initialize internal certificate authority service
+	internalCAService := services.NewInternalCAService(dbService, cfg.InternalCAPath)
+	handlers.SetInternalCAService(internalCAService)
+
 	var nginxService *services.NginxService
 	if nginxConfigPath != "" && nginxReloadCmd != "" {
 		nginxService = services.NewNginxService(nginxConfigPath, nginxReloadCmd, nginxContainerName, dbService)
+		nginxService.BackendURL = cfg.InternalBackendURL
+		nginxService.GeoIP = geoIPService
+		nginxService.InternalCA = internalCAService
+		nginxService.HTTP3Enabled = cfg.HTTP3Enabled
+		nginxService.HTTP3AltSvcPort = cfg.HTTP3AltSvcPort
 		handlers.SetNginxService(nginxService)
 		log.Printf("Nginx service initialized with config path: %s, container: %s", nginxConfigPath, nginxContainerName)
+		if cfg.HTTP3Enabled {
+			log.Printf("HTTP/3 enabled for opted-in proxies, advertised on UDP port %s", cfg.HTTP3AltSvcPort)
+		}
 	} else {
 		log.Printf("Nginx service not initialized - missing environment variables")
 	}
@@ -159,14 +180,42 @@ func main() {
 		log.Printf("Warning: Failed to load scheduled jobs: %v", err)
 	}
 
-	// Start certificate auto-renewal when Let's Encrypt is configured
-	if cfg.LetsEncryptEmail != "" {
-		certRenewalService := services.NewCertificateRenewalService(dbService, nginxService, cfg.CertRenewalCheckInterval)
-		handlers.SetCertificateRenewalService(certRenewalService)
-		certRenewalService.Start()
+	// Schedule stored maintenance windows of proxies
+	if nginxService != nil {
+		schedulerService.SetMaintenanceHandler(nginxService.ApplyMaintenanceState)
+		if err := schedulerService.LoadMaintenanceWindows(); err != nil {
+			log.Printf("Warning: Failed to load maintenance windows: %v", err)
+		}
+	}
+
+	// Start certificate auto-renewal; Let's Encrypt certificates are only
+	// renewed when it is configured, internal certificates always
+	certRenewalService := services.NewCertificateRenewalService(dbService, nginxService, cfg.CertRenewalCheckInterval)
+	certRenewalService.ACMEEnabled = cfg.LetsEncryptEmail != ""
+	certRenewalService.InternalCA = internalCAService
+	handlers.SetCertificateRenewalService(certRenewalService)
+	certRenewalService.Start()
+	if certRenewalService.ACMEEnabled {
 		log.Printf("Certificate auto-renewal enabled (check interval: %v)", cfg.CertRenewalCheckInterval)
 	} else {
-		log.Printf("Certificate auto-renewal disabled - LETSENCRYPT_EMAIL not set")
+		log.Printf("Certificate auto-renewal enabled for internal certificates only - LETSENCRYPT_EMAIL not set")
+	}
+
+	// Start active health checks of proxy targets
+	if cfg.HealthCheckTick > 0 {
+		healthCheckService := services.NewHealthCheckService(dbService, cfg.HealthCheckTick)
+		healthCheckService.Start()
+		log.Printf("Proxy health checks enabled")
+	} else {
+		log.Printf("Proxy health checks disabled - HEALTH_CHECK_TICK is 0")
+	}
+
+	// Watch the generated nginx files for drift from the database
+	if nginxService != nil && cfg.DriftCheckInterval > 0 {
+		driftMonitor := services.NewDriftMonitor(nginxService, cfg.DriftCheckInterval)
+		driftMonitor.Start()
+	} else if nginxService != nil {
+		log.Printf("Config drift monitor disabled - DRIFT_CHECK_INTERVAL is 0")
 	}
 
 	// Initialize Gin router
@@ -202,6 +251,13 @@ func main() {
 		{
 			auth.POST("/login", handlers.Login)
 			auth.POST("/register", handlers.Register)
+
+			// Forward auth for proxies that require UPM login
+			auth.GET("/verify", handlers.VerifySession)
+			auth.GET("/sso/login", handlers.SSOLoginPage)
+			auth.POST("/sso/login", handlers.SSOLogin)
+			auth.GET("/sso/logout", handlers.SSOLogout)
+			auth.GET("/forward-auth/:id/sign-in", handlers.ForwardAuthSignIn)
 		}
 
 		// Protected routes (require authentication)
@@ -217,6 +273,57 @@ func main() {
 				proxies.PUT("/:id", handlers.UpdateProxy)
 				proxies.DELETE("/:id", handlers.DeleteProxy)
 				proxies.GET("/:id/certificate", handlers.GetProxyCertificate)
+				proxies.POST("/:id/forward-auth/probe", handlers.ProbeProxyForwardAuth)
+				proxies.POST("/:id/cache/purge", handlers.PurgeProxyCache)
+				proxies.GET("/:id/health", handlers.GetProxyHealth)
+				proxies.GET("/:id/maintenance", handlers.GetProxyMaintenance)
+				proxies.PUT("/:id/maintenance", handlers.UpdateProxyMaintenance)
+				proxies.DELETE("/:id/maintenance", handlers.EndProxyMaintenance)
+				proxies.GET("/:id/error-pages", handlers.GetProxyErrorPages)
+				proxies.GET("/:id/error-pages/:code/preview", handlers.PreviewProxyErrorPage)
+				proxies.PUT("/:id/error-pages/:code", handlers.SetProxyErrorPage)
+				proxies.DELETE("/:id/error-pages/:code", handlers.ResetProxyErrorPage)
+			}
+
+			// Stream (TCP/UDP) proxy management endpoints
+			streams := protected.Group("/streams")
+			{
+				streams.GET("", handlers.GetStreams)
+				streams.POST("", handlers.CreateStream)
+				streams.GET("/:id", handlers.GetStream)
+				streams.PUT("/:id", handlers.UpdateStream)
+				streams.DELETE("/:id", handlers.DeleteStream)
+			}
+
+			// Global custom error page endpoints
+			errorPages := protected.Group("/error-pages")
+			{
+				errorPages.GET("", handlers.GetErrorPages)
+				errorPages.GET("/:code/preview", handlers.PreviewErrorPage)
+				errorPages.PUT("/:code", handlers.SetErrorPage)
+				errorPages.DELETE("/:code", handlers.ResetErrorPage)
+			}
+
+			// Basic auth credential set endpoints
+			basicAuth := protected.Group("/basic-auth")
+			{
+				basicAuth.GET("", handlers.GetBasicAuthSets)
+				basicAuth.POST("", handlers.CreateBasicAuthSet)
+				basicAuth.GET("/:id", handlers.GetBasicAuthSet)
+				basicAuth.PUT("/:id", handlers.UpdateBasicAuthSet)
+				basicAuth.DELETE("/:id", handlers.DeleteBasicAuthSet)
+				basicAuth.PUT("/:id/users/:username", handlers.SetBasicAuthUser)
+				basicAuth.DELETE("/:id/users/:username", handlers.DeleteBasicAuthUser)
+			}
+
+			// IP access list endpoints
+			accessLists := protected.Group("/access-lists")
+			{
+				accessLists.GET("", handlers.GetAccessLists)
+				accessLists.POST("", handlers.CreateAccessList)
+				accessLists.GET("/:id", handlers.GetAccessList)
+				accessLists.PUT("/:id", handlers.UpdateAccessList)
+				accessLists.DELETE("/:id", handlers.DeleteAccessList)
 			}
 
 			// User management endpoints (admin only)
@@ -243,10 +350,18 @@ func main() {
 				nginx.POST("/reload", handlers.ReloadNginx)
 				nginx.POST("/test", handlers.TestNginxConfig)
 				nginx.POST("/regenerate-config", handlers.RegenerateProxyConfig)
+				nginx.GET("/drift", handlers.GetNginxDrift)
+				nginx.POST("/reconcile", handlers.ReconcileNginx)
 				nginx.GET("/admin-ip-restrictions", handlers.GetAdminIPRestrictions)
 				nginx.PUT("/admin-ip-restrictions", handlers.UpdateAdminIPRestrictions)
 			}
 
+			// GeoIP endpoints
+			geoip := protected.Group("/geoip")
+			{
+				geoip.GET("/lookup", handlers.LookupGeoIP)
+			}
+
 			// DNS management endpoints
 			dns := protected.Group("/dns")
 			{
@@ -286,6 +401,20 @@ func main() {
 				certificates.DELETE("/:id", handlers.DeleteCertificate)
 				certificates.GET("/:id/proxies", handlers.GetCertificateProxies)
 				certificates.POST("/:id/renew", handlers.RenewCertificate)
+				certificates.POST("/:id/revoke", handlers.RevokeCertificate)
+				certificates.GET("/:id/pem", handlers.GetCertificatePEM)
+			}
+
+			// Internal certificate authority endpoints
+			certificateAuthorities := protected.Group("/certificate-authorities")
+			{
+				certificateAuthorities.GET("", handlers.GetCertificateAuthorities)
+				certificateAuthorities.POST("", handlers.CreateCertificateAuthority)
+				certificateAuthorities.POST("/import", handlers.ImportCertificateAuthority)
+				certificateAuthorities.GET("/:id", handlers.GetCertificateAuthority)
+				certificateAuthorities.DELETE("/:id", handlers.DeleteCertificateAuthority)
+				certificateAuthorities.GET("/:id/crl", handlers.GetCertificateAuthorityCRL)
+				certificateAuthorities.POST("/:id/certificates", handlers.IssueInternalCertificate)
 			}
 
 			// Settings management endpoints
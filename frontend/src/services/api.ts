@@ -15,8 +15,10 @@ import type {
   DNSRecordUpdateRequest,
   DNSStatus,
   DNSUpdateResponse,
+  DriftReport,
   JobInfo,
   Proxy,
+  ProxyConfigPreview,
   ProxyCreateRequest,
   ProxyResponse,
   ProxyUpdateRequest,
@@ -159,6 +161,24 @@ class ApiService {
     });
   }
 
+  // Dry runs: render the nginx config a change would produce without saving it
+  async previewCreateProxy(proxy: ProxyCreateRequest): Promise<ApiResponse<ProxyConfigPreview>> {
+    return this.request('/api/v1/proxies?dry_run=true', {
+      method: 'POST',
+      body: JSON.stringify(proxy),
+    });
+  }
+
+  async previewUpdateProxy(
+    id: number,
+    proxy: ProxyUpdateRequest
+  ): Promise<ApiResponse<ProxyConfigPreview>> {
+    return this.request(`/api/v1/proxies/${id}?dry_run=true`, {
+      method: 'PUT',
+      body: JSON.stringify(proxy),
+    });
+  }
+
   async deleteProxy(id: number): Promise<{ message: string }> {
     return this.request(`/api/v1/proxies/${id}`, {
       method: 'DELETE',
@@ -263,6 +283,16 @@ class ApiService {
     });
   }
 
+  async getNginxDrift(): Promise<ApiResponse<DriftReport>> {
+    return this.request('/api/v1/nginx/drift');
+  }
+
+  async reconcileNginx(): Promise<ApiResponse<DriftReport> & { message: string }> {
+    return this.request('/api/v1/nginx/reconcile', {
+      method: 'POST',
+    });
+  }
+
   // DNS management endpoints
   async getDNSConfigs(): Promise<{ configs: DNSConfig[] }> {
     return this.request('/api/v1/dns/configs');
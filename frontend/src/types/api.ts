@@ -18,19 +18,302 @@ export interface Proxy {
   status: 'active' | 'inactive' | 'error';
   created_at: string;
   updated_at: string;
+  quarantined?: boolean;
+  last_error?: string;
+  type?: ProxyType;
+  redirect?: RedirectHost | null;
+  static?: StaticHost | null;
+  load_balance_method?: LoadBalanceMethod;
+  upstream_servers?: UpstreamServer[];
+  locations?: ProxyLocation[];
+  aliases?: string[];
+  canonical_redirect?: boolean;
+  ssl_mode?: SSLMode;
+  basic_auth_set_id?: number;
+  access_list_id?: number;
+  geoip?: GeoIPRule | null;
+  client_auth?: ClientAuth | null;
+  tls?: TLSPolicy | null;
+  http3_enabled?: boolean;
+  require_upm_login?: boolean;
+  forward_auth?: ForwardAuth | null;
+  header_rules?: ProxyHeaderRule[];
+  cache_enabled?: boolean;
+  cache?: CachePolicy | null;
+  rate_limit?: RateLimitPolicy | null;
+  health_check?: HealthCheck | null;
+  maintenance?: Maintenance | null;
+  error_pages?: ErrorPage[];
   // Computed fields for UI
   connected_containers?: Container[];
   certificate?: Certificate;
 }
 
+export type SSLMode = 'terminate' | 'passthrough';
+
+export type ProxyType = 'proxy' | 'redirect' | 'static';
+
+export interface RedirectHost {
+  status_code: 301 | 302 | 307 | 308;
+  preserve_path: boolean;
+  preserve_query: boolean;
+}
+
+export interface StaticHost {
+  root: string;
+  index: string;
+  spa_fallback: boolean;
+}
+
+export type LoadBalanceMethod = 'round_robin' | 'least_conn' | 'ip_hash';
+
+export interface UpstreamServer {
+  id?: number;
+  proxy_id?: number;
+  url: string;
+  weight?: number;
+  max_fails?: number;
+  fail_timeout?: number;
+  backup?: boolean;
+}
+
+export type ClientAuthMode = 'on' | 'optional';
+
+export interface ClientAuth {
+  mode: ClientAuthMode;
+  ca_bundle?: string;
+  ca_id?: number;
+  verify_depth?: number;
+  dn_header?: string;
+}
+
+export type TLSProfile = 'modern' | 'intermediate' | 'old' | 'custom';
+export type TLSProtocol = 'TLSv1' | 'TLSv1.1' | 'TLSv1.2' | 'TLSv1.3';
+
+export interface HSTSPolicy {
+  enabled: boolean;
+  max_age?: number;
+  include_subdomains: boolean;
+  preload: boolean;
+}
+
+export interface TLSPolicy {
+  profile?: TLSProfile;
+  protocols?: TLSProtocol[];
+  ciphers?: string;
+  prefer_server_ciphers?: boolean;
+  hsts?: HSTSPolicy;
+  ocsp_stapling?: boolean;
+  resolvers?: string[];
+  resolver_timeout?: number;
+  session_tickets?: boolean;
+  session_timeout?: number;
+}
+
+export interface ForwardAuth {
+  url: string;
+  response_headers?: string[];
+  signin_url?: string;
+}
+
+export interface ForwardAuthProbe {
+  url: string;
+  status_code: number;
+  usable: boolean;
+  authorized: boolean;
+  headers?: Record<string, string>;
+  location?: string;
+}
+
+export type HeaderDirection = 'request' | 'response';
+
+export type HeaderAction = 'set' | 'remove';
+
+export interface ProxyHeaderRule {
+  id?: number;
+  proxy_id?: number;
+  direction: HeaderDirection;
+  action: HeaderAction;
+  name: string;
+  value?: string;
+  always?: boolean;
+  created_at?: string;
+}
+
+export interface ProxyHeaderRuleRequest {
+  direction: HeaderDirection;
+  action?: HeaderAction;
+  name: string;
+  value?: string;
+  always?: boolean;
+}
+
+export interface CacheValidity {
+  status: string;
+  ttl: number;
+}
+
+export interface CachePolicy {
+  zone_size_mb?: number;
+  max_size_mb?: number;
+  inactive_minutes?: number;
+  valid?: CacheValidity[];
+  key?: string[];
+  bypass_cookies?: string[];
+  bypass_headers?: string[];
+  bypass_args?: string[];
+  status_header?: boolean;
+}
+
+export type RateLimitPer = 'second' | 'minute';
+
+export interface RateLimitPolicy {
+  rate?: number;
+  per?: RateLimitPer;
+  burst?: number;
+  delay?: number | null;
+  // 'ip', 'api_key', 'header:NAME' or 'cookie:NAME'
+  key?: string;
+  exempt_cidrs?: string[];
+  conn_limit?: number;
+  status?: number;
+  response_body?: string;
+  response_content_type?: string;
+  retry_after?: number;
+  zone_size_mb?: number;
+}
+
+export interface CachePurgeResult {
+  proxy_id: number;
+  removed: number;
+}
+
+export interface HealthCheck {
+  enabled: boolean;
+  path: string;
+  expected_status?: number;
+  interval_seconds: number;
+  timeout_seconds: number;
+  unhealthy_threshold: number;
+}
+
+export interface HealthCheckRequest {
+  enabled?: boolean;
+  path?: string;
+  expected_status?: number;
+  interval_seconds?: number;
+  timeout_seconds?: number;
+  unhealthy_threshold?: number;
+}
+
+export interface HealthCheckResult {
+  id: number;
+  proxy_id: number;
+  healthy: boolean;
+  status_code?: number;
+  latency_ms: number;
+  error?: string;
+  checked_at: string;
+}
+
+export interface ProxyHealth {
+  proxy_id: number;
+  status: Proxy['status'];
+  check: HealthCheck;
+  consecutive_failures: number;
+  uptime_percent: number;
+  avg_latency_ms: number;
+  last_checked_at?: string;
+  history: HealthCheckResult[];
+}
+
+// Config drift between the generated nginx files and the database
+export type ConfigDriftKind = 'missing' | 'orphaned' | 'modified';
+
+export interface ConfigDrift {
+  path: string;
+  kind: ConfigDriftKind;
+  diff?: string;
+}
+
+export interface DriftReport {
+  checked_at: string;
+  in_sync: boolean;
+  files: ConfigDrift[];
+}
+
+export interface Maintenance {
+  enabled: boolean;
+  page?: string;
+  retry_after_seconds: number;
+  allowed_ips?: string[];
+  starts_at?: string;
+  ends_at?: string;
+}
+
+// Custom error page; proxy_id 0 is a global page
+export interface ErrorPage {
+  id: number;
+  proxy_id: number;
+  status_code: number;
+  content: string;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface ErrorPageRequest {
+  content: string;
+}
+
+export type LocationMatchType = 'prefix' | 'exact' | 'regex';
+
+export interface ProxyLocation {
+  id?: number;
+  proxy_id?: number;
+  path: string;
+  match_type?: LocationMatchType;
+  target_url: string;
+  strip_prefix?: boolean;
+  rewrite?: string;
+  ws_enabled?: boolean;
+  rate_limit_enabled?: boolean;
+  rate_limit_rate?: number;
+  rate_limit_burst?: number;
+  basic_auth_set_id?: number;
+  access_list_id?: number;
+  cache_enabled?: boolean;
+}
+
 export interface ProxyCreateRequest {
   name: string;
   domain: string;
-  target_url: string;
+  target_url?: string;
   ssl_enabled: boolean;
   ws_enabled?: boolean;
   rate_limit_enabled?: boolean;
   rate_limit_rps?: number;
+  type?: ProxyType;
+  redirect?: RedirectHost;
+  static?: StaticHost;
+  load_balance_method?: LoadBalanceMethod;
+  upstream_servers?: UpstreamServer[];
+  locations?: ProxyLocation[];
+  aliases?: string[];
+  canonical_redirect?: boolean;
+  ssl_mode?: SSLMode;
+  basic_auth_set_id?: number;
+  access_list_id?: number;
+  geoip?: GeoIPRule | null;
+  client_auth?: ClientAuth | null;
+  tls?: TLSPolicy | null;
+  http3_enabled?: boolean;
+  require_upm_login?: boolean;
+  forward_auth?: ForwardAuth | null;
+  header_rules?: ProxyHeaderRuleRequest[];
+  cache_enabled?: boolean;
+  cache?: CachePolicy;
+  rate_limit?: RateLimitPolicy;
+  health_check?: HealthCheckRequest;
 }
 
 export interface ProxyUpdateRequest {
@@ -42,12 +325,156 @@ export interface ProxyUpdateRequest {
   ws_enabled?: boolean;
   rate_limit_enabled?: boolean;
   rate_limit_rps?: number;
+  type?: ProxyType;
+  redirect?: RedirectHost;
+  static?: StaticHost;
+  load_balance_method?: LoadBalanceMethod;
+  upstream_servers?: UpstreamServer[];
+  locations?: ProxyLocation[];
+  aliases?: string[];
+  canonical_redirect?: boolean;
+  ssl_mode?: SSLMode;
+  basic_auth_set_id?: number;
+  access_list_id?: number;
+  geoip?: GeoIPRule | null;
+  client_auth?: ClientAuth | null;
+  tls?: TLSPolicy | null;
+  http3_enabled?: boolean;
+  require_upm_login?: boolean;
+  forward_auth?: ForwardAuth | null;
+  header_rules?: ProxyHeaderRuleRequest[];
+  cache_enabled?: boolean;
+  cache?: CachePolicy;
+  rate_limit?: RateLimitPolicy;
+  health_check?: HealthCheckRequest;
+}
+
+export interface ProxyConfigPreview {
+  path?: string;
+  config: string;
+  changed: boolean;
+  diff?: string;
+  ssl_enabled: boolean;
 }
 
 export interface ProxyResponse {
   data: Proxy;
   ssl_status?: 'certificate_generated' | 'certificate_failed';
   ssl_message?: string;
+  warning?: string;
+}
+
+// Basic Auth Types
+export interface BasicAuthUser {
+  id: number;
+  set_id: number;
+  username: string;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface BasicAuthSet {
+  id: number;
+  name: string;
+  users: BasicAuthUser[];
+  created_at: string;
+  updated_at: string;
+}
+
+export interface BasicAuthCredential {
+  username: string;
+  password: string;
+}
+
+export interface BasicAuthSetCreateRequest {
+  name: string;
+  users: BasicAuthCredential[];
+}
+
+export interface BasicAuthSetUpdateRequest {
+  name?: string;
+}
+
+// Access List Types
+export type AccessAction = 'allow' | 'deny';
+
+export interface AccessListEntry {
+  id: number;
+  list_id: number;
+  action: AccessAction;
+  address: string;
+}
+
+export interface AccessList {
+  id: number;
+  name: string;
+  entries: AccessListEntry[];
+  created_at: string;
+  updated_at: string;
+}
+
+export interface AccessListEntryRequest {
+  action?: AccessAction;
+  address: string;
+}
+
+export interface AccessListCreateRequest {
+  name: string;
+  entries: AccessListEntryRequest[];
+}
+
+export interface AccessListUpdateRequest {
+  name?: string;
+  entries?: AccessListEntryRequest[];
+}
+
+// GeoIP Types
+export interface GeoIPRule {
+  action: AccessAction;
+  countries: string[];
+}
+
+export interface GeoIPLookup {
+  ip: string;
+  country?: string;
+  country_name?: string;
+  network?: string;
+  database: string;
+  build_date: string;
+}
+
+// Stream Proxy Types
+export type StreamProtocol = 'tcp' | 'udp';
+
+export interface StreamProxy {
+  id: number;
+  name: string;
+  listen_port: number;
+  protocol: StreamProtocol;
+  targets: string[];
+  allowed_ip_ranges?: string;
+  proxy_timeout: number;
+  status: 'active' | 'inactive' | 'error';
+  created_at: string;
+  updated_at: string;
+}
+
+export interface StreamProxyCreateRequest {
+  name: string;
+  listen_port: number;
+  protocol?: StreamProtocol;
+  targets: string[];
+  allowed_ip_ranges?: string;
+  proxy_timeout?: number;
+}
+
+export interface StreamProxyUpdateRequest {
+  name?: string;
+  listen_port?: number;
+  protocol?: StreamProtocol;
+  targets?: string[];
+  allowed_ip_ranges?: string;
+  proxy_timeout?: number;
 }
 
 // User Types
@@ -83,11 +510,19 @@ export interface Certificate {
   key_path: string;
   expires_at: string;
   is_valid: boolean;
+  source: CertificateSource;
+  usage: CertificateUsage;
   issuer?: string;
+  ca_id?: number;
+  serial_number?: string;
+  revoked_at?: string;
   created_at: string;
   updated_at: string;
 }
 
+export type CertificateSource = 'letsencrypt' | 'manual' | 'internal';
+export type CertificateUsage = 'server' | 'client';
+
 export interface CertificateCreateRequest {
   domain: string;
   cert_path: string;
@@ -110,6 +545,56 @@ export interface CertificateRenewResponse {
   certificate?: Certificate;
 }
 
+export type CertificateRevocationReason =
+  | 'unspecified'
+  | 'key_compromise'
+  | 'affiliation_changed'
+  | 'superseded'
+  | 'cessation_of_operation';
+
+// Certificate Authority Types
+export interface CertificateAuthority {
+  id: number;
+  name: string;
+  parent_id?: number;
+  common_name: string;
+  certificate: string;
+  imported: boolean;
+  expires_at: string;
+  crl_number: number;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface CertificateAuthorityCreateRequest {
+  name: string;
+  common_name?: string;
+  parent_id?: number;
+  validity_days?: number;
+}
+
+export interface CertificateAuthorityImportRequest {
+  name: string;
+  certificate: string;
+  private_key: string;
+  parent_id?: number;
+}
+
+export interface InternalCertificateRequest {
+  usage?: CertificateUsage;
+  common_name: string;
+  dns_names?: string[];
+  ip_addresses?: string[];
+  validity_days?: number;
+}
+
+export interface IssuedCertificate {
+  certificate: Certificate;
+  certificate_pem: string;
+  chain_pem: string;
+  private_key_pem?: string;
+}
+
 // Container Types
 export interface Container {
   id: string;
@@ -188,10 +673,7 @@ export interface DNSRecord {
   config_id: number;
   host: string;
   current_ip?: string;
-  allowed_ip_ranges?: string;
   dynamic_dns_refresh_rate?: number;
-  include_backend: boolean;
-  backend_url?: string;
   last_update?: string;
   is_active: boolean;
   created_at: string;
@@ -201,18 +683,12 @@ export interface DNSRecord {
 export interface DNSRecordCreateRequest {
   config_id: number;
   host: string;
-  allowed_ip_ranges?: string;
   dynamic_dns_refresh_rate?: number;
-  include_backend: boolean;
-  backend_url?: string;
 }
 
 export interface DNSRecordUpdateRequest {
   host?: string;
-  allowed_ip_ranges?: string;
   dynamic_dns_refresh_rate?: number;
-  include_backend?: boolean;
-  backend_url?: string;
   is_active?: boolean;
 }
 